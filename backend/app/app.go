@@ -1,16 +1,23 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -23,23 +30,57 @@ type ErrorHandlerFunc func(*Context, int)
 // Route represents a single route in the application
 type Route struct {
 	pattern  string
+	method   string
 	handlers []HandlerFunc
-	methods  map[string]bool
+	timeout  time.Duration
+}
+
+// Pattern returns the pattern the route was registered with, e.g. "/groups/{groupID}".
+func (r *Route) Pattern() string {
+	return r.pattern
+}
+
+// Timeout sets how long requests to the route may run before their context is
+// cancelled. Zero uses App.RouteTimeout; a negative duration disables the timeout,
+// e.g. for long-lived WebSocket connections.
+func (r *Route) Timeout(d time.Duration) *Route {
+	r.timeout = d
+	return r
 }
 
 // App represents the core application structure
 type App struct {
 	Db               *db
-	routes           []*Route
+	tree             *node
 	onErrorCode      ErrorHandlerFunc
 	globalMiddleware []HandlerFunc
+	ShutdownTimeout  time.Duration // Upper bound for draining requests and running shutdown hooks
+	RouteTimeout     time.Duration // Default time a request may run, see Route.Timeout; zero for none
+	Logger           *slog.Logger  // Base of the request loggers, see Context.Logger; nil for slog.Default()
+
+	server     *http.Server
+	onStart    []func()
+	onShutdown []func(ctx context.Context) error
+	stop       chan struct{}
+	stopOnce   sync.Once
 }
 
+const (
+	// DefaultShutdownTimeout is the ShutdownTimeout of apps created with New.
+	DefaultShutdownTimeout = 15 * time.Second
+
+	// DefaultRouteTimeout is the RouteTimeout of apps created with New.
+	DefaultRouteTimeout = 10 * time.Second
+)
+
 // New creates and initializes a new App instance
 func New() *App {
 	return &App{
-		routes:           make([]*Route, 0),
+		tree:             &node{kind: staticNode},
 		globalMiddleware: make([]HandlerFunc, 0),
+		ShutdownTimeout:  DefaultShutdownTimeout,
+		RouteTimeout:     DefaultRouteTimeout,
+		stop:             make(chan struct{}),
 	}
 }
 
@@ -48,15 +89,34 @@ func (app *App) UseDb(conn *sql.DB) {
 	app.Db = &db{Conn: conn}
 }
 
-// handle registers a route with specific handlers and methods
-func (app *App) handle(pattern string, handlers []HandlerFunc, methods ...string) {
-	methodsMap := make(map[string]bool)
+// handle registers a route with specific handlers and methods.
+// The pattern may contain {name} segments, read back with Context.Param,
+// and may end with '*' to match any path sharing its prefix.
+// It returns the route registered for the first method.
+func (app *App) handle(pattern string, handlers []HandlerFunc, methods ...string) *Route {
+	chain := make([]HandlerFunc, 0, len(app.globalMiddleware)+len(handlers))
+	chain = append(chain, app.globalMiddleware...)
+	chain = append(chain, handlers...)
+
+	n := app.tree.insert(pattern)
+	if n.routes == nil {
+		n.routes = make(map[string]*Route)
+	}
+	var first *Route
 	for _, method := range methods {
-		methodsMap[method] = true
+		if _, exists := n.routes[method]; exists {
+			panic(fmt.Sprintf("router: %s %s is already registered", method, pattern))
+		}
+		route := &Route{pattern: pattern, method: method, handlers: chain}
+		n.routes[method] = route
+		if n.fallback == nil {
+			n.fallback = route
+		}
+		if first == nil {
+			first = route
+		}
 	}
-	handlers = append(app.globalMiddleware, handlers...)
-	route := &Route{pattern: pattern, handlers: handlers, methods: methodsMap}
-	app.routes = append(app.routes, route)
+	return first
 }
 
 // Use adds global middleware to the app
@@ -73,20 +133,20 @@ func (app *App) Static(path, dir string) {
 }
 
 // Register HTTP methods (GET, POST, PUT, DELETE)
-func (app *App) GET(path string, handler ...HandlerFunc) {
-	app.handle(path, handler, "GET")
+func (app *App) GET(path string, handler ...HandlerFunc) *Route {
+	return app.handle(path, handler, "GET")
 }
 
-func (app *App) PUT(path string, handler ...HandlerFunc) {
-	app.handle(path, handler, "PUT")
+func (app *App) PUT(path string, handler ...HandlerFunc) *Route {
+	return app.handle(path, handler, "PUT")
 }
 
-func (app *App) POST(path string, handler ...HandlerFunc) {
-	app.handle(path, handler, "POST")
+func (app *App) POST(path string, handler ...HandlerFunc) *Route {
+	return app.handle(path, handler, "POST")
 }
 
-func (app *App) DELETE(path string, handler ...HandlerFunc) {
-	app.handle(path, handler, "DELETE")
+func (app *App) DELETE(path string, handler ...HandlerFunc) *Route {
+	return app.handle(path, handler, "DELETE")
 }
 
 // OnErrorCode sets a custom handler for HTTP error codes
@@ -96,76 +156,176 @@ func (app *App) OnErrorCode(handler ErrorHandlerFunc) {
 
 // NotAllowed sends a 405 error response
 func (app *App) NotAllowed(c *Context) {
-	http.Error(c.ResponseWriter, "405 Method not allowed", http.StatusMethodNotAllowed)
+	c.Error(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed", nil)
+}
+
+// NotFound sends a 404 error response
+func (app *App) NotFound(c *Context) {
+	c.Error(http.StatusNotFound, CodeNotFound, "Not found", nil)
+}
+
+// maxRequestIDLength bounds the X-Request-ID accepted from clients.
+const maxRequestIDLength = 128
+
+// requestID returns the X-Request-ID sent by the client if it is usable, or a new one.
+func requestID(r *http.Request) string {
+	id := r.Header.Get("X-Request-ID")
+	if id == "" || len(id) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return uuid.NewString()
+		}
+	}
+	return id
 }
 
 // ServeHTTP handles incoming HTTP requests and routes them appropriately
 func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	c := &Context{ResponseWriter: w, Request: r, Db: app.Db, Values: make(map[any]any)}
-	for _, route := range app.routes {
-		if strings.HasSuffix(route.pattern, "*") {
-			if strings.HasPrefix(r.URL.Path, strings.TrimSuffix(route.pattern, "*")) {
-				if route.methods[r.Method] {
-					c.handlers = route.handlers
-					c.Next()
-					return
-				}
-				app.NotAllowed(c)
-				return
-			}
-		} else if r.URL.Path == route.pattern {
-			if route.methods[r.Method] || r.Method == "OPTIONS" {
-				c.handlers = route.handlers
-				c.Next()
-				return
-			}
-			app.NotAllowed(c)
-			return
+	rw := &responseWriter{ResponseWriter: w}
+	c := &Context{ResponseWriter: rw, Request: r, Db: app.Db, Values: make(map[any]any), requestID: requestID(r)}
+	w.Header().Set("X-Request-ID", c.requestID)
+	defer observeRequest(c, time.Now()) // Runs last, once the status is final
+	defer rw.writeHeader()              // Send a status set without a body
+	c.SetLogger(app.logger().With("request_id", c.requestID))
+
+	n := app.tree.match(r.URL.Path, &c.params)
+	if n == nil {
+		if app.onErrorCode != nil {
+			app.onErrorCode(c, http.StatusNotFound)
+		} else {
+			app.NotFound(c)
 		}
+		return
+	}
+
+	route, ok := n.routes[r.Method]
+	if !ok && r.Method == http.MethodOptions {
+		// Preflight requests are answered by the global middleware (CORS).
+		route, ok = n.fallback, true
+	}
+	if !ok {
+		w.Header().Set("Allow", allowedMethods(n))
+		app.NotAllowed(c)
+		return
+	}
+
+	timeout := route.timeout
+	if timeout == 0 {
+		timeout = app.RouteTimeout
 	}
-	if app.onErrorCode != nil {
-		app.onErrorCode(c, http.StatusNotFound)
-	} else {
-		http.NotFound(w, r)
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.SetContext(ctx)
+	}
+
+	c.route = route
+	c.handlers = route.handlers
+	c.Next()
+
+	if errors.Is(c.Err(), context.DeadlineExceeded) && !c.Written() {
+		c.Error(http.StatusServiceUnavailable, CodeTimeout, "Request timed out.", nil)
 	}
 }
 
-var wg sync.WaitGroup
+// logger returns the logger requests are logged with.
+func (app *App) logger() *slog.Logger {
+	if app.Logger != nil {
+		return app.Logger
+	}
+	return slog.Default()
+}
 
-// checkServer verifies if the server is running
-func checkServer(addr string) {
-	resp, err := http.Get("http://" + addr)
-	if err != nil {
-		log.Println("Server is not running")
-	} else {
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				log.Printf("Error closing response body: %v", err)
-			}
-		}(resp.Body)
-		displayLaunchMessage(addr)
+// allowedMethods lists the methods registered on a node, for the Allow header.
+func allowedMethods(n *node) string {
+	methods := make([]string, 0, len(n.routes))
+	for method := range n.routes {
+		methods = append(methods, method)
 	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
 }
 
-// Run starts the application on the specified address
+// OnStart registers hooks run once the server is listening, in registration order.
+func (app *App) OnStart(hooks ...func()) {
+	app.onStart = append(app.onStart, hooks...)
+}
+
+// OnShutdown registers hooks run during a graceful shutdown, in registration order.
+// They run after in-flight HTTP requests have drained and before the database is closed,
+// and must return once the given context is done.
+func (app *App) OnShutdown(hooks ...func(ctx context.Context) error) {
+	app.onShutdown = append(app.onShutdown, hooks...)
+}
+
+// Stop asks a running server to shut down gracefully. Run returns once the shutdown completes.
+func (app *App) Stop() {
+	app.stopOnce.Do(func() { close(app.stop) })
+}
+
+// Run starts the application on the specified address and blocks until the server
+// stops, either on SIGINT/SIGTERM or through Stop. On the way out it drains in-flight
+// requests, runs the OnShutdown hooks and closes the database, waiting at most
+// ShutdownTimeout for all of it.
 func (app *App) Run(addr string) error {
-	wg.Add(1)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	app.server = &http.Server{Addr: addr, Handler: app}
+	serveErr := make(chan error, 1)
 	go func() {
-		defer wg.Done()
-		if err := http.ListenAndServe(addr, app); err != nil {
-			log.Fatal(err)
-		}
+		serveErr <- app.server.Serve(listener)
 	}()
 
-	// Wait for the server to start
-	time.Sleep(time.Second)
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
 
-	// Check if the server is running
-	checkServer(addr)
+	displayLaunchMessage(addr)
+	for _, hook := range app.onStart {
+		hook()
+	}
 
-	wg.Wait()
-	return nil
+	select {
+	case err := <-serveErr:
+		// The server failed on its own; release what we hold before reporting it.
+		ctx, cancel := context.WithTimeout(context.Background(), app.ShutdownTimeout)
+		defer cancel()
+		return errors.Join(err, app.shutdown(ctx))
+	case <-signals.Done():
+		log.Println("Shutdown signal received, draining connections...")
+	case <-app.stop:
+		log.Println("Shutdown requested, draining connections...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.ShutdownTimeout)
+	defer cancel()
+	err = app.shutdown(ctx)
+	log.Println("Server stopped")
+	return err
+}
+
+// shutdown drains the HTTP server, runs the shutdown hooks and closes the database.
+// Every step runs even if a previous one failed; all errors are reported together.
+func (app *App) shutdown(ctx context.Context) error {
+	var errs []error
+	if err := app.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http server shutdown: %w", err))
+	}
+	for _, hook := range app.onShutdown {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if app.Db != nil && app.Db.Conn != nil {
+		if err := app.Db.Conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing database: %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // displayLaunchMessage prints a visually appealing launch message
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxMultipartMemory is the memory Bind lets multipart forms use before spilling files to disk.
+const MaxMultipartMemory = 10 << 20 // 10 MB
+
+// ErrInvalidBody is returned, wrapped, by Bind when the request body cannot be decoded.
+var ErrInvalidBody = errors.New("invalid request body")
+
+var (
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	timeType       = reflect.TypeOf(time.Time{})
+	fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// Bind decodes the request body into dst, a pointer to a struct, then validates
+// it against its `validate` tags (see Validate).
+//
+// The body is decoded according to the Content-Type header: url-encoded and
+// multipart forms are mapped onto fields by their `form` tag, falling back to
+// their `json` tag name; anything else is decoded as JSON. Multipart file parts
+// can be bound to *multipart.FileHeader fields.
+//
+// The returned error wraps ErrInvalidBody when decoding fails, and is a
+// ValidationErrors listing every offending field when validation fails.
+// The body stays readable for later handlers.
+func (c *Context) Bind(dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: destination must be a non-nil pointer to a struct, got %T", dst)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+	var err error
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if err = c.Request.ParseForm(); err == nil {
+			err = bindForm(rv.Elem(), c.Request.PostForm, nil)
+		}
+	case "multipart/form-data":
+		if err = c.Request.ParseMultipartForm(MaxMultipartMemory); err == nil {
+			form := c.Request.MultipartForm
+			err = bindForm(rv.Elem(), url.Values(form.Value), form.File)
+		}
+	default:
+		err = c.bindJSON(dst)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+	return Validate(dst)
+}
+
+// bindJSON decodes the JSON body into dst and puts the body back for later readers.
+func (c *Context) bindJSON(dst any) error {
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	if err != nil {
+		return err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		return errors.New("empty body")
+	}
+	return json.Unmarshal(body, dst)
+}
+
+// BindError sends the response matching an error returned by Bind: the
+// invalid fields for validation errors, a generic bad request otherwise.
+func (c *Context) BindError(err error) error {
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		return c.Error(http.StatusBadRequest, CodeValidation, "Some fields are invalid.", verrs)
+	}
+	return c.Error(http.StatusBadRequest, CodeBadRequest, "Invalid request body.", nil)
+}
+
+// fieldName returns the name a struct field is known by in requests: its tag
+// value for the given key, then its json name, then its Go name.
+// It returns "-" for fields that must be skipped.
+func fieldName(f reflect.StructField, key string) string {
+	for _, k := range []string{key, "json"} {
+		if tag, ok := f.Tag.Lookup(k); ok {
+			name, _, _ := strings.Cut(tag, ",")
+			if name != "" {
+				return name
+			}
+		}
+	}
+	return f.Name
+}
+
+// bindForm copies form values and files onto the exported fields of v.
+func bindForm(v reflect.Value, values url.Values, files map[string][]*multipart.FileHeader) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := fieldName(f, "form")
+		if name == "-" {
+			continue
+		}
+		field := v.Field(i)
+
+		if f.Type == fileHeaderType {
+			if fh := files[name]; len(fh) > 0 {
+				field.Set(reflect.ValueOf(fh[0]))
+			}
+			continue
+		}
+		raw, ok := values[name]
+		if !ok || len(raw) == 0 {
+			continue
+		}
+		if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() != reflect.Uint8 {
+			slice := reflect.MakeSlice(f.Type, len(raw), len(raw))
+			for j, s := range raw {
+				if err := setFromString(slice.Index(j), s); err != nil {
+					return fmt.Errorf("field %q: %v", name, err)
+				}
+			}
+			field.Set(slice)
+			continue
+		}
+		if err := setFromString(field, raw[0]); err != nil {
+			return fmt.Errorf("field %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// setFromString parses s into v according to its type.
+func setFromString(v reflect.Value, s string) error {
+	switch v.Type() {
+	case uuidType:
+		if s == "" {
+			return nil
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(id))
+		return nil
+	case timeType:
+		if s == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := setFromString(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
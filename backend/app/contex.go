@@ -2,14 +2,22 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"html/template"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"Social_Network/app/logger"
+
+	"github.com/google/uuid"
 )
 
 // db is a wrapper around the database connection
@@ -25,9 +33,108 @@ type Context struct {
 	Request        *http.Request       // Incoming HTTP request
 	handlers       []HandlerFunc       // Middleware or route handlers for the request
 	index          int                 // Current handler index
+	route          *Route              // Route matched for the request
+	params         Params              // Path parameters captured by the router
+	requestID      string              // Identifier of the request, see RequestID
 	Values         map[any]any         // Shared values accessible during request handling
 }
 
+// Context implements context.Context on top of the request's context, so it can be
+// passed to the data layer as is. It is done once the client goes away, the route
+// timeout expires or the handler chain returns.
+var _ context.Context = (*Context)(nil)
+
+// Deadline returns the deadline of the request, set by the route timeout.
+func (c *Context) Deadline() (time.Time, bool) {
+	return c.Request.Context().Deadline()
+}
+
+// Done returns a channel closed when the request is cancelled or times out.
+func (c *Context) Done() <-chan struct{} {
+	return c.Request.Context().Done()
+}
+
+// Err reports why Done was closed, see context.Context.
+func (c *Context) Err() error {
+	return c.Request.Context().Err()
+}
+
+// Value returns the value stored under key in Values, falling back to the request's context.
+func (c *Context) Value(key any) any {
+	if v, ok := c.Values[key]; ok {
+		return v
+	}
+	return c.Request.Context().Value(key)
+}
+
+// SetContext replaces the context of the request, e.g. to attach a value or a
+// tighter deadline for the rest of the handler chain.
+func (c *Context) SetContext(ctx context.Context) {
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// RequestID returns the identifier of the request. It is taken from the
+// X-Request-ID header when the client sent a usable one, generated otherwise,
+// and echoed back in the X-Request-ID response header.
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+// Logger returns the logger of the request. It carries the request ID, and the
+// user ID once authenticated, and is also what logger.FromContext returns for the
+// Context, so the data layer logs with the same attributes as the handler.
+func (c *Context) Logger() *slog.Logger {
+	return logger.FromContext(c)
+}
+
+// SetLogger replaces the logger of the request for the rest of the handler chain,
+// typically with one derived from Logger carrying more attributes.
+func (c *Context) SetLogger(l *slog.Logger) {
+	c.SetContext(logger.NewContext(c.Request.Context(), l))
+}
+
+// Param returns the value of the named path parameter, e.g. "groupID" for the
+// pattern "/groups/{groupID}". Returns an empty string if the parameter is absent.
+func (c *Context) Param(name string) string {
+	return c.params.Get(name)
+}
+
+// ParamUUID returns the named path parameter parsed as a UUID.
+func (c *Context) ParamUUID(name string) (uuid.UUID, error) {
+	value := c.params.Get(name)
+	if value == "" {
+		return uuid.Nil, fmt.Errorf("missing path parameter %q", name)
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid path parameter %q: %v", name, err)
+	}
+	return id, nil
+}
+
+// ParamInt returns the named path parameter parsed as an integer.
+func (c *Context) ParamInt(name string) (int, error) {
+	value := c.params.Get(name)
+	if value == "" {
+		return 0, fmt.Errorf("missing path parameter %q", name)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid path parameter %q: %v", name, err)
+	}
+	return n, nil
+}
+
+// Params returns all path parameters captured for the request.
+func (c *Context) Params() Params {
+	return c.params
+}
+
+// Route returns the route matched for the request.
+func (c *Context) Route() *Route {
+	return c.route
+}
+
 // BodyParser parses the request body into the specified struct or map.
 // Returns an error if parsing fails.
 func (c *Context) BodyParser(out interface{}) error {
@@ -36,7 +143,7 @@ func (c *Context) BodyParser(out interface{}) error {
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("Error closing request body: %v", err)
+			c.Logger().Warn("closing request body failed", "error", err)
 		}
 	}(c.Request.Body) // Ensure the body is closed after reading
 	if err != nil {
@@ -48,7 +155,7 @@ func (c *Context) BodyParser(out interface{}) error {
 	if err != nil {
 		var e *json.SyntaxError
 		if errors.As(err, &e) {
-			log.Printf("Syntax error at byte offset %d", e.Offset)
+			c.Logger().Debug("request body is not valid JSON", "offset", e.Offset)
 		}
 		return err
 	}
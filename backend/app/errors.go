@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Error codes used in the "code" field of error responses.
+// Clients should branch on the code rather than on the human readable message.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "payload_too_large"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeInternal         = "internal_error"
+	CodeUnavailable      = "service_unavailable"
+	CodeTimeout          = "timeout"
+)
+
+// ErrorBody is the payload of an error response.
+type ErrorBody struct {
+	Code      string `json:"code"`       // Stable, machine readable error code, e.g. "not_found"
+	Message   string `json:"message"`    // Human readable description of the error
+	Details   any    `json:"details"`    // Optional extra information, e.g. the invalid fields
+	RequestID string `json:"request_id"` // Identifier of the request, also sent as X-Request-ID
+}
+
+// ErrorResponse is the envelope every error response is wrapped in:
+//
+//	{"error": {"code": "...", "message": "...", "details": ..., "request_id": "..."}}
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// Error sends an error response with the given status, code and message in the
+// standard envelope. details may be nil.
+// Server errors raised after the route timeout expired are reported as a timeout,
+// since the failure most likely comes from the cancelled context.
+func (c *Context) Error(status int, code, message string, details any) error {
+	if status >= http.StatusInternalServerError && errors.Is(c.Err(), context.DeadlineExceeded) {
+		status, code, message, details = http.StatusServiceUnavailable, CodeTimeout, "Request timed out.", nil
+	}
+	return c.Status(status).JSON(ErrorResponse{Error: ErrorBody{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: c.RequestID(),
+	}})
+}
+
+// CodeForStatus returns the default error code for an HTTP status.
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
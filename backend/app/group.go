@@ -0,0 +1,65 @@
+package app
+
+// RouterGroup registers routes under a shared path prefix and middleware chain.
+// Routes added to a group run the app's global middleware, then the middleware
+// of every enclosing group from the outermost inwards, then their own handlers.
+type RouterGroup struct {
+	app        *App
+	prefix     string
+	middleware []HandlerFunc
+}
+
+// Group creates a route group whose routes are prefixed with prefix and run
+// the given middleware before their own handlers. The prefix may be empty to
+// share middleware only, and may contain {name} segments.
+func (app *App) Group(prefix string, handlers ...HandlerFunc) *RouterGroup {
+	return &RouterGroup{
+		app:        app,
+		prefix:     prefix,
+		middleware: append([]HandlerFunc(nil), handlers...),
+	}
+}
+
+// Group creates a nested group inheriting this group's prefix and middleware.
+func (g *RouterGroup) Group(prefix string, handlers ...HandlerFunc) *RouterGroup {
+	middleware := make([]HandlerFunc, 0, len(g.middleware)+len(handlers))
+	middleware = append(middleware, g.middleware...)
+	middleware = append(middleware, handlers...)
+	return &RouterGroup{app: g.app, prefix: g.prefix + prefix, middleware: middleware}
+}
+
+// Use adds middleware to the group. Like App.Use, it only applies to routes
+// registered after the call.
+func (g *RouterGroup) Use(handlers ...HandlerFunc) {
+	g.middleware = append(g.middleware, handlers...)
+}
+
+// Prefix returns the full path prefix of the group.
+func (g *RouterGroup) Prefix() string {
+	return g.prefix
+}
+
+// handle registers a route relative to the group prefix with the group middleware prepended
+func (g *RouterGroup) handle(path string, handlers []HandlerFunc, methods ...string) *Route {
+	chain := make([]HandlerFunc, 0, len(g.middleware)+len(handlers))
+	chain = append(chain, g.middleware...)
+	chain = append(chain, handlers...)
+	return g.app.handle(g.prefix+path, chain, methods...)
+}
+
+// Register HTTP methods (GET, POST, PUT, DELETE) on the group
+func (g *RouterGroup) GET(path string, handler ...HandlerFunc) *Route {
+	return g.handle(path, handler, "GET")
+}
+
+func (g *RouterGroup) PUT(path string, handler ...HandlerFunc) *Route {
+	return g.handle(path, handler, "PUT")
+}
+
+func (g *RouterGroup) POST(path string, handler ...HandlerFunc) *Route {
+	return g.handle(path, handler, "POST")
+}
+
+func (g *RouterGroup) DELETE(path string, handler ...HandlerFunc) *Route {
+	return g.handle(path, handler, "DELETE")
+}
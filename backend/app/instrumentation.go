@@ -0,0 +1,38 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"Social_Network/app/metrics"
+)
+
+// unmatchedRoute is the route label of requests that matched no route.
+const unmatchedRoute = "unmatched"
+
+var (
+	requestsTotal = metrics.Default.NewCounterVec("http_requests_total",
+		"Total number of HTTP requests by route, method and status.", "route", "method", "status")
+	requestDuration = metrics.Default.NewHistogramVec("http_request_duration_seconds",
+		"Time taken to handle HTTP requests by route and method.", metrics.DefBuckets, "route", "method")
+)
+
+// observeRequest records a handled request in the HTTP metrics. Requests are
+// labelled with the route pattern rather than the path, to keep the number of
+// series bounded.
+func observeRequest(c *Context, start time.Time) {
+	route := unmatchedRoute
+	if c.route != nil {
+		route = c.route.pattern
+	}
+	method := c.Request.Method
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+	default:
+		method = "other" // Clients choose the method; do not let them create series
+	}
+	requestsTotal.Inc(route, method, strconv.Itoa(c.StatusCode()))
+	requestDuration.Observe(time.Since(start).Seconds(), route, method)
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"Social_Network/app/logger"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Dev is a mailer for development: instead of sending messages, it logs them,
+// body included, and writes them as .eml files to a directory if it has one.
+// The zero value only logs messages.
+type Dev struct {
+	from  string
+	dir   string
+	count atomic.Int64
+}
+
+var _ Mailer = (*Dev)(nil)
+
+// NewDev returns a mailer logging messages as sent by from, and writing them to
+// dir unless it is empty. The directory is created if needed.
+func NewDev(from, dir string) (*Dev, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("mailer: %v", err)
+		}
+	}
+	return &Dev{from: from, dir: dir}, nil
+}
+
+// Send logs msg and writes it to the directory of the mailer.
+func (d *Dev) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	now := time.Now()
+	attrs := []any{"to", strings.Join(msg.To, ", "), "subject", msg.Subject, "text", msg.Text}
+	if d.dir != "" {
+		name := fmt.Sprintf("%s-%d.eml", now.Format("20060102T150405"), d.count.Add(1))
+		path := filepath.Join(d.dir, name)
+		if err := os.WriteFile(path, msg.Bytes(d.from, now), 0644); err != nil {
+			return fmt.Errorf("mailer: %v", err)
+		}
+		attrs = append(attrs, "file", path)
+	}
+	logger.FromContext(ctx).Info("mail not sent in development", attrs...)
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package mailer sends emails. Mailer is implemented by SMTP, which delivers
+// them through a mail server, and by Dev, which logs them and writes them to
+// files instead, for development. smtptest provides a local SMTP server to test
+// against.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Message is an email in plain text.
+type Message struct {
+	To      []string // Recipient addresses
+	Subject string   // Subject line
+	Text    string   // Plain text body
+}
+
+// Mailer sends emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	// Send sends msg, giving up once ctx is done.
+	Send(ctx context.Context, msg Message) error
+}
+
+// validate checks the message has recipients and that they are addresses.
+func (m Message) validate() error {
+	if len(m.To) == 0 {
+		return fmt.Errorf("mailer: message has no recipient")
+	}
+	for _, to := range m.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("mailer: invalid recipient %q: %v", to, err)
+		}
+	}
+	return nil
+}
+
+// Bytes formats the message as sent by from at date, with its headers and a
+// quoted-printable UTF-8 body, as expected by SMTP's DATA command.
+func (m Message) Bytes(from string, date time.Time) []byte {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(strings.ReplaceAll(m.Text, "\n", "\r\n")))
+	w.Close()
+	return b.Bytes()
+}
+
+// messageID returns a unique Message-ID in the domain of the from address.
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	random := make([]byte, 16)
+	rand.Read(random)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(random), domain)
+}
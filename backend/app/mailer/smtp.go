@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig defines how SMTP reaches the mail server.
+type SMTPConfig struct {
+	Addr     string // Address of the server, e.g. "smtp.example.com:587".
+	Username string // User to authenticate as with AUTH PLAIN, if set.
+	Password string // Password of the user.
+	From     string // Sender of the messages, e.g. "Social Network <no-reply@example.com>".
+}
+
+// SMTP sends emails through a mail server. It upgrades the connection with
+// STARTTLS when the server offers it; credentials are only sent over TLS,
+// unless the server is on localhost.
+type SMTP struct {
+	config SMTPConfig
+	dialer net.Dialer
+}
+
+var _ Mailer = (*SMTP)(nil)
+
+// NewSMTP returns a mailer using the server described by config.
+func NewSMTP(config SMTPConfig) *SMTP {
+	return &SMTP{config: config}
+}
+
+// Send sends msg through the server, in a connection of its own.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(s.config.From)
+	if err != nil {
+		return fmt.Errorf("mailer: invalid sender %q: %v", s.config.From, err)
+	}
+	host, _, err := net.SplitHostPort(s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("mailer: invalid address %q: %v", s.config.Addr, err)
+	}
+
+	conn, err := s.dialer.DialContext(ctx, "tcp", s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("mailer: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Unblock the exchange if ctx is canceled before its deadline
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("mailer: %v", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("mailer: starting TLS: %v", err)
+		}
+	}
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("mailer: authenticating: %v", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("mailer: %v", err)
+	}
+	for _, to := range msg.To {
+		addr, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("mailer: %v", err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mailer: %v", err)
+	}
+	if _, err := w.Write(msg.Bytes(s.config.From, time.Now())); err != nil {
+		return fmt.Errorf("mailer: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mailer: %v", err)
+	}
+	return client.Quit()
+}
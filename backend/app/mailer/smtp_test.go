@@ -0,0 +1,120 @@
+package mailer_test
+
+import (
+	"Social_Network/app/mailer"
+	"Social_Network/app/mailer/smtptest"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSMTP starts a fake server and returns it along with a mailer using it.
+func newSMTP(t *testing.T, config mailer.SMTPConfig) (*smtptest.Server, *mailer.SMTP) {
+	t.Helper()
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	config.Addr = server.Addr
+	if config.From == "" {
+		config.From = "Social Network <no-reply@example.com>"
+	}
+	return server, mailer.NewSMTP(config)
+}
+
+func TestSMTPSend(t *testing.T) {
+	server, m := newSMTP(t, mailer.SMTPConfig{})
+	msg := mailer.Message{
+		To:      []string{"Ada <ada@example.com>", "bob@example.com"},
+		Subject: "Vérifiez votre adresse",
+		Text:    "Hello,\nfollow the link.",
+	}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Wait(1, time.Second)
+	if len(messages) != 1 {
+		t.Fatalf("%d messages received, want 1", len(messages))
+	}
+	got := messages[0]
+	if got.From != "no-reply@example.com" {
+		t.Errorf("envelope sender = %q", got.From)
+	}
+	if want := []string{"ada@example.com", "bob@example.com"}; !reflect.DeepEqual(got.To, want) {
+		t.Errorf("envelope recipients = %v, want %v", got.To, want)
+	}
+	if got.Mail == nil {
+		t.Fatalf("message could not be parsed: %q", got.Data)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(got.Mail.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("subject = %q, %v, want %q", subject, err, msg.Subject)
+	}
+	if to := got.Mail.Header.Get("To"); to != strings.Join(msg.To, ", ") {
+		t.Errorf("To = %q", to)
+	}
+	if got.Mail.Header.Get("Message-ID") == "" || got.Mail.Header.Get("Date") == "" {
+		t.Errorf("headers = %v, want a Message-ID and a Date", got.Mail.Header)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(got.Mail.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Line endings are the server's business
+	if text := strings.TrimSpace(strings.ReplaceAll(string(body), "\r\n", "\n")); text != msg.Text {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSMTPAuth(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		password string
+		ok       bool
+	}{
+		{"right password", "secret", true},
+		{"wrong password", "wrong", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, m := newSMTP(t, mailer.SMTPConfig{Username: "user", Password: test.password})
+			server.RequireAuth("user", "secret")
+
+			err := m.Send(context.Background(), mailer.Message{To: []string{"ada@example.com"}, Subject: "Hi", Text: "Hi"})
+			if test.ok && err != nil {
+				t.Errorf("Send() = %v", err)
+			}
+			if !test.ok && err == nil {
+				t.Error("Send() succeeded with the wrong password")
+			}
+			want := 0
+			if test.ok {
+				want = 1
+			}
+			if messages := server.Wait(want, 100*time.Millisecond); len(messages) != want {
+				t.Errorf("%d messages received, want %d", len(messages), want)
+			}
+		})
+	}
+}
+
+func TestSMTPInvalidMessage(t *testing.T) {
+	server, m := newSMTP(t, mailer.SMTPConfig{})
+	for _, msg := range []mailer.Message{
+		{Subject: "Hi", Text: "Hi"},
+		{To: []string{"not an address"}, Subject: "Hi", Text: "Hi"},
+	} {
+		if err := m.Send(context.Background(), msg); err == nil {
+			t.Errorf("Send(%v) succeeded", msg.To)
+		}
+	}
+	if messages := server.Messages(); len(messages) != 0 {
+		t.Errorf("%d messages received, want none", len(messages))
+	}
+}
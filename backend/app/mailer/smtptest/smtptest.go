@@ -0,0 +1,238 @@
+// Package smtptest provides an in-process SMTP server, for testing mailer.SMTP
+// and what is sent through it without a real mail server. Like net/http/httptest,
+// it listens on a local port: point the mailer at Server.Addr.
+//
+// It accepts every message, without TLS, and keeps them for inspection. It
+// understands EHLO, HELO, AUTH PLAIN, MAIL, RCPT, DATA, RSET, NOOP and QUIT.
+package smtptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a message received by the server.
+type Message struct {
+	From string        // Envelope sender, from MAIL FROM
+	To   []string      // Envelope recipients, from RCPT TO
+	Data []byte        // Message as sent with DATA, dot-unstuffed
+	Mail *mail.Message // Message parsed from Data, nil if it could not be
+}
+
+// Server is a fake SMTP server.
+type Server struct {
+	// Addr is the address the server listens on, e.g. "127.0.0.1:49152".
+	Addr string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	messages []Message
+	username string
+	password string
+	received chan struct{}
+}
+
+// NewServer starts a server on a free local port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     l.Addr().String(),
+		listener: l,
+		conns:    make(map[net.Conn]struct{}),
+		received: make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and closes every client connection.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// RequireAuth makes the server accept messages only from clients authenticated
+// with AUTH PLAIN as username and password.
+func (s *Server) RequireAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+// Messages returns the messages received so far, in order.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Wait waits until the server received at least n messages, at most for timeout,
+// and returns the messages received.
+func (s *Server) Wait(n int, timeout time.Duration) []Message {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		messages, received := s.messages, s.received
+		s.mu.Unlock()
+		if len(messages) >= n {
+			return s.Messages()
+		}
+		select {
+		case <-received:
+		case <-deadline:
+			return s.Messages()
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+// session is the state of a connection.
+type session struct {
+	authed bool
+	from   string
+	to     []string
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	s.mu.Lock()
+	username, password := s.username, s.password
+	s.mu.Unlock()
+
+	conn := textproto.NewConn(c)
+	reply := func(code int, text string) bool {
+		return conn.PrintfLine("%d %s", code, text) == nil
+	}
+	if !reply(220, "smtptest ready") {
+		return
+	}
+
+	sess := &session{authed: username == ""}
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			conn.PrintfLine("250-smtptest")
+			conn.PrintfLine("250-8BITMIME")
+			if !reply(250, "AUTH PLAIN") {
+				return
+			}
+		case "HELO", "NOOP":
+			reply(250, "OK")
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			if !strings.EqualFold(mechanism, "PLAIN") {
+				reply(504, "unrecognized authentication type")
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(decoded), "\x00")
+			if err != nil || len(parts) != 3 || parts[1] != username || parts[2] != password {
+				reply(535, "authentication failed")
+				continue
+			}
+			sess.authed = true
+			reply(235, "authenticated")
+		case "MAIL":
+			if !sess.authed {
+				reply(530, "authentication required")
+				continue
+			}
+			sess.from, sess.to = pathArg(arg, "FROM:"), nil
+			reply(250, "OK")
+		case "RCPT":
+			if sess.from == "" {
+				reply(503, "need MAIL first")
+				continue
+			}
+			sess.to = append(sess.to, pathArg(arg, "TO:"))
+			reply(250, "OK")
+		case "DATA":
+			if len(sess.to) == 0 {
+				reply(503, "need RCPT first")
+				continue
+			}
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.receive(Message{From: sess.from, To: sess.to, Data: data})
+			sess.from, sess.to = "", nil
+			reply(250, "OK queued")
+		case "RSET":
+			sess.from, sess.to = "", nil
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+// receive records a message and wakes up the callers of Wait.
+func (s *Server) receive(msg Message) {
+	msg.Mail, _ = mail.ReadMessage(bytes.NewReader(msg.Data))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	close(s.received)
+	s.received = make(chan struct{})
+}
+
+// pathArg extracts the address of a MAIL FROM:<...> or RCPT TO:<...> argument.
+func pathArg(arg, prefix string) string {
+	if len(arg) >= len(prefix) && strings.EqualFold(arg[:len(prefix)], prefix) {
+		arg = arg[len(prefix):]
+	}
+	arg, _, _ = strings.Cut(strings.TrimSpace(arg), " ")
+	return strings.Trim(arg, "<>")
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import "database/sql"
+
+// RegisterDBStats registers metrics reading the pool statistics of db, see
+// sql.DBStats, on every scrape. Their names start with prefix, e.g. "db".
+func (r *Registry) RegisterDBStats(prefix string, db *sql.DB) {
+	stat := func(f func(s sql.DBStats) float64) func() float64 {
+		return func() float64 { return f(db.Stats()) }
+	}
+	r.NewGaugeFunc(prefix+"_max_open_connections", "Maximum number of open connections to the database.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	r.NewGaugeFunc(prefix+"_open_connections", "Number of established connections, both in use and idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	r.NewGaugeFunc(prefix+"_in_use_connections", "Number of connections currently in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	r.NewGaugeFunc(prefix+"_idle_connections", "Number of idle connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	r.NewCounterFunc(prefix+"_wait_count_total", "Total number of connections waited for.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	r.NewCounterFunc(prefix+"_wait_duration_seconds_total", "Total time blocked waiting for a new connection.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	r.NewCounterFunc(prefix+"_max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	r.NewCounterFunc(prefix+"_max_idle_time_closed_total", "Total number of connections closed due to SetConnMaxIdleTime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	r.NewCounterFunc(prefix+"_max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
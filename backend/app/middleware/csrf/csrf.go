@@ -0,0 +1,96 @@
+package csrf
+
+import (
+	"Social_Network/app"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// SessionFunc returns the ID of the session a request is authenticated with
+// through a cookie. Requests for which it returns "" are not checked: without a
+// session cookie there is nothing a forged request could act on, and other
+// credentials, like bearer tokens, are not attached by browsers on their own.
+type SessionFunc func(c *app.Context) string
+
+// Config defines the structure for CSRF settings. Tokens are synchronizer tokens
+// derived from the session ID with an HMAC, so they need no storage, are the same
+// on every replica sharing Secret, and change whenever the session ID does.
+type Config struct {
+	Secret  []byte      // Key tokens are derived with; required.
+	Header  string      // Header carrying the token, defaults to "X-CSRF-Token".
+	Methods []string    // Methods requiring a token, defaults to POST, PUT, PATCH and DELETE.
+	Session SessionFunc // Session the request is authenticated with; required.
+}
+
+// DefaultConfig provides sensible default values for CSRF protection.
+func DefaultConfig() Config {
+	return Config{
+		Header:  "X-CSRF-Token",
+		Methods: []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+	}
+}
+
+// MergeConfig combines user-provided and default CSRF settings.
+func MergeConfig(userConfig Config) Config {
+	defaultConfig := DefaultConfig()
+
+	if userConfig.Header != "" {
+		defaultConfig.Header = userConfig.Header
+	}
+	if len(userConfig.Methods) > 0 {
+		defaultConfig.Methods = userConfig.Methods
+	}
+	defaultConfig.Secret = userConfig.Secret
+	defaultConfig.Session = userConfig.Session
+
+	return defaultConfig
+}
+
+// New creates a CSRF middleware handler based on the provided configuration.
+// Requests using one of the methods without the token of their session get a
+// 403 response. It panics if Secret or Session is missing.
+func New(userConfig Config) app.HandlerFunc {
+	config := MergeConfig(userConfig)
+	if len(config.Secret) == 0 || config.Session == nil {
+		panic("csrf: Secret and Session are required")
+	}
+	methods := make(map[string]bool, len(config.Methods))
+	for _, method := range config.Methods {
+		methods[strings.ToUpper(method)] = true
+	}
+
+	return func(c *app.Context) {
+		if !methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+		sessionID := config.Session(c)
+		if sessionID == "" {
+			c.Next()
+			return
+		}
+		if !Valid(config.Secret, sessionID, c.Request.Header.Get(config.Header)) {
+			c.Error(http.StatusForbidden, app.CodeForbidden, "Missing or invalid CSRF token.", nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Token returns the CSRF token of a session.
+func Token(secret []byte, sessionID string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("csrf:" + sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Valid reports whether token is the CSRF token of a session.
+func Valid(secret []byte, sessionID, token string) bool {
+	if token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(Token(secret, sessionID)))
+}
@@ -0,0 +1,230 @@
+package ratelimit
+
+import (
+	"Social_Network/app"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyFunc returns the key requests are counted under, e.g. the client IP.
+// Requests for which it returns "" are not limited.
+type KeyFunc func(c *app.Context) string
+
+// Config defines the structure for rate limit settings. Every key gets a token
+// bucket holding up to Burst tokens and refilled with Requests tokens per Per;
+// each request takes one token.
+type Config struct {
+	Requests int           // Number of requests allowed per period.
+	Per      time.Duration // Length of the period.
+	Burst    int           // Requests allowed at once after being idle, defaults to Requests.
+	Key      KeyFunc       // Key requests are counted under, defaults to ByIP.
+}
+
+// DefaultConfig provides sensible default values for rate limiting.
+func DefaultConfig() Config {
+	return Config{
+		Requests: 60,
+		Per:      time.Minute,
+		Key:      ByIP,
+	}
+}
+
+// MergeConfig combines user-provided and default rate limit settings.
+func MergeConfig(userConfig Config) Config {
+	defaultConfig := DefaultConfig()
+
+	if userConfig.Requests > 0 && userConfig.Per > 0 {
+		defaultConfig.Requests = userConfig.Requests
+		defaultConfig.Per = userConfig.Per
+	}
+	defaultConfig.Burst = defaultConfig.Requests
+	if userConfig.Burst > 0 {
+		defaultConfig.Burst = userConfig.Burst
+	}
+	if userConfig.Key != nil {
+		defaultConfig.Key = userConfig.Key
+	}
+
+	return defaultConfig
+}
+
+// New creates a rate limit middleware handler based on the provided configuration.
+// Requests over the limit get a 429 response with a Retry-After header.
+func New(userConfig Config) app.HandlerFunc {
+	config := MergeConfig(userConfig)
+	limiter := NewLimiter(config)
+
+	return func(c *app.Context) {
+		key := config.Key(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if ok, retryAfter := limiter.Allow(key); !ok {
+			c.ResponseWriter.Header().Set("Retry-After", strconv.Itoa(RetryAfterSeconds(retryAfter)))
+			c.Error(http.StatusTooManyRequests, app.CodeTooManyRequests, "Too many requests, please retry later.", nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ByIP keys requests by the IP address of the client connection.
+func ByIP(c *app.Context) string {
+	return ClientIP(c.Request, Proxies{})
+}
+
+// Proxies describes the reverse proxies in front of the server, for ClientIP.
+type Proxies struct {
+	Trust   bool         // Requests come through a proxy appending the address it saw to X-Forwarded-For
+	Trusted []*net.IPNet // Further proxies, whose hops in X-Forwarded-For are skipped too
+}
+
+// ParseNetworks parses a comma-separated list of IP addresses and CIDR networks.
+func ParseNetworks(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// trusts tells whether ip is the address of one of the trusted proxies.
+func (p Proxies) trusts(ip net.IP) bool {
+	for _, network := range p.Trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client. If proxies are trusted, the
+// address is taken from X-Forwarded-For rather than from the connection: its
+// rightmost hop that is not a trusted proxy, as everything left of it was sent
+// by the client and can be anything. Without X-Forwarded-For, X-Real-IP is
+// used. Only trust a proxy that sets them, in front of a server not reachable
+// otherwise.
+func ClientIP(r *http.Request, proxies Proxies) string {
+	if proxies.Trust {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				ip := net.ParseIP(strings.TrimSpace(hops[i]))
+				if ip == nil {
+					break // Whatever is left of a malformed hop cannot be told apart
+				}
+				if i == 0 || !proxies.trusts(ip) {
+					return ip.String()
+				}
+			}
+		} else if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			return ip.String()
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds, as used by Retry-After.
+func RetryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
+
+// sweepInterval is how often a Limiter drops the buckets of idle keys.
+const sweepInterval = time.Minute
+
+// Limiter keeps a token bucket per key. It can be used directly where the
+// middleware does not fit, e.g. to throttle messages on a WebSocket connection.
+type Limiter struct {
+	rate  float64 // Tokens added per second
+	burst float64 // Capacity of a bucket
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket holds the tokens left for a key as of last.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter using the rate and burst of the configuration.
+func NewLimiter(userConfig Config) *Limiter {
+	config := MergeConfig(userConfig)
+	return &Limiter{
+		rate:      float64(config.Requests) / config.Per.Seconds(),
+		burst:     float64(config.Burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key. When it is empty, it returns false
+// along with how long to wait for the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled completely; a missing bucket is
+// the same as a full one, so this frees memory without changing any limit.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
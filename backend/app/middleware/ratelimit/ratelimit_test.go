@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNetworks("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		proxies   Proxies
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{"connection without trust", Proxies{}, []string{"203.0.113.7"}, "203.0.113.8", "198.51.100.1"},
+		{"single hop", Proxies{Trust: true}, []string{"203.0.113.7"}, "", "203.0.113.7"},
+		{"spoofed hops on the left", Proxies{Trust: true}, []string{"1.2.3.4, 203.0.113.7"}, "", "203.0.113.7"},
+		{"hops in several headers", Proxies{Trust: true}, []string{"1.2.3.4", "203.0.113.7"}, "", "203.0.113.7"},
+		{"trusted proxies skipped", Proxies{Trust: true, Trusted: trusted}, []string{"1.2.3.4, 203.0.113.7, 10.1.2.3, 192.0.2.1"}, "", "203.0.113.7"},
+		{"untrusted proxy not skipped", Proxies{Trust: true, Trusted: trusted}, []string{"203.0.113.7, 192.0.2.2"}, "", "192.0.2.2"},
+		{"only trusted hops", Proxies{Trust: true, Trusted: trusted}, []string{"10.1.2.3, 192.0.2.1"}, "", "10.1.2.3"},
+		{"malformed hop", Proxies{Trust: true}, []string{"203.0.113.7, nonsense"}, "", "198.51.100.1"},
+		{"real IP", Proxies{Trust: true}, nil, "203.0.113.8", "203.0.113.8"},
+		{"no header", Proxies{Trust: true}, nil, "", "198.51.100.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "198.51.100.1:4242"
+			for _, value := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if test.realIP != "" {
+				r.Header.Set("X-Real-IP", test.realIP)
+			}
+			if got := ClientIP(r, test.proxies); got != test.want {
+				t.Errorf("ClientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks(" 10.0.0.0/8,192.0.2.1 , 2001:db8::/32,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 3 || networks[1].String() != "192.0.2.1/32" {
+		t.Errorf("ParseNetworks() = %v", networks)
+	}
+	for _, list := range []string{"nonsense", "10.0.0.0/99"} {
+		if _, err := ParseNetworks(list); err == nil {
+			t.Errorf("ParseNetworks(%q) succeeded", list)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidToken is returned for ID tokens that fail verification.
+var ErrInvalidToken = errors.New("oidc: invalid ID token")
+
+// Leeway is the clock skew allowed between the provider and the application.
+const Leeway = time.Minute
+
+// keysRefreshInterval is how often the keys are fetched again at most, when a
+// token is signed with a key not seen yet, as after the provider rotated its keys.
+const keysRefreshInterval = time.Minute
+
+// Claims are the claims of an ID token the application uses.
+type Claims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	Expiry          int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	NotBefore       int64    `json:"nbf"`
+	Nonce           string   `json:"nonce"`
+
+	Email         string  `json:"email"`
+	EmailVerified boolish `json:"email_verified"`
+	Name          string  `json:"name"`
+	GivenName     string  `json:"given_name"`
+	FamilyName    string  `json:"family_name"`
+	Picture       string  `json:"picture"`
+}
+
+// audience is the aud claim, which is a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// boolish is a boolean claim, which some providers send as a string.
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// VerifyIDToken checks the signature of an ID token against the keys of the
+// provider, that it was issued by the provider to the client and is valid at
+// now, and that it carries nonce; then it returns its claims.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string, now time.Time) (Claims, error) {
+	var claims Claims
+	if _, err := p.Metadata(ctx); err != nil {
+		return claims, err
+	}
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return claims, ErrInvalidToken
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, ErrInvalidToken
+	}
+	keys, err := p.keys.lookup(ctx, header.KeyID)
+	if err != nil {
+		return claims, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if verifySignature(header.Algorithm, key, signed, signature) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return claims, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, ErrInvalidToken
+	}
+	// ID Token Validation, OpenID Connect Core 1.0 section 3.1.3.7
+	switch {
+	case claims.Issuer != p.config.Issuer:
+		return claims, fmt.Errorf("%w: issued by %q", ErrInvalidToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, p.config.ClientID):
+		return claims, fmt.Errorf("%w: not issued to the client", ErrInvalidToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID:
+		return claims, fmt.Errorf("%w: not authorized for the client", ErrInvalidToken)
+	case claims.Expiry == 0 || !now.Before(time.Unix(claims.Expiry, 0).Add(Leeway)):
+		return claims, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case claims.NotBefore != 0 && now.Add(Leeway).Before(time.Unix(claims.NotBefore, 0)):
+		return claims, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	case claims.Nonce != nonce:
+		return claims, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	case claims.Subject == "":
+		return claims, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT into out.
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// verifySignature checks the JWS signature of signed with key, for the
+// asymmetric algorithms of RFC 7518. Symmetric ones and "none" are refused, as
+// the keys come from the provider.
+func verifySignature(algorithm string, key crypto.PublicKey, signed, signature []byte) error {
+	if len(algorithm) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	var hash crypto.Hash
+	switch algorithm[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	if hash == 0 {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	var digest []byte
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256(signed)
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384(signed)
+		digest = sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(signed)
+		digest = sum[:]
+	}
+
+	switch algorithm[:2] {
+	case "RS":
+		if key, ok := key.(*rsa.PublicKey); ok {
+			return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+		}
+	case "PS":
+		if key, ok := key.(*rsa.PublicKey); ok {
+			return rsa.VerifyPSS(key, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case "ES":
+		if key, ok := key.(*ecdsa.PublicKey); ok {
+			size := (key.Curve.Params().BitSize + 7) / 8
+			if len(signature) != 2*size {
+				return errors.New("bad signature length")
+			}
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return nil
+			}
+			return errors.New("bad signature")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	return errors.New("key does not match the algorithm")
+}
+
+// keySet caches the signing keys of a provider, from its JWKS document.
+type keySet struct {
+	uri   string
+	fetch func(ctx context.Context, url string, out any) error
+
+	mu      sync.Mutex
+	keys    map[string][]crypto.PublicKey // By key ID, "" holding every key
+	fetched time.Time
+}
+
+func newKeySet(uri string, fetch func(ctx context.Context, url string, out any) error) *keySet {
+	return &keySet{uri: uri, fetch: fetch}
+}
+
+// lookup returns the keys a token signed with the given key ID may be verified
+// with, fetching the keys again if none is known by that ID.
+func (k *keySet) lookup(ctx context.Context, keyID string) ([]crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if keys := k.keys[keyID]; len(keys) > 0 {
+		return keys, nil
+	}
+	if time.Since(k.fetched) < keysRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, keyID)
+	}
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := k.fetch(ctx, k.uri, &document); err != nil {
+		return nil, fmt.Errorf("oidc: fetching keys: %v", err)
+	}
+	k.fetched = time.Now()
+	k.keys = map[string][]crypto.PublicKey{}
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue // Key types the client does not know are skipped
+		}
+		k.keys[""] = append(k.keys[""], key)
+		if jwk.KeyID != "" {
+			k.keys[jwk.KeyID] = append(k.keys[jwk.KeyID], key)
+		}
+	}
+	if keys := k.keys[keyID]; len(keys) > 0 {
+		return keys, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, keyID)
+}
+
+// jsonWebKey is a public key as published in a JWKS document, RFC 7517.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(data) == 0 {
+			return nil, errors.New("bad key parameter")
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("bad key exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+}
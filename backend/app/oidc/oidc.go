@@ -0,0 +1,230 @@
+// Package oidc signs users in with an OpenID Connect provider, through the
+// authorization code flow with PKCE. It discovers the endpoints of the provider
+// from its issuer URL, exchanges codes for tokens, and verifies ID tokens against
+// the keys the provider publishes, so it works with any compliant issuer.
+//
+// oidctest provides a local provider to test against.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes the client registered with the provider.
+type Config struct {
+	Issuer       string       // Issuer URL, e.g. "https://accounts.example.com".
+	ClientID     string       // ID of the client.
+	ClientSecret string       // Secret of the client, empty for a public client.
+	RedirectURL  string       // Where the provider sends users back with a code.
+	Scopes       []string     // Scopes to ask for, defaults to openid, email and profile.
+	HTTPClient   *http.Client // Client reaching the provider, defaults to one with a 10s timeout.
+}
+
+// DefaultConfig provides sensible default values for the client.
+func DefaultConfig() Config {
+	return Config{
+		Scopes:     []string{"openid", "email", "profile"},
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// MergeConfig combines user-provided and default client settings.
+func MergeConfig(userConfig Config) Config {
+	config := DefaultConfig()
+	config.Issuer = strings.TrimSuffix(userConfig.Issuer, "/")
+	config.ClientID = userConfig.ClientID
+	config.ClientSecret = userConfig.ClientSecret
+	config.RedirectURL = userConfig.RedirectURL
+	if len(userConfig.Scopes) > 0 {
+		config.Scopes = userConfig.Scopes
+	}
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	if userConfig.HTTPClient != nil {
+		config.HTTPClient = userConfig.HTTPClient
+	}
+	return config
+}
+
+// Metadata is the part of the discovery document of a provider the client uses.
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider is an OpenID Connect provider, as seen by the client of Config. It
+// discovers the provider on first use, and retries on the next use if that failed,
+// so the provider need not be up when the application starts.
+type Provider struct {
+	config Config
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     *keySet
+}
+
+// New returns the provider of config.Issuer. It panics without an issuer or a client ID.
+func New(config Config) *Provider {
+	config = MergeConfig(config)
+	if config.Issuer == "" || config.ClientID == "" {
+		panic("oidc: Config.Issuer and Config.ClientID are required")
+	}
+	return &Provider{config: config}
+}
+
+// Issuer returns the issuer URL of the provider.
+func (p *Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+// Metadata returns the discovery document of the provider, fetching it on first use.
+func (p *Provider) Metadata(ctx context.Context) (Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return *p.metadata, nil
+	}
+
+	var metadata Metadata
+	if err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return metadata, fmt.Errorf("oidc: discovery: %v", err)
+	}
+	// The document must be the one of the configured issuer, OpenID Connect Discovery 1.0 section 4.3
+	if metadata.Issuer != p.config.Issuer {
+		return metadata, fmt.Errorf("oidc: discovery: issuer %q does not match %q", metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return metadata, errors.New("oidc: discovery: missing endpoints")
+	}
+	if len(metadata.CodeChallengeMethods) > 0 && !slices.Contains(metadata.CodeChallengeMethods, "S256") {
+		return metadata, errors.New("oidc: discovery: provider does not support PKCE with S256")
+	}
+	p.metadata = &metadata
+	p.keys = newKeySet(metadata.JWKSURI, p.getJSON)
+	return metadata, nil
+}
+
+// AuthCodeURL returns the URL to send the user to for signing in. state is
+// given back with the code, nonce ends up in the ID token, and challenge is the
+// PKCE challenge of the verifier later given to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", challenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Tokens is the response of the token endpoint.
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Exchange trades the code the user came back with, and the PKCE verifier it
+// was asked for with, for tokens. The ID token is not verified yet.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (Tokens, error) {
+	var tokens Tokens
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return tokens, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	// client_secret_basic is the default method, RFC 8414 section 2
+	basic := p.config.ClientSecret != "" &&
+		(len(metadata.TokenAuthMethods) == 0 || slices.Contains(metadata.TokenAuthMethods, "client_secret_basic"))
+	if !basic {
+		form.Set("client_id", p.config.ClientID)
+		if p.config.ClientSecret != "" {
+			form.Set("client_secret", p.config.ClientSecret)
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokens, fmt.Errorf("oidc: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if basic {
+		request.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	response, err := p.config.HTTPClient.Do(request)
+	if err != nil {
+		return tokens, fmt.Errorf("oidc: token request: %v", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return tokens, fmt.Errorf("oidc: token request: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		json.Unmarshal(body, &failure)
+		return tokens, fmt.Errorf("oidc: token request: %s: %s %s", response.Status, failure.Error, failure.Description)
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return tokens, fmt.Errorf("oidc: token response: %v", err)
+	}
+	if tokens.IDToken == "" {
+		return tokens, errors.New("oidc: token response has no ID token")
+	}
+	return tokens, nil
+}
+
+// getJSON fetches a JSON document from the provider into out.
+func (p *Provider) getJSON(ctx context.Context, url string, out any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := p.config.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, response.Status)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(out)
+}
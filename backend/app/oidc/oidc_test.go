@@ -0,0 +1,176 @@
+package oidc_test
+
+import (
+	"Social_Network/app/oidc"
+	"Social_Network/app/oidc/oidctest"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const redirectURL = "http://localhost:3000/auth/callback"
+
+// newProvider starts a provider for a client with a secret, and returns it
+// along with the client of it.
+func newProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	t.Helper()
+	server, err := oidctest.NewServer("client", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server, oidc.New(oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	})
+}
+
+// signIn goes through the authorization code flow with nonce, and returns the
+// ID token.
+func signIn(t *testing.T, server *oidctest.Server, provider *oidc.Provider, nonce string) string {
+	t.Helper()
+	ctx := context.Background()
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "state", nonce, oidc.Challenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := server.Login(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state" {
+		t.Fatalf("state = %q, want %q", state, "state")
+	}
+	tokens, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens.IDToken
+}
+
+func TestSignIn(t *testing.T) {
+	server, provider := newProvider(t)
+	server.SetUser(oidctest.User{Subject: "42", Email: "ada@example.com", EmailVerified: true, GivenName: "Ada", FamilyName: "Lovelace"})
+
+	idToken := signIn(t, server, provider, "nonce")
+	claims, err := provider.VerifyIDToken(context.Background(), idToken, "nonce", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Issuer != server.URL || claims.Subject != "42" || claims.Email != "ada@example.com" || !bool(claims.EmailVerified) ||
+		claims.GivenName != "Ada" || claims.FamilyName != "Lovelace" {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	server, _ := newProvider(t)
+	provider := oidc.New(oidc.Config{Issuer: server.URL + "/other", ClientID: "client"})
+	if _, err := provider.Metadata(context.Background()); err == nil {
+		t.Error("discovery succeeded for another issuer")
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	server, provider := newProvider(t)
+	ctx := context.Background()
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", oidc.Challenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := server.Login(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(ctx, code, "another verifier"); err == nil {
+		t.Error("code exchanged with the wrong PKCE verifier")
+	}
+}
+
+func TestExchangeCodeTwice(t *testing.T) {
+	server, provider := newProvider(t)
+	ctx := context.Background()
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", oidc.Challenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := server.Login(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(ctx, code, "verifier"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(ctx, code, "verifier"); err == nil {
+		t.Error("code exchanged twice")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	server, provider := newProvider(t)
+	idToken := signIn(t, server, provider, "nonce")
+	now := time.Now()
+	claims := func(change func(map[string]any)) string {
+		c := map[string]any{
+			"iss":   server.URL,
+			"sub":   "user-1",
+			"aud":   "client",
+			"exp":   now.Add(5 * time.Minute).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "nonce",
+		}
+		change(c)
+		token, err := server.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	// A token whose claims were changed after it was signed
+	parts := strings.Split(claims(func(map[string]any) {}), ".")
+	tampered := parts[0] + "." + strings.Split(claims(func(c map[string]any) { c["sub"] = "admin" }), ".")[1] + "." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		now   time.Time
+		valid bool
+	}{
+		{"issued by the provider", idToken, "nonce", now, true},
+		{"signed with the same claims", claims(func(map[string]any) {}), "nonce", now, true},
+		{"audience list with the client as authorized party", claims(func(c map[string]any) {
+			c["aud"], c["azp"] = []string{"client", "other"}, "client"
+		}), "nonce", now, true},
+		{"bad signature", tampered, "nonce", now, false},
+		{"not a JWT", "not.a.jwt", "nonce", now, false},
+		{"wrong issuer", claims(func(c map[string]any) { c["iss"] = "https://evil.example.com" }), "nonce", now, false},
+		{"wrong audience", claims(func(c map[string]any) { c["aud"] = "other" }), "nonce", now, false},
+		{"audience list without authorized party", claims(func(c map[string]any) { c["aud"] = []string{"client", "other"} }), "nonce", now, false},
+		{"expired", idToken, "nonce", now.Add(time.Hour), false},
+		{"expired within leeway", claims(func(c map[string]any) { c["exp"] = now.Add(-oidc.Leeway / 2).Unix() }), "nonce", now, true},
+		{"no expiry", claims(func(c map[string]any) { delete(c, "exp") }), "nonce", now, false},
+		{"not valid yet", claims(func(c map[string]any) { c["nbf"] = now.Add(time.Hour).Unix() }), "nonce", now, false},
+		{"nonce mismatch", idToken, "another nonce", now, false},
+		{"no subject", claims(func(c map[string]any) { c["sub"] = "" }), "nonce", now, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(context.Background(), test.token, test.nonce, test.now)
+			if test.valid && err != nil {
+				t.Errorf("VerifyIDToken() = %v, want no error", err)
+			}
+			if !test.valid && !errors.Is(err, oidc.ErrInvalidToken) {
+				t.Errorf("VerifyIDToken() = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
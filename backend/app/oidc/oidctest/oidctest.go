@@ -0,0 +1,326 @@
+// Package oidctest provides a local OpenID Connect provider, for testing sign-in
+// through package oidc without a real one. Like net/http/httptest, it listens on
+// a local port: use Server.URL as the issuer.
+//
+// It signs in as the user set with SetUser whoever opens the authorization
+// endpoint, without asking anything, and redirects back with a code right away.
+// It checks the client, the redirect URI and PKCE like a real provider would.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// User is who the provider signs in as.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// Server is a fake OpenID Connect provider.
+type Server struct {
+	// URL is the issuer URL of the provider, e.g. "http://127.0.0.1:49152".
+	URL string
+
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	keyID  int
+	user   User
+	codes  map[string]grant
+	issued int
+}
+
+// grant is what an authorization code was issued for.
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        User
+	expires     time.Time
+}
+
+// NewServer starts a provider on a free local port, for the client with the
+// given ID and secret. Without a secret, the client is public.
+func NewServer(clientID, clientSecret string) (*Server, error) {
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]grant),
+		user: User{
+			Subject:       "user-1",
+			Email:         "user@example.com",
+			EmailVerified: true,
+			GivenName:     "Test",
+			FamilyName:    "User",
+		},
+	}
+	if err := s.RotateKey(); err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	return s, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// SetUser sets who the provider signs in as from now on.
+func (s *Server) SetUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = user
+}
+
+// RotateKey makes the provider sign tokens with a new key, with a new key ID.
+// The previous key is no longer published.
+func (s *Server) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key = key
+	s.keyID++
+	return nil
+}
+
+// Issued returns how many ID tokens the provider issued.
+func (s *Server) Issued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issued
+}
+
+// Login goes through the authorization endpoint with the URL built by the
+// client, as a browser would, and returns the code and state it redirected
+// back with.
+func (s *Server) Login(authURL string) (code, state string, err error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	response.Body.Close()
+	location, err := response.Location()
+	if err != nil {
+		return "", "", fmt.Errorf("oidctest: no redirect: %s", response.Status)
+	}
+	query := location.Query()
+	if e := query.Get("error"); e != "" {
+		return "", "", fmt.Errorf("oidctest: %s: %s", e, query.Get("error_description"))
+	}
+	return query.Get("code"), query.Get("state"), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	key, keyID := s.key.PublicKey, s.keyID
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": fmt.Sprint(keyID),
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	target, err := url.Parse(redirectURI)
+	if err != nil || redirectURI == "" || query.Get("client_id") != s.ClientID {
+		// Never redirect to an unverified URI
+		http.Error(w, "invalid client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back := target.Query()
+	back.Set("state", query.Get("state"))
+	switch {
+	case query.Get("response_type") != "code":
+		back.Set("error", "unsupported_response_type")
+	case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+		back.Set("error", "invalid_request")
+		back.Set("error_description", "PKCE with S256 is required")
+	default:
+		code := randomString()
+		s.mu.Lock()
+		s.codes[code] = grant{
+			redirectURI: redirectURI,
+			challenge:   query.Get("code_challenge"),
+			nonce:       query.Get("nonce"),
+			user:        s.user,
+			expires:     time.Now().Add(time.Minute),
+		}
+		s.mu.Unlock()
+		back.Set("code", code)
+	}
+	target.RawQuery = back.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+	clientID, clientSecret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oidctest"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "")
+		return
+	}
+
+	s.mu.Lock()
+	code := r.PostForm.Get("code")
+	g, ok := s.codes[code]
+	delete(s.codes, code) // Codes work once
+	s.mu.Unlock()
+	switch {
+	case !ok || time.Now().After(g.expires):
+		tokenError(w, "invalid_grant", "unknown or expired code")
+		return
+	case r.PostForm.Get("redirect_uri") != g.redirectURI:
+		tokenError(w, "invalid_grant", "redirect_uri mismatch")
+		return
+	case challenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		tokenError(w, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	idToken, err := s.idToken(g)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// idToken signs an ID token for a grant with the current key.
+func (s *Server) idToken(g grant) (string, error) {
+	s.mu.Lock()
+	s.issued++
+	s.mu.Unlock()
+
+	now := time.Now()
+	return s.Sign(map[string]any{
+		"iss":            s.URL,
+		"sub":            g.user.Subject,
+		"aud":            s.ClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          g.nonce,
+		"email":          g.user.Email,
+		"email_verified": g.user.EmailVerified,
+		"given_name":     g.user.GivenName,
+		"family_name":    g.user.FamilyName,
+		"name":           g.user.GivenName + " " + g.user.FamilyName,
+	})
+}
+
+// Sign returns a token with the given claims, as is, signed with the current
+// key, to test how clients handle tokens the provider would not issue.
+func (s *Server) Sign(claims map[string]any) (string, error) {
+	s.mu.Lock()
+	key, keyID := s.key, s.keyID
+	s.mu.Unlock()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": fmt.Sprint(keyID)})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() string {
+	random := make([]byte, 24)
+	rand.Read(random)
+	return base64.RawURLEncoding.EncodeToString(random)
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// Recovery returns a middleware turning panics raised further down the chain
+// into a 500 response in the standard error envelope. The panic value and stack
+// trace are logged with the request's logger, so they carry the request ID sent
+// to the client. It should be registered with App.Use right after the request
+// logging middleware, so it covers every other handler and the 500 gets logged.
+func Recovery() HandlerFunc {
+	return func(c *Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// Deliberate abort of the response; let net/http deal with it.
+				panic(rec)
+			}
+			c.Logger().Error("panic recovered",
+				"method", c.Request.Method, "path", c.Request.URL.Path, "panic", rec, "stack", string(debug.Stack()))
+
+			if w, ok := c.ResponseWriter.(*responseWriter); ok {
+				if w.written || w.hijacked {
+					// Part of the response is already out; nothing sensible can follow it.
+					return
+				}
+				w.status = 0 // Drop a status the handler set before panicking
+			}
+			c.Error(http.StatusInternalServerError, CodeInternal, "Internal server error", nil)
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps the http.ResponseWriter handed to handlers. It holds the
+// status back until the body is written, so headers set after Context.Status
+// (e.g. Content-Type by Context.JSON) still reach the client, and it remembers
+// what was sent so middleware running after the handler can inspect it.
+type responseWriter struct {
+	http.ResponseWriter
+	status   int  // Status set by the handler, 0 while unset
+	written  bool // Whether the status line has been sent
+	hijacked bool // Whether the connection was taken over, e.g. by a WebSocket upgrade
+}
+
+// WriteHeader records the status; the first call wins, like net/http.
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 && !w.written {
+		w.status = status
+	}
+}
+
+// Write sends the pending status, then the body.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.writeHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+// writeHeader sends the pending status, defaulting to 200, if it was not sent yet.
+func (w *responseWriter) writeHeader() {
+	if w.written || w.hijacked {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.written = true
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Flush sends the pending status and flushes buffered data to the client.
+func (w *responseWriter) Flush() {
+	w.writeHeader()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection, as needed for WebSockets.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+		if w.status == 0 {
+			w.status = http.StatusSwitchingProtocols // What the new owner of the connection is expected to send
+		}
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the original writer, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// StatusCode returns the status sent, or about to be sent, for the request.
+// It is 0 while the handler has neither set a status nor written a body.
+func (c *Context) StatusCode() int {
+	if w, ok := c.ResponseWriter.(*responseWriter); ok {
+		return w.status
+	}
+	return 0
+}
+
+// Written reports whether a response has been started for the request,
+// either by setting its status or by taking over the connection.
+func (c *Context) Written() bool {
+	if w, ok := c.ResponseWriter.(*responseWriter); ok {
+		return w.status != 0 || w.written || w.hijacked
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nodeKind identifies how a tree node matches a piece of the request path.
+type nodeKind uint8
+
+const (
+	staticNode   nodeKind = iota // Matches a literal piece of the path
+	paramNode                    // Matches a single path segment, e.g. {groupID}
+	catchAllNode                 // Matches the rest of the path, e.g. /uploads*
+)
+
+// Param is a single path parameter captured while matching a route.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is the ordered list of path parameters captured for a request.
+type Params []Param
+
+// Get returns the value of the named parameter, or an empty string when it is absent.
+func (ps Params) Get(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// node is a single node of the radix tree used to route requests.
+// Static children share compressed prefixes; each node has at most one
+// parameter child and one catch-all child, tried in that order after the
+// static children.
+type node struct {
+	kind     nodeKind
+	prefix   string            // Edge label for static nodes, parameter name otherwise
+	indices  []byte            // First byte of each static child, for fast lookup
+	children []*node           // Static children
+	param    *node             // Child matching a {name} segment
+	catchAll *node             // Child matching the remainder of the path
+	routes   map[string]*Route // Routes registered on this node, keyed by HTTP method
+	fallback *Route            // First route registered on this node, used for OPTIONS
+}
+
+// insert adds the pattern to the tree and returns the node that terminates it.
+// Patterns may contain {name} segments and may end with a '*' catch-all.
+func (n *node) insert(pattern string) *node {
+	current := n
+	for pattern != "" {
+		i := strings.IndexAny(pattern, "{*")
+		if i < 0 {
+			return current.addStatic(pattern)
+		}
+		current = current.addStatic(pattern[:i])
+		pattern = pattern[i:]
+
+		if pattern[0] == '*' {
+			if len(pattern) > 1 {
+				panic(fmt.Sprintf("router: catch-all must be the last element of the pattern, got %q", pattern))
+			}
+			if current.catchAll == nil {
+				current.catchAll = &node{kind: catchAllNode, prefix: "*"}
+			}
+			return current.catchAll
+		}
+
+		end := strings.IndexByte(pattern, '}')
+		if end < 0 {
+			panic(fmt.Sprintf("router: unterminated parameter in pattern %q", pattern))
+		}
+		name := pattern[1:end]
+		if name == "" || strings.ContainsAny(name, "/{") {
+			panic(fmt.Sprintf("router: invalid parameter name in pattern %q", pattern))
+		}
+		if end+1 < len(pattern) && pattern[end+1] != '/' {
+			panic(fmt.Sprintf("router: parameter must span a whole segment in pattern %q", pattern))
+		}
+		if current.param == nil {
+			current.param = &node{kind: paramNode, prefix: name}
+		} else if current.param.prefix != name {
+			panic(fmt.Sprintf("router: parameter {%s} conflicts with existing {%s}", name, current.param.prefix))
+		}
+		current = current.param
+		pattern = pattern[end+1:]
+	}
+	return current
+}
+
+// addStatic inserts a literal path piece below n, splitting existing edges as needed.
+func (n *node) addStatic(s string) *node {
+	if s == "" {
+		return n
+	}
+	for i, c := range n.indices {
+		if c != s[0] {
+			continue
+		}
+		child := n.children[i]
+		l := commonPrefix(child.prefix, s)
+		if l < len(child.prefix) {
+			// Split the existing edge so the shared prefix becomes its own node.
+			split := &node{kind: staticNode, prefix: child.prefix[:l]}
+			child.prefix = child.prefix[l:]
+			split.indices = []byte{child.prefix[0]}
+			split.children = []*node{child}
+			n.children[i] = split
+			child = split
+		}
+		return child.addStatic(s[l:])
+	}
+	child := &node{kind: staticNode, prefix: s}
+	n.indices = append(n.indices, s[0])
+	n.children = append(n.children, child)
+	return child
+}
+
+// match walks the tree for the given path and returns the terminating node,
+// appending captured parameters to params. Static edges take precedence over
+// parameters, which take precedence over catch-alls.
+func (n *node) match(path string, params *Params) *node {
+	saved := len(*params)
+	switch n.kind {
+	case staticNode:
+		if !strings.HasPrefix(path, n.prefix) {
+			return nil
+		}
+		path = path[len(n.prefix):]
+	case paramNode:
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end == 0 {
+			return nil
+		}
+		*params = append(*params, Param{Key: n.prefix, Value: path[:end]})
+		path = path[end:]
+	case catchAllNode:
+		*params = append(*params, Param{Key: n.prefix, Value: path})
+		return n
+	}
+
+	if path == "" && len(n.routes) > 0 {
+		return n
+	}
+	if path != "" {
+		for i, c := range n.indices {
+			if c == path[0] {
+				if found := n.children[i].match(path, params); found != nil {
+					return found
+				}
+				break
+			}
+		}
+		if n.param != nil {
+			if found := n.param.match(path, params); found != nil {
+				return found
+			}
+		}
+	}
+	if n.catchAll != nil {
+		return n.catchAll.match(path, params)
+	}
+
+	*params = (*params)[:saved]
+	return nil
+}
+
+// commonPrefix returns the length of the longest common prefix of a and b.
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// testRouter returns an app whose handlers answer with the pattern of the
+// matched route and the parameters captured for it.
+func testRouter() *App {
+	app := New()
+	app.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	echo := func(c *Context) {
+		params := map[string]string{}
+		for _, p := range c.Params() {
+			params[p.Key] = p.Value
+		}
+		c.Status(http.StatusOK).JSON(map[string]any{"pattern": c.Route().Pattern(), "params": params})
+	}
+	app.GET("/", echo)
+	app.GET("/users/search", echo)
+	app.GET("/users/{userID}", echo)
+	app.PUT("/users/{userID}", echo)
+	app.DELETE("/users/{userID}", echo)
+	app.GET("/users/{userID}/posts", echo)
+	app.GET("/groups/new", echo)
+	app.GET("/groups/{groupID}", echo)
+	app.GET("/groups/{groupID}/events/{eventID}", echo)
+	app.GET("/docs/{page}", echo)
+	app.GET("/docs/*", echo)
+	app.GET("/uploads*", echo)
+	return app
+}
+
+func TestRouterMatch(t *testing.T) {
+	app := testRouter()
+	tests := []struct {
+		name    string
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		{"root", "/", "/", map[string]string{}},
+		{"static", "/users/search", "/users/search", map[string]string{}},
+		{"static over param", "/groups/new", "/groups/new", map[string]string{}},
+		{"param", "/users/42", "/users/{userID}", map[string]string{"userID": "42"}},
+		{"param sharing the static prefix", "/groups/newer", "/groups/{groupID}", map[string]string{"groupID": "newer"}},
+		{"backtrack from static to param", "/users/search/posts", "/users/{userID}/posts", map[string]string{"userID": "search"}},
+		{"several params", "/groups/7/events/9", "/groups/{groupID}/events/{eventID}", map[string]string{"groupID": "7", "eventID": "9"}},
+		{"param over catch-all", "/docs/intro", "/docs/{page}", map[string]string{"page": "intro"}},
+		{"backtrack from param to catch-all", "/docs/intro/setup", "/docs/*", map[string]string{"*": "intro/setup"}},
+		{"catch-all", "/uploads/avatars/a.png", "/uploads*", map[string]string{"*": "/avatars/a.png"}},
+		{"empty catch-all", "/uploads", "/uploads*", map[string]string{"*": ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d, want %d", test.path, w.Code, http.StatusOK)
+			}
+			var got struct {
+				Pattern string            `json:"pattern"`
+				Params  map[string]string `json:"params"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Pattern != test.pattern {
+				t.Errorf("GET %s matched %q, want %q", test.path, got.Pattern, test.pattern)
+			}
+			if len(got.Params) != len(test.params) {
+				t.Errorf("GET %s captured %v, want %v", test.path, got.Params, test.params)
+			}
+			for key, value := range test.params {
+				if got.Params[key] != value {
+					t.Errorf("GET %s captured %s = %q, want %q", test.path, key, got.Params[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterErrors(t *testing.T) {
+	app := testRouter()
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+		allow  string
+	}{
+		{"unknown path", http.MethodGet, "/posts", http.StatusNotFound, CodeNotFound, ""},
+		{"partial static", http.MethodGet, "/uploa", http.StatusNotFound, CodeNotFound, ""},
+		{"empty param", http.MethodGet, "/users/", http.StatusNotFound, CodeNotFound, ""},
+		{"trailing segment", http.MethodGet, "/users/42/comments", http.StatusNotFound, CodeNotFound, ""},
+		{"other method", http.MethodPost, "/users/42", http.StatusMethodNotAllowed, CodeMethodNotAllowed, "DELETE, GET, PUT"},
+		{"other method on static", http.MethodDelete, "/users/search", http.StatusMethodNotAllowed, CodeMethodNotAllowed, "GET"},
+		{"other method on catch-all", http.MethodPost, "/uploads/a.png", http.StatusMethodNotAllowed, CodeMethodNotAllowed, "GET"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+			if w.Code != test.status {
+				t.Fatalf("%s %s = %d, want %d", test.method, test.path, w.Code, test.status)
+			}
+			if allow := w.Header().Get("Allow"); allow != test.allow {
+				t.Errorf("%s %s Allow = %q, want %q", test.method, test.path, allow, test.allow)
+			}
+			var got ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Error.Code != test.code {
+				t.Errorf("%s %s code = %q, want %q", test.method, test.path, got.Error.Code, test.code)
+			}
+		})
+	}
+}
+
+func TestRouterPreflight(t *testing.T) {
+	app := testRouter()
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/users/42", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Allow") != "" {
+		t.Errorf("OPTIONS /users/42 = %d with Allow %q, want the first route of the path", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestRouterInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+	}{
+		{"duplicate route", []string{"/users", "/users"}},
+		{"conflicting params", []string{"/users/{userID}", "/users/{id}/posts"}},
+		{"catch-all not last", []string{"/uploads*/a"}},
+		{"unterminated param", []string{"/users/{userID"}},
+		{"empty param", []string{"/users/{}"}},
+		{"param within a segment", []string{"/users/{userID}.json"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %v did not panic", test.patterns)
+				}
+			}()
+			app := New()
+			for _, pattern := range test.patterns {
+				app.GET(pattern, func(*Context) {})
+			}
+		})
+	}
+}
+
+func TestParamUUID(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name   string
+		params Params
+		want   uuid.UUID
+		err    string
+	}{
+		{"valid", Params{{Key: "userID", Value: id.String()}}, id, ""},
+		{"invalid", Params{{Key: "userID", Value: "42"}}, uuid.Nil, `invalid path parameter "userID"`},
+		{"missing", Params{{Key: "groupID", Value: id.String()}}, uuid.Nil, `missing path parameter "userID"`},
+		{"none", nil, uuid.Nil, `missing path parameter "userID"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Context{params: test.params}
+			got, err := c.ParamUUID("userID")
+			if got != test.want {
+				t.Errorf("ParamUUID() = %v, want %v", got, test.want)
+			}
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("ParamUUID() failed: %v", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("ParamUUID() error = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
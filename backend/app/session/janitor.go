@@ -0,0 +1,105 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultJanitorInterval is how often the janitor purges expired sessions unless told otherwise.
+const DefaultJanitorInterval = time.Minute
+
+// janitor is the goroutine purging expired sessions, and how to stop it.
+type janitor struct {
+	cancel context.CancelFunc // Stops the goroutine, interrupting a purge in progress
+	done   chan struct{}      // Closed once the goroutine returned
+}
+
+// StartJanitor starts purging the expired sessions from the store every interval,
+// or every DefaultJanitorInterval if it is not positive, and notifying their expiry
+// through Notif. It does nothing if the janitor is already running.
+func (s *session) StartJanitor(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.janitor != nil {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &janitor{cancel: cancel, done: make(chan struct{})}
+	s.janitor = j
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.purge(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// StopJanitor stops the janitor and waits for it to return, at most until ctx is
+// done. It matches the signature of shutdown hooks.
+func (s *session) StopJanitor(ctx context.Context) error {
+	s.mu.Lock()
+	j := s.janitor
+	s.janitor = nil
+	s.mu.Unlock()
+	if j == nil {
+		return nil
+	}
+
+	j.cancel()
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Purged returns the number of expired sessions the janitor removed so far.
+func (s *session) Purged() uint64 {
+	return s.purged.Load()
+}
+
+// purge removes the expired sessions from the store, if it can, and notifies the
+// users left without a session.
+func (s *session) purge(ctx context.Context) {
+	store := s.getStore()
+	purger, ok := store.(Purger)
+	if !ok {
+		return // The store cannot purge sessions
+	}
+	purged, err := purger.Purge(ctx)
+	s.purged.Add(uint64(len(purged)))
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("purging expired sessions failed", "error", err)
+		}
+		return
+	}
+
+	notified := make(map[string]bool)
+	for _, data := range purged {
+		if notified[data.UserID.String()] {
+			continue
+		}
+		notified[data.UserID.String()] = true
+		// Notify about session expiration, unless the user has other sessions
+		if list, err := store.ListByUser(ctx, data.UserID); err == nil && len(list) == 0 {
+			Notif.Store(data.UserID, false)
+		}
+	}
+	if len(purged) > 0 {
+		slog.Debug("purged expired sessions", "count", len(purged))
+	}
+}
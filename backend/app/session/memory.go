@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on restart
+// and not shared between replicas; it suits development and tests.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]Data
+	byUser   map[uuid.UUID]map[string]struct{} // Session IDs of each user
+}
+
+var (
+	_ Store   = (*MemoryStore)(nil)
+	_ Counter = (*MemoryStore)(nil)
+	_ Purger  = (*MemoryStore)(nil)
+)
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]Data),
+		byUser:   make(map[uuid.UUID]map[string]struct{}),
+	}
+}
+
+// Get returns the session with the given ID, or ErrNotFound.
+func (m *MemoryStore) Get(_ context.Context, id string) (Data, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.sessions[id]
+	if !ok || data.Expired(time.Now()) {
+		return Data{}, ErrNotFound
+	}
+	return data, nil
+}
+
+// Set creates or replaces a session.
+func (m *MemoryStore) Set(_ context.Context, data Data) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.sessions[data.ID]; ok {
+		m.unindex(old)
+	}
+	m.sessions[data.ID] = data
+	ids, ok := m.byUser[data.UserID]
+	if !ok {
+		ids = make(map[string]struct{})
+		m.byUser[data.UserID] = ids
+	}
+	ids[data.ID] = struct{}{}
+	return nil
+}
+
+// Delete removes a session.
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if data, ok := m.sessions[id]; ok {
+		delete(m.sessions, id)
+		m.unindex(data)
+	}
+	return nil
+}
+
+// Touch records the session was used and moves its expiry.
+func (m *MemoryStore) Touch(_ context.Context, id string, seen, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.sessions[id]
+	if !ok || data.Expired(time.Now()) {
+		return ErrNotFound
+	}
+	data.LastSeen, data.ExpiresAt = seen, expiresAt
+	m.sessions[id] = data
+	return nil
+}
+
+// ListByUser returns the sessions of a user.
+func (m *MemoryStore) ListByUser(_ context.Context, userID uuid.UUID) ([]Data, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	var list []Data
+	for id := range m.byUser[userID] {
+		if data := m.sessions[id]; !data.Expired(now) {
+			list = append(list, data)
+		}
+	}
+	return list, nil
+}
+
+// DeleteByUser removes every session of a user.
+func (m *MemoryStore) DeleteByUser(_ context.Context, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range m.byUser[userID] {
+		delete(m.sessions, id)
+	}
+	delete(m.byUser, userID)
+	return nil
+}
+
+// Count returns the number of sessions that have not expired.
+func (m *MemoryStore) Count(_ context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	count := 0
+	for _, data := range m.sessions {
+		if !data.Expired(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Purge removes the expired sessions and returns them.
+func (m *MemoryStore) Purge(_ context.Context) ([]Data, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var purged []Data
+	for id, data := range m.sessions {
+		if data.Expired(now) {
+			delete(m.sessions, id)
+			m.unindex(data)
+			purged = append(purged, data)
+		}
+	}
+	return purged, nil
+}
+
+// unindex removes a session from the index of its user. m.mu must be held.
+func (m *MemoryStore) unindex(data Data) {
+	ids := m.byUser[data.UserID]
+	delete(ids, data.ID)
+	if len(ids) == 0 {
+		delete(m.byUser, data.UserID)
+	}
+}
@@ -0,0 +1,511 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RedisConfig defines how RedisStore reaches the server.
+type RedisConfig struct {
+	Addr     string // Address of the server, defaults to "localhost:6379".
+	Password string // Password sent with AUTH, if set.
+	DB       int    // Database selected with SELECT, if not 0.
+	Prefix   string // Prefix of every key, defaults to "session:".
+	MaxIdle  int    // Idle connections kept for reuse, defaults to 4.
+}
+
+// RedisStore keeps sessions in Redis, or any server speaking its protocol, so
+// that several replicas share them. Sessions expire on the server on their own.
+//
+// Every session is a key holding its JSON encoding with a TTL. The IDs of the
+// sessions of a user are kept in a sorted set scored by expiry, to list them
+// without scanning the keyspace. So are all sessions, as "<user ID>/<ID>", to
+// count them and to tell whose sessions expired when purging.
+type RedisStore struct {
+	config RedisConfig
+	dialer net.Dialer
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+var (
+	_ Store   = (*RedisStore)(nil)
+	_ Counter = (*RedisStore)(nil)
+	_ Purger  = (*RedisStore)(nil)
+)
+
+// NewRedisStore returns a store using the server described by config.
+// Connections are opened when needed; use Ping to check the server is reachable.
+func NewRedisStore(config RedisConfig) *RedisStore {
+	if config.Addr == "" {
+		config.Addr = "localhost:6379"
+	}
+	if config.Prefix == "" {
+		config.Prefix = "session:"
+	}
+	if config.MaxIdle <= 0 {
+		config.MaxIdle = 4
+	}
+	return &RedisStore{config: config}
+}
+
+func (r *RedisStore) sessionKey(id string) string {
+	return r.config.Prefix + id
+}
+
+func (r *RedisStore) userKey(userID uuid.UUID) string {
+	return r.config.Prefix + "user:" + userID.String()
+}
+
+func (r *RedisStore) allKey() string {
+	return r.config.Prefix + "all"
+}
+
+// allMember is the member standing for a session in the set of all sessions.
+func allMember(userID uuid.UUID, id string) string {
+	return userID.String() + "/" + id
+}
+
+// Ping checks the server answers.
+func (r *RedisStore) Ping(ctx context.Context) error {
+	_, err := r.do(ctx, "PING")
+	return err
+}
+
+// Get returns the session with the given ID, or ErrNotFound.
+func (r *RedisStore) Get(ctx context.Context, id string) (Data, error) {
+	reply, err := r.do(ctx, "GET", r.sessionKey(id))
+	if err != nil {
+		return Data{}, err
+	}
+	return decodeSession(reply)
+}
+
+// Set creates or replaces a session.
+func (r *RedisStore) Set(ctx context.Context, data Data) error {
+	ttl := time.Until(data.ExpiresAt).Milliseconds()
+	if ttl <= 0 {
+		return r.Delete(ctx, data.ID)
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding session: %v", err)
+	}
+	score := strconv.FormatInt(data.ExpiresAt.UnixMilli(), 10)
+	_, err = r.transaction(ctx,
+		[]string{"SET", r.sessionKey(data.ID), string(value), "PX", strconv.FormatInt(ttl, 10)},
+		[]string{"ZADD", r.userKey(data.UserID), score, data.ID},
+		[]string{"ZADD", r.allKey(), score, allMember(data.UserID, data.ID)},
+	)
+	return err
+}
+
+// Delete removes a session.
+func (r *RedisStore) Delete(ctx context.Context, id string) error {
+	data, err := r.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil // Expired sessions are left for Purge
+	}
+	if err != nil {
+		return err
+	}
+	_, err = r.transaction(ctx,
+		[]string{"DEL", r.sessionKey(id)},
+		[]string{"ZREM", r.userKey(data.UserID), id},
+		[]string{"ZREM", r.allKey(), allMember(data.UserID, id)},
+	)
+	return err
+}
+
+// Touch records the session was used and moves its expiry.
+func (r *RedisStore) Touch(ctx context.Context, id string, seen, expiresAt time.Time) error {
+	data, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	data.LastSeen, data.ExpiresAt = seen, expiresAt
+	return r.Set(ctx, data)
+}
+
+// ListByUser returns the sessions of a user.
+func (r *RedisStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]Data, error) {
+	ids, err := r.liveIDs(ctx, r.userKey(userID))
+	if err != nil {
+		return nil, err
+	}
+	var list []Data
+	for _, id := range ids {
+		data, err := r.Get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, data)
+	}
+	return list, nil
+}
+
+// DeleteByUser removes every session of a user.
+func (r *RedisStore) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	reply, err := r.do(ctx, "ZRANGEBYSCORE", r.userKey(userID), "-inf", "+inf")
+	if err != nil {
+		return err
+	}
+	ids, err := replyStrings(reply)
+	if err != nil {
+		return err
+	}
+	commands := [][]string{{"DEL", r.userKey(userID)}}
+	for _, id := range ids {
+		commands = append(commands, []string{"DEL", r.sessionKey(id)}, []string{"ZREM", r.allKey(), allMember(userID, id)})
+	}
+	_, err = r.transaction(ctx, commands...)
+	return err
+}
+
+// Count returns the number of sessions that have not expired.
+func (r *RedisStore) Count(ctx context.Context) (int, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	reply, err := r.do(ctx, "ZCOUNT", r.allKey(), "("+now, "+inf")
+	if err != nil {
+		return 0, err
+	}
+	count, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected reply to ZCOUNT: %v", reply)
+	}
+	return int(count), nil
+}
+
+// Purge forgets the expired sessions and returns them. The server already
+// dropped their keys, so only their ID and user are known.
+func (r *RedisStore) Purge(ctx context.Context) ([]Data, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	replies, err := r.transaction(ctx,
+		[]string{"ZRANGEBYSCORE", r.allKey(), "-inf", now},
+		[]string{"ZREMRANGEBYSCORE", r.allKey(), "-inf", now},
+	)
+	if err != nil {
+		return nil, err
+	}
+	members, err := replyStrings(replies[0])
+	if err != nil {
+		return nil, err
+	}
+	var purged []Data
+	var commands [][]string
+	for _, member := range members {
+		user, id, _ := strings.Cut(member, "/")
+		userID, err := uuid.Parse(user)
+		if err != nil {
+			continue
+		}
+		purged = append(purged, Data{ID: id, UserID: userID})
+		commands = append(commands, []string{"ZREM", r.userKey(userID), id})
+	}
+	if len(commands) > 0 {
+		if _, err := r.transaction(ctx, commands...); err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// liveIDs drops the expired members of the sorted set at key and returns the others.
+func (r *RedisStore) liveIDs(ctx context.Context, key string) ([]string, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	replies, err := r.transaction(ctx,
+		[]string{"ZREMRANGEBYSCORE", key, "-inf", now},
+		[]string{"ZRANGEBYSCORE", key, "(" + now, "+inf"},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return replyStrings(replies[1])
+}
+
+// decodeSession turns the reply to GET into a session.
+func decodeSession(reply any) (Data, error) {
+	if reply == nil {
+		return Data{}, ErrNotFound
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return Data{}, fmt.Errorf("unexpected reply to GET: %v", reply)
+	}
+	var data Data
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return Data{}, fmt.Errorf("error decoding session: %v", err)
+	}
+	if data.Expired(time.Now()) {
+		return Data{}, ErrNotFound
+	}
+	return data, nil
+}
+
+// replyStrings converts an array reply to strings.
+func replyStrings(reply any) ([]string, error) {
+	items, ok := reply.([]any)
+	if !ok && reply != nil {
+		return nil, fmt.Errorf("unexpected reply: %v", reply)
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected reply item: %v", item)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// do sends a single command and returns its reply.
+func (r *RedisStore) do(ctx context.Context, args ...string) (any, error) {
+	var reply any
+	err := r.withConn(ctx, func(c *redisConn) error {
+		if err := c.write(args); err != nil {
+			return err
+		}
+		var err error
+		reply, err = c.read()
+		return err
+	})
+	return reply, err
+}
+
+// transaction runs the commands in a MULTI/EXEC block and returns their replies.
+func (r *RedisStore) transaction(ctx context.Context, commands ...[]string) ([]any, error) {
+	var replies []any
+	err := r.withConn(ctx, func(c *redisConn) error {
+		all := append([][]string{{"MULTI"}}, commands...)
+		all = append(all, []string{"EXEC"})
+		for _, args := range all {
+			if err := c.write(args); err != nil {
+				return err
+			}
+		}
+		// MULTI and every queued command answer +OK/+QUEUED, then EXEC the replies.
+		// A command refused while queuing makes EXEC fail, reported below.
+		for range len(all) - 1 {
+			if _, err := c.readReply(); err != nil {
+				return err
+			}
+		}
+		reply, err := c.read()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if replies, ok = reply.([]any); !ok {
+			return fmt.Errorf("transaction aborted: %v", reply)
+		}
+		for _, reply := range replies {
+			if err, ok := reply.(redisError); ok {
+				return err
+			}
+		}
+		return nil
+	})
+	return replies, err
+}
+
+// withConn runs f on a pooled connection, bounded by the deadline of ctx.
+// Connections that fail at the protocol or network level are discarded;
+// error replies from the server leave them usable.
+func (r *RedisStore) withConn(ctx context.Context, f func(c *redisConn) error) error {
+	c, err := r.get(ctx)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+
+	err = f(c)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.conn.Close()
+		return fmt.Errorf("redis: %v", err)
+	}
+	r.put(c)
+	if err != nil {
+		return fmt.Errorf("redis: %v", err)
+	}
+	return nil
+}
+
+// get returns an idle connection or dials a new one.
+func (r *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if n := len(r.idle); n > 0 {
+		c := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return c, nil
+	}
+	r.mu.Unlock()
+
+	conn, err := r.dialer.DialContext(ctx, "tcp", r.config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %v", err)
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if r.config.Password != "" {
+		if err := c.command("AUTH", r.config.Password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis: %v", err)
+		}
+	}
+	if r.config.DB != 0 {
+		if err := c.command("SELECT", strconv.Itoa(r.config.DB)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// put returns a connection to the pool, or closes it if the pool is full.
+func (r *RedisStore) put(c *redisConn) {
+	c.conn.SetDeadline(time.Time{})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.idle) >= r.config.MaxIdle {
+		c.conn.Close()
+		return
+	}
+	r.idle = append(r.idle, c)
+}
+
+// Close closes the idle connections.
+func (r *RedisStore) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, c := range r.idle {
+		errs = append(errs, c.conn.Close())
+	}
+	r.idle = nil
+	return errors.Join(errs...)
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisConn speaks RESP, the Redis protocol, over a connection.
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// command sends a command and fails unless the server accepts it.
+func (c *redisConn) command(args ...string) error {
+	if err := c.write(args); err != nil {
+		return err
+	}
+	reply, err := c.read()
+	if err != nil {
+		return err
+	}
+	if err, ok := reply.(redisError); ok {
+		return err
+	}
+	return nil
+}
+
+// write sends a command as an array of bulk strings.
+func (c *redisConn) write(args []string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return c.w.Flush()
+}
+
+// read reads a reply: a string for simple and bulk strings, an int64 for
+// integers, a []any for arrays, nil for null replies and a redisError for errors.
+// Error replies are returned as the reply, not as err, unless at the top level.
+func (c *redisConn) read() (any, error) {
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if replyErr, ok := reply.(redisError); ok {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
+
+// readLine reads a line without its CRLF terminator.
+func (c *redisConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
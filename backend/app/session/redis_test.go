@@ -0,0 +1,204 @@
+package session_test
+
+import (
+	"Social_Network/app/session"
+	"Social_Network/app/session/redistest"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newRedisStore starts a fake server and returns it along with a store using it.
+func newRedisStore(t *testing.T, config session.RedisConfig) (*redistest.Server, *session.RedisStore) {
+	t.Helper()
+	server, err := redistest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	config.Addr = server.Addr
+	store := session.NewRedisStore(config)
+	t.Cleanup(func() { store.Close() })
+	return server, store
+}
+
+// newSession returns a session of userID expiring after ttl.
+func newSession(userID uuid.UUID, ttl time.Duration) session.Data {
+	now := time.Now().Truncate(time.Millisecond)
+	return session.Data{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		UserAgent: "test",
+		IP:        "127.0.0.1",
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+func TestRedisStoreSetGet(t *testing.T) {
+	server, store := newRedisStore(t, session.RedisConfig{})
+	ctx := context.Background()
+	data := newSession(uuid.New(), time.Hour)
+
+	if err := store.Set(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.ExpiresAt.Equal(data.ExpiresAt) || got.ID != data.ID || got.UserID != data.UserID || got.UserAgent != data.UserAgent {
+		t.Errorf("Get() = %+v, want %+v", got, data)
+	}
+	want := []string{"session:" + data.ID, "session:all", "session:user:" + data.UserID.String()}
+	sort.Strings(want)
+	if keys := server.Keys(); !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	if err := store.Delete(ctx, data.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, data.ID); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Get() after Delete = %v, want ErrNotFound", err)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("keys left after Delete: %v", keys)
+	}
+}
+
+func TestRedisStoreExpiry(t *testing.T) {
+	server, store := newRedisStore(t, session.RedisConfig{})
+	ctx := context.Background()
+	data := newSession(uuid.New(), time.Hour)
+	if err := store.Set(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Touching moves the expiry
+	seen := time.Now()
+	if err := store.Touch(ctx, data.ID, seen, data.ExpiresAt.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(90 * time.Minute)
+	if _, err := store.Get(ctx, data.ID); err != nil {
+		t.Fatalf("Get() after Touch = %v", err)
+	}
+
+	server.FastForward(time.Hour)
+	if _, err := store.Get(ctx, data.ID); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Get() after expiry = %v, want ErrNotFound", err)
+	}
+	if err := store.Touch(ctx, data.ID, seen, seen.Add(time.Hour)); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Touch() after expiry = %v, want ErrNotFound", err)
+	}
+	list, err := store.ListByUser(ctx, data.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Errorf("ListByUser() after expiry = %v", list)
+	}
+}
+
+func TestRedisStoreByUser(t *testing.T) {
+	_, store := newRedisStore(t, session.RedisConfig{Prefix: "test:"})
+	ctx := context.Background()
+	user, other := uuid.New(), uuid.New()
+	first, second := newSession(user, time.Hour), newSession(user, 2*time.Hour)
+	kept := newSession(other, time.Hour)
+	for _, data := range []session.Data{first, second, kept} {
+		if err := store.Set(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := store.ListByUser(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+		t.Errorf("ListByUser() = %+v, want the two sessions of the user by expiry", list)
+	}
+	if count, err := store.Count(ctx); err != nil || count != 3 {
+		t.Errorf("Count() = %d, %v, want 3", count, err)
+	}
+
+	if err := store.DeleteByUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []session.Data{first, second} {
+		if _, err := store.Get(ctx, data.ID); !errors.Is(err, session.ErrNotFound) {
+			t.Errorf("Get(%s) after DeleteByUser = %v, want ErrNotFound", data.ID, err)
+		}
+	}
+	if _, err := store.Get(ctx, kept.ID); err != nil {
+		t.Errorf("session of another user: %v", err)
+	}
+	if count, err := store.Count(ctx); err != nil || count != 1 {
+		t.Errorf("Count() after DeleteByUser = %d, %v, want 1", count, err)
+	}
+}
+
+func TestRedisStorePurge(t *testing.T) {
+	server, store := newRedisStore(t, session.RedisConfig{})
+	ctx := context.Background()
+	expiring, kept := newSession(uuid.New(), 50*time.Millisecond), newSession(uuid.New(), time.Hour)
+	for _, data := range []session.Data{expiring, kept} {
+		if err := store.Set(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	purged, err := store.Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].ID != expiring.ID || purged[0].UserID != expiring.UserID {
+		t.Errorf("Purge() = %+v, want the expired session", purged)
+	}
+	want := []string{"session:" + kept.ID, "session:all", "session:user:" + kept.UserID.String()}
+	sort.Strings(want)
+	if keys := server.Keys(); !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys after Purge = %v, want %v", keys, want)
+	}
+	if purged, err := store.Purge(ctx); err != nil || len(purged) != 0 {
+		t.Errorf("second Purge() = %v, %v, want nothing", purged, err)
+	}
+}
+
+func TestRedisStoreAuth(t *testing.T) {
+	server, err := redistest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.RequireAuth("secret")
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		password string
+		ok       bool
+	}{
+		{"secret", true},
+		{"wrong", false},
+		{"", false},
+	} {
+		store := session.NewRedisStore(session.RedisConfig{Addr: server.Addr, Password: test.password})
+		err := store.Ping(ctx)
+		if test.ok && err != nil {
+			t.Errorf("Ping() with password %q = %v", test.password, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("Ping() with password %q succeeded", test.password)
+		}
+		store.Close()
+	}
+}
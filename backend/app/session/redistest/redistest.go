@@ -0,0 +1,527 @@
+// Package redistest provides an in-process server speaking the Redis protocol,
+// for testing session.RedisStore without a real Redis. Like net/http/httptest,
+// it listens on a local port: point the store at Server.Addr.
+//
+// It implements the commands the store uses, with key expiry:
+// PING, AUTH, SELECT, GET, SET (EX, PX), DEL, EXISTS, PEXPIRE, PTTL, ZADD, ZREM,
+// ZCARD, ZCOUNT, ZRANGEBYSCORE, ZREMRANGEBYSCORE, MULTI, EXEC, DISCARD and FLUSHALL.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake Redis server.
+type Server struct {
+	// Addr is the address the server listens on, e.g. "127.0.0.1:49152".
+	Addr     string
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	password string
+	conns    map[net.Conn]struct{}
+	strings  map[string]string
+	zsets    map[string]map[string]float64
+	expires  map[string]time.Time
+}
+
+// NewServer starts a server on a free local port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     l.Addr().String(),
+		listener: l,
+		conns:    make(map[net.Conn]struct{}),
+		strings:  make(map[string]string),
+		zsets:    make(map[string]map[string]float64),
+		expires:  make(map[string]time.Time),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and closes every client connection.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// RequireAuth makes the next connections authenticate with password through AUTH.
+func (s *Server) RequireAuth(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// Keys returns the live keys, sorted, to inspect what a test stored.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.strings {
+		if s.alive(key) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range s.zsets {
+		if s.alive(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FastForward expires the keys whose TTL would run out within d, as if d had passed.
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, at := range s.expires {
+		s.expires[key] = at.Add(-d)
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+// client is the state of a connection.
+type client struct {
+	password string
+	authed   bool
+	multi    bool
+	queue    [][]string
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	s.mu.Lock()
+	cl := &client{password: s.password, authed: s.password == ""}
+	s.mu.Unlock()
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				writeReply(w, fmt.Errorf("ERR protocol error: %v", err))
+				w.Flush()
+			}
+			return
+		}
+		writeReply(w, s.dispatch(cl, args))
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// status is a simple string reply, e.g. OK.
+type status string
+
+// dispatch handles the transaction commands and queues or runs the others.
+func (s *Server) dispatch(cl *client, args []string) any {
+	name := strings.ToUpper(args[0])
+	if name == "AUTH" {
+		if len(args) != 2 || args[1] != cl.password {
+			return errors.New("WRONGPASS invalid password")
+		}
+		cl.authed = true
+		return status("OK")
+	}
+	if !cl.authed {
+		return errors.New("NOAUTH Authentication required.")
+	}
+
+	switch name {
+	case "MULTI":
+		if cl.multi {
+			return errors.New("ERR MULTI calls can not be nested")
+		}
+		cl.multi, cl.queue = true, nil
+		return status("OK")
+	case "DISCARD":
+		if !cl.multi {
+			return errors.New("ERR DISCARD without MULTI")
+		}
+		cl.multi, cl.queue = false, nil
+		return status("OK")
+	case "EXEC":
+		if !cl.multi {
+			return errors.New("ERR EXEC without MULTI")
+		}
+		queue := cl.queue
+		cl.multi, cl.queue = false, nil
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		replies := make([]any, len(queue))
+		for i, args := range queue {
+			replies[i] = s.exec(args)
+		}
+		return replies
+	}
+
+	if cl.multi {
+		cl.queue = append(cl.queue, args)
+		return status("QUEUED")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exec(args)
+}
+
+// exec runs a data command. s.mu must be held.
+func (s *Server) exec(args []string) any {
+	name := strings.ToUpper(args[0])
+	argc := len(args) - 1
+	wrongArgs := fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+
+	switch name {
+	case "PING":
+		return status("PONG")
+	case "SELECT", "FLUSHALL":
+		if name == "FLUSHALL" {
+			s.strings = make(map[string]string)
+			s.zsets = make(map[string]map[string]float64)
+			s.expires = make(map[string]time.Time)
+		}
+		return status("OK")
+	case "GET":
+		if argc != 1 {
+			return wrongArgs
+		}
+		if _, ok := s.zsets[args[1]]; ok && s.alive(args[1]) {
+			return wrongType()
+		}
+		if v, ok := s.strings[args[1]]; ok && s.alive(args[1]) {
+			return v
+		}
+		return nil
+	case "SET":
+		if argc < 2 {
+			return wrongArgs
+		}
+		var ttl time.Duration
+		for i := 3; i < len(args); i += 2 {
+			if i+1 >= len(args) {
+				return errors.New("ERR syntax error")
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return errors.New("ERR invalid expire time in 'set' command")
+			}
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				ttl = time.Duration(n) * time.Second
+			case "PX":
+				ttl = time.Duration(n) * time.Millisecond
+			default:
+				return errors.New("ERR syntax error")
+			}
+		}
+		s.remove(args[1])
+		s.strings[args[1]] = args[2]
+		if ttl > 0 {
+			s.expires[args[1]] = time.Now().Add(ttl)
+		}
+		return status("OK")
+	case "DEL", "EXISTS":
+		if argc < 1 {
+			return wrongArgs
+		}
+		var n int64
+		for _, key := range args[1:] {
+			if s.alive(key) && s.exists(key) {
+				n++
+				if name == "DEL" {
+					s.remove(key)
+				}
+			}
+		}
+		return n
+	case "PEXPIRE":
+		if argc != 2 {
+			return wrongArgs
+		}
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return notInteger()
+		}
+		if !s.alive(args[1]) || !s.exists(args[1]) {
+			return int64(0)
+		}
+		s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return int64(1)
+	case "PTTL":
+		if argc != 1 {
+			return wrongArgs
+		}
+		if !s.alive(args[1]) || !s.exists(args[1]) {
+			return int64(-2)
+		}
+		at, ok := s.expires[args[1]]
+		if !ok {
+			return int64(-1)
+		}
+		return time.Until(at).Milliseconds()
+	case "ZADD":
+		if argc < 3 || argc%2 != 1 {
+			return wrongArgs
+		}
+		zset, err := s.zset(args[1], true)
+		if err != nil {
+			return err
+		}
+		var added int64
+		for i := 2; i < len(args); i += 2 {
+			score, err := parseScore(args[i])
+			if err != nil {
+				return errors.New("ERR value is not a valid float")
+			}
+			if _, ok := zset[args[i+1]]; !ok {
+				added++
+			}
+			zset[args[i+1]] = score
+		}
+		return added
+	case "ZREM":
+		if argc < 2 {
+			return wrongArgs
+		}
+		zset, err := s.zset(args[1], false)
+		if err != nil {
+			return err
+		}
+		var removed int64
+		for _, member := range args[2:] {
+			if _, ok := zset[member]; ok {
+				delete(zset, member)
+				removed++
+			}
+		}
+		s.dropEmpty(args[1])
+		return removed
+	case "ZCARD":
+		if argc != 1 {
+			return wrongArgs
+		}
+		zset, err := s.zset(args[1], false)
+		if err != nil {
+			return err
+		}
+		return int64(len(zset))
+	case "ZCOUNT", "ZRANGEBYSCORE", "ZREMRANGEBYSCORE":
+		if argc != 3 {
+			return wrongArgs
+		}
+		min, minExcl, err1 := parseBound(args[2])
+		max, maxExcl, err2 := parseBound(args[3])
+		if err1 != nil || err2 != nil {
+			return errors.New("ERR min or max is not a float")
+		}
+		zset, err := s.zset(args[1], false)
+		if err != nil {
+			return err
+		}
+		var members []string
+		for member, score := range zset {
+			if (score > min || !minExcl && score == min) && (score < max || !maxExcl && score == max) {
+				members = append(members, member)
+			}
+		}
+		if name == "ZCOUNT" {
+			return int64(len(members))
+		}
+		if name == "ZREMRANGEBYSCORE" {
+			for _, member := range members {
+				delete(zset, member)
+			}
+			s.dropEmpty(args[1])
+			return int64(len(members))
+		}
+		sort.Slice(members, func(i, j int) bool {
+			if zset[members[i]] != zset[members[j]] {
+				return zset[members[i]] < zset[members[j]]
+			}
+			return members[i] < members[j]
+		})
+		replies := make([]any, len(members))
+		for i, member := range members {
+			replies[i] = member
+		}
+		return replies
+	}
+	return fmt.Errorf("ERR unknown command '%s'", args[0])
+}
+
+// alive reports whether key has not expired, dropping it if it has. s.mu must be held.
+func (s *Server) alive(key string) bool {
+	if at, ok := s.expires[key]; ok && !time.Now().Before(at) {
+		s.remove(key)
+		return false
+	}
+	return true
+}
+
+func (s *Server) exists(key string) bool {
+	_, isString := s.strings[key]
+	_, isZset := s.zsets[key]
+	return isString || isZset
+}
+
+func (s *Server) remove(key string) {
+	delete(s.strings, key)
+	delete(s.zsets, key)
+	delete(s.expires, key)
+}
+
+// zset returns the sorted set at key, creating it if asked; a missing set is empty.
+func (s *Server) zset(key string, create bool) (map[string]float64, error) {
+	if !s.alive(key) {
+		return nil, nil
+	}
+	if _, ok := s.strings[key]; ok {
+		return nil, wrongType()
+	}
+	zset, ok := s.zsets[key]
+	if !ok && create {
+		zset = make(map[string]float64)
+		s.zsets[key] = zset
+	}
+	return zset, nil
+}
+
+// dropEmpty deletes the sorted set at key once it has no members, like Redis.
+func (s *Server) dropEmpty(key string) {
+	if zset, ok := s.zsets[key]; ok && len(zset) == 0 {
+		s.remove(key)
+	}
+}
+
+func wrongType() error {
+	return errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func notInteger() error {
+	return errors.New("ERR value is not an integer or out of range")
+}
+
+func parseScore(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "+inf", "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseBound parses a score range bound, exclusive when prefixed with '('.
+func parseBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	v, err := parseScore(strings.TrimPrefix(s, "("))
+	return v, exclusive, err
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected array, got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid array length %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// writeReply encodes a reply: status, error, int64, string (bulk), nil or []any.
+func writeReply(w *bufio.Writer, reply any) {
+	switch v := reply.(type) {
+	case status:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case error:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case nil:
+		w.WriteString("$-1\r\n")
+	case []any:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	}
+}
@@ -2,11 +2,15 @@ package session
 
 import (
 	socialnetwork "Social_Network/app"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,35 +26,46 @@ type Config struct {
 	Value      string        // Value for the session cookie
 	Path       string        // Path where the cookie is valid
 	Domain     string        // Domain for the session cookie
-	Expires    time.Time     // Expiration time for the session cookie
 	RawExpires string        // Raw expiration value used during cookie reading
-	MaxAge     int           // Max age for the session in seconds
+	MaxAge     int           // Max age for the session in seconds, used if AbsoluteTimeout is not set
 	Secure     bool          // Whether the cookie is secure (HTTPS only)
 	HttpOnly   bool          // If true, the cookie is inaccessible via JavaScript
 	SameSite   http.SameSite // SameSite setting for cross-site cookie handling
 	Raw        string        // Unparsed raw cookie string
 	Unparsed   []string      // Additional unparsed cookie attributes
+
+	// AbsoluteTimeout is how long a session lasts, however active it is.
+	AbsoluteTimeout time.Duration
+	// IdleTimeout is how long a session lasts unused; each use renews it, up to
+	// the absolute timeout. Zero disables it.
+	IdleTimeout time.Duration
+
+	// ClientIP returns the IP address recorded with new sessions, by default the
+	// one of the connection.
+	ClientIP func(r *http.Request) string
 }
 
+// lastSeenInterval is how stale the last-seen time of a session may get before
+// a request updates it, to spare the store a write on every request.
+const lastSeenInterval = time.Minute
+
+// maxUserAgent is the length user-agents are truncated to before being stored.
+const maxUserAgent = 512
+
 // starter represents the session starter that handles session-related logic.
 type starter struct {
 	session *session               // Reference to the session instance
 	Ctx     *socialnetwork.Context // Context associated with the current session
 }
 
-// session holds session configuration, state, and the database connection.
+// session holds session configuration and the store sessions are kept in.
 type session struct {
-	Config      *Config    // Session configuration
-	database    *sql.DB    // Database connection for session persistence
-	data        *sync.Map  // In-memory data storage for sessions
-	mu          sync.Mutex // Mutex for synchronizing access to session data
-	SessionName string     // Name of the session (e.g., "user_sessions")
-}
-
-// storage represents a session's cookie and ID, used for managing session state.
-type storage struct {
-	cookie *http.Cookie // Session cookie associated with the user
-	id     uuid.UUID    // Unique identifier for the user session
+	Config      *Config       // Session configuration
+	store       Store         // Store the sessions are persisted in
+	janitor     *janitor      // Goroutine purging expired sessions, if started
+	purged      atomic.Uint64 // Number of expired sessions purged
+	mu          sync.Mutex    // Mutex for synchronizing access to the store and janitor
+	SessionName string        // Name of the session (e.g., "user_sessions")
 }
 
 // New initializes and returns a new session instance with default or provided configuration.
@@ -64,264 +79,309 @@ func New(c *Config) *session {
 	if c.MaxAge == 0 {
 		c.MaxAge = 31536000 // Default max age: 1 year
 	}
-	if c.Expires.IsZero() {
-		c.Expires = time.Now().Add(time.Second * time.Duration(c.MaxAge)) // Default expiration
+	if c.AbsoluteTimeout == 0 {
+		c.AbsoluteTimeout = time.Second * time.Duration(c.MaxAge) // Default to the max age
 	}
 	if c.SameSite == 0 {
 		c.SameSite = http.SameSiteNoneMode // Default SameSite value
 	}
+	if c.ClientIP == nil {
+		c.ClientIP = remoteIP // Default to the address of the connection
+	}
 	return &session{
 		Config:      c,
 		SessionName: c.CookieName,
-		database:    nil,
-		data:        &sync.Map{}, // Initialize the session data store
+		store:       NewMemoryStore(), // Keep sessions in memory until a store is configured
 	}
 }
 
-// tmp is a background function that periodically removes expired sessions from memory and the database.
-func (s *session) tmp() {
-	go func() {
-		ticker := time.NewTicker(10 * time.Second) // Ticker for periodic cleanup
-		quit := make(chan struct{})
-		go func() {
-			for {
-				select {
-				case <-ticker.C:
-					s.mu.Lock() // Lock to ensure safe concurrent access
-					if s.database != nil {
-						// Clean expired sessions from the database
-						_, err := s.database.Exec(fmt.Sprintf(
-							`DELETE FROM %s WHERE datetime(expiration_date) <= datetime('now')`,
-							s.SessionName,
-						))
-						if err != nil {
-							s.mu.Unlock()
-							return
-						}
-					}
-
-					// Clean expired sessions from in-memory storage
-					s.data.Range(func(key, value interface{}) bool {
-						val, ok := value.(map[string]interface{})
-						if !ok {
-							return true
-						}
-						userKey := val["key"].(uuid.UUID)
-						storage := val["cookie"].(*storage)
-						if time.Now().After(storage.cookie.Expires) {
-							s.data.Delete(key)          // Remove expired session from memory
-							Notif.Store(userKey, false) // Notify about session expiration
-						}
-						return true
-					})
-					s.mu.Unlock()
-				case <-quit:
-					ticker.Stop() // Stop the ticker when quitting
-					return
-				}
-			}
-		}()
-		time.Sleep(30 * time.Minute) // Run cleanup every 30 minutes
-		close(quit)
-	}()
+// UseStore sets the store sessions are kept in. Sessions of the previous store are not carried over.
+func (s *session) UseStore(store Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
 }
 
-// UseDB associates a database with the session and creates the session table if it doesn't exist.
+// UseDB keeps sessions in the database, creating the session table if it doesn't exist.
 func (s *session) UseDB(db *sql.DB) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.database = db
-	_, err := s.database.Exec(fmt.Sprintf(
-		`CREATE TABLE IF NOT EXISTS %s (id UUID PRIMARY KEY, user_id UUID NOT NULL, expiration_date DATETIME NOT NULL);`,
-		s.SessionName,
-	))
+	store, err := NewSQLiteStore(db, s.SessionName)
 	if err != nil {
 		log.Fatal(err) // Log error if database table creation fails
 	}
+	s.UseStore(store)
+}
+
+// getStore returns the store in use.
+func (s *session) getStore() Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store
+}
+
+// Active returns the number of sessions that have not expired yet, if the store can count them.
+func (s *session) Active() (int, error) {
+	counter, ok := s.getStore().(Counter)
+	if !ok {
+		return 0, fmt.Errorf("session store cannot count sessions")
+	}
+	return counter.Count(context.Background())
 }
 
 // Start initializes a session starter that manages session-related operations.
 func (s *session) Start(c *socialnetwork.Context) *starter {
-	s.tmp() // Start periodic cleanup of expired sessions
 	return &starter{session: s, Ctx: c}
 }
 
-// Set creates a new session for a user, storing it in the database and memory, and setting a cookie.
-func (s *starter) Set(userID uuid.UUID) (string, error) {
-	s.session.mu.Lock() // Lock session data for thread safety
-	defer s.session.mu.Unlock()
+// expiry returns when a session used at now expires: once idle for the idle
+// timeout, but no later than the absolute timeout after it started.
+func (s *session) expiry(data Data, now time.Time) time.Time {
+	expires := data.CreatedAt.Add(s.Config.AbsoluteTimeout)
+	if idle := s.Config.IdleTimeout; idle > 0 && now.Add(idle).Before(expires) {
+		expires = now.Add(idle)
+	}
+	return expires
+}
+
+// renewInterval is how stale the last use of a session may get before a request
+// records it and slides its expiry.
+func (s *session) renewInterval() time.Duration {
+	if idle := s.Config.IdleTimeout; idle > 0 {
+		return min(lastSeenInterval, idle/2)
+	}
+	return lastSeenInterval
+}
+
+// setCookie sends the cookie holding a session ID, expiring with the absolute timeout of the session.
+func (s *starter) setCookie(data Data) {
+	c := s.session.Config
+	expires := data.CreatedAt.Add(c.AbsoluteTimeout)
+	http.SetCookie(s.Ctx.ResponseWriter, &http.Cookie{
+		Name:     c.CookieName,
+		Value:    data.ID,
+		Secure:   c.Secure,
+		Expires:  expires,
+		MaxAge:   max(1, int(time.Until(expires).Seconds())),
+		Path:     c.Path,
+		Domain:   c.Domain,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	})
+}
 
+// Set creates a new session for a user, storing it and setting a cookie.
+// Sessions the user started elsewhere are left alone, but a session ID the
+// request carried is dropped: logging in always issues a new one.
+func (s *starter) Set(userID uuid.UUID) (string, error) {
 	session := s.session
-	db := session.database
-	tmpdata := session.data
-
-	// Generate a new session ID
-	sessionID := uuid.New()
-
-	// Check if a session already exists for the user and delete it if so
-	if db != nil {
-		var existingUserID uuid.UUID
-		err := db.QueryRow(fmt.Sprintf(
-			"SELECT id FROM %s WHERE user_id = $1", session.SessionName,
-		), userID).Scan(&existingUserID)
-
-		if err != sql.ErrNoRows {
-			stmt, err := db.Prepare(fmt.Sprintf(
-				"DELETE FROM %s WHERE id = $1", session.SessionName,
-			))
-			if err != nil {
-				return "", err
-			}
-			_, err = stmt.Exec(existingUserID) // Remove the old session
-			if err != nil {
-				return "", err
-			}
-			tmpdata.Delete(existingUserID.String()) // Remove old session from memory
-		}
+	store := session.getStore()
 
-		// Insert the new session for the user into the database
-		stmt, err := db.Prepare(fmt.Sprintf(
-			"INSERT INTO %s (id, user_id, expiration_date) VALUES ($1, $2, $3)",
-			session.SessionName,
-		))
-		if err != nil {
-			return "", err
-		}
-		_, err = stmt.Exec(sessionID, userID, time.Now().Add(time.Second*time.Duration(session.Config.MaxAge)))
-		if err != nil {
+	if cookie, err := s.Ctx.Request.Cookie(session.Config.CookieName); err == nil {
+		if err := store.Delete(s.Ctx, cookie.Value); err != nil {
 			return "", err
 		}
 	}
 
-	// Create a new session cookie for the user
-	cookie := &http.Cookie{
-		Name:     session.Config.CookieName,
-		Value:    sessionID.String(),
-		Secure:   session.Config.Secure,
-		Expires:  time.Now().Add(time.Second * time.Duration(session.Config.MaxAge)),
-		MaxAge:   session.Config.MaxAge,
-		Path:     session.Config.Path,
-		Domain:   session.Config.Domain,
-		HttpOnly: session.Config.HttpOnly,
-		SameSite: session.Config.SameSite,
+	// Store a new session for the user, recording the client that started it
+	now := time.Now()
+	userAgent := s.Ctx.Request.UserAgent()
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+	data := Data{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        session.Config.ClientIP(s.Ctx.Request),
+		CreatedAt: now,
+		LastSeen:  now,
+	}
+	data.ExpiresAt = session.expiry(data, now)
+	if err := store.Set(s.Ctx, data); err != nil {
+		return "", err
 	}
 
 	// Set the cookie in the response header
-	http.SetCookie(s.Ctx.ResponseWriter, cookie)
+	s.setCookie(data)
 
-	// Store the new session in memory
-	tmpdata.Store(sessionID.String(), map[string]interface{}{
-		"key":    userID,
-		"cookie": &storage{cookie: cookie, id: userID},
-	})
 	Notif.Store(userID, true) // Notify about the new session
-	return sessionID.String(), nil
+	return data.ID, nil
 }
 
-func (s *starter) Get(bearer string) (uuid.UUID, error) {
-	s.session.mu.Lock()
-	defer s.session.mu.Unlock()
+// Rotate replaces the ID of a session by a new one, keeping the rest, and returns
+// it. It is meant for privilege changes, so that an ID leaked before is useless.
+func (s *starter) Rotate(sessionID string) (string, error) {
+	store := s.session.getStore()
+	data, err := store.Get(s.Ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	data.ID = uuid.New().String()
+	data.LastSeen = now
+	data.ExpiresAt = s.session.expiry(data, now)
+	if err := store.Set(s.Ctx, data); err != nil {
+		return "", err
+	}
+	if err := store.Delete(s.Ctx, sessionID); err != nil {
+		return "", err
+	}
+	s.setCookie(data)
+	return data.ID, nil
+}
 
+// Current returns the session named by the bearer token, or by the cookie if there
+// is no bearer token, and records it was used, which renews it if it has an idle timeout.
+// A bearer token takes precedence so that requests sending one are authenticated
+// by it alone, which is what exempts them from CSRF checks.
+func (s *starter) Current(bearer string) (Data, error) {
 	session := s.session
 	c := session.Config
-	db := session.database
-	tmpdata := session.data
 
-	// Retrieve the cookie
-	cookie, err := s.Ctx.Request.Cookie(c.CookieName)
-	if err != nil {
-		// Fallback to the bearer token if the cookie is not found
-		if bearer != "" {
-			cookie = &http.Cookie{
-				Name:  c.CookieName,
-				Value: bearer,
-			}
-		} else {
-			return uuid.Nil, fmt.Errorf("error retrieving cookie: %v", err)
+	// Retrieve the session ID from the bearer token, or else from the cookie
+	sessionID := bearer
+	if sessionID == "" {
+		cookie, err := s.Ctx.Request.Cookie(c.CookieName)
+		if err != nil {
+			return Data{}, fmt.Errorf("error retrieving cookie: %v", err)
 		}
+		sessionID = cookie.Value
 	}
 
-	// Get the session ID from the cookie
-	sessionID := cookie.Value
-
-	// Check if the session exists in memory
-	value, ok := tmpdata.Load(sessionID)
-	if ok {
-		val := value.(map[string]interface{})
-		userKey := val["key"].(uuid.UUID)
-		storage := val["cookie"].(*storage)
+	store := session.getStore()
+	data, err := store.Get(s.Ctx, sessionID)
+	if err != nil {
+		return Data{}, err
+	}
 
-		// Check if the session is expired
-		expirationDate := storage.cookie.Expires
-		if time.Now().After(expirationDate) {
-			tmpdata.Delete(sessionID)
-			Notif.Store(userKey, false) // Notify that the session has expired
-			return uuid.Nil, fmt.Errorf("session expired")
+	// Record the activity and slide the expiry, once in a while
+	if now := time.Now(); now.Sub(data.LastSeen) >= session.renewInterval() {
+		expires := session.expiry(data, now)
+		if err := store.Touch(s.Ctx, data.ID, now, expires); err != nil {
+			s.Ctx.Logger().Warn("renewing session failed", "error", err)
 		} else {
-			Notif.Store(userKey, true)
-			return storage.id, nil
+			data.LastSeen, data.ExpiresAt = now, expires
 		}
 	}
+	Notif.Store(data.UserID, true)
+	return data, nil
+}
 
-	// If the session is not in memory, check the database
-	if db != nil {
-		var userID uuid.UUID
-		var expirationDate time.Time
-		err = db.QueryRow(fmt.Sprintf("SELECT user_id, expiration_date FROM %s WHERE id = $1", session.SessionName), sessionID).Scan(&userID, &expirationDate)
-		if err != nil {
-			return uuid.Nil, fmt.Errorf("error retrieving session from database: %v", err)
-		}
-
-		// Check if the session is expired
-		if time.Now().After(expirationDate) {
-			Notif.Store(userID, false)
-			return uuid.Nil, fmt.Errorf("session expired")
-		}
-		Notif.Store(userID, true)
-		return userID, nil
+// Get returns the user of the session named by the bearer token, or by the cookie if there is no bearer token.
+func (s *starter) Get(bearer string) (uuid.UUID, error) {
+	data, err := s.Current(bearer)
+	if err != nil {
+		return uuid.Nil, err
 	}
-
-	// Return an error if no valid session is found
-	return uuid.Nil, fmt.Errorf("session not found")
+	return data.UserID, nil
 }
 
-// Valid checks if the session is valid by verifying its expiration and existence in memory.
+// Valid checks if the session exists and has not expired.
 func (s *starter) Valid(sessionID string) bool {
 	_, err := s.Get(sessionID)
 	return err == nil // If no error, the session is valid
 }
 
-// Delete removes a session from memory and the database.
+// List returns the sessions of a user, the most recently used first.
+func (s *starter) List(userID uuid.UUID) ([]Data, error) {
+	list, err := s.session.getStore().ListByUser(s.Ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeen.After(list[j].LastSeen)
+	})
+	return list, nil
+}
+
+// Delete removes a session from the store.
 func (s *starter) Delete(sessionID string) error {
-	s.session.mu.Lock()
-	defer s.session.mu.Unlock()
+	store := s.session.getStore()
 
-	session := s.session
-	db := session.database
-	tmpdata := session.data
+	data, err := store.Get(s.Ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(s.Ctx, sessionID); err != nil {
+		return err
+	}
+	s.notify(data.UserID)
+	return nil
+}
 
-	// Retrieve session data and delete from memory
-	sessionData, ok := tmpdata.Load(sessionID)
-	if !ok {
-		return fmt.Errorf("session not found")
+// Revoke removes the session of a user with the given public ID, or returns ErrNotFound.
+func (s *starter) Revoke(userID uuid.UUID, publicID string) error {
+	list, err := s.session.getStore().ListByUser(s.Ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, data := range list {
+		if data.PublicID() == publicID {
+			if err := s.session.getStore().Delete(s.Ctx, data.ID); err != nil {
+				return err
+			}
+			s.notify(userID)
+			return nil
+		}
 	}
-	data := sessionData.(map[string]interface{})
-	cookie := data["cookie"].(*storage)
+	return ErrNotFound
+}
 
-	// Delete session from the database
-	if db != nil {
-		stmt, err := db.Prepare(fmt.Sprintf("DELETE FROM %s WHERE id = $1", session.SessionName))
-		if err != nil {
-			return err
+// DeleteOthers removes every session of a user but the one with the given ID,
+// and returns how many were removed.
+func (s *starter) DeleteOthers(userID uuid.UUID, keepID string) (int, error) {
+	store := s.session.getStore()
+	list, err := store.ListByUser(s.Ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, data := range list {
+		if data.ID == keepID {
+			continue
 		}
-		_, err = stmt.Exec(cookie.id)
-		if err != nil {
-			return err
+		if err := store.Delete(s.Ctx, data.ID); err != nil {
+			return removed, err
 		}
+		removed++
 	}
+	s.notify(userID)
+	return removed, nil
+}
 
-	// Delete session from memory
-	tmpdata.Delete(sessionID)
-	Notif.Store(cookie.id, false) // Notify session deletion
+// DeleteAll removes every session of a user, logging them out everywhere.
+func (s *starter) DeleteAll(userID uuid.UUID) error {
+	return s.session.DeleteAll(s.Ctx, userID)
+}
+
+// DeleteAll removes every session of a user from the store in use, outside of
+// any request, such as when their account is purged.
+func (s *session) DeleteAll(ctx context.Context, userID uuid.UUID) error {
+	if err := s.getStore().DeleteByUser(ctx, userID); err != nil {
+		return err
+	}
+	s.notify(ctx, userID)
 	return nil
 }
+
+// notify updates Notif once sessions of a user were removed, as the user is only
+// gone once no session is left.
+func (s *starter) notify(userID uuid.UUID) {
+	s.session.notify(s.Ctx, userID)
+}
+
+func (s *session) notify(ctx context.Context, userID uuid.UUID) {
+	list, err := s.getStore().ListByUser(ctx, userID)
+	if err == nil && len(list) == 0 {
+		Notif.Store(userID, false) // Notify session deletion
+	}
+}
+
+// remoteIP returns the IP address of the connection a request came through.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,211 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SQLiteStore keeps sessions in a table of a SQLite database, so they survive
+// restarts and are shared by replicas using the same database file.
+type SQLiteStore struct {
+	db    *sql.DB
+	table string
+}
+
+var (
+	_ Store   = (*SQLiteStore)(nil)
+	_ Counter = (*SQLiteStore)(nil)
+	_ Purger  = (*SQLiteStore)(nil)
+)
+
+// sessionColumns are the columns read into Data, in the order scanSession expects.
+const sessionColumns = "id, user_id, user_agent, ip, created_at, last_seen, expiration_date"
+
+// addedColumns are the columns added since the session table was first created,
+// with their definitions, for upgrading existing tables.
+var addedColumns = []struct{ name, definition string }{
+	{"user_agent", "TEXT NOT NULL DEFAULT ''"},
+	{"ip", "TEXT NOT NULL DEFAULT ''"},
+	{"created_at", "DATETIME"},
+	{"last_seen", "DATETIME"},
+}
+
+// NewSQLiteStore returns a store keeping sessions in the given table, creating
+// it if needed and adding the columns an older table lacks.
+func NewSQLiteStore(db *sql.DB, table string) (*SQLiteStore, error) {
+	_, err := db.Exec(fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (id UUID PRIMARY KEY, user_id UUID NOT NULL, expiration_date DATETIME NOT NULL);`,
+		table,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error creating session table: %v", err)
+	}
+	if err := upgradeTable(db, table); err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db, table: table}, nil
+}
+
+// upgradeTable adds the missing columns to the session table, and fills in the
+// times of the sessions started before they existed.
+func upgradeTable(db *sql.DB, table string) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return fmt.Errorf("error reading session table: %v", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("error reading session table: %v", err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading session table: %v", err)
+	}
+
+	for _, column := range addedColumns {
+		if existing[column.name] {
+			continue
+		}
+		_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column.name, column.definition))
+		if err != nil {
+			return fmt.Errorf("error adding %s to session table: %v", column.name, err)
+		}
+	}
+	_, err = db.Exec(fmt.Sprintf(
+		`UPDATE %s SET created_at = COALESCE(created_at, datetime('now')), last_seen = COALESCE(last_seen, created_at, datetime('now'))
+		WHERE created_at IS NULL OR last_seen IS NULL`,
+		table,
+	))
+	if err != nil {
+		return fmt.Errorf("error upgrading session table: %v", err)
+	}
+	return nil
+}
+
+// Get returns the session with the given ID, or ErrNotFound.
+func (s *SQLiteStore) Get(ctx context.Context, id string) (Data, error) {
+	data, err := scanSession(s.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id = $1", sessionColumns, s.table,
+	), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Data{}, ErrNotFound
+	}
+	if err != nil {
+		return Data{}, fmt.Errorf("error retrieving session from database: %v", err)
+	}
+	if data.Expired(time.Now()) {
+		return Data{}, ErrNotFound
+	}
+	return data, nil
+}
+
+// Set creates or replaces a session.
+func (s *SQLiteStore) Set(ctx context.Context, data Data) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT OR REPLACE INTO %s (%s) VALUES ($1, $2, $3, $4, $5, $6, $7)", s.table, sessionColumns,
+	), data.ID, data.UserID, data.UserAgent, data.IP, data.CreatedAt.UTC(), data.LastSeen.UTC(), data.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("error storing session: %v", err)
+	}
+	return nil
+}
+
+// Delete removes a session.
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.table), id)
+	if err != nil {
+		return fmt.Errorf("error deleting session: %v", err)
+	}
+	return nil
+}
+
+// Touch records the session was used and moves its expiry.
+func (s *SQLiteStore) Touch(ctx context.Context, id string, seen, expiresAt time.Time) error {
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET last_seen = $1, expiration_date = $2 WHERE id = $3 AND datetime(expiration_date) > datetime('now')", s.table,
+	), seen.UTC(), expiresAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error updating session: %v", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListByUser returns the sessions of a user.
+func (s *SQLiteStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]Data, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE user_id = $1 AND datetime(expiration_date) > datetime('now')", sessionColumns, s.table,
+	), userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing sessions: %v", err)
+	}
+	return scanSessions(rows)
+}
+
+// DeleteByUser removes every session of a user.
+func (s *SQLiteStore) DeleteByUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", s.table), userID)
+	if err != nil {
+		return fmt.Errorf("error deleting sessions: %v", err)
+	}
+	return nil
+}
+
+// Count returns the number of sessions that have not expired.
+func (s *SQLiteStore) Count(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT COUNT(*) FROM %s WHERE datetime(expiration_date) > datetime('now')", s.table,
+	)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting sessions: %v", err)
+	}
+	return count, nil
+}
+
+// Purge removes the expired sessions and returns them.
+func (s *SQLiteStore) Purge(ctx context.Context) ([]Data, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"DELETE FROM %s WHERE datetime(expiration_date) <= datetime('now') RETURNING %s", s.table, sessionColumns,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error purging sessions: %v", err)
+	}
+	return scanSessions(rows)
+}
+
+// scanSession reads a row of sessionColumns.
+func scanSession(row interface{ Scan(...any) error }) (Data, error) {
+	var data Data
+	err := row.Scan(&data.ID, &data.UserID, &data.UserAgent, &data.IP, &data.CreatedAt, &data.LastSeen, &data.ExpiresAt)
+	return data, err
+}
+
+// scanSessions reads rows of sessionColumns, then closes them.
+func scanSessions(rows *sql.Rows) ([]Data, error) {
+	defer rows.Close()
+	var list []Data
+	for rows.Next() {
+		data, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading session: %v", err)
+		}
+		list = append(list, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading sessions: %v", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned by stores for sessions that do not exist or have expired.
+var ErrNotFound = errors.New("session not found")
+
+// Data is what a store keeps about a session.
+type Data struct {
+	ID        string    `json:"id"`         // Session ID, as sent in the cookie or bearer token
+	UserID    uuid.UUID `json:"user_id"`    // User the session belongs to
+	UserAgent string    `json:"user_agent"` // User-Agent of the client that started the session
+	IP        string    `json:"ip"`         // IP address of the client that started the session
+	CreatedAt time.Time `json:"created_at"` // Time the session was started
+	LastSeen  time.Time `json:"last_seen"`  // Time the session was last used
+	ExpiresAt time.Time `json:"expires_at"` // Time after which the session is no longer valid
+}
+
+// PublicID returns an identifier of the session that is safe to show, unlike
+// its ID which authenticates whoever sends it.
+func (d Data) PublicID() string {
+	sum := sha256.Sum256([]byte(d.ID))
+	return hex.EncodeToString(sum[:12])
+}
+
+// Expired reports whether the session is expired at t.
+func (d Data) Expired(t time.Time) bool {
+	return !t.Before(d.ExpiresAt)
+}
+
+// Store persists sessions. Implementations must be safe for concurrent use and
+// never return expired sessions, whether or not they were removed yet.
+type Store interface {
+	// Get returns the session with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (Data, error)
+	// Set creates or replaces a session.
+	Set(ctx context.Context, data Data) error
+	// Delete removes a session. Deleting a missing session is not an error.
+	Delete(ctx context.Context, id string) error
+	// Touch records the session was used at seen and moves its expiry, or returns ErrNotFound.
+	Touch(ctx context.Context, id string, seen, expiresAt time.Time) error
+	// ListByUser returns the sessions of a user.
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]Data, error)
+	// DeleteByUser removes every session of a user.
+	DeleteByUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// Counter is implemented by stores able to count their sessions, e.g. for metrics.
+type Counter interface {
+	// Count returns the number of sessions that have not expired.
+	Count(ctx context.Context) (int, error)
+}
+
+// Purger is implemented by stores able to remove the expired sessions and tell
+// which they were, so that their expiry can be notified.
+type Purger interface {
+	// Purge removes the expired sessions and returns them, with at least their ID and user.
+	Purge(ctx context.Context) ([]Data, error)
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FieldError describes why a single field failed validation.
+type FieldError struct {
+	Field   string `json:"field"`   // Name of the field in the request, e.g. "title"
+	Rule    string `json:"rule"`    // Rule that failed, e.g. "required" or "max"
+	Message string `json:"message"` // Human readable description of the failure
+}
+
+// ValidationErrors lists every field that failed validation.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the fields of the struct pointed to by v against the rules in
+// their `validate` tag and returns a ValidationErrors, or nil when all pass.
+// Rules are separated by commas:
+//
+//	required        the field must not be empty; blank strings count as empty
+//	max=N / min=N   length bounds, in characters for strings and items for slices
+//	uuid            the string must be a valid UUID
+//	oneof=a|b|c     the value must be one of the listed ones
+//	future          the date must be in the future; strings are read as RFC 3339
+//
+// Rules other than required are skipped for empty fields. Fields are reported
+// under their json name.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs ValidationErrors
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("validate")
+		if tag == "" || tag == "-" || !f.IsExported() {
+			continue
+		}
+		name := fieldName(f, "json")
+		if fe, ok := validateField(rv.Field(i), tag); !ok {
+			fe.Field = name
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateField applies the rules of tag to v and returns the first failure.
+func validateField(v reflect.Value, tag string) (FieldError, bool) {
+	empty := isEmpty(v)
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if empty {
+				return FieldError{Rule: name, Message: "is required"}, false
+			}
+			continue
+		}
+		if empty {
+			continue
+		}
+
+		switch name {
+		case "max", "min":
+			limit, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validate: invalid %s argument %q", name, arg))
+			}
+			n, unit := length(v)
+			if name == "max" && n > limit {
+				return FieldError{Rule: name, Message: fmt.Sprintf("must have at most %d %s", limit, unit)}, false
+			}
+			if name == "min" && n < limit {
+				return FieldError{Rule: name, Message: fmt.Sprintf("must have at least %d %s", limit, unit)}, false
+			}
+		case "uuid":
+			if v.Kind() == reflect.String {
+				if _, err := uuid.Parse(v.String()); err != nil {
+					return FieldError{Rule: name, Message: "must be a valid UUID"}, false
+				}
+			}
+		case "oneof":
+			allowed := strings.Split(arg, "|")
+			value := fmt.Sprint(v.Interface())
+			found := false
+			for _, a := range allowed {
+				if a == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return FieldError{Rule: name, Message: "must be one of: " + strings.Join(allowed, ", ")}, false
+			}
+		case "future":
+			var date time.Time
+			switch {
+			case v.Type() == timeType:
+				date = v.Interface().(time.Time)
+			case v.Kind() == reflect.String:
+				parsed, err := time.Parse(time.RFC3339, v.String())
+				if err != nil {
+					return FieldError{Rule: name, Message: "must be a valid date"}, false
+				}
+				date = parsed
+			}
+			if !date.After(time.Now()) {
+				return FieldError{Rule: name, Message: "must be in the future"}, false
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q", name))
+		}
+	}
+	return FieldError{}, true
+}
+
+// isEmpty reports whether v holds the zero value; strings made of spaces count as empty.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// length returns the size checked by the max and min rules, and its unit.
+func length(v reflect.Value) (int, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), "characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len(), "items"
+	}
+	panic(fmt.Sprintf("validate: max and min do not apply to %s", v.Type()))
+}
@@ -2,17 +2,36 @@ package main
 
 import (
 	socialnetwork "Social_Network/app"
+	"Social_Network/app/logger"
+	"Social_Network/app/mailer"
 	"Social_Network/app/middleware/cors"
+	"Social_Network/app/middleware/csrf"
+	"Social_Network/app/middleware/ratelimit"
+	"Social_Network/app/oidc"
+	"Social_Network/app/session"
 	"Social_Network/pkg/config"
 	"Social_Network/pkg/db/sqlite"
 	"Social_Network/pkg/handlers"
 	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
 	"Social_Network/pkg/tools"
 
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"html"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	netmail "net/mail"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -24,6 +43,9 @@ func main() {
 		log.Fatalf("Failed to load environment variables: %v", err)
 	}
 
+	// Log through log/slog at the configured level and format
+	configureLogging()
+
 	// Ensure necessary directories exist
 	ensureDirectory(middleware.DirName)
 
@@ -35,16 +57,39 @@ func main() {
 
 	// Configure and set up the database
 	database := sqlite.OpenDB(migrate)
+
+	// Lift a login lockout from the command line instead of serving, if asked to
+	if subject, ok := unlockSubject(os.Args[1:]); ok {
+		unlockLogins(database, subject)
+		return
+	}
+	// Or give a user a site role, which is how the first admin is appointed
+	if email, role, ok := roleAssignment(os.Args[1:]); ok {
+		assignRole(database, email, role)
+		return
+	}
 	app.UseDb(database)
 
-	// Add middleware for CORS and static file serving
+	// Add middleware for request logging, panic recovery, CORS, CSRF protection and static file serving
 	configureMiddleware(app)
 
+	// Serve /metrics only to the scrapers sending METRICS_TOKEN
+	config.MetricsToken = os.Getenv("METRICS_TOKEN")
+
 	// Register all application handlers
 	handlers.HandleAll(app)
 
 	// Set up session management
-	config.Sess.UseDB(app.Db.Conn)
+	configureSessions(app)
+
+	// Set up how emails, such as address verifications, are sent
+	configureMailer()
+
+	// Set up sign-in with an OpenID Connect provider, if any
+	configureOIDC()
+
+	// Purge the accounts whose deletion grace period is over
+	configureAccountDeletion(app)
 
 	// Start the application server
 	port := os.Getenv("PORT")
@@ -53,10 +98,21 @@ func main() {
 	}
 	log.Printf("Starting server on port: %s\n", port)
 	if err := app.Run(":" + port); err != nil {
-		panic(err)
+		log.Fatalf("Server stopped with error: %v", err)
 	}
 }
 
+// configureLogging makes a log/slog logger the default one, which the standard log
+// package then writes through too. LOG_LEVEL is one of debug, info (default), warn
+// or error, and LOG_FORMAT is text (default) or json.
+func configureLogging() {
+	l, err := logger.New(os.Stderr, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	slog.SetDefault(l)
+}
+
 // ensureDirectory checks if a directory exists and creates it if not.
 func ensureDirectory(dirName string) {
 	if _, err := os.Stat(dirName); os.IsNotExist(err) {
@@ -94,13 +150,211 @@ func parseMigrationArgs(args []string) sqlite.Migrations {
 	return migrate
 }
 
+// unlockSubject returns the account email or client IP given with -unlock=, for
+// an admin to lift its lockout after failed logins.
+func unlockSubject(args []string) (string, bool) {
+	for _, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "-unlock="); ok {
+			if ip := net.ParseIP(value); ip != nil {
+				return models.IPLoginSubject(ip.String()), true
+			}
+			return models.AccountLoginSubject(value), true
+		}
+	}
+	return "", false
+}
+
+// unlockLogins forgets the failed logins of subject, lifting its lockout.
+func unlockLogins(db *sql.DB, subject string) {
+	cleared, err := models.ClearLoginThrottle(context.Background(), db, subject)
+	if err != nil {
+		log.Fatalf("Failed to unlock %s: %v", subject, err)
+	}
+	if cleared {
+		log.Printf("Unlocked %s\n", subject)
+	} else {
+		log.Printf("No failed logins recorded for %s\n", subject)
+	}
+}
+
+// configureAccountDeletion purges the accounts due for deletion while the server
+// runs. ACCOUNT_DELETION_GRACE (e.g. "720h") overrides how long after asking for
+// it an account is deleted, and ACCOUNT_PURGE_INTERVAL how often due accounts
+// are looked for.
+func configureAccountDeletion(app *socialnetwork.App) {
+	durationFromEnv("ACCOUNT_DELETION_GRACE", &config.AccountDeletionGrace)
+	interval := time.Hour
+	durationFromEnv("ACCOUNT_PURGE_INTERVAL", &interval)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	app.OnStart(func() {
+		go func() {
+			defer close(done)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				purgeAccounts(ctx, app.Db.Conn)
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	})
+	app.OnShutdown(func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	})
+}
+
+// purgeAccounts deletes the data of the users whose deletion is due, and the
+// files they uploaded that nobody uses anymore.
+func purgeAccounts(ctx context.Context, db *sql.DB) {
+	users, err := models.DueAccountDeletions(ctx, db, time.Now())
+	if err != nil {
+		slog.Error("listing due account deletions failed", "error", err)
+		return
+	}
+	for _, userID := range users {
+		if err := config.Sess.DeleteAll(ctx, userID); err != nil {
+			slog.Error("revoking sessions before purging account failed", "user_id", userID, "error", err)
+			continue
+		}
+		files, err := models.PurgeAccount(ctx, db, userID)
+		if err != nil {
+			slog.Error("purging account failed", "user_id", userID, "error", err)
+			continue
+		}
+		for _, file := range files {
+			if err := os.Remove(filepath.FromSlash(file)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("removing uploaded file failed", "user_id", userID, "file", file, "error", err)
+			}
+		}
+		slog.Info("account purged", "user_id", userID, "files_removed", len(files))
+	}
+}
+
+// roleAssignment returns the email and site role given with -role=<email>:<role>.
+func roleAssignment(args []string) (string, models.SiteRole, bool) {
+	for _, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "-role="); ok {
+			i := strings.LastIndex(value, ":")
+			if i < 0 {
+				log.Fatalf("Invalid -role %q, expected <email>:<role>", value)
+			}
+			return value[:i], models.SiteRole(value[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// assignRole gives the user with the given email the site role role.
+func assignRole(db *sql.DB, email string, role models.SiteRole) {
+	if !role.Valid() {
+		log.Fatalf("Unknown role %q, expected user, moderator or admin", role)
+	}
+	ctx := context.Background()
+	user := models.User{}
+	if err := user.Get(ctx, db, html.EscapeString(email)); err != nil {
+		log.Fatalf("Failed to find the user %s: %v", email, err)
+	}
+	if err := user.SetRole(ctx, db, role); err != nil {
+		log.Fatalf("Failed to give %s the role %s: %v", email, role, err)
+	}
+	log.Printf("%s is now %s\n", email, role)
+}
+
 // initializeApp creates and initializes the application instance.
+// SHUTDOWN_TIMEOUT (e.g. "30s") overrides how long a graceful shutdown may take,
+// and ROUTE_TIMEOUT how long a request may run unless its route says otherwise.
 func initializeApp() *socialnetwork.App {
-	return socialnetwork.New()
+	app := socialnetwork.New()
+	durationFromEnv("SHUTDOWN_TIMEOUT", &app.ShutdownTimeout)
+	durationFromEnv("ROUTE_TIMEOUT", &app.RouteTimeout)
+	return app
+}
+
+// durationFromEnv overrides *d with the positive duration in the named environment variable, if set.
+func durationFromEnv(name string, d *time.Duration) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Fatalf("Invalid %s %q", name, value)
+	}
+	*d = parsed
 }
 
-// configureMiddleware sets up CORS and static file serving middleware.
+// configureSessions selects the store sessions are kept in from SESSION_STORE:
+// sqlite (default) keeps them in the application database, memory in the process,
+// and redis in the server at REDIS_ADDR (with REDIS_PASSWORD and REDIS_DB), which
+// lets several replicas share them. SESSION_ABSOLUTE_TIMEOUT (e.g. "720h") and
+// SESSION_IDLE_TIMEOUT override how long sessions last in total and unused, and
+// SESSION_JANITOR_INTERVAL how often expired sessions are purged.
+func configureSessions(app *socialnetwork.App) {
+	durationFromEnv("SESSION_ABSOLUTE_TIMEOUT", &config.Sess.Config.AbsoluteTimeout)
+	durationFromEnv("SESSION_IDLE_TIMEOUT", &config.Sess.Config.IdleTimeout)
+
+	// Purge expired sessions while the server runs; registered before the store
+	// is closed on shutdown
+	janitorInterval := session.DefaultJanitorInterval
+	durationFromEnv("SESSION_JANITOR_INTERVAL", &janitorInterval)
+	app.OnStart(func() { config.Sess.StartJanitor(janitorInterval) })
+	app.OnShutdown(config.Sess.StopJanitor)
+
+	// Record the client IP rate limits see, forwarded by the proxy if trusted
+	config.Sess.Config.ClientIP = func(r *http.Request) string {
+		return ratelimit.ClientIP(r, middleware.Proxies)
+	}
+
+	switch store := os.Getenv("SESSION_STORE"); store {
+	case "", "sqlite":
+		config.Sess.UseDB(app.Db.Conn)
+	case "memory":
+		config.Sess.UseStore(session.NewMemoryStore())
+	case "redis":
+		db, err := strconv.Atoi(os.Getenv("REDIS_DB"))
+		if err != nil && os.Getenv("REDIS_DB") != "" {
+			log.Fatalf("Invalid REDIS_DB %q", os.Getenv("REDIS_DB"))
+		}
+		redis := session.NewRedisStore(session.RedisConfig{
+			Addr:     os.Getenv("REDIS_ADDR"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+			DB:       db,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := redis.Ping(ctx); err != nil {
+			log.Fatalf("Failed to reach the session store: %v", err)
+		}
+		config.Sess.UseStore(redis)
+		app.OnShutdown(func(context.Context) error { return redis.Close() })
+	default:
+		log.Fatalf("Invalid SESSION_STORE %q", store)
+	}
+}
+
+// configureMiddleware sets up request logging, panic recovery, CORS, CSRF protection and static file serving middleware.
+// TRUST_PROXY=true makes rate limits trust the client IP forwarded by the reverse proxy,
+// skipping the hops of the further proxies listed in TRUSTED_PROXIES.
 func configureMiddleware(app *socialnetwork.App) {
+	middleware.Proxies.Trust, _ = strconv.ParseBool(os.Getenv("TRUST_PROXY"))
+	trusted, err := ratelimit.ParseNetworks(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	middleware.Proxies.Trusted = trusted
+	app.Use(middleware.Logging)
+	app.Use(socialnetwork.Recovery())
 	app.Use(cors.New(cors.Config{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
@@ -109,5 +363,94 @@ func configureMiddleware(app *socialnetwork.App) {
 		ExposedHeaders:   []string{},
 		MaxAge:           86400,
 	}))
+	middleware.CSRFSecret = secretFromEnv("CSRF_SECRET")
+	app.Use(csrf.New(csrf.Config{
+		Secret:  middleware.CSRFSecret,
+		Session: middleware.CookieSession,
+	}))
 	app.Static("/uploads", middleware.DirName)
 }
+
+// secretFromEnv returns the key in the named environment variable. Without it a
+// random key is used, so what is signed with it is invalidated on restart and
+// differs between replicas.
+func secretFromEnv(name string) []byte {
+	if secret := os.Getenv(name); secret != "" {
+		return []byte(secret)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("Failed to generate %s: %v", name, err)
+	}
+	slog.Warn(name + " is not set, using a random key")
+	return secret
+}
+
+// configureMailer selects how emails are sent from MAILER: log (default) only logs
+// them, and writes them to MAIL_DIR if set, while smtp sends them through the
+// server at SMTP_ADDR, as SMTP_USERNAME with SMTP_PASSWORD if set. MAIL_FROM is
+// the sender, PUBLIC_URL the address of the API that verification links point to,
+// and RESET_PASSWORD_URL the page of the client that password reset links open.
+func configureMailer() {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Social Network <no-reply@localhost>"
+	}
+	if _, err := netmail.ParseAddress(from); err != nil {
+		log.Fatalf("Invalid MAIL_FROM %q: %v", from, err)
+	}
+	if url := os.Getenv("PUBLIC_URL"); url != "" {
+		config.PublicURL = strings.TrimSuffix(url, "/")
+	} else {
+		config.PublicURL = "http://localhost:" + os.Getenv("PORT")
+	}
+	if url := os.Getenv("RESET_PASSWORD_URL"); url != "" {
+		config.ResetPasswordURL = url
+	}
+	config.EmailVerificationSecret = secretFromEnv("EMAIL_VERIFICATION_SECRET")
+
+	switch kind := os.Getenv("MAILER"); kind {
+	case "", "log":
+		dev, err := mailer.NewDev(from, os.Getenv("MAIL_DIR"))
+		if err != nil {
+			log.Fatalf("Failed to configure the mailer: %v", err)
+		}
+		config.Mailer = dev
+	case "smtp":
+		if os.Getenv("SMTP_ADDR") == "" {
+			log.Fatal("SMTP_ADDR environment variable is not set")
+		}
+		config.Mailer = mailer.NewSMTP(mailer.SMTPConfig{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	default:
+		log.Fatalf("Invalid MAILER %q", kind)
+	}
+}
+
+// configureOIDC lets users sign in with the OpenID Connect provider at OIDC_ISSUER,
+// if set, as the client OIDC_CLIENT_ID with OIDC_CLIENT_SECRET. OIDC_REDIRECT_URL
+// is the page of the client the provider sends users back to, and OIDC_SCOPES the
+// space-separated scopes asked for, if not the default ones.
+func configureOIDC() {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return
+	}
+	if os.Getenv("OIDC_CLIENT_ID") == "" {
+		log.Fatal("OIDC_CLIENT_ID environment variable is not set")
+	}
+	if os.Getenv("OIDC_REDIRECT_URL") == "" {
+		log.Fatal("OIDC_REDIRECT_URL environment variable is not set")
+	}
+	config.OIDC = oidc.New(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	})
+}
@@ -0,0 +1,8 @@
+package config
+
+import "time"
+
+// AccountDeletionGrace is how long after asking for it an account is deleted,
+// during which logging in again calls the deletion off. It is set from the
+// ACCOUNT_DELETION_GRACE environment variable.
+var AccountDeletionGrace = 30 * 24 * time.Hour
@@ -0,0 +1,21 @@
+package config
+
+import "Social_Network/app/mailer"
+
+// Mailer sends the emails of the application. Until main configures it from
+// MAILER, messages are only logged.
+var Mailer mailer.Mailer = new(mailer.Dev)
+
+// PublicURL is the address the API is reached at from outside, which links in
+// emails point to. It is set from the PUBLIC_URL environment variable.
+var PublicURL = "http://localhost:8081"
+
+// ResetPasswordURL is the page of the client where users choose a new password,
+// which password reset emails link to with the token in the query string. It is
+// set from the RESET_PASSWORD_URL environment variable.
+var ResetPasswordURL = "http://localhost:3000/reset-password"
+
+// EmailVerificationSecret is the key email verification links are signed with.
+// It is set from the EMAIL_VERIFICATION_SECRET environment variable, or randomly
+// at startup.
+var EmailVerificationSecret []byte
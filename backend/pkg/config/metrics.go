@@ -0,0 +1,5 @@
+package config
+
+// MetricsToken is the bearer token scrapers send to read /metrics. While it is
+// empty, /metrics is not served.
+var MetricsToken string
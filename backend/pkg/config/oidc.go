@@ -0,0 +1,7 @@
+package config
+
+import "Social_Network/app/oidc"
+
+// OIDC is the OpenID Connect provider users can sign in with, nil unless main
+// configures one from OIDC_ISSUER.
+var OIDC *oidc.Provider
@@ -2,12 +2,15 @@ package config
 
 import (
 	"Social_Network/app/session"
+	"time"
 )
 
 // DefaultSessionConfig defines the default configuration for session management.
 func DefaultSessionConfig() session.Config {
 	return session.Config{
-		CookieName: "sessions",
+		CookieName:      "sessions",
+		AbsoluteTimeout: 30 * 24 * time.Hour, // Log in again every month
+		IdleTimeout:     7 * 24 * time.Hour,  // or after a week without activity
 	}
 }
 
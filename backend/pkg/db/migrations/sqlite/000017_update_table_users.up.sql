@@ -0,0 +1,4 @@
+ALTER TABLE users ADD email_verified_at TIMESTAMP;
+
+-- Accounts created before verification existed are trusted as they are
+UPDATE users SET email_verified_at = created_at;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id),
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
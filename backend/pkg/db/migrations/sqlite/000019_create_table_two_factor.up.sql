@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS two_factor (
+    user_id UUID PRIMARY KEY REFERENCES users(id),
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMP,
+    last_step INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id),
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id),
+    token_hash TEXT UNIQUE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,21 @@
+CREATE TABLE notifications_old (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id),
+    group_id UUID,
+    concern_id UUID,
+    member_id UUID,
+    is_invite BOOLEAN DEFAULT FALSE,
+    type TEXT CHECK(type = 'follow_request'OR type = 'follow_accepted' OR type = 'follow_declined' OR type = 'unfollow' OR type = 'group_invitation' OR type = 'new_message' OR type = 'new_event'),
+    message TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+INSERT INTO notifications_old (id, user_id, group_id, concern_id, member_id, is_invite, type, message, created_at, deleted_at)
+SELECT id, user_id, group_id, concern_id, member_id, is_invite, type, message, created_at, deleted_at
+FROM notifications
+WHERE type != 'account_locked';
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_old RENAME TO notifications;
@@ -0,0 +1,21 @@
+-- SQLite cannot alter a CHECK constraint, so the table is rebuilt to allow account_locked notifications
+CREATE TABLE notifications_new (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id),
+    group_id UUID,
+    concern_id UUID,
+    member_id UUID,
+    is_invite BOOLEAN DEFAULT FALSE,
+    type TEXT CHECK(type = 'follow_request'OR type = 'follow_accepted' OR type = 'follow_declined' OR type = 'unfollow' OR type = 'group_invitation' OR type = 'new_message' OR type = 'new_event' OR type = 'account_locked'),
+    message TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+INSERT INTO notifications_new (id, user_id, group_id, concern_id, member_id, is_invite, type, message, created_at, deleted_at)
+SELECT id, user_id, group_id, concern_id, member_id, is_invite, type, message, created_at, deleted_at
+FROM notifications;
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_new RENAME TO notifications;
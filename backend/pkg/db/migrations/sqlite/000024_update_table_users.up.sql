@@ -0,0 +1,3 @@
+ALTER TABLE users ADD role TEXT NOT NULL DEFAULT 'user' CHECK(role = 'user' OR role = 'moderator' OR role = 'admin');
+ALTER TABLE users ADD suspended_at TIMESTAMP;
+ALTER TABLE users ADD suspension_reason TEXT;
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id UUID PRIMARY KEY REFERENCES users(id),
+    requested_at TIMESTAMP NOT NULL,
+    purge_at TIMESTAMP NOT NULL
+);
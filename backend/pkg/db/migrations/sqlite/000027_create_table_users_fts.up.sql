@@ -0,0 +1,19 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts5(
+    user_id UNINDEXED,
+    nickname,
+    first_name,
+    last_name,
+    about_me,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+CREATE TRIGGER IF NOT EXISTS users_fts_insert AFTER INSERT ON users BEGIN
+    INSERT INTO users_fts (user_id, nickname, first_name, last_name, about_me) VALUES (new.id, new.nickname, new.first_name, new.last_name, new.about_me);
+END;
+CREATE TRIGGER IF NOT EXISTS users_fts_update AFTER UPDATE OF nickname, first_name, last_name, about_me ON users BEGIN
+    DELETE FROM users_fts WHERE user_id = old.id;
+    INSERT INTO users_fts (user_id, nickname, first_name, last_name, about_me) VALUES (new.id, new.nickname, new.first_name, new.last_name, new.about_me);
+END;
+CREATE TRIGGER IF NOT EXISTS users_fts_delete AFTER DELETE ON users BEGIN
+    DELETE FROM users_fts WHERE user_id = old.id;
+END;
+INSERT INTO users_fts (user_id, nickname, first_name, last_name, about_me) SELECT id, nickname, first_name, last_name, about_me FROM users;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS users_trigram_delete;
+DROP TRIGGER IF EXISTS users_trigram_update;
+DROP TRIGGER IF EXISTS users_trigram_insert;
+DROP TABLE IF EXISTS users_trigram;
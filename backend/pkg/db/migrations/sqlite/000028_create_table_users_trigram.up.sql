@@ -0,0 +1,18 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS users_trigram USING fts5(
+    user_id UNINDEXED,
+    nickname,
+    first_name,
+    last_name,
+    tokenize = 'trigram remove_diacritics 1'
+);
+CREATE TRIGGER IF NOT EXISTS users_trigram_insert AFTER INSERT ON users BEGIN
+    INSERT INTO users_trigram (user_id, nickname, first_name, last_name) VALUES (new.id, new.nickname, new.first_name, new.last_name);
+END;
+CREATE TRIGGER IF NOT EXISTS users_trigram_update AFTER UPDATE OF nickname, first_name, last_name ON users BEGIN
+    DELETE FROM users_trigram WHERE user_id = old.id;
+    INSERT INTO users_trigram (user_id, nickname, first_name, last_name) VALUES (new.id, new.nickname, new.first_name, new.last_name);
+END;
+CREATE TRIGGER IF NOT EXISTS users_trigram_delete AFTER DELETE ON users BEGIN
+    DELETE FROM users_trigram WHERE user_id = old.id;
+END;
+INSERT INTO users_trigram (user_id, nickname, first_name, last_name) SELECT id, nickname, first_name, last_name FROM users;
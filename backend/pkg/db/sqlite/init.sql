@@ -11,6 +11,10 @@ CREATE TABLE IF NOT EXISTS users (
                                      nickname TEXT,
                                      about_me TEXT,
                                      is_public BOOLEAN,
+                                     email_verified_at TIMESTAMP,
+                                     role TEXT NOT NULL DEFAULT 'user' CHECK(role = 'user' OR role = 'moderator' OR role = 'admin'),
+                                     suspended_at TIMESTAMP,
+                                     suspension_reason TEXT,
                                      created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
                                      updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
                                      deleted_at TIMESTAMP
@@ -129,7 +133,7 @@ CREATE TABLE IF NOT EXISTS notifications (
                                              concern_id UUID,
                                              member_id UUID,
                                              is_invite BOOLEAN DEFAULT FALSE,
-                                             type TEXT CHECK(type = 'follow_request'OR type = 'follow_accepted' OR type = 'follow_declined' OR type = 'unfollow' OR type = 'group_invitation' OR type = 'new_message' OR type = 'new_event'),
+                                             type TEXT CHECK(type = 'follow_request'OR type = 'follow_accepted' OR type = 'follow_declined' OR type = 'unfollow' OR type = 'group_invitation' OR type = 'new_message' OR type = 'new_event' OR type = 'account_locked'),
                                              message TEXT,
                                              created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
                                              deleted_at TIMESTAMP
@@ -172,7 +176,150 @@ CREATE TABLE IF NOT EXISTS invitations (
 CREATE TABLE sessions (
                           id UUID PRIMARY KEY,
                           user_id UUID  REFERENCES Users(id),
+                          user_agent TEXT NOT NULL DEFAULT '',
+                          ip TEXT NOT NULL DEFAULT '',
                           created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                          last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
                           expiration_date TIMESTAMP,
                           deleted_at TIMESTAMP
-);
\ No newline at end of file
+);
+-- Password Resets Table
+CREATE TABLE password_resets (
+                          id UUID PRIMARY KEY,
+                          user_id UUID REFERENCES users(id),
+                          token_hash TEXT UNIQUE NOT NULL,
+                          expires_at TIMESTAMP NOT NULL,
+                          used_at TIMESTAMP,
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Two-Factor Authentication Table
+CREATE TABLE two_factor (
+                          user_id UUID PRIMARY KEY REFERENCES users(id),
+                          secret TEXT NOT NULL,
+                          confirmed_at TIMESTAMP,
+                          last_step INTEGER NOT NULL DEFAULT 0,
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Recovery Codes Table
+CREATE TABLE recovery_codes (
+                          id UUID PRIMARY KEY,
+                          user_id UUID REFERENCES users(id),
+                          code_hash TEXT NOT NULL,
+                          used_at TIMESTAMP,
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Login Challenges Table
+CREATE TABLE login_challenges (
+                          id UUID PRIMARY KEY,
+                          user_id UUID REFERENCES users(id),
+                          token_hash TEXT UNIQUE NOT NULL,
+                          attempts INTEGER NOT NULL DEFAULT 0,
+                          expires_at TIMESTAMP NOT NULL,
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- User Identities Table
+CREATE TABLE user_identities (
+                          id UUID PRIMARY KEY,
+                          user_id UUID REFERENCES users(id),
+                          issuer TEXT NOT NULL,
+                          subject TEXT NOT NULL,
+                          email TEXT,
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                          UNIQUE (issuer, subject)
+);
+
+-- OIDC States Table
+CREATE TABLE oidc_states (
+                          id UUID PRIMARY KEY,
+                          state_hash TEXT UNIQUE NOT NULL,
+                          nonce TEXT NOT NULL,
+                          code_verifier TEXT NOT NULL,
+                          expires_at TIMESTAMP NOT NULL,
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Access Tokens Table
+CREATE TABLE access_tokens (
+                          id UUID PRIMARY KEY,
+                          user_id UUID REFERENCES users(id),
+                          name TEXT NOT NULL,
+                          token_hash TEXT UNIQUE NOT NULL,
+                          scopes TEXT NOT NULL,
+                          expires_at TIMESTAMP NOT NULL,
+                          last_used_at TIMESTAMP,
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Login Throttles Table
+CREATE TABLE login_throttles (
+                          subject TEXT PRIMARY KEY,
+                          failures INTEGER NOT NULL DEFAULT 0,
+                          last_failed_at TIMESTAMP NOT NULL,
+                          locked_until TIMESTAMP
+);
+
+-- Account Deletions Table
+CREATE TABLE account_deletions (
+                          user_id UUID PRIMARY KEY REFERENCES users(id),
+                          requested_at TIMESTAMP NOT NULL,
+                          purge_at TIMESTAMP NOT NULL
+);
+
+-- Blocks Table
+CREATE TABLE blocks (
+                          blocker_id UUID NOT NULL REFERENCES users(id),
+                          blocked_id UUID NOT NULL REFERENCES users(id),
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                          PRIMARY KEY (blocker_id, blocked_id)
+);
+
+-- Mutes Table
+CREATE TABLE mutes (
+                          muter_id UUID NOT NULL REFERENCES users(id),
+                          muted_id UUID NOT NULL REFERENCES users(id),
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                          PRIMARY KEY (muter_id, muted_id)
+);
+
+-- Users Full-Text Search Table, kept in sync with users by triggers
+CREATE VIRTUAL TABLE users_fts USING fts5(
+                          user_id UNINDEXED,
+                          nickname,
+                          first_name,
+                          last_name,
+                          about_me,
+                          tokenize = 'unicode61 remove_diacritics 2'
+);
+CREATE TRIGGER users_fts_insert AFTER INSERT ON users BEGIN
+    INSERT INTO users_fts (user_id, nickname, first_name, last_name, about_me) VALUES (new.id, new.nickname, new.first_name, new.last_name, new.about_me);
+END;
+CREATE TRIGGER users_fts_update AFTER UPDATE OF nickname, first_name, last_name, about_me ON users BEGIN
+    DELETE FROM users_fts WHERE user_id = old.id;
+    INSERT INTO users_fts (user_id, nickname, first_name, last_name, about_me) VALUES (new.id, new.nickname, new.first_name, new.last_name, new.about_me);
+END;
+CREATE TRIGGER users_fts_delete AFTER DELETE ON users BEGIN
+    DELETE FROM users_fts WHERE user_id = old.id;
+END;
+
+-- Users Trigram Table, for searches with typos, kept in sync with users by triggers
+CREATE VIRTUAL TABLE users_trigram USING fts5(
+                          user_id UNINDEXED,
+                          nickname,
+                          first_name,
+                          last_name,
+                          tokenize = 'trigram remove_diacritics 1'
+);
+CREATE TRIGGER users_trigram_insert AFTER INSERT ON users BEGIN
+    INSERT INTO users_trigram (user_id, nickname, first_name, last_name) VALUES (new.id, new.nickname, new.first_name, new.last_name);
+END;
+CREATE TRIGGER users_trigram_update AFTER UPDATE OF nickname, first_name, last_name ON users BEGIN
+    DELETE FROM users_trigram WHERE user_id = old.id;
+    INSERT INTO users_trigram (user_id, nickname, first_name, last_name) VALUES (new.id, new.nickname, new.first_name, new.last_name);
+END;
+CREATE TRIGGER users_trigram_delete AFTER DELETE ON users BEGIN
+    DELETE FROM users_trigram WHERE user_id = old.id;
+END;
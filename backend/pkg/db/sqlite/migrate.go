@@ -1,11 +1,13 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -13,6 +15,70 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
+// MigrationDir is the directory holding the migration files, relative to the working directory.
+const MigrationDir = "pkg/db/migrations/sqlite"
+
+// MigrationState describes the schema version of the database.
+type MigrationState struct {
+	Versioned bool // Whether the database is managed by migrations at all
+	Version   uint // Version of the last migration applied
+	Dirty     bool // Whether the last migration failed halfway
+	Latest    uint // Highest version available in MigrationDir
+}
+
+// MigrationStatus reads the schema version recorded by the migrations in the
+// database, along with the latest version available in MigrationDir. A database
+// created from init.sql has no record and is reported as not versioned.
+func MigrationStatus(ctx context.Context, DB *sql.DB) (MigrationState, error) {
+	var state MigrationState
+	latest, err := LatestMigration()
+	if err != nil {
+		return state, err
+	}
+	state.Latest = latest
+
+	var tables int
+	err = DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&tables)
+	if err != nil {
+		return state, fmt.Errorf("unable to look up the migrations table. %v", err)
+	}
+	if tables == 0 {
+		return state, nil
+	}
+
+	err = DB.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&state.Version, &state.Dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("unable to read the migration version. %v", err)
+	}
+	state.Versioned = true
+	return state, nil
+}
+
+// LatestMigration returns the highest version among the up migrations in MigrationDir.
+func LatestMigration() (uint, error) {
+	entries, err := os.ReadDir(MigrationDir)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list the migrations. %v", err)
+	}
+	var latest uint
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok || !strings.HasSuffix(name, ".up.sql") {
+			continue
+		}
+		version, err := strconv.ParseUint(prefix, 10, 0)
+		if err != nil {
+			continue
+		}
+		latest = max(latest, uint(version))
+	}
+	return latest, nil
+}
+
 // Migration performs the database migration based on the specified action.
 func Migration(DB *sql.DB, migration Migrations) { // Pass by reference
 	// Get the current working directory
@@ -22,7 +88,7 @@ func Migration(DB *sql.DB, migration Migrations) { // Pass by reference
 	}
 
 	// Define the migration directory and database path
-	migrationDir := currentDir + "/pkg/db/migrations/sqlite/"
+	migrationDir := currentDir + "/" + MigrationDir + "/"
 	databasePath := currentDir + "/pkg/db/sqlite/social-network.db"
 
 	// Initialize the migration instance
@@ -42,27 +108,27 @@ func Migration(DB *sql.DB, migration Migrations) { // Pass by reference
 	case "-up":
 		// Apply one migration (1 Up)
 		if err := m.Steps(1); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-			fmt.Println("Migration Error: ", err)
+			log.Printf("Migration error: %v", err)
 		}
 	case "-down":
 		// Rollback one migration (1 Down)
 		if err := m.Steps(-1); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-			fmt.Println("Migration Error: ", err)
+			log.Printf("Migration error: %v", err)
 		}
 	case "-up--all":
 		// Apply all migrations (Up)
 		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-			fmt.Println("Migration Error: ", err)
+			log.Printf("Migration error: %v", err)
 		}
 	case "-down--all":
 		// Rollback all migrations (Down)
 		if err := m.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-			fmt.Println("Migration Error: ", err)
+			log.Printf("Migration error: %v", err)
 		}
 	case "-to":
 		// Migrate directly to the target version
 		if err := m.Migrate(uint(migration.Version)); err != nil {
-			fmt.Println("Migration Error: ", err)
+			log.Printf("Migration error: %v", err)
 		}
 	}
 
@@ -71,6 +137,5 @@ func Migration(DB *sql.DB, migration Migrations) { // Pass by reference
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
 		log.Fatal(err)
 	}
-	fmt.Println("Current database version:", currentVersion)
-	fmt.Println("Dirty state of Database: ", dirty)
+	log.Printf("Current database version: %d, dirty: %t", currentVersion, dirty)
 }
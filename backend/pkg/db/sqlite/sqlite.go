@@ -27,7 +27,15 @@ func OpenDB(migration Migrations) *sql.DB { // Pass by reference
 		Migration(DB, migration)
 	}
 
+	// Checked before the FTS5 query below, which would create the file
 	_, errorNoFile := os.Stat("./pkg/db/sqlite/social-network.db")
+
+	// User search relies on FTS5, which go-sqlite3 only builds in with the sqlite_fts5 tag
+	var fts5 bool
+	if err := DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil || !fts5 {
+		log.Fatal("SQLite was built without FTS5. Build the server with -tags sqlite_fts5.")
+	}
+
 	if errorNoFile != nil {
 		// Initialize the database with the SQL script if the database does not exist
 		sqlCode, ERR := os.ReadFile("./pkg/db/sqlite/init.sql")
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// accessTokenDefaultDays is how many days a personal access token is valid for
+// when its user does not say.
+const accessTokenDefaultDays = 30
+
+// listAccessTokensHandler lists the personal access tokens of the current user.
+func listAccessTokensHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+
+	tokens, err := models.ListAccessTokens(ctx, ctx.Db.Conn, userId)
+	if err != nil {
+		ctx.Logger().Error("listing access tokens failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(tokens)
+}
+
+var listAccessTokensRoute = route{
+	method: http.MethodGet,
+	group:  authenticated,
+	path:   "/me/tokens",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		listAccessTokensHandler,
+	},
+}
+
+// createAccessTokenHandler creates a personal access token for the current user,
+// with the given name, scopes and validity in days. The token is only shown in
+// this response.
+func createAccessTokenHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	var request struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expiresInDays"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || len(request.Name) > 100 {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "The token needs a name of at most 100 characters.", nil)
+		return
+	}
+	if len(request.Scopes) == 0 {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "The token needs at least one scope.", map[string]interface{}{"scopes": models.AccessTokenScopes})
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !slices.Contains(models.AccessTokenScopes, scope) {
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Unknown scope "+scope+".", map[string]interface{}{"scopes": models.AccessTokenScopes})
+			return
+		}
+	}
+	if request.ExpiresInDays == 0 {
+		request.ExpiresInDays = accessTokenDefaultDays
+	}
+	maxDays := int(models.AccessTokenMaxTTL / (24 * time.Hour))
+	if request.ExpiresInDays < 0 || request.ExpiresInDays > maxDays {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, fmt.Sprintf("A token expires in 1 to %d days.", maxDays), nil)
+		return
+	}
+
+	slices.Sort(request.Scopes)
+	accessToken := models.AccessToken{
+		UserID:    userId,
+		Name:      request.Name,
+		Scopes:    slices.Compact(request.Scopes),
+		ExpiresAt: time.Now().AddDate(0, 0, request.ExpiresInDays),
+	}
+	token, err := accessToken.Create(ctx, ctx.Db.Conn)
+	if err != nil {
+		ctx.Logger().Error("creating access token failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("access token created", "access_token_id", accessToken.ID, "scopes", accessToken.Scopes)
+	ctx.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"token":   token,
+		"data":    accessToken,
+		"message": "Copy the token now, it will not be shown again.",
+	})
+}
+
+var createAccessTokenRoute = route{
+	method: http.MethodPost,
+	group:  authenticated,
+	path:   "/me/tokens",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		createAccessTokenHandler,
+	},
+}
+
+// revokeAccessTokenHandler deletes one of the personal access tokens of the current user.
+func revokeAccessTokenHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	tokenID, err := ctx.ParamUUID("tokenID")
+	if err != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Access token not found.", nil)
+		return
+	}
+
+	accessToken := models.AccessToken{ID: tokenID, UserID: userId}
+	err = accessToken.Delete(ctx, ctx.Db.Conn)
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Access token not found.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("revoking access token failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Access token revoked.",
+	})
+}
+
+var revokeAccessTokenRoute = route{
+	method: http.MethodDelete,
+	group:  authenticated,
+	path:   "/me/tokens/{tokenID}",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		revokeAccessTokenHandler,
+	},
+}
+
+func init() {
+	AllHandler[listAccessTokensRoute.key()] = listAccessTokensRoute
+	AllHandler[createAccessTokenRoute.key()] = createAccessTokenRoute
+	AllHandler[revokeAccessTokenRoute.key()] = revokeAccessTokenRoute
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/config"
+	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// exportHandler sends the current user a ZIP of their data: a JSON file per
+// section, such as posts.json, and the files they uploaded under files/.
+func exportHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+
+	export, err := models.ExportUserData(ctx, ctx.Db.Conn, userId)
+	if err != nil {
+		ctx.Logger().Error("exporting user data failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+
+	// The response has started once the archive is written, so only missing
+	// files are skipped from here on
+	now := time.Now()
+	header := ctx.ResponseWriter.Header()
+	header.Set("Content-Type", "application/zip")
+	header.Set("Content-Disposition", `attachment; filename="social-network-export-`+now.Format("2006-01-02")+`.zip"`)
+	ctx.Status(http.StatusOK)
+
+	archive := zip.NewWriter(ctx.ResponseWriter)
+	names := make([]string, 0, len(export.Sections))
+	for name := range export.Sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: name + ".json", Method: zip.Deflate, Modified: now})
+		if err != nil {
+			ctx.Logger().Error("writing data export failed", "error", err)
+			return
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(export.Sections[name]); err != nil {
+			ctx.Logger().Error("writing data export failed", "error", err)
+			return
+		}
+	}
+	for _, file := range export.Files {
+		if err := exportFile(archive, file, now); err != nil {
+			ctx.Logger().Warn("skipping file from data export", "file", file, "error", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		ctx.Logger().Error("writing data export failed", "error", err)
+		return
+	}
+	ctx.Logger().Info("user data exported", "files", len(export.Files))
+}
+
+// exportFile copies an uploaded file into the archive, under files/.
+func exportFile(archive *zip.Writer, file string, modified time.Time) error {
+	f, err := os.Open(filepath.FromSlash(file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "files/" + path.Base(file), Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+var exportRoute = route{
+	method:  http.MethodGet,
+	group:   authenticated,
+	path:    "/me/export",
+	timeout: time.Minute, // Users with many uploads take a while to archive
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.ExportRateLimit,
+		exportHandler,
+	},
+}
+
+// deleteAccountHandler schedules the deletion of the current user's account
+// once they confirmed their password, and logs them out everywhere. The account
+// is purged after config.AccountDeletionGrace, unless they log in before.
+func deleteAccountHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	var request struct {
+		Password string `json:"password"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+
+	user := models.User{}
+	if err := user.Get(ctx, ctx.Db.Conn, userId); err != nil {
+		ctx.Logger().Error("retrieving the current user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)) != nil {
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "Invalid credentials. Please try again.", nil)
+		return
+	}
+
+	now := time.Now()
+	deletion := models.AccountDeletion{UserID: userId, RequestedAt: now, PurgeAt: now.Add(config.AccountDeletionGrace)}
+	if err := deletion.Schedule(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("scheduling account deletion failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	// Anything still logged in would call the deletion off
+	if err := models.DeleteAccessTokens(ctx, ctx.Db.Conn, userId); err != nil {
+		ctx.Logger().Error("revoking access tokens before account deletion failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if err := config.Sess.Start(ctx).DeleteAll(userId); err != nil {
+		ctx.Logger().Error("revoking sessions before account deletion failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("account deletion scheduled", "purge_at", deletion.PurgeAt)
+	ctx.Status(http.StatusAccepted).JSON(map[string]interface{}{
+		"data":    deletion,
+		"message": "Your account will be deleted. Log in again before then to keep it.",
+	})
+}
+
+var deleteAccountRoute = route{
+	method: http.MethodPost,
+	group:  authenticated,
+	path:   "/me/delete",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		deleteAccountHandler,
+	},
+}
+
+func init() {
+	AllHandler[exportRoute.key()] = exportRoute
+	AllHandler[deleteAccountRoute.key()] = deleteAccountRoute
+}
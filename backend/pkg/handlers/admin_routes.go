@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/config"
+	"Social_Network/pkg/models"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// adminPageSize is how many users are listed at once when the client does not say.
+	adminPageSize = 20
+
+	// adminMaxPageSize is how many users are listed at once at most.
+	adminMaxPageSize = 100
+)
+
+// adminListUsersHandler lists the users matching the query parameters q (part
+// of their email, nickname or names), role and suspended, a page at a time
+// given by limit and offset.
+func adminListUsersHandler(ctx *socialnetwork.Context) {
+	query := ctx.Request.URL.Query()
+	filter := models.UserFilter{
+		Query: strings.TrimSpace(query.Get("q")),
+		Role:  models.SiteRole(query.Get("role")),
+		Limit: adminPageSize,
+	}
+	if filter.Role != "" && !filter.Role.Valid() {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Unknown role "+string(filter.Role)+".", nil)
+		return
+	}
+	if value := query.Get("suspended"); value != "" {
+		suspended, err := strconv.ParseBool(value)
+		if err != nil {
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "suspended must be true or false.", nil)
+			return
+		}
+		filter.Suspended = &suspended
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > adminMaxPageSize {
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "limit must be between 1 and "+strconv.Itoa(adminMaxPageSize)+".", nil)
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "offset must be a positive number.", nil)
+			return
+		}
+		filter.Offset = offset
+	}
+
+	users, total, err := models.SearchUsers(ctx, ctx.Db.Conn, filter)
+	if err != nil {
+		ctx.Logger().Error("searching users failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"data":  users,
+		"total": total,
+	})
+}
+
+var adminListUsersRoute = route{
+	method: http.MethodGet,
+	group:  moderators,
+	path:   "/users",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminListUsersHandler,
+	},
+}
+
+// adminGetUserHandler shows a user as listed by adminListUsersHandler.
+func adminGetUserHandler(ctx *socialnetwork.Context) {
+	userID, err := ctx.ParamUUID("userID")
+	if err != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "User not found.", nil)
+		return
+	}
+
+	users, _, err := models.SearchUsers(ctx, ctx.Db.Conn, models.UserFilter{ID: userID, Limit: 1})
+	if err != nil {
+		ctx.Logger().Error("retrieving user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if len(users) == 0 {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "User not found.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(users[0])
+}
+
+var adminGetUserRoute = route{
+	method: http.MethodGet,
+	group:  moderators,
+	path:   "/users/{userID}",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminGetUserHandler,
+	},
+}
+
+// moderatedUser gets the user given by the userID parameter, if the current
+// user may act on them: moderators on plain users, admins on anyone, but no one
+// on themselves. It responds with an error and returns false otherwise.
+func moderatedUser(ctx *socialnetwork.Context) (models.User, bool) {
+	actorID, _ := ctx.Values["userId"].(uuid.UUID)
+	actorRole, _ := ctx.Values["siteRole"].(models.SiteRole)
+
+	user := models.User{}
+	userID, err := ctx.ParamUUID("userID")
+	if err != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "User not found.", nil)
+		return user, false
+	}
+	if err := user.Get(ctx, ctx.Db.Conn, userID, true); err != nil {
+		ctx.Logger().Error("retrieving user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return user, false
+	}
+	if user.ID == uuid.Nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "User not found.", nil)
+		return user, false
+	}
+	if user.ID == actorID {
+		ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "You cannot do this to your own account.", nil)
+		return user, false
+	}
+	if actorRole != models.SiteRoleAdmin && user.Role.AtLeast(actorRole) {
+		ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "You are not authorized.", nil)
+		return user, false
+	}
+	return user, true
+}
+
+// adminSuspendUserHandler suspends a user for the given reason, and logs them
+// out everywhere. They cannot log in again until unsuspended.
+func adminSuspendUserHandler(ctx *socialnetwork.Context) {
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" || len(request.Reason) > 500 {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "A suspension needs a reason of at most 500 characters.", nil)
+		return
+	}
+	user, ok := moderatedUser(ctx)
+	if !ok {
+		return
+	}
+
+	if err := user.Suspend(ctx, ctx.Db.Conn, request.Reason); err != nil {
+		ctx.Logger().Error("suspending user failed", "user_id", user.ID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if err := config.Sess.Start(ctx).DeleteAll(user.ID); err != nil {
+		ctx.Logger().Error("revoking sessions after suspension failed", "user_id", user.ID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "The user was suspended, but their sessions could not be closed.", nil)
+		return
+	}
+	ctx.Logger().Info("user suspended", "user_id", user.ID, "reason", request.Reason)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "User suspended.",
+	})
+}
+
+var adminSuspendUserRoute = route{
+	method: http.MethodPost,
+	group:  moderators,
+	path:   "/users/{userID}/suspend",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminSuspendUserHandler,
+	},
+}
+
+// adminUnsuspendUserHandler lets a suspended user log in again.
+func adminUnsuspendUserHandler(ctx *socialnetwork.Context) {
+	user, ok := moderatedUser(ctx)
+	if !ok {
+		return
+	}
+
+	if err := user.Unsuspend(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("unsuspending user failed", "user_id", user.ID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("user unsuspended", "user_id", user.ID)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "User unsuspended.",
+	})
+}
+
+var adminUnsuspendUserRoute = route{
+	method: http.MethodPost,
+	group:  moderators,
+	path:   "/users/{userID}/unsuspend",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminUnsuspendUserHandler,
+	},
+}
+
+// adminLogoutUserHandler ends every session of a user, who has to log in again.
+func adminLogoutUserHandler(ctx *socialnetwork.Context) {
+	user, ok := moderatedUser(ctx)
+	if !ok {
+		return
+	}
+
+	if err := config.Sess.Start(ctx).DeleteAll(user.ID); err != nil {
+		ctx.Logger().Error("revoking sessions failed", "user_id", user.ID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("user logged out", "user_id", user.ID)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "User logged out.",
+	})
+}
+
+var adminLogoutUserRoute = route{
+	method: http.MethodPost,
+	group:  moderators,
+	path:   "/users/{userID}/logout",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminLogoutUserHandler,
+	},
+}
+
+// adminSetRoleHandler gives a user another site role.
+func adminSetRoleHandler(ctx *socialnetwork.Context) {
+	var request struct {
+		Role models.SiteRole `json:"role"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+	if !request.Role.Valid() {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Unknown role "+string(request.Role)+".", map[string]interface{}{
+			"roles": []models.SiteRole{models.SiteRoleUser, models.SiteRoleModerator, models.SiteRoleAdmin},
+		})
+		return
+	}
+	user, ok := moderatedUser(ctx)
+	if !ok {
+		return
+	}
+
+	previous := user.Role
+	if err := user.SetRole(ctx, ctx.Db.Conn, request.Role); err != nil {
+		ctx.Logger().Error("changing site role failed", "user_id", user.ID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("site role changed", "user_id", user.ID, "from", previous, "to", user.Role)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Role changed.",
+		"data":    user,
+	})
+}
+
+var adminSetRoleRoute = route{
+	method: http.MethodPut,
+	group:  admins,
+	path:   "/users/{userID}/role",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminSetRoleHandler,
+	},
+}
+
+// adminDeletePostHandler soft-deletes any post, which can be restored later.
+func adminDeletePostHandler(ctx *socialnetwork.Context) {
+	post := models.Post{}
+	postID, err := ctx.ParamUUID("postID")
+	if err != nil || post.Get(ctx, ctx.Db.Conn, postID) != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Post not found.", nil)
+		return
+	}
+
+	if err := post.Delete(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("deleting post failed", "post_id", post.ID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("post deleted by moderator", "post_id", post.ID, "author_id", post.UserID)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Post deleted.",
+	})
+}
+
+var adminDeletePostRoute = route{
+	method: http.MethodDelete,
+	group:  moderators,
+	path:   "/posts/{postID}",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminDeletePostHandler,
+	},
+}
+
+// adminRestorePostHandler brings back a deleted post.
+func adminRestorePostHandler(ctx *socialnetwork.Context) {
+	postID, err := ctx.ParamUUID("postID")
+	if err != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Deleted post not found.", nil)
+		return
+	}
+
+	post := models.Post{ID: postID}
+	err = post.Restore(ctx, ctx.Db.Conn)
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Deleted post not found.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("restoring post failed", "post_id", postID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("post restored", "post_id", postID)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Post restored.",
+	})
+}
+
+var adminRestorePostRoute = route{
+	method: http.MethodPost,
+	group:  moderators,
+	path:   "/posts/{postID}/restore",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminRestorePostHandler,
+	},
+}
+
+// adminDeleteGroupHandler soft-deletes any group, which can be restored later.
+func adminDeleteGroupHandler(ctx *socialnetwork.Context) {
+	group := models.Group{}
+	groupID, err := ctx.ParamUUID("groupID")
+	if err != nil || group.Get(ctx, ctx.Db.Conn, groupID, false, false) != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Group not found.", nil)
+		return
+	}
+
+	if err := group.Delete(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("deleting group failed", "group_id", group.ID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("group deleted by moderator", "group_id", group.ID, "creator_id", group.CreatorID)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Group deleted.",
+	})
+}
+
+var adminDeleteGroupRoute = route{
+	method: http.MethodDelete,
+	group:  moderators,
+	path:   "/groups/{groupID}",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminDeleteGroupHandler,
+	},
+}
+
+// adminRestoreGroupHandler brings back a deleted group.
+func adminRestoreGroupHandler(ctx *socialnetwork.Context) {
+	groupID, err := ctx.ParamUUID("groupID")
+	if err != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Deleted group not found.", nil)
+		return
+	}
+
+	group := models.Group{ID: groupID}
+	err = group.Restore(ctx, ctx.Db.Conn)
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Deleted group not found.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("restoring group failed", "group_id", groupID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("group restored", "group_id", groupID)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Group restored.",
+	})
+}
+
+var adminRestoreGroupRoute = route{
+	method: http.MethodPost,
+	group:  moderators,
+	path:   "/groups/{groupID}/restore",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminRestoreGroupHandler,
+	},
+}
+
+// adminStatsHandler shows the figures of the whole site.
+func adminStatsHandler(ctx *socialnetwork.Context) {
+	stats, err := models.GetSiteStats(ctx, ctx.Db.Conn)
+	if err != nil {
+		ctx.Logger().Error("retrieving site stats failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(stats)
+}
+
+var adminStatsRoute = route{
+	method: http.MethodGet,
+	group:  admins,
+	path:   "/stats",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		adminStatsHandler,
+	},
+}
+
+func init() {
+	AllHandler[adminListUsersRoute.key()] = adminListUsersRoute
+	AllHandler[adminGetUserRoute.key()] = adminGetUserRoute
+	AllHandler[adminSuspendUserRoute.key()] = adminSuspendUserRoute
+	AllHandler[adminUnsuspendUserRoute.key()] = adminUnsuspendUserRoute
+	AllHandler[adminLogoutUserRoute.key()] = adminLogoutUserRoute
+	AllHandler[adminSetRoleRoute.key()] = adminSetRoleRoute
+	AllHandler[adminDeletePostRoute.key()] = adminDeletePostRoute
+	AllHandler[adminRestorePostRoute.key()] = adminRestorePostRoute
+	AllHandler[adminDeleteGroupRoute.key()] = adminDeleteGroupRoute
+	AllHandler[adminRestoreGroupRoute.key()] = adminRestoreGroupRoute
+	AllHandler[adminStatsRoute.key()] = adminStatsRoute
+}
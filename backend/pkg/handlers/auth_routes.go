@@ -2,16 +2,23 @@ package handlers
 
 import (
 	socialnetwork "Social_Network/app"
+	"Social_Network/app/mailer"
+	"Social_Network/app/middleware/ratelimit"
 	"Social_Network/pkg/config"
 	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
-	"encoding/json"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"html"
+	"math"
 	"net/http"
 	"net/mail"
+	"strconv"
+	"sync"
+	"time"
 )
 
 type userCredentials struct {
@@ -32,26 +39,30 @@ func (c *userCredentials) Validate() error {
 	return nil
 }
 
+// dummyPasswordHash is checked against the password of logins with an unknown
+// email, so that they take as long as logins with a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not the password of anyone"), bcrypt.DefaultCost)
+	return hash
+})
+
 var loginHandler = func(ctx *socialnetwork.Context) {
 	var credentials = userCredentials{}
 
 	if err := ctx.BodyParser(&credentials); err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while parsing the form data.",
-			"status":  http.StatusInternalServerError,
-			"data":    nil,
-		})
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while parsing the form data.", nil)
 		return
 	}
 
 	if err := credentials.Validate(); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"session": "",
-			"message": err.Error(),
-			"status":  http.StatusBadRequest,
-			"data":    nil,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, err.Error(), nil)
+		return
+	}
+
+	// Accounts and clients with too many recent failures wait, or are locked out
+	now := time.Now()
+	account, client, ok := loginThrottles(ctx, credentials.Email, now)
+	if !ok {
 		return
 	}
 
@@ -60,58 +71,175 @@ var loginHandler = func(ctx *socialnetwork.Context) {
 		Password: credentials.Password,
 	}
 
-	fmt.Println("newUser loginHandler ************************")
-	fmt.Println(newUser)
-
-	err := newUser.Get(ctx.Db.Conn, credentials.Email, true)
+	// Unknown emails and wrong passwords get the same answer in the same time
+	err := newUser.Get(ctx, ctx.Db.Conn, credentials.Email, true)
+	hash := []byte(newUser.Password)
 	if err != nil {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]interface{}{
-			"session": "",
-			"message": "invalid email.",
-			"status":  http.StatusUnauthorized,
-			"data":    nil,
-		})
+		newUser, hash = models.User{}, dummyPasswordHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(credentials.Password)) != nil || err != nil {
+		loginFailed(ctx, newUser, &account, &client, now)
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "Invalid email or password.", nil)
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(newUser.Password), []byte(credentials.Password)); err != nil {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Invalid credentials. Please try again.",
-			"status":  http.StatusUnauthorized,
-			"data":    nil,
+	startLogin(ctx, newUser)
+}
+
+// loginThrottles returns the failed logins recorded for the account with the
+// given email and for the client. It responds with an error, and ok is false,
+// if either must wait before trying again.
+func loginThrottles(ctx *socialnetwork.Context, email string, now time.Time) (account, client models.LoginThrottle, ok bool) {
+	err := errors.Join(
+		account.Get(ctx, ctx.Db.Conn, models.AccountLoginSubject(email)),
+		client.Get(ctx, ctx.Db.Conn, models.IPLoginSubject(ratelimit.ClientIP(ctx.Request, middleware.Proxies))),
+	)
+	if err != nil {
+		ctx.Logger().Error("retrieving failed logins failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return account, client, false
+	}
+	if wait := max(account.RetryAfter(models.AccountLoginLimit, now), client.RetryAfter(models.IPLoginLimit, now)); wait > 0 {
+		ctx.ResponseWriter.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(wait)))
+		ctx.Error(http.StatusTooManyRequests, socialnetwork.CodeTooManyRequests, "Too many failed login attempts, please retry later.", nil)
+		return account, client, false
+	}
+	return account, client, true
+}
+
+// loginFailed records a failed login with the account and from the client, and
+// tells the owner of the account if it got locked out. user is the zero value
+// if no account uses the email. The caller answers the request.
+func loginFailed(ctx *socialnetwork.Context, user models.User, account, client *models.LoginThrottle, now time.Time) {
+	locked, err := account.Fail(ctx, ctx.Db.Conn, models.AccountLoginLimit, now)
+	if err != nil {
+		ctx.Logger().Error("recording failed login failed", "error", err)
+	}
+	if locked {
+		ctx.Logger().Warn("account locked out after failed logins", "user_id", user.ID, "until", account.LockedUntil.Time)
+		if user.ID != uuid.Nil {
+			notifyLockout(ctx, user, account.LockedUntil.Time)
+		}
+	}
+	clientLocked, err := client.Fail(ctx, ctx.Db.Conn, models.IPLoginLimit, now)
+	if err != nil {
+		ctx.Logger().Error("recording failed login failed", "error", err)
+	}
+	if clientLocked {
+		ctx.Logger().Warn("client locked out after failed logins", "subject", client.Subject, "until", client.LockedUntil.Time)
+	}
+}
+
+// notifyLockout tells a user, by email and in the app, that their account was
+// locked out after failed logins until the given time.
+func notifyLockout(ctx *socialnetwork.Context, user models.User, until time.Time) {
+	minutes := int(math.Ceil(time.Until(until).Minutes()))
+	notification := models.Notification{
+		UserID:    user.ID,
+		ConcernID: user.ID,
+		Type:      models.TypeAccountLocked,
+		Message:   fmt.Sprintf("Logins to your account were blocked for %d minutes after too many failed attempts.", minutes),
+	}
+	if err := notification.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("creating lockout notification failed", "error", err)
+	}
+
+	sendMailInBackground(ctx, mailer.Message{
+		To:      []string{html.UnescapeString(user.Email)},
+		Subject: "Your account was locked",
+		Text: "Hello " + html.UnescapeString(user.FirstName) + ",\n\n" +
+			fmt.Sprintf("After %d failed attempts to log in to your account, the last from %s, logins are blocked for %d minutes.\n\n", models.AccountLoginLimit.MaxFailures, ratelimit.ClientIP(ctx.Request, middleware.Proxies), minutes) +
+			"If these attempts were not yours, someone may be guessing your password. Choose a strong one you use nowhere else, and consider enabling two-factor authentication. " +
+			"Resetting your password also lifts the block:\n\n" +
+			config.ResetPasswordURL + "\n",
+	}, "lockout")
+}
+
+// loginSuspended responds with an error if user was suspended, and tells whether it did.
+func loginSuspended(ctx *socialnetwork.Context, user models.User) bool {
+	if !user.Suspended() {
+		return false
+	}
+	ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "Your account is suspended.", nil)
+	return true
+}
+
+// startLogin logs in a user who gave their first factor: with two-factor
+// authentication, it only earns a challenge to complete with a code at /login/2fa.
+func startLogin(ctx *socialnetwork.Context, user models.User) {
+	if loginSuspended(ctx, user) {
+		return
+	}
+	twoFactor := models.TwoFactor{}
+	err := twoFactor.Get(ctx, ctx.Db.Conn, user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.Logger().Error("retrieving two-factor settings failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if err == nil && twoFactor.Enabled() {
+		challenge := models.LoginChallenge{UserID: user.ID}
+		token, err := challenge.Create(ctx, ctx.Db.Conn)
+		if err != nil {
+			ctx.Logger().Error("creating login challenge failed", "error", err)
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+			return
+		}
+		ctx.Status(http.StatusAccepted).JSON(map[string]interface{}{
+			"challenge":         token,
+			"twoFactorRequired": true,
+			"message":           "Enter the code of your authenticator app, or a recovery code.",
+			"status":            "202",
 		})
 		return
 	}
 
-	idSession, err := config.Sess.Start(ctx).Set(newUser.ID)
+	completeLogin(ctx, user)
+}
+
+// completeLogin starts a session for a user who proved who they are, and
+// responds with it.
+func completeLogin(ctx *socialnetwork.Context, user models.User) {
+	if loginSuspended(ctx, user) {
+		return
+	}
+	// Logging in during the grace period is how a user keeps their account
+	message := "User successfully logged."
+	canceled, err := models.CancelAccountDeletion(ctx, ctx.Db.Conn, user.ID)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while starting the session.",
-			"status":  http.StatusInternalServerError,
-			"data":    nil,
-		})
+		ctx.Logger().Error("canceling account deletion failed", "user_id", user.ID, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if canceled {
+		ctx.Logger().Info("account deletion canceled", "user_id", user.ID)
+		message = "User successfully logged. Your account will not be deleted."
+	}
+	// Failures are forgotten once a login completes, not after the password
+	// alone, so that a second factor cannot be guessed across logins
+	if _, err := models.ClearLoginThrottle(ctx, ctx.Db.Conn, models.AccountLoginSubject(html.UnescapeString(user.Email))); err != nil {
+		ctx.Logger().Error("clearing failed logins failed", "error", err)
+	}
+	idSession, err := config.Sess.Start(ctx).Set(user.ID)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while starting the session.", nil)
 		return
 	}
 
 	ctx.JSON(map[string]interface{}{
 		"session": idSession,
-		"message": "User successfully logged.",
+		"message": message,
 		"status":  "200",
-		"data":    newUser,
+		"data":    user,
 	})
-
-	fmt.Println("ctx.JSON(map[string]interface{}{")
-	fmt.Printf("Context: %+v\n", ctx)
-	debugContext(ctx)
 }
 
 var loginRoute = route{
 	method: http.MethodPost,
+	group:  guests,
 	path:   "/login",
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.NoAuthRequired,
+		middleware.LoginRateLimit,
 		loginHandler,
 	},
 }
@@ -119,60 +247,39 @@ var loginRoute = route{
 var registrationHandler = func(ctx *socialnetwork.Context) {
 	var newUser = models.User{}
 	if err := ctx.BodyParser(&newUser); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while parsing the form data.",
-			"status":  http.StatusBadRequest,
-			"data":    nil,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
 		return
 	}
 
-	err := newUser.Validate(ctx.Db.Conn)
+	err := newUser.Validate(ctx, ctx.Db.Conn)
 	if err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"session": "",
-			"message": err.Error(),
-			"status":  http.StatusBadRequest,
-			"data":    nil,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, err.Error(), nil)
 		return
 	}
 
 	newHash, err := bcrypt.GenerateFromPassword([]byte(newUser.Password), bcrypt.DefaultCost)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while hashing the password.",
-			"status":  http.StatusInternalServerError,
-			"data":    nil,
-		})
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while hashing the password.", nil)
 		return
 	}
 	newUser.Password = string(newHash)
-	if err := newUser.Create(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while creating the user. User already registered.",
-			"status":  http.StatusInternalServerError,
-			"data":    nil,
-		})
+	if err := newUser.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while creating the user. User already registered.", nil)
 		return
 	}
 
+	// The account works without a verified address, within limits, so a mail
+	// failure does not fail the registration: the user can ask for a new link
+	sendVerificationEmail(ctx, newUser)
+
 	idSession, err := config.Sess.Start(ctx).Set(newUser.ID)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while starting the session.",
-			"status":  http.StatusInternalServerError,
-			"data":    nil,
-		})
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while starting the session.", nil)
 		return
 	}
 	ctx.JSON(map[string]interface{}{
 		"session": idSession,
-		"message": "User successfully registered and logged.",
+		"message": "User successfully registered and logged. Please check your email to verify your address.",
 		"status":  "200",
 		"data":    newUser,
 	})
@@ -180,9 +287,10 @@ var registrationHandler = func(ctx *socialnetwork.Context) {
 
 var registrationRoute = route{
 	method: http.MethodPost,
+	group:  guests,
 	path:   "/registration",
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.NoAuthRequired,
+		middleware.RegistrationRateLimit,
 		registrationHandler,
 	},
 }
@@ -203,9 +311,7 @@ func LogoutHandler(ctx *socialnetwork.Context) {
 	token := ctx.Values["token"].(string)
 	err := config.Sess.Start(ctx).Delete(token)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]string{
-			"error": "Error while deleting session",
-		})
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while deleting session", nil)
 		return
 	}
 
@@ -214,28 +320,21 @@ func LogoutHandler(ctx *socialnetwork.Context) {
 
 var logoutRoute = route{
 	method: http.MethodDelete,
+	group:  authenticated,
 	path:   "/logout",
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		LogoutHandler,
 	},
 }
 
 func meHandler(ctx *socialnetwork.Context) {
-	fmt.Println("ctx *******************************************")
-	fmt.Printf("Context: %+v\n", ctx)
-	fmt.Printf("Type of userId: %T\n", ctx.Values["userId"])
-
 	userId, _ := ctx.Values["userId"].(uuid.UUID)
 
-	fmt.Println("userId *******************************************")
-	fmt.Println(userId)
-
 	user := models.User{}
-	err := user.Get(ctx.Db.Conn, userId)
+	err := user.Get(ctx, ctx.Db.Conn, userId, true)
 	if err != nil {
-		fmt.Println("Error retrieving user:", err)
-		ctx.Status(http.StatusInternalServerError)
+		ctx.Logger().Error("retrieving the current user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
 		return
 	}
 	ctx.Status(http.StatusOK).JSON(user)
@@ -243,26 +342,18 @@ func meHandler(ctx *socialnetwork.Context) {
 
 var meRoute = route{
 	method: http.MethodGet,
+	group:  authenticated,
 	path:   "/me",
+	scope:  "read:users",
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		meHandler,
 	},
 }
 
 func init() {
-	AllHandler[loginRoute.path] = loginRoute
-	AllHandler[logoutRoute.path] = logoutRoute
-	AllHandler[meRoute.path] = meRoute
-	AllHandler[registrationRoute.path] = registrationRoute
-	AllHandler[healthRoute.path] = healthRoute
-}
-
-func debugContext(ctx *socialnetwork.Context) {
-	jsonData, err := json.MarshalIndent(ctx, "", "  ")
-	if err != nil {
-		fmt.Println("Error serializing context to JSON:", err)
-		return
-	}
-	fmt.Println(string(jsonData))
+	AllHandler[loginRoute.key()] = loginRoute
+	AllHandler[logoutRoute.key()] = logoutRoute
+	AllHandler[meRoute.key()] = meRoute
+	AllHandler[registrationRoute.key()] = registrationRoute
+	AllHandler[healthRoute.key()] = healthRoute
 }
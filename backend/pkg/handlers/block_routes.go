@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/models"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// relationTarget gets the user given by nickname in the request body, whom the
+// current user wants to block or mute. It responds with an error and returns
+// false if there is none, or if it is the current user.
+func relationTarget(ctx *socialnetwork.Context) (models.User, bool) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	var request struct {
+		Nickname string `json:"nickname"`
+	}
+	user := models.User{}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return user, false
+	}
+	if request.Nickname == "" || user.Get(ctx, ctx.Db.Conn, request.Nickname) != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "User not found.", nil)
+		return user, false
+	}
+	if user.ID == userId {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "You cannot do this to yourself.", nil)
+		return user, false
+	}
+	return user, true
+}
+
+// listBlocksHandler lists the users the current user blocked.
+func listBlocksHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+
+	blocks, err := models.ListBlocks(ctx, ctx.Db.Conn, userId)
+	if err != nil {
+		ctx.Logger().Error("listing blocks failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(blocks)
+}
+
+var listBlocksRoute = route{
+	method: http.MethodGet,
+	group:  authenticated,
+	path:   "/me/blocks",
+	scope:  "read:users",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		listBlocksHandler,
+	},
+}
+
+// blockHandler blocks the user given by nickname, which also ends the follow
+// relationships between them and the current user.
+func blockHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	user, ok := relationTarget(ctx)
+	if !ok {
+		return
+	}
+
+	block := models.Block{BlockerID: userId, BlockedID: user.ID}
+	if err := block.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("blocking user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "User blocked.",
+	})
+}
+
+var blockRoute = route{
+	method: http.MethodPost,
+	group:  authenticated,
+	path:   "/me/blocks",
+	scope:  "write:users",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		blockHandler,
+	},
+}
+
+// unblockHandler lifts the block of a user. Follow relationships are not restored.
+func unblockHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	blockedID, err := ctx.ParamUUID("userID")
+	if err != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Block not found.", nil)
+		return
+	}
+
+	block := models.Block{BlockerID: userId, BlockedID: blockedID}
+	err = block.Delete(ctx, ctx.Db.Conn)
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Block not found.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("unblocking user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "User unblocked.",
+	})
+}
+
+var unblockRoute = route{
+	method: http.MethodDelete,
+	group:  authenticated,
+	path:   "/me/blocks/{userID}",
+	scope:  "write:users",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		unblockHandler,
+	},
+}
+
+// listMutesHandler lists the users the current user muted.
+func listMutesHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+
+	mutes, err := models.ListMutes(ctx, ctx.Db.Conn, userId)
+	if err != nil {
+		ctx.Logger().Error("listing mutes failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(mutes)
+}
+
+var listMutesRoute = route{
+	method: http.MethodGet,
+	group:  authenticated,
+	path:   "/me/mutes",
+	scope:  "read:users",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		listMutesHandler,
+	},
+}
+
+// muteHandler mutes the user given by nickname, whose posts, comments and
+// notifications the current user then no longer sees.
+func muteHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	user, ok := relationTarget(ctx)
+	if !ok {
+		return
+	}
+
+	mute := models.Mute{MuterID: userId, MutedID: user.ID}
+	if err := mute.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("muting user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "User muted.",
+	})
+}
+
+var muteRoute = route{
+	method: http.MethodPost,
+	group:  authenticated,
+	path:   "/me/mutes",
+	scope:  "write:users",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		muteHandler,
+	},
+}
+
+// unmuteHandler lifts the mute of a user.
+func unmuteHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	mutedID, err := ctx.ParamUUID("userID")
+	if err != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Mute not found.", nil)
+		return
+	}
+
+	mute := models.Mute{MuterID: userId, MutedID: mutedID}
+	err = mute.Delete(ctx, ctx.Db.Conn)
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Mute not found.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("unmuting user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "User unmuted.",
+	})
+}
+
+var unmuteRoute = route{
+	method: http.MethodDelete,
+	group:  authenticated,
+	path:   "/me/mutes/{userID}",
+	scope:  "write:users",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		unmuteHandler,
+	},
+}
+
+func init() {
+	AllHandler[listBlocksRoute.key()] = listBlocksRoute
+	AllHandler[blockRoute.key()] = blockRoute
+	AllHandler[unblockRoute.key()] = unblockRoute
+	AllHandler[listMutesRoute.key()] = listMutesRoute
+	AllHandler[muteRoute.key()] = muteRoute
+	AllHandler[unmuteRoute.key()] = unmuteRoute
+}
@@ -2,36 +2,162 @@ package handlers
 
 import (
 	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
+	"database/sql"
 	"net/http"
+	"time"
 )
 
 // HandlerConstructor is a type alias for a function that takes a path and a variadic number of HandlerFuncs.
 // It's used to define the constructor for creating new routes with associated middleware and handlers.
-type HandlerConstructor func(path string, middlewareAndHandler ...socialnetwork.HandlerFunc)
+type HandlerConstructor func(path string, middlewareAndHandler ...socialnetwork.HandlerFunc) *socialnetwork.Route
 
 // route represents a route with its associated path, constructor, and middleware/handler functions.
+// Routes belonging to a group inherit the group's path prefix and middleware; path is relative to it.
+// timeout bounds the request's context; zero keeps the app default and noTimeout disables it.
+// scope is the personal access token scope the route requires; routes without one are only
+// reachable with a session.
 type route struct {
 	path, method         string
+	group                *routeGroup
+	timeout              time.Duration
+	scope                string
 	middlewareAndHandler []socialnetwork.HandlerFunc
 }
 
-// AllHandler is a map that defines all the routes for the application.
+// noTimeout disables the request timeout of a route, for long-lived connections.
+const noTimeout time.Duration = -1
+
+// key identifies the route in AllHandler by its method and full pattern.
+func (r route) key() string {
+	return r.method + " " + r.group.fullPrefix() + r.path
+}
+
+// routeGroup describes a set of routes sharing a path prefix and middleware.
+// Groups nest through parent and are turned into socialnetwork.RouterGroup values by HandleAll.
+type routeGroup struct {
+	parent     *routeGroup
+	prefix     string
+	middleware []socialnetwork.HandlerFunc
+}
+
+var (
+	// guests groups the routes only reachable without a valid session.
+	guests = &routeGroup{
+		middleware: []socialnetwork.HandlerFunc{middleware.NoAuthRequired},
+	}
+
+	// authenticated groups the routes requiring a valid session.
+	authenticated = &routeGroup{
+		middleware: []socialnetwork.HandlerFunc{middleware.AuthRequired},
+	}
+
+	// verified groups the routes reserved to users who confirmed their email address.
+	verified = &routeGroup{
+		parent:     authenticated,
+		middleware: []socialnetwork.HandlerFunc{middleware.VerifiedRequired},
+	}
+
+	// moderators groups the /admin routes open to moderators and admins.
+	moderators = &routeGroup{
+		parent:     authenticated,
+		prefix:     "/admin",
+		middleware: []socialnetwork.HandlerFunc{middleware.RequireSiteRole(models.SiteRoleModerator)},
+	}
+
+	// admins groups the /admin routes reserved to admins.
+	admins = &routeGroup{
+		parent:     moderators,
+		middleware: []socialnetwork.HandlerFunc{middleware.RequireSiteRole(models.SiteRoleAdmin)},
+	}
+
+	// existingGroup groups the routes acting on the group given by ?group_id=.
+	existingGroup = &routeGroup{
+		parent:     verified,
+		middleware: []socialnetwork.HandlerFunc{middleware.IsGroupExist},
+	}
+
+	// groupMembers groups the routes reserved to members of the group given by ?group_id=.
+	groupMembers = &routeGroup{
+		parent:     existingGroup,
+		middleware: []socialnetwork.HandlerFunc{middleware.HaveGroupAccess},
+	}
+
+	// groupResource groups the routes living under /groups/{groupID}.
+	groupResource = &routeGroup{
+		parent:     verified,
+		prefix:     "/groups/{groupID}",
+		middleware: []socialnetwork.HandlerFunc{middleware.IsGroupExist},
+	}
+
+	// groupMemberResource groups the routes under /groups/{groupID} reserved to members.
+	groupMemberResource = &routeGroup{
+		parent:     groupResource,
+		middleware: []socialnetwork.HandlerFunc{middleware.HaveGroupAccess},
+	}
+)
+
+// fullPrefix returns the path prefix of the group including its parents.
+func (g *routeGroup) fullPrefix() string {
+	if g == nil {
+		return ""
+	}
+	return g.parent.fullPrefix() + g.prefix
+}
+
+// router returns the socialnetwork.RouterGroup for the group, creating it and its parents on first use.
+// A nil group stands for routes registered without any group middleware.
+func (g *routeGroup) router(app *socialnetwork.App, routers map[*routeGroup]*socialnetwork.RouterGroup) *socialnetwork.RouterGroup {
+	if r, ok := routers[g]; ok {
+		return r
+	}
+	var r *socialnetwork.RouterGroup
+	switch {
+	case g == nil:
+		r = app.Group("")
+	case g.parent == nil:
+		r = app.Group(g.prefix, g.middleware...)
+	default:
+		r = g.parent.router(app, routers).Group(g.prefix, g.middleware...)
+	}
+	routers[g] = r
+	return r
+}
+
+// AllHandler is a map that defines all the routes for the application, keyed by method and full pattern.
 // Each route includes the path, the constructor for creating the route, and the middleware/handler functions to be executed.
 var AllHandler = map[string]route{}
 
 // HandleAll is a function that iterates over the AllHandler map and applies each Handler's constructor to register the routes.
 // This function should be called during the initialization phase of the application to set up all the routes.
 var HandleAll = func(app *socialnetwork.App) {
-	// Use a map of HTTP methods to corresponding route constructors
-	var mapConstructors = map[string]HandlerConstructor{
-		http.MethodGet:    app.GET,
-		http.MethodDelete: app.DELETE,
-		http.MethodPost:   app.POST,
-		http.MethodPut:    app.PUT,
-	}
+	routers := map[*routeGroup]*socialnetwork.RouterGroup{}
 
 	// Iterate over the AllHandler map and apply the constructors
 	for _, v := range AllHandler {
-		mapConstructors[v.method](v.path, v.middlewareAndHandler...) // Apply the method-specific constructor
+		router := v.group.router(app, routers)
+
+		// Use a map of HTTP methods to corresponding route constructors
+		var mapConstructors = map[string]HandlerConstructor{
+			http.MethodGet:    router.GET,
+			http.MethodDelete: router.DELETE,
+			http.MethodPost:   router.POST,
+			http.MethodPut:    router.PUT,
+		}
+		// Check the scope of access tokens before anything else the route does
+		middlewareAndHandler := append([]socialnetwork.HandlerFunc{middleware.ScopeRequired(v.scope)}, v.middlewareAndHandler...)
+		mapConstructors[v.method](v.path, middlewareAndHandler...).Timeout(v.timeout) // Apply the method-specific constructor
+	}
+
+	var db *sql.DB
+	if app.Db != nil {
+		db = app.Db.Conn
 	}
+	registerMetrics(db)
+
+	// Forward notifications and messages to WebSocket clients from the start,
+	// and say goodbye to them before the database goes away
+	app.OnStart(startBroadcaster)
+	app.OnShutdown(closeSockets)
 }
@@ -7,7 +7,6 @@ import (
 	"Social_Network/pkg/models"
 
 	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -15,14 +14,12 @@ import (
 
 // createEventHandler handles the creation of a new event in a group.
 func createEventHandler(ctx *socialnetwork.Context) {
-	newEvent := models.Event{}
-
-	// Parse the request body into the newEvent object
-	if err := ctx.BodyParser(&newEvent); err != nil {
-		// Handle error if body parsing fails
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
-		return
+	// The body was validated by CreateEventMiddleware
+	req := ctx.Values["request"].(*middleware.EventRequest)
+	newEvent := models.Event{
+		Title:       req.Title,
+		Description: req.Description,
+		DateTime:    req.DateTime,
 	}
 
 	// Set the creator and group IDs from the context values
@@ -30,19 +27,19 @@ func createEventHandler(ctx *socialnetwork.Context) {
 	newEvent.GroupID = ctx.Values["group_id"].(uuid.UUID)
 
 	// Create the event in the database
-	if err := newEvent.Create(ctx.Db.Conn); err != nil {
+	if err := newEvent.Create(ctx, ctx.Db.Conn); err != nil {
 		// Handle error if event creation fails
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("creating event failed", "error", err)
 		return
 	}
 
 	// Fetch group members to send notifications
 	members := new(models.GroupMembers)
-	if err := members.Get(ctx.Db.Conn, newEvent.GroupID, models.MemberStatusAccepted); err != nil {
+	if err := members.Get(ctx, ctx.Db.Conn, newEvent.GroupID, models.MemberStatusAccepted); err != nil {
 		// Handle error if fetching members fails
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("fetching group members failed", "error", err)
 		return
 	}
 
@@ -51,7 +48,6 @@ func createEventHandler(ctx *socialnetwork.Context) {
 
 	// Notify all group members about the new event
 	for _, member := range *members {
-		log.Println(member.ID)
 		notif := &models.Notification{
 			UserID:    newEvent.CreatorID,
 			ConcernID: member.MemberID,
@@ -62,11 +58,10 @@ func createEventHandler(ctx *socialnetwork.Context) {
 		}
 
 		// Create notification for the member
-		if err := notif.Create(ctx.Db.Conn); err != nil {
-			log.Println(err)
+		if err := notif.Create(ctx, ctx.Db.Conn); err != nil {
+			ctx.Logger().Error("creating event notification failed", "error", err)
 			continue // Continue on error, but do not stop execution
 		}
-		log.Println(notif.ID)
 	}
 
 	// Return success response with the created event
@@ -79,12 +74,12 @@ func createEventHandler(ctx *socialnetwork.Context) {
 // Define the route for creating an event
 var createEventRoute = route{
 	path:   "/create-event", // Path for creating event
+	scope:  "write:groups",  // Access token scope required
 	method: http.MethodPost, // HTTP method (POST)
+	group:  groupMembers,    // Authenticated members of the group given by ?group_id=
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,    // Authentication required
-		middleware.IsGroupExist,    // Check if the group exists
-		middleware.HaveGroupAccess, // Check if the user has access to the group
-		createEventHandler,         // Event handler
+		middleware.CreateEventMiddleware, // Check the event data
+		createEventHandler,               // Event handler
 	},
 }
 
@@ -97,11 +92,11 @@ func getAllEventByGroup(ctx *socialnetwork.Context) {
 	isUserNeeded := ctx.Request.URL.Query().Get("isUserNeeded") == "true"
 
 	// Fetch the events from the database
-	err := events.GetGroupEvents(ctx.Db.Conn, groupId, isParticipantNeeded, isUserNeeded)
+	err := events.GetGroupEvents(ctx, ctx.Db.Conn, groupId, isParticipantNeeded, isUserNeeded)
 	if err != nil {
 		// Handle error if fetching events fails
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("fetching group events failed", "error", err)
 		return
 	}
 
@@ -115,12 +110,22 @@ func getAllEventByGroup(ctx *socialnetwork.Context) {
 // Define the route for getting all events in a group
 var getAllEventRoute = route{
 	path:   "/get-all-event-group", // Path for getting events
+	scope:  "read:groups",          // Access token scope required
 	method: http.MethodGet,         // HTTP method (GET)
+	group:  groupMembers,           // Authenticated members of the group given by ?group_id=
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,    // Authentication required
-		middleware.IsGroupExist,    // Check if the group exists
-		middleware.HaveGroupAccess, // Check if the user has access to the group
-		getAllEventByGroup,         // Event handler
+		getAllEventByGroup, // Event handler
+	},
+}
+
+// Define the resource route for getting all events in a group
+var groupEventsResourceRoute = route{
+	path:   "/events",           // Path for getting events
+	scope:  "read:groups",       // Access token scope required
+	method: http.MethodGet,      // HTTP method (GET)
+	group:  groupMemberResource, // Authenticated members of the group under /groups/{groupID}
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		getAllEventByGroup, // Event handler
 	},
 }
 
@@ -130,39 +135,33 @@ func respondEventHandler(ctx *socialnetwork.Context) {
 	member := ctx.Values["member"].(*models.GroupMember)
 
 	participant := models.EventParticipant{}
-	_participant := models.EventParticipant{}
+	_participant := struct {
+		Response models.EventResponse `json:"response" validate:"required,oneof=going|not_going"`
+	}{}
 
-	// Parse the participant response from the request body
-	if err := ctx.BodyParser(&_participant); err != nil {
-		// Handle error if body parsing fails
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
-		return
-	}
-
-	// Validate the participant response (should be either 'going' or 'not_going')
-	if _participant.Response != "not_going" && _participant.Response != "going" {
-		ctx.Status(http.StatusBadRequest).JSON("Invalid response")
+	// Parse and validate the participant response from the request body
+	if err := ctx.Bind(&_participant); err != nil {
+		ctx.BindError(err)
 		return
 	}
 
 	// Fetch existing participant data or create a new participant record
-	err := participant.GetParticipant(ctx.Db.Conn, event.ID, member.ID, member.MemberID, false)
+	err := participant.GetParticipant(ctx, ctx.Db.Conn, event.ID, member.ID, member.MemberID, false)
 	participant.Response = _participant.Response
 	if err != nil {
 		// Create new participant if not found
-		err := participant.CreateParticipant(ctx.Db.Conn, event.ID, member.ID)
+		err := participant.CreateParticipant(ctx, ctx.Db.Conn, event.ID, member.ID)
 		if err != nil {
-			ctx.Status(http.StatusInternalServerError)
-			log.Println(err)
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+			ctx.Logger().Error("creating event participant failed", "error", err)
 			return
 		}
 	} else {
 		// Update participant response if already exists
-		err := participant.UpdateParticipant(ctx.Db.Conn)
+		err := participant.UpdateParticipant(ctx, ctx.Db.Conn)
 		if err != nil {
-			ctx.Status(http.StatusInternalServerError)
-			log.Println(err)
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+			ctx.Logger().Error("updating event participant failed", "error", err)
 			return
 		}
 	}
@@ -177,19 +176,32 @@ func respondEventHandler(ctx *socialnetwork.Context) {
 // Define the route for responding to an event
 var respondEventRoute = route{
 	path:   "/response-event", // Path for responding to an event
+	scope:  "write:groups",    // Access token scope required
 	method: http.MethodPost,   // HTTP method (POST)
+	group:  groupMembers,      // Authenticated members of the group given by ?group_id=
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.IsEventExist, // Check if the event exists
+		respondEventHandler,     // Event handler
+	},
+}
+
+// Define the resource route for responding to an event
+var eventResponseResourceRoute = route{
+	path:   "/events/{eventID}/response", // Path for responding to an event
+	scope:  "write:groups",               // Access token scope required
+	method: http.MethodPost,              // HTTP method (POST)
+	group:  groupMemberResource,          // Authenticated members of the group under /groups/{groupID}
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,    // Authentication required
-		middleware.IsGroupExist,    // Check if the group exists
-		middleware.HaveGroupAccess, // Check if the user has access to the group
-		middleware.IsEventExist,    // Check if the event exists
-		respondEventHandler,        // Event handler
+		middleware.IsEventExist, // Check if the event exists
+		respondEventHandler,     // Event handler
 	},
 }
 
 // Initialize routes for the application
 func init() {
-	AllHandler[createEventRoute.path] = createEventRoute
-	AllHandler[getAllEventRoute.path] = getAllEventRoute
-	AllHandler[respondEventRoute.path] = respondEventRoute
+	AllHandler[createEventRoute.key()] = createEventRoute
+	AllHandler[getAllEventRoute.key()] = getAllEventRoute
+	AllHandler[respondEventRoute.key()] = respondEventRoute
+	AllHandler[groupEventsResourceRoute.key()] = groupEventsResourceRoute
+	AllHandler[eventResponseResourceRoute.key()] = eventResponseResourceRoute
 }
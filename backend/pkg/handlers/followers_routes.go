@@ -2,10 +2,7 @@ package handlers
 
 import (
 	socialnetwork "Social_Network/app"
-	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
-	"fmt"
-	"log"
 	"net/http"
 	"slices"
 
@@ -21,27 +18,18 @@ func handleFollower(ctx *socialnetwork.Context) {
 
 	req := new(request)
 	if err := ctx.BodyParser(req); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": "Error while parsing the form data.",
-			"status":  http.StatusBadRequest,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
 		return
 	}
 
 	user := new(models.User)
 
-	if err := user.Get(ctx.Db.Conn, req.Id); err != nil {
-		ctx.Status(http.StatusNotFound).JSON(map[string]interface{}{
-			"message": "User not found.",
-			"status":  http.StatusNotFound,
-		})
+	if err := user.Get(ctx, ctx.Db.Conn, req.Id); err != nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "User not found.", nil)
 		return
 	}
 	if user.ID == userId {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": "Invalid request.",
-			"status":  http.StatusBadRequest,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid request.", nil)
 		return
 	}
 
@@ -53,17 +41,24 @@ func handleFollower(ctx *socialnetwork.Context) {
 	if req.Action == "accept" || req.Action == "decline" {
 		reverse = true
 	}
-	follow.Get(ctx.Db.Conn, reverse)
+	follow.Get(ctx, ctx.Db.Conn, reverse)
 
 	notif := new(models.Notification)
 
 	switch req.Action {
 	case "follow":
+		blocked, err := models.IsBlocked(ctx, ctx.Db.Conn, userId, user.ID)
+		if err != nil {
+			ctx.Logger().Error("checking block failed", "error", err)
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+			return
+		}
+		if blocked {
+			ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "You cannot follow this user.", nil)
+			return
+		}
 		if follow.Status == models.StatusRequested || follow.Status == models.StatusAccepted || follow.Status == models.StatusDeclined {
-			ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-				"message": "You have already sent a follow request.",
-				"status":  http.StatusBadRequest,
-			})
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "You have already sent a follow request.", nil)
 			return
 		}
 		if user.IsPublic {
@@ -71,11 +66,8 @@ func handleFollower(ctx *socialnetwork.Context) {
 		} else {
 			follow.Status = models.StatusRequested
 		}
-		if err := follow.Create(ctx.Db.Conn); err != nil {
-			ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-				"message": err.Error(),
-				"status":  http.StatusInternalServerError,
-			})
+		if err := follow.Create(ctx, ctx.Db.Conn); err != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 			return
 		}
 		notif.UserID = follow.FollowerID
@@ -97,18 +89,12 @@ func handleFollower(ctx *socialnetwork.Context) {
 		return
 	case "unfollow":
 		if follow.Status != models.StatusAccepted && follow.Status != models.StatusRequested {
-			ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-				"message": "You are not following this user.",
-				"status":  http.StatusBadRequest,
-			})
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "You are not following this user.", nil)
 			return
 
 		}
-		if err := follow.Delete(ctx.Db.Conn); err != nil {
-			ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-				"message": err.Error(),
-				"status":  http.StatusInternalServerError,
-			})
+		if err := follow.Delete(ctx, ctx.Db.Conn); err != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 			return
 		}
 		notif.UserID = follow.FollowerID
@@ -124,18 +110,12 @@ func handleFollower(ctx *socialnetwork.Context) {
 		return
 	case "accept":
 		if follow.Status != models.StatusRequested {
-			ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-				"message": "Invalid request.",
-				"status":  http.StatusBadRequest,
-			})
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid request.", nil)
 			return
 		}
 		follow.Status = models.StatusAccepted
-		if err := follow.Update(ctx.Db.Conn); err != nil {
-			ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-				"message": err.Error(),
-				"status":  http.StatusInternalServerError,
-			})
+		if err := follow.Update(ctx, ctx.Db.Conn); err != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 			return
 		}
 
@@ -144,8 +124,8 @@ func handleFollower(ctx *socialnetwork.Context) {
 		currentNotif.ConcernID = follow.FolloweeID
 		currentNotif.Type = models.TypeFollowRequest
 
-		currentNotif.Get(ctx.Db.Conn)
-		currentNotif.Delete(ctx.Db.Conn)
+		currentNotif.Get(ctx, ctx.Db.Conn)
+		currentNotif.Delete(ctx, ctx.Db.Conn)
 
 		notif.UserID = follow.FolloweeID
 		notif.ConcernID = follow.FollowerID
@@ -161,18 +141,12 @@ func handleFollower(ctx *socialnetwork.Context) {
 	case "decline":
 
 		if follow.Status != models.StatusRequested {
-			ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-				"message": "Invalid request.",
-				"status":  http.StatusBadRequest,
-			})
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid request.", nil)
 			return
 		}
 		follow.Status = models.StatusDeclined
-		if err := follow.Update(ctx.Db.Conn); err != nil {
-			ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-				"message": err.Error(),
-				"status":  http.StatusInternalServerError,
-			})
+		if err := follow.Update(ctx, ctx.Db.Conn); err != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 			return
 		}
 		currentNotif := new(models.Notification)
@@ -180,8 +154,8 @@ func handleFollower(ctx *socialnetwork.Context) {
 		currentNotif.ConcernID = follow.FolloweeID
 		currentNotif.Type = models.TypeFollowRequest
 
-		currentNotif.Get(ctx.Db.Conn)
-		currentNotif.Delete(ctx.Db.Conn)
+		currentNotif.Get(ctx, ctx.Db.Conn)
+		currentNotif.Delete(ctx, ctx.Db.Conn)
 
 		notif.UserID = follow.FolloweeID
 		notif.ConcernID = follow.FollowerID
@@ -194,10 +168,7 @@ func handleFollower(ctx *socialnetwork.Context) {
 		})
 		return
 	default:
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": "Invalid action.",
-			"status":  http.StatusBadRequest,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid action.", nil)
 		return
 	}
 }
@@ -209,21 +180,18 @@ func createNotif(notif *models.Notification, ctx *socialnetwork.Context) {
 		newNotif.Type = t
 		newNotif.UserID = notif.UserID
 		newNotif.ConcernID = notif.ConcernID
-		err := notif.Get(ctx.Db.Conn)
+		err := notif.Get(ctx, ctx.Db.Conn)
 		if err != nil {
 			continue
 		}
-		err = newNotif.Delete(ctx.Db.Conn)
+		err = newNotif.Delete(ctx, ctx.Db.Conn)
 		if err != nil {
 			continue
 		}
 	}
-	if err := notif.Create(ctx.Db.Conn); err != nil {
-		log.Println(err)
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"message": err.Error(),
-			"status":  http.StatusInternalServerError,
-		})
+	if err := notif.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("creating follow notification failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 		return
 	}
 }
@@ -231,14 +199,13 @@ func createNotif(notif *models.Notification, ctx *socialnetwork.Context) {
 func handleGetAllFollowersRequest(ctx *socialnetwork.Context) {
 
 	userUUID := ctx.Values["userId"].(uuid.UUID)
-	fmt.Println(userUUID)
 	userFollowers := models.Followers{}
-	userFollowers.GetAllByFolloweeID(ctx.Db.Conn, userUUID)
+	userFollowers.GetAllByFolloweeID(ctx, ctx.Db.Conn, userUUID)
 	userFollowersJson := []map[string]interface{}{}
 	for _, follower := range userFollowers {
 		newUser := models.User{}
-		if err := newUser.Get(ctx.Db.Conn, follower.FollowerID); err != nil {
-			ctx.Status(http.StatusInternalServerError)
+		if err := newUser.Get(ctx, ctx.Db.Conn, follower.FollowerID); err != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
 			return
 		}
 		userFollowersJson = append(userFollowersJson,
@@ -251,8 +218,6 @@ func handleGetAllFollowersRequest(ctx *socialnetwork.Context) {
 			},
 		)
 	}
-	fmt.Println(userFollowersJson)
-	// fmt.Println(userFollowersJson)
 	ctx.JSON(map[string]interface{}{
 		"status": http.StatusOK,
 		"data":   userFollowersJson,
@@ -265,9 +230,9 @@ func handleGetAllFolloweesRequest(ctx *socialnetwork.Context) {
 	userUUID := ctx.Values["userId"].(uuid.UUID)
 
 	userFollowers := models.Followers{}
-	if err := userFollowers.GetAllByFollowerID(ctx.Db.Conn, userUUID); err != nil {
-		fmt.Println(err)
-		ctx.Status(http.StatusInternalServerError)
+	if err := userFollowers.GetAllByFollowerID(ctx, ctx.Db.Conn, userUUID); err != nil {
+		ctx.Logger().Error("fetching followees failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
 		return
 	}
 	userFollowersJson := []map[string]interface{}{}
@@ -286,9 +251,9 @@ func handleGetAllFolloweesRequest(ctx *socialnetwork.Context) {
 		}
 
 		newUser := models.User{}
-		if err := newUser.Get(ctx.Db.Conn, id); err != nil {
-			fmt.Println(err)
-			ctx.Status(http.StatusInternalServerError)
+		if err := newUser.Get(ctx, ctx.Db.Conn, id); err != nil {
+			ctx.Logger().Error("fetching followee failed", "user_id", id, "error", err)
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
 			return
 		}
 		userFollowersJson = append(userFollowersJson,
@@ -313,34 +278,37 @@ func handleGetAllFolloweesRequest(ctx *socialnetwork.Context) {
 // It specifies the HTTP method (POST), the path for the endpoint, and the sequence of middleware and handler functions to execute.
 var FollowerRoute = route{
 	path:   "/follower",
+	scope:  "write:users",
 	method: http.MethodPost,
+	group:  verified,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Middleware to check if the request is authenticated.
-		handleFollower,          // Handler function to process the follower request.
+		handleFollower, // Handler function to process the follower request.
 	},
 }
 
 var getAllFollowers = route{
 	path:   "/getAllFollowers",
+	scope:  "read:users",
 	method: http.MethodGet,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,      // Middleware to check if the request is authenticated.
 		handleGetAllFollowersRequest, // Handler function to process the follower request.
 	},
 }
 
 var getAllFollowees = route{
 	path:   "/getAllFollowees",
+	scope:  "read:users",
 	method: http.MethodGet,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,      // Middleware to check if the request is authenticated.
 		handleGetAllFolloweesRequest, // Handler function to process the follower request.
 	},
 }
 
 func init() {
 	// Register the follower route with the global AllHandler map.
-	AllHandler[FollowerRoute.path] = FollowerRoute
-	AllHandler[getAllFollowers.path] = getAllFollowers
-	AllHandler[getAllFollowees.path] = getAllFollowees
+	AllHandler[FollowerRoute.key()] = FollowerRoute
+	AllHandler[getAllFollowers.key()] = getAllFollowers
+	AllHandler[getAllFollowees.key()] = getAllFollowees
 }
@@ -5,25 +5,23 @@ import (
 	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
 
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
 )
 
 func createGroup(ctx *socialnetwork.Context) {
-	newGroup := models.Group{}
-
-	if err := ctx.BodyParser(&newGroup); err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
-		return
+	req := ctx.Values["request"].(*middleware.GroupRequest)
+	newGroup := models.Group{
+		Title:       req.Title,
+		Description: req.Description,
+		BannerURL:   req.BannerURL,
 	}
 
 	newGroup.CreatorID = ctx.Values["userId"].(uuid.UUID)
-	if err := newGroup.Create(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+	if err := newGroup.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("creating group failed", "error", err)
 		return
 	}
 
@@ -35,9 +33,10 @@ func createGroup(ctx *socialnetwork.Context) {
 
 var createGroupRoute = route{
 	path:   "/create-group",
+	scope:  "write:groups",
 	method: http.MethodPost,
+	group:  verified,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		middleware.IsGroupValid,
 		createGroup,
 	},
@@ -47,10 +46,10 @@ func getAllGroups(ctx *socialnetwork.Context) {
 	groups := models.Groups{}
 	isMemberNeeded := ctx.Request.URL.Query().Get("isMemberNeeded") == "true"
 	isUserNeeded := ctx.Request.URL.Query().Get("isUserNeeded") == "true"
-	err := groups.GetAllGroups(ctx.Db.Conn, isMemberNeeded, isUserNeeded)
+	err := groups.GetAllGroups(ctx, ctx.Db.Conn, isMemberNeeded, isUserNeeded)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("fetching groups failed", "error", err)
 		return
 	}
 
@@ -62,9 +61,10 @@ func getAllGroups(ctx *socialnetwork.Context) {
 
 var getAllGroupsRoute = route{
 	path:   "/get-all-groups",
+	scope:  "read:groups",
 	method: http.MethodGet,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		getAllGroups,
 	},
 }
@@ -74,10 +74,10 @@ func getGroupById(ctx *socialnetwork.Context) {
 	groupID := ctx.Values["group_id"].(uuid.UUID)
 	isMemberNeeded := ctx.Request.URL.Query().Get("isMemberNeeded") == "true"
 	isUserNeeded := ctx.Request.URL.Query().Get("isUserNeeded") == "true"
-	err := group.Get(ctx.Db.Conn, groupID, isMemberNeeded, isUserNeeded)
+	err := group.Get(ctx, ctx.Db.Conn, groupID, isMemberNeeded, isUserNeeded)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("fetching group failed", "error", err)
 		return
 	}
 
@@ -89,46 +89,55 @@ func getGroupById(ctx *socialnetwork.Context) {
 
 var getGroupByIdRoute = route{
 	path:   "/get-group",
+	scope:  "read:groups",
+	method: http.MethodGet,
+	group:  existingGroup,
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		getGroupById,
+	},
+}
+
+// groupResourceRoute exposes the group under its resource URL, /groups/{groupID}.
+var groupResourceRoute = route{
+	path:   "",
+	scope:  "read:groups",
 	method: http.MethodGet,
+	group:  groupResource,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
 		getGroupById,
 	},
 }
 
 func createPostGroup(ctx *socialnetwork.Context) {
-	post := models.Post{}
+	req := ctx.Values["request"].(*middleware.GroupPostRequest)
+	post := models.Post{
+		Title:    req.Title,
+		Content:  req.Content,
+		ImageURL: req.ImageURL,
+	}
 	post.GroupID = ctx.Values["group_id"].(uuid.UUID)
 	post.UserID = ctx.Values["userId"].(uuid.UUID)
 
-	if err := ctx.BodyParser(&post); err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(post, err.Error())
-		return
-	}
-
 	post.Privacy = "group"
 
-	if err := post.Create(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+	if err := post.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("creating group post failed", "error", err)
 		return
 	}
 
 	ctx.Status(http.StatusCreated).JSON(map[string]interface{}{
 		"message": "Post created successfully",
-		"data":    post.ExploitForRendering(ctx.Db.Conn),
+		"data":    post.ExploitForRendering(ctx, ctx.Db.Conn),
 	})
 }
 
 var createPostGroupRoute = route{
 	path:   "/create-post-group",
+	scope:  "write:posts",
 	method: http.MethodPost,
+	group:  groupMembers,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
-		middleware.HaveGroupAccess,
 		middleware.IsGroupPostValid,
 		createPostGroup,
 	},
@@ -137,26 +146,35 @@ var createPostGroupRoute = route{
 func getAllGroupPosts(ctx *socialnetwork.Context) {
 	posts := models.Posts{}
 	groupID := ctx.Values["group_id"].(uuid.UUID)
-	err := posts.GetGroupPosts(ctx.Db.Conn, groupID)
+	err := posts.GetGroupPosts(ctx, ctx.Db.Conn, groupID)
 
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("fetching group posts failed", "error", err)
 		return
 	}
 
 	ctx.JSON(map[string]interface{}{
-		"data": posts.ExploitForRendering(ctx.Db.Conn),
+		"data": posts.ExploitForRendering(ctx, ctx.Db.Conn),
 	})
 }
 
 var getAllGroupPostsRoute = route{
 	path:   "/get-all-post-group",
+	scope:  "read:posts",
 	method: http.MethodGet,
+	group:  groupMembers,
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		getAllGroupPosts,
+	},
+}
+
+var groupPostsResourceRoute = route{
+	path:   "/posts",
+	scope:  "read:posts",
+	method: http.MethodGet,
+	group:  groupMemberResource,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
-		middleware.HaveGroupAccess,
 		getAllGroupPosts,
 	},
 }
@@ -164,10 +182,10 @@ var getAllGroupPostsRoute = route{
 func getAllGroupMessages(ctx *socialnetwork.Context) {
 	groupID := ctx.Values["group_id"].(uuid.UUID)
 	messages := models.GroupMessages{}
-	err := messages.GetGroupMessages(ctx.Db.Conn, groupID)
+	err := messages.GetGroupMessages(ctx, ctx.Db.Conn, groupID)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("fetching group messages failed", "error", err)
 		return
 	}
 
@@ -179,28 +197,32 @@ func getAllGroupMessages(ctx *socialnetwork.Context) {
 
 var getAllGroupMessagesRoute = route{
 	path:   "/group/messages",
+	scope:  "read:messages",
 	method: http.MethodGet,
+	group:  groupMembers,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
-		middleware.HaveGroupAccess,
 		getAllGroupMessages,
 	},
 }
 
-func addNewGroupMessage(ctx *socialnetwork.Context) {
-	newMessage := models.GroupMessage{}
-	if err := ctx.BodyParser(&newMessage); err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
-		return
-	}
+var groupMessagesResourceRoute = route{
+	path:   "/messages",
+	scope:  "read:messages",
+	method: http.MethodGet,
+	group:  groupMemberResource,
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		getAllGroupMessages,
+	},
+}
 
+func addNewGroupMessage(ctx *socialnetwork.Context) {
+	req := ctx.Values["request"].(*middleware.GroupMessageRequest)
+	newMessage := models.GroupMessage{Content: req.Content}
 	newMessage.GroupID = ctx.Values["group_id"].(uuid.UUID)
 	newMessage.SenderID = ctx.Values["userId"].(uuid.UUID)
-	if err := newMessage.Create(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+	if err := newMessage.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("creating group message failed", "error", err)
 		return
 	}
 
@@ -212,21 +234,24 @@ func addNewGroupMessage(ctx *socialnetwork.Context) {
 
 var addNewGroupMessageRoute = route{
 	path:   "/group/messages/new",
+	scope:  "write:messages",
 	method: http.MethodPost,
+	group:  groupMembers,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
-		middleware.HaveGroupAccess,
+		middleware.CreateGroupMessageMiddleware,
 		addNewGroupMessage,
 	},
 }
 
 func init() {
-	AllHandler[createGroupRoute.path] = createGroupRoute
-	AllHandler[getAllGroupsRoute.path] = getAllGroupsRoute
-	AllHandler[getGroupByIdRoute.path] = getGroupByIdRoute
-	AllHandler[createPostGroupRoute.path] = createPostGroupRoute
-	AllHandler[getAllGroupPostsRoute.path] = getAllGroupPostsRoute
-	AllHandler[getAllGroupMessagesRoute.path] = getAllGroupMessagesRoute
-	AllHandler[addNewGroupMessageRoute.path] = addNewGroupMessageRoute
+	AllHandler[createGroupRoute.key()] = createGroupRoute
+	AllHandler[getAllGroupsRoute.key()] = getAllGroupsRoute
+	AllHandler[getGroupByIdRoute.key()] = getGroupByIdRoute
+	AllHandler[groupResourceRoute.key()] = groupResourceRoute
+	AllHandler[createPostGroupRoute.key()] = createPostGroupRoute
+	AllHandler[getAllGroupPostsRoute.key()] = getAllGroupPostsRoute
+	AllHandler[groupPostsResourceRoute.key()] = groupPostsResourceRoute
+	AllHandler[getAllGroupMessagesRoute.key()] = getAllGroupMessagesRoute
+	AllHandler[groupMessagesResourceRoute.key()] = groupMessagesResourceRoute
+	AllHandler[addNewGroupMessageRoute.key()] = addNewGroupMessageRoute
 }
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"Social_Network/pkg/models"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewGroupMessageValidation(t *testing.T) {
+	user := createTestUser(t, testEmail("group-creator"), true)
+	token := newTestSession(t, user.ID)
+	group := models.Group{Title: "Group", Description: "A group", CreatorID: user.ID}
+	if err := group.Create(context.Background(), testDB); err != nil {
+		t.Fatal(err)
+	}
+	path := "/group/messages/new?group_id=" + group.ID.String()
+
+	for _, test := range []struct {
+		name   string
+		body   map[string]interface{}
+		status int
+	}{
+		{"no content", map[string]interface{}{}, http.StatusBadRequest},
+		{"content too long", map[string]interface{}{"content": strings.Repeat("a", 2001)}, http.StatusBadRequest},
+		{"valid", map[string]interface{}{"content": "hello"}, http.StatusCreated},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if status := request(t, http.MethodPost, path, token, test.body, nil); status != test.status {
+				t.Errorf("status = %d, want %d", status, test.status)
+			}
+		})
+	}
+}
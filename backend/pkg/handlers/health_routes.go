@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/db/sqlite"
+	"Social_Network/pkg/middleware"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// readinessCheckTimeout bounds each readiness check, so one slow dependency
+// cannot hold the probe past the orchestrator's own timeout.
+const readinessCheckTimeout = 2 * time.Second
+
+// readinessCheck is a dependency the app needs to serve traffic.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context, db *sql.DB) error
+}
+
+// readinessChecks are run in order by readyzHandler.
+var readinessChecks = []readinessCheck{
+	{"database", checkDatabase},
+	{"migrations", checkMigrations},
+	{"uploads", checkUploads},
+	{"broadcaster", func(context.Context, *sql.DB) error { return broadcasterAlive() }},
+}
+
+// checkDatabase pings the database.
+func checkDatabase(ctx context.Context, db *sql.DB) error {
+	if db == nil {
+		return errors.New("no database")
+	}
+	return db.PingContext(ctx)
+}
+
+// checkMigrations fails while the last migration is dirty or the database is not
+// at the latest migration version. Databases created from init.sql carry no
+// version and are accepted as they are.
+func checkMigrations(ctx context.Context, db *sql.DB) error {
+	if db == nil {
+		return errors.New("no database")
+	}
+	state, err := sqlite.MigrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !state.Versioned:
+		return nil
+	case state.Dirty:
+		return fmt.Errorf("migration %d is dirty", state.Version)
+	case state.Version != state.Latest:
+		return fmt.Errorf("database at version %d, expected %d", state.Version, state.Latest)
+	}
+	return nil
+}
+
+// checkUploads confirms a file can be created in the uploads directory.
+func checkUploads(context.Context, *sql.DB) error {
+	f, err := os.CreateTemp(middleware.DirName, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// healthzHandler answers liveness probes: the process is up and serving requests.
+func healthzHandler(ctx *socialnetwork.Context) {
+	ctx.JSON(map[string]interface{}{
+		"status": "ok",
+	})
+}
+
+// readyzHandler answers readiness probes. It runs every readiness check and
+// responds 200 if all of them pass, 503 otherwise, listing the result of each.
+func readyzHandler(ctx *socialnetwork.Context) {
+	var db *sql.DB
+	if ctx.Db != nil {
+		db = ctx.Db.Conn
+	}
+
+	status, code := "ready", http.StatusOK
+	checks := make(map[string]string, len(readinessChecks))
+	for _, c := range readinessChecks {
+		checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+		err := c.check(checkCtx, db)
+		cancel()
+		if err != nil {
+			ctx.Logger().Warn("readiness check failed", "check", c.name, "error", err)
+			checks[c.name] = err.Error()
+			status, code = "not_ready", http.StatusServiceUnavailable
+			continue
+		}
+		checks[c.name] = "ok"
+	}
+
+	ctx.Status(code).JSON(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+var healthzRoute = route{
+	method: http.MethodGet,
+	path:   "/healthz",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		healthzHandler,
+	},
+}
+
+var readyzRoute = route{
+	method: http.MethodGet,
+	path:   "/readyz",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		readyzHandler,
+	},
+}
+
+func init() {
+	AllHandler[healthzRoute.key()] = healthzRoute
+	AllHandler[readyzRoute.key()] = readyzRoute
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/config"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testApp serves every route on testDB, a database created from init.sql, for
+// the tests of the package to share.
+var (
+	testApp *socialnetwork.App
+	testDB  *sql.DB
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "handlers-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	testDB, err = openTestDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	testApp = socialnetwork.New()
+	testApp.UseDb(testDB)
+	HandleAll(testApp)
+	config.Sess.UseDB(testDB)
+
+	code := m.Run()
+	testDB.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// openTestDB creates a database at path from init.sql. User search is left out
+// unless SQLite was built with FTS5, so that the other tests run without the tag.
+func openTestDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := os.ReadFile("../db/sqlite/init.sql")
+	if err != nil {
+		return nil, err
+	}
+	var fts5 bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+		return nil, err
+	}
+	if !fts5 {
+		schema, _, _ = bytes.Cut(schema, []byte("-- Users Full-Text Search Table"))
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		return nil, fmt.Errorf("creating the test database: %v", err)
+	}
+	return db, nil
+}
+
+// clients numbers the clients requests come from, so that rate limits keyed by
+// IP do not carry over from one test to the next.
+var clients atomic.Int32
+
+// request sends a request with a JSON body, if any, as a new client, with the
+// given session token if not empty, and decodes the JSON response into out, if
+// not nil.
+func request(t *testing.T, method, path, token string, body, out any) int {
+	t.Helper()
+	var reader *strings.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = strings.NewReader(string(data))
+	} else {
+		reader = strings.NewReader("")
+	}
+	r := httptest.NewRequest(method, path, reader)
+	r.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	n := clients.Add(1)
+	r.RemoteAddr = fmt.Sprintf("10.%d.%d.%d:1234", n>>16&255, n>>8&255, n&255)
+
+	w := httptest.NewRecorder()
+	testApp.ServeHTTP(w, r)
+	if out != nil && w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// newTestSession starts a session for a user and returns its token.
+func newTestSession(t *testing.T, userID uuid.UUID) string {
+	t.Helper()
+	ctx := &socialnetwork.Context{ResponseWriter: httptest.NewRecorder(), Request: httptest.NewRequest(http.MethodPost, "/login", nil)}
+	token, err := config.Sess.Start(ctx).Set(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
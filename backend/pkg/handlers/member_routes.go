@@ -4,7 +4,6 @@ import (
 	socialnetwork "Social_Network/app"
 	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -19,17 +18,17 @@ func sendInvitationHandler(ctx *socialnetwork.Context) {
 	groupId := ctx.Values["group_id"].(uuid.UUID)
 	userId := ctx.Values["userId"].(uuid.UUID)
 	invitedUserId := ctx.Values["invited_user_id"].(uuid.UUID)
-	err := newMember.CreateMember(ctx.Db.Conn, invitedUserId, groupId)
+	err := newMember.CreateMember(ctx, ctx.Db.Conn, invitedUserId, groupId)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("creating group member failed", "error", err)
 		return
 	}
 	var invitation models.GroupInvitation
 
-	if err := invitation.SaveInvitation(ctx.Db.Conn, newMember, userId, invitedUserId); err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+	if err := invitation.SaveInvitation(ctx, ctx.Db.Conn, newMember, userId, invitedUserId); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("saving group invitation failed", "error", err)
 		return
 	}
 	group := ctx.Values["group"].(*models.Group)
@@ -42,10 +41,10 @@ func sendInvitationHandler(ctx *socialnetwork.Context) {
 		Type:      models.TypeGroupInvitation,
 		Message:   "initied you to join the group " + group.Title,
 	}
-	err = notification.Create(ctx.Db.Conn)
+	err = notification.Create(ctx, ctx.Db.Conn)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("creating invitation notification failed", "error", err)
 		return
 	}
 
@@ -57,11 +56,10 @@ func sendInvitationHandler(ctx *socialnetwork.Context) {
 
 var sendInvitationRoute = route{
 	path:   "/send-invitation",
+	scope:  "write:groups",
 	method: http.MethodPost,
+	group:  groupMembers,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.HaveGroupAccess,
-		middleware.IsGroupExist,
 		middleware.IsInvitedUserExist,
 		sendInvitationHandler,
 	},
@@ -70,10 +68,10 @@ var sendInvitationRoute = route{
 func acceptIntegrationHandler(ctx *socialnetwork.Context) {
 	member := ctx.Values["member"].(*models.GroupMember)
 	member.Status = models.MemberStatusAccepted
-	err := member.UpdateMember(ctx.Db.Conn)
+	err := member.UpdateMember(ctx, ctx.Db.Conn)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("accepting group invitation failed", "error", err)
 		return
 	}
 
@@ -85,9 +83,10 @@ func acceptIntegrationHandler(ctx *socialnetwork.Context) {
 
 var acceptIntegrationRoute = route{
 	path:   "/accept-invitation",
+	scope:  "write:groups",
 	method: http.MethodPost,
+	group:  verified,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		middleware.IsInvitationExist,
 		acceptIntegrationHandler,
 	},
@@ -96,10 +95,10 @@ var acceptIntegrationRoute = route{
 func declineIntegrationHandler(ctx *socialnetwork.Context) {
 	member := ctx.Values["member"].(*models.GroupMember)
 	member.Status = models.MemberStatusDeclined
-	err := member.UpdateMember(ctx.Db.Conn)
+	err := member.UpdateMember(ctx, ctx.Db.Conn)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("declining group invitation failed", "error", err)
 		return
 	}
 
@@ -111,9 +110,10 @@ func declineIntegrationHandler(ctx *socialnetwork.Context) {
 
 var declineIntegrationRoute = route{
 	path:   "/decline-invitation",
+	scope:  "write:groups",
 	method: http.MethodPost,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		middleware.IsInvitationExist,
 		declineIntegrationHandler,
 	},
@@ -127,10 +127,10 @@ func demandAccessHandler(ctx *socialnetwork.Context) {
 
 	group := ctx.Values["group"].(*models.Group)
 	requestingUserId := ctx.Values["userId"].(uuid.UUID)
-	err := newMember.CreateMember(ctx.Db.Conn, requestingUserId, group.ID)
+	err := newMember.CreateMember(ctx, ctx.Db.Conn, requestingUserId, group.ID)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("creating group join request failed", "error", err)
 		return
 	}
 
@@ -143,10 +143,10 @@ func demandAccessHandler(ctx *socialnetwork.Context) {
 		Type:      models.TypeGroupInvitation,
 		Message:   "A user has requested to join your group",
 	}
-	err = notification.Create(ctx.Db.Conn)
+	err = notification.Create(ctx, ctx.Db.Conn)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("creating join request notification failed", "error", err)
 		return
 	}
 
@@ -158,10 +158,10 @@ func demandAccessHandler(ctx *socialnetwork.Context) {
 
 var demandAccessRoute = route{
 	path:   "/demand-access",
+	scope:  "write:groups",
 	method: http.MethodPost,
+	group:  existingGroup,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
 		middleware.NoGroupAccess,
 		demandAccessHandler,
 	},
@@ -171,10 +171,10 @@ func getAllAccessDemand(ctx *socialnetwork.Context) {
 	group := models.Group{
 		ID: ctx.Values["group_id"].(uuid.UUID),
 	}
-	err := group.GetMembers(ctx.Db.Conn, models.MemberStatusRequesting, true)
+	err := group.GetMembers(ctx, ctx.Db.Conn, models.MemberStatusRequesting, true)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("fetching group join requests failed", "error", err)
 		return
 	}
 	requestingUsers := group.GroupMembers
@@ -187,22 +187,20 @@ func getAllAccessDemand(ctx *socialnetwork.Context) {
 
 var getAllAccessDemandRoute = route{
 	path:   "/get-all-access-demand",
+	scope:  "read:groups",
 	method: http.MethodGet,
+	group:  groupMembers,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
-		middleware.HaveGroupAccess,
 		getAllAccessDemand,
 	},
 }
 
 var acceptAccessDemandRoute = route{
 	path:   "/accept-access-demand",
+	scope:  "write:groups",
 	method: http.MethodPost,
+	group:  groupMembers,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
-		middleware.HaveGroupAccess,
 		middleware.IsGroupAdmin,
 		middleware.IsAccessDemandExist,
 		acceptIntegrationHandler,
@@ -211,11 +209,10 @@ var acceptAccessDemandRoute = route{
 
 var declineAccessDemandRoute = route{
 	path:   "/decline-access-demand",
+	scope:  "write:groups",
 	method: http.MethodPost,
+	group:  groupMembers,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
-		middleware.IsGroupExist,
-		middleware.HaveGroupAccess,
 		middleware.IsGroupAdmin,
 		middleware.IsAccessDemandExist,
 		declineIntegrationHandler,
@@ -224,10 +221,10 @@ var declineAccessDemandRoute = route{
 
 func getAllInvitations(ctx *socialnetwork.Context) {
 	var inv models.Invitations
-	err := inv.GetInvitations(ctx.Db.Conn, ctx.Values["userId"].(uuid.UUID))
+	err := inv.GetInvitations(ctx, ctx.Db.Conn, ctx.Values["userId"].(uuid.UUID))
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError)
-		log.Println(err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		ctx.Logger().Error("fetching invitations failed", "error", err)
 		return
 	}
 
@@ -239,20 +236,21 @@ func getAllInvitations(ctx *socialnetwork.Context) {
 
 var getAllInvitationsRoute = route{
 	path:   "/get-all-invitations",
+	scope:  "read:groups",
 	method: http.MethodGet,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		getAllInvitations,
 	},
 }
 
 func init() {
-	AllHandler[sendInvitationRoute.path] = sendInvitationRoute
-	AllHandler[acceptIntegrationRoute.path] = acceptIntegrationRoute
-	AllHandler[declineIntegrationRoute.path] = declineIntegrationRoute
-	AllHandler[demandAccessRoute.path] = demandAccessRoute
-	AllHandler[getAllAccessDemandRoute.path] = getAllAccessDemandRoute
-	AllHandler[acceptAccessDemandRoute.path] = acceptAccessDemandRoute
-	AllHandler[declineAccessDemandRoute.path] = declineAccessDemandRoute
-	AllHandler[getAllInvitationsRoute.path] = getAllInvitationsRoute
+	AllHandler[sendInvitationRoute.key()] = sendInvitationRoute
+	AllHandler[acceptIntegrationRoute.key()] = acceptIntegrationRoute
+	AllHandler[declineIntegrationRoute.key()] = declineIntegrationRoute
+	AllHandler[demandAccessRoute.key()] = demandAccessRoute
+	AllHandler[getAllAccessDemandRoute.key()] = getAllAccessDemandRoute
+	AllHandler[acceptAccessDemandRoute.key()] = acceptAccessDemandRoute
+	AllHandler[declineAccessDemandRoute.key()] = declineAccessDemandRoute
+	AllHandler[getAllInvitationsRoute.key()] = getAllInvitationsRoute
 }
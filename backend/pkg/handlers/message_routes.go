@@ -2,7 +2,6 @@ package handlers
 
 import (
 	socialnetwork "Social_Network/app"
-	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
 	"net/http"
 
@@ -16,8 +15,8 @@ func GetUsers(ctx *socialnetwork.Context) {
 	userId := ctx.Values["userId"].(uuid.UUID)
 
 	AllFollows := new(models.Followers)
-	AllFollows.GetAllByFolloweeID(ctx.Db.Conn, userId)
-	AllFollows.GetAllByFollowerID(ctx.Db.Conn, userId)
+	AllFollows.GetAllByFolloweeID(ctx, ctx.Db.Conn, userId)
+	AllFollows.GetAllByFollowerID(ctx, ctx.Db.Conn, userId)
 
 	exist := map[uuid.UUID]bool{}
 	AllUser := []map[string]interface{}{}
@@ -31,13 +30,12 @@ func GetUsers(ctx *socialnetwork.Context) {
 			continue
 		}
 		exist[id] = true
-		if user.Get(ctx.Db.Conn, id) != nil {
-			ctx.JSON(map[string]interface{}{
-				"error": "error geting users.",
-			})
+		if user.Get(ctx, ctx.Db.Conn, id) != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "error geting users.", nil)
+			return
 		}
 		lastMessage := new(models.PrivateMessage)
-		lastMessage.GetLastMessage(ctx.Db.Conn, userId, id)
+		lastMessage.GetLastMessage(ctx, ctx.Db.Conn, userId, id)
 		user.Password = ""
 		AllUser = append(AllUser, map[string]interface{}{
 			"user":        user,
@@ -54,13 +52,28 @@ func handlerGetMessages(ctx *socialnetwork.Context) {
 	var messages = new(models.PrivateMessages)
 	var receiverId map[string]string
 	if err := ctx.BodyParser(&receiverId); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]string{"message": "bad request"})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "bad request", nil)
 		return
 	}
-	err1 := messages.GetPrivateMessages(ctx.Db.Conn, uuid.MustParse(receiverId["receiver_id"]), senderId)
+	receiver, err := uuid.Parse(receiverId["receiver_id"])
+	if err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid receiver_id.", nil)
+		return
+	}
+	blocked, err := models.IsBlocked(ctx, ctx.Db.Conn, senderId, receiver)
+	if err != nil {
+		ctx.Logger().Error("checking block failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if blocked {
+		ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "You cannot message this user.", nil)
+		return
+	}
+	err1 := messages.GetPrivateMessages(ctx, ctx.Db.Conn, receiver, senderId)
 	if err1 != nil {
 		// HandleError(ctx.ResponseWriter, http.StatusInternalServerError, "Error getting users : "+err1.Error())
-		ctx.Status(http.StatusBadRequest).JSON(map[string]string{"message": "bad request"})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "bad request", nil)
 		return
 	}
 	data := map[string]interface{}{
@@ -73,31 +86,34 @@ func handlerGetMessages(ctx *socialnetwork.Context) {
 
 var messagesRoutes = route{
 	path:   "/groups/messages",
+	scope:  "read:messages",
 	method: http.MethodGet,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		handleMessages,
 	},
 }
 var getUsers = route{
 	path:   "/usersByFollow",
+	scope:  "read:users",
 	method: http.MethodGet,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Middleware to check if the request is authenticated.
-		GetUsers,                // Handler function to process the messages request.
+		GetUsers, // Handler function to process the messages request.
 	},
 }
 var getMessages = route{
 	path:   "/getMessages",
+	scope:  "read:messages",
 	method: http.MethodPost,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Middleware to check if the request is authenticated.
-		handlerGetMessages,      // Handler function to process the messages request.
+		handlerGetMessages, // Handler function to process the messages request.
 	},
 }
 
 func init() {
 	// Register the events route with the global AllHandler map.
-	AllHandler[getUsers.path] = getUsers
-	AllHandler[getMessages.path] = getMessages
+	AllHandler[getUsers.key()] = getUsers
+	AllHandler[getMessages.key()] = getMessages
 }
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/app/metrics"
+	"Social_Network/pkg/config"
+	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
+	"database/sql"
+	"log/slog"
+	"math"
+	"net/http"
+)
+
+// metricsHandler writes every registered metric in the Prometheus text format,
+// for the scrapers sending METRICS_TOKEN.
+func metricsHandler(ctx *socialnetwork.Context) {
+	ctx.ResponseWriter.Header().Set("Content-Type", metrics.ContentType)
+	if _, err := metrics.Default.WriteTo(ctx.ResponseWriter); err != nil {
+		ctx.Logger().Warn("writing metrics failed", "error", err)
+	}
+}
+
+var metricsRoute = route{
+	method: http.MethodGet,
+	path:   "/metrics",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.MetricsAuth,
+		metricsHandler,
+	},
+}
+
+// registerMetrics registers the application metrics read at scrape time:
+// WebSocket connections, broadcast backlog, sessions and database pool.
+// The HTTP request metrics are recorded by the app itself.
+func registerMetrics(db *sql.DB) {
+	metrics.Default.NewGaugeFunc("websocket_connections_active", "Number of open WebSocket connections.",
+		func() float64 {
+			count := 0
+			conns.Range(func(_, _ interface{}) bool {
+				count++
+				return true
+			})
+			return float64(count)
+		})
+	metrics.Default.NewGaugeFunc("websocket_broadcast_backlog", "Number of broadcasts waiting for the WebSocket broadcaster.",
+		func() float64 { return float64(len(models.Data)) })
+	metrics.Default.NewGaugeFunc("websocket_broadcast_capacity", "Number of broadcasts that may wait for the WebSocket broadcaster.",
+		func() float64 { return float64(cap(models.Data)) })
+	metrics.Default.NewGaugeFunc("sessions_active", "Number of sessions that have not expired.",
+		func() float64 {
+			count, err := config.Sess.Active()
+			if err != nil {
+				slog.Warn("counting sessions for metrics failed", "error", err)
+				return math.NaN()
+			}
+			return float64(count)
+		})
+	metrics.Default.NewCounterFunc("sessions_purged_total", "Number of expired sessions removed by the session janitor.",
+		func() float64 { return float64(config.Sess.Purged()) })
+	if db != nil {
+		metrics.Default.RegisterDBStats("db", db)
+	}
+}
+
+func init() {
+	AllHandler[metricsRoute.key()] = metricsRoute
+}
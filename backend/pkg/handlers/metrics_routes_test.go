@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"Social_Network/pkg/config"
+	"net/http"
+	"testing"
+)
+
+func TestMetricsToken(t *testing.T) {
+	defer func(token string) { config.MetricsToken = token }(config.MetricsToken)
+
+	for _, test := range []struct {
+		name   string
+		token  string
+		sent   string
+		status int
+	}{
+		{"disabled", "", "", http.StatusNotFound},
+		{"disabled with a token", "", "secret", http.StatusNotFound},
+		{"no token", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "wrong", http.StatusUnauthorized},
+		{"right token", "secret", "secret", http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			config.MetricsToken = test.token
+			if status := request(t, http.MethodGet, "/metrics", test.sent, nil, nil); status != test.status {
+				t.Errorf("status = %d, want %d", status, test.status)
+			}
+		})
+	}
+}
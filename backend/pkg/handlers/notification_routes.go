@@ -2,7 +2,6 @@ package handlers
 
 import (
 	socialnetwork "Social_Network/app"
-	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
 	"net/http"
 	"strings"
@@ -17,10 +16,8 @@ func handlerNotifications(ctx *socialnetwork.Context) {
 
 	// Fetch notifications for the user
 	notifications := new(models.Notifications)
-	if err := notifications.GetByUser(ctx.Db.Conn, userId); err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"error": err,
-		})
+	if err := notifications.GetByUser(ctx, ctx.Db.Conn, userId); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 		return
 	}
 
@@ -30,7 +27,7 @@ func handlerNotifications(ctx *socialnetwork.Context) {
 
 		// Retrieve the user details for the notification
 		user := new(models.User)
-		user.Get(ctx.Db.Conn, notification.UserID)
+		user.Get(ctx, ctx.Db.Conn, notification.UserID)
 		user.Password = "" // Do not expose user password
 
 		// Append each notification to the response list
@@ -66,35 +63,27 @@ func handlerclearnotifications(ctx *socialnetwork.Context) {
 	// Parse the incoming request
 	req := new(request)
 	if err := ctx.BodyParser(req); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"error": "Invalid request",
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid request", nil)
 		return
 	}
 
 	// Process clearing of a single notification
 	if req.Type == "clear" {
 		notification := new(models.Notification)
-		if err := notification.Get(ctx.Db.Conn, uuid.MustParse(req.Id)); err != nil {
-			ctx.Status(http.StatusNotFound).JSON(map[string]interface{}{
-				"error": "Notification not found",
-			})
+		if err := notification.Get(ctx, ctx.Db.Conn, uuid.MustParse(req.Id)); err != nil {
+			ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Notification not found", nil)
 			return
 		}
 
 		// Ensure the user is authorized to clear the notification
 		if notification.ConcernID != userId {
-			ctx.Status(http.StatusForbidden).JSON(map[string]interface{}{
-				"error": "You are not authorized to clear this notification",
-			})
+			ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "You are not authorized to clear this notification", nil)
 			return
 		}
 
 		// Delete the notification
-		if err := notification.Delete(ctx.Db.Conn); err != nil {
-			ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-				"error": "Something went wrong",
-			})
+		if err := notification.Delete(ctx, ctx.Db.Conn); err != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Something went wrong", nil)
 			return
 		}
 
@@ -106,10 +95,8 @@ func handlerclearnotifications(ctx *socialnetwork.Context) {
 	} else if req.Type == "clear_all" {
 		// Clear all notifications for the user
 		notifications := new(models.Notifications)
-		if err := notifications.GetByUser(ctx.Db.Conn, userId); err != nil {
-			ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-				"error": "Something went wrong",
-			})
+		if err := notifications.GetByUser(ctx, ctx.Db.Conn, userId); err != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Something went wrong", nil)
 			return
 		}
 
@@ -122,10 +109,8 @@ func handlerclearnotifications(ctx *socialnetwork.Context) {
 			}
 
 			// Delete the notification
-			if err := notification.Delete(ctx.Db.Conn); err != nil {
-				ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-					"error": "Something went wrong",
-				})
+			if err := notification.Delete(ctx, ctx.Db.Conn); err != nil {
+				ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Something went wrong", nil)
 				return
 			}
 		}
@@ -137,29 +122,29 @@ func handlerclearnotifications(ctx *socialnetwork.Context) {
 		return
 	} else {
 		// Invalid request type
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"error": "Invalid request",
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid request", nil)
 		return
 	}
 }
 
 // Route for fetching notifications with authentication middleware
 var notificationsRoute = route{
-	path:   "/getnotifications", // Endpoint to fetch notifications
-	method: http.MethodGet,      // HTTP method
+	path:   "/getnotifications",  // Endpoint to fetch notifications
+	scope:  "read:notifications", // Access token scope required
+	method: http.MethodGet,       // HTTP method
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Ensure user is authenticated
-		handlerNotifications,    // The handler function to call
+		handlerNotifications, // The handler function to call
 	},
 }
 
 // Route for clearing notifications with authentication middleware
 var clearnotificationsRoute = route{
 	path:   "/clearnotifications", // Endpoint to clear notifications
+	scope:  "write:notifications", // Access token scope required
 	method: http.MethodPost,       // HTTP method
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,   // Ensure user is authenticated
 		handlerclearnotifications, // The handler function to call
 	},
 }
@@ -167,6 +152,6 @@ var clearnotificationsRoute = route{
 // Initialization of routes to be registered
 func init() {
 	// Register the notification-related routes
-	AllHandler[notificationsRoute.path] = notificationsRoute
-	AllHandler[clearnotificationsRoute.path] = clearnotificationsRoute
+	AllHandler[notificationsRoute.key()] = notificationsRoute
+	AllHandler[clearnotificationsRoute.key()] = clearnotificationsRoute
 }
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/app/oidc"
+	"Social_Network/pkg/config"
+	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
+	"database/sql"
+	"errors"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// oidcEnabled responds with an error unless a provider is configured.
+func oidcEnabled(ctx *socialnetwork.Context) {
+	if config.OIDC == nil {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Sign-in with an external provider is not enabled.", nil)
+		return
+	}
+	ctx.Next()
+}
+
+// oidcStartHandler starts a sign-in with the provider, and returns the URL to
+// send the user to. The provider sends them back to the redirect URL with a code
+// and the state, for the client to post to /auth/oidc/callback.
+func oidcStartHandler(ctx *socialnetwork.Context) {
+	var pending models.OIDCState
+	state, err := oidc.RandomString()
+	if err == nil {
+		pending.Nonce, err = oidc.RandomString()
+	}
+	if err == nil {
+		pending.CodeVerifier, err = oidc.RandomString()
+	}
+	if err != nil {
+		ctx.Logger().Error("generating sign-in secrets failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+
+	authURL, err := config.OIDC.AuthCodeURL(ctx, state, pending.Nonce, oidc.Challenge(pending.CodeVerifier))
+	if err != nil {
+		ctx.Logger().Error("reaching the identity provider failed", "error", err)
+		ctx.Error(http.StatusServiceUnavailable, socialnetwork.CodeUnavailable, "The identity provider is unavailable, please try again later.", nil)
+		return
+	}
+	if err := pending.Create(ctx, ctx.Db.Conn, state); err != nil {
+		ctx.Logger().Error("saving sign-in state failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"authorizationUrl": authURL,
+		"state":            state,
+	})
+}
+
+var oidcStartRoute = route{
+	method: http.MethodPost,
+	group:  guests,
+	path:   "/auth/oidc/start",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		oidcEnabled,
+		oidcStartHandler,
+	},
+}
+
+// oidcCallbackHandler completes a sign-in with the code the provider sent the
+// user back with. The user linked to the identity is logged in; otherwise the
+// identity is linked to the user with the same verified email address, or to a
+// new user.
+func oidcCallbackHandler(ctx *socialnetwork.Context) {
+	var request struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+
+	var pending models.OIDCState
+	err := pending.Consume(ctx, ctx.Db.Conn, request.State)
+	if errors.Is(err, models.ErrInvalidToken) {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "This sign-in expired. Please try again.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("retrieving sign-in state failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+
+	tokens, err := config.OIDC.Exchange(ctx, request.Code, pending.CodeVerifier)
+	if err != nil {
+		ctx.Logger().Warn("exchanging sign-in code failed", "error", err)
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "Sign-in with the identity provider failed.", nil)
+		return
+	}
+	claims, err := config.OIDC.VerifyIDToken(ctx, tokens.IDToken, pending.Nonce, time.Now())
+	if err != nil {
+		ctx.Logger().Warn("verifying ID token failed", "error", err)
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "Sign-in with the identity provider failed.", nil)
+		return
+	}
+
+	identity := models.UserIdentity{}
+	err = identity.Get(ctx, ctx.Db.Conn, claims.Issuer, claims.Subject)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.Logger().Error("retrieving identity failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if err == nil {
+		user := models.User{}
+		if err := user.Get(ctx, ctx.Db.Conn, identity.UserID, true); err != nil || user.ID == uuid.Nil {
+			ctx.Logger().Error("retrieving linked user failed", "user_id", identity.UserID, "error", err)
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+			return
+		}
+		startLogin(ctx, user)
+		return
+	}
+
+	// Users are only matched by an address the provider vouches for
+	if claims.Email == "" || !claims.EmailVerified {
+		ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "The identity provider did not confirm your email address.", nil)
+		return
+	}
+	user, ok := oidcUser(ctx, claims)
+	if !ok {
+		return
+	}
+	identity = models.UserIdentity{UserID: user.ID, Issuer: claims.Issuer, Subject: claims.Subject, Email: claims.Email}
+	if err := identity.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("linking identity failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Logger().Info("identity linked", "user_id", user.ID, "issuer", claims.Issuer)
+	startLogin(ctx, user)
+}
+
+// oidcUser returns the user to link an identity with the verified email address
+// of claims to, creating one if the address has no account. It responds with an
+// error otherwise.
+func oidcUser(ctx *socialnetwork.Context, claims oidc.Claims) (models.User, bool) {
+	// Emails are stored escaped; Get also matches IDs and nicknames
+	user := models.User{}
+	err := user.Get(ctx, ctx.Db.Conn, html.EscapeString(claims.Email))
+	if err == nil && strings.EqualFold(html.UnescapeString(user.Email), claims.Email) {
+		// Whoever registered an address without verifying it may not own it, and
+		// would keep their password on the account of the owner
+		if !user.EmailVerified {
+			ctx.Error(http.StatusConflict, socialnetwork.CodeConflict, "An account with this email address exists. Log in with your password and verify your address first.", nil)
+			return user, false
+		}
+		return user, true
+	}
+
+	// The new user has a random password, which they can reset to log in without the provider
+	password, err := oidc.RandomString()
+	if err != nil {
+		ctx.Logger().Error("generating password failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return user, false
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while hashing the password.", nil)
+		return user, false
+	}
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(claims.Name, " ")
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(claims.Email, "@")
+	}
+	user = models.User{
+		Email:       claims.Email,
+		Password:    string(hash),
+		FirstName:   firstName,
+		LastName:    lastName,
+		AvatarImage: "uploads/default-avatar.png",
+		Nickname:    uuid.NewString(),
+	}
+	if err := user.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("creating user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while creating the user.", nil)
+		return user, false
+	}
+	if err := user.MarkEmailVerified(ctx, ctx.Db.Conn, claims.Email); err != nil {
+		ctx.Logger().Error("marking email verified failed", "error", err)
+	}
+	user.Password = ""
+	ctx.Logger().Info("user created from identity provider", "user_id", user.ID)
+	return user, true
+}
+
+var oidcCallbackRoute = route{
+	method: http.MethodPost,
+	group:  guests,
+	path:   "/auth/oidc/callback",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.LoginRateLimit,
+		oidcEnabled,
+		oidcCallbackHandler,
+	},
+}
+
+func init() {
+	AllHandler[oidcStartRoute.key()] = oidcStartRoute
+	AllHandler[oidcCallbackRoute.key()] = oidcCallbackRoute
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"Social_Network/app/oidc"
+	"Social_Network/app/oidc/oidctest"
+	"Social_Network/pkg/config"
+	"Social_Network/pkg/models"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newTestProvider makes the application sign users in with a local provider
+// for the rest of the test.
+func newTestProvider(t *testing.T) *oidctest.Server {
+	t.Helper()
+	server, err := oidctest.NewServer("social-network", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	previous := config.OIDC
+	config.OIDC = oidc.New(oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     "social-network",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:3000/auth/oidc/callback",
+	})
+	t.Cleanup(func() { config.OIDC = previous })
+	return server
+}
+
+// oidcSignIn signs in through the provider as its current user, and returns
+// the status and body of the callback.
+func oidcSignIn(t *testing.T, server *oidctest.Server) (int, map[string]interface{}) {
+	t.Helper()
+	var start struct {
+		AuthorizationURL string `json:"authorizationUrl"`
+		State            string `json:"state"`
+	}
+	if status := request(t, http.MethodPost, "/auth/oidc/start", "", nil, &start); status != http.StatusOK {
+		t.Fatalf("starting sign-in: status %d", status)
+	}
+	code, state, err := server.Login(start.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != start.State {
+		t.Fatalf("state = %q, want %q", state, start.State)
+	}
+	var response map[string]interface{}
+	status := request(t, http.MethodPost, "/auth/oidc/callback", "", map[string]string{"code": code, "state": state}, &response)
+	return status, response
+}
+
+// linkedUser returns the user the identity of the provider's user is linked to,
+// or uuid.Nil if none.
+func linkedUser(t *testing.T, server *oidctest.Server, subject string) uuid.UUID {
+	t.Helper()
+	identity := models.UserIdentity{}
+	if err := identity.Get(context.Background(), testDB, server.URL, subject); err != nil {
+		return uuid.Nil
+	}
+	return identity.UserID
+}
+
+// testEmail returns an address no other test uses, so that tests may run again
+// on the same database.
+func testEmail(name string) string {
+	return name + "-" + uuid.NewString()[:8] + "@example.com"
+}
+
+// createTestUser registers a user with the given email, verified or not.
+func createTestUser(t *testing.T, email string, verified bool) models.User {
+	t.Helper()
+	ctx := context.Background()
+	user := models.User{
+		Email:       email,
+		Password:    "not a hash",
+		FirstName:   "Test",
+		LastName:    "User",
+		AvatarImage: "uploads/default-avatar.png",
+		Nickname:    uuid.NewString(),
+	}
+	if err := user.Create(ctx, testDB); err != nil {
+		t.Fatal(err)
+	}
+	if verified {
+		if err := user.MarkEmailVerified(ctx, testDB, email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return user
+}
+
+func TestOIDCCreatesUser(t *testing.T) {
+	server := newTestProvider(t)
+	email, renamed := testEmail("new"), testEmail("renamed")
+	server.SetUser(oidctest.User{Subject: "new", Email: email, EmailVerified: true, GivenName: "Ada", FamilyName: "Lovelace"})
+
+	status, response := oidcSignIn(t, server)
+	if status != http.StatusOK || response["session"] == "" {
+		t.Fatalf("callback = %d %v, want a session", status, response)
+	}
+	userID := linkedUser(t, server, "new")
+	if userID == uuid.Nil {
+		t.Fatal("identity not linked")
+	}
+	user := models.User{}
+	if err := user.Get(context.Background(), testDB, userID); err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != email || !user.EmailVerified || user.FirstName != "Ada" || user.LastName != "Lovelace" {
+		t.Errorf("created user = %+v", user)
+	}
+
+	// The next sign-in logs into the same user, whatever the email is now
+	server.SetUser(oidctest.User{Subject: "new", Email: renamed, EmailVerified: true})
+	if status, _ := oidcSignIn(t, server); status != http.StatusOK {
+		t.Fatalf("second callback = %d", status)
+	}
+	if linkedUser(t, server, "new") != userID {
+		t.Error("second sign-in linked another user")
+	}
+	var users int
+	testDB.QueryRow(`SELECT COUNT(*) FROM users WHERE email IN ($1, $2)`, email, renamed).Scan(&users)
+	if users != 1 {
+		t.Errorf("%d users, want 1", users)
+	}
+}
+
+func TestOIDCLinksVerifiedUser(t *testing.T) {
+	server := newTestProvider(t)
+	email := testEmail("linked")
+	user := createTestUser(t, email, true)
+	server.SetUser(oidctest.User{Subject: "linked", Email: email, EmailVerified: true})
+
+	if status, response := oidcSignIn(t, server); status != http.StatusOK {
+		t.Fatalf("callback = %d %v", status, response)
+	}
+	if got := linkedUser(t, server, "linked"); got != user.ID {
+		t.Errorf("identity linked to %v, want the existing user %v", got, user.ID)
+	}
+}
+
+func TestOIDCRefusesUnverifiedAccount(t *testing.T) {
+	server := newTestProvider(t)
+	email := testEmail("unverified")
+	createTestUser(t, email, false)
+	server.SetUser(oidctest.User{Subject: "unverified", Email: email, EmailVerified: true})
+
+	if status, _ := oidcSignIn(t, server); status != http.StatusConflict {
+		t.Errorf("callback = %d, want %d", status, http.StatusConflict)
+	}
+	if linkedUser(t, server, "unverified") != uuid.Nil {
+		t.Error("identity linked to an account with an unverified address")
+	}
+}
+
+func TestOIDCRefusesUnverifiedEmail(t *testing.T) {
+	server := newTestProvider(t)
+	email := testEmail("victim")
+	user := createTestUser(t, email, true)
+	server.SetUser(oidctest.User{Subject: "attacker", Email: email, EmailVerified: false})
+
+	if status, _ := oidcSignIn(t, server); status != http.StatusForbidden {
+		t.Errorf("callback = %d, want %d", status, http.StatusForbidden)
+	}
+	if got := linkedUser(t, server, "attacker"); got != uuid.Nil {
+		t.Errorf("identity linked to %v, the account of %v", got, user.ID)
+	}
+}
+
+func TestOIDCStateWorksOnce(t *testing.T) {
+	server := newTestProvider(t)
+	server.SetUser(oidctest.User{Subject: "replay", Email: testEmail("replay"), EmailVerified: true})
+
+	var start struct {
+		AuthorizationURL string `json:"authorizationUrl"`
+	}
+	request(t, http.MethodPost, "/auth/oidc/start", "", nil, &start)
+	code, state, err := server.Login(start.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := map[string]string{"code": code, "state": state}
+	if status := request(t, http.MethodPost, "/auth/oidc/callback", "", body, nil); status != http.StatusOK {
+		t.Fatalf("callback = %d", status)
+	}
+	if status := request(t, http.MethodPost, "/auth/oidc/callback", "", body, nil); status != http.StatusBadRequest {
+		t.Errorf("replayed callback = %d, want %d", status, http.StatusBadRequest)
+	}
+}
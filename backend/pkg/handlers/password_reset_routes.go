@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/app/mailer"
+	"Social_Network/app/middleware/ratelimit"
+	"Social_Network/pkg/config"
+	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetMailLimiter keeps an address from being flooded with reset emails,
+// whoever asks for them.
+var passwordResetMailLimiter = ratelimit.NewLimiter(ratelimit.Config{Requests: 3, Per: time.Hour})
+
+// backgroundMailTimeout bounds sending an email in the background, which outlives the request.
+const backgroundMailTimeout = 30 * time.Second
+
+// sendMailInBackground sends msg without holding up the response, logging
+// failures as the given kind of email.
+func sendMailInBackground(ctx *socialnetwork.Context, msg mailer.Message, kind string) {
+	mailCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundMailTimeout)
+	logger := ctx.Logger()
+	go func() {
+		defer cancel()
+		if err := config.Mailer.Send(mailCtx, msg); err != nil {
+			logger.Error("sending "+kind+" email failed", "error", err)
+		}
+	}()
+}
+
+// forgotPasswordHandler mails a password reset link to the given address, if it
+// belongs to a user. The response is the same either way, so that it does not
+// tell which addresses have an account.
+func forgotPasswordHandler(ctx *socialnetwork.Context) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+	address, err := mail.ParseAddress(request.Email)
+	if err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid email", nil)
+		return
+	}
+	email := address.Address
+
+	respond := func() {
+		ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+			"message": "If an account uses this address, an email with a link to reset the password was sent to it.",
+		})
+	}
+
+	// Emails are stored escaped; Get also matches IDs and nicknames
+	user := models.User{}
+	if err := user.Get(ctx, ctx.Db.Conn, html.EscapeString(email)); err != nil || !strings.EqualFold(html.UnescapeString(user.Email), email) {
+		respond()
+		return
+	}
+	if ok, _ := passwordResetMailLimiter.Allow(strings.ToLower(email)); !ok {
+		ctx.Logger().Warn("password reset emails limited", "user_id", user.ID)
+		respond()
+		return
+	}
+
+	reset := models.PasswordReset{UserID: user.ID}
+	token, err := reset.Create(ctx, ctx.Db.Conn)
+	if err != nil {
+		ctx.Logger().Error("creating password reset failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	msg := mailer.Message{
+		To:      []string{html.UnescapeString(user.Email)},
+		Subject: "Reset your password",
+		Text: "Hello " + html.UnescapeString(user.FirstName) + ",\n\n" +
+			"Someone, hopefully you, asked to reset the password of your account. Choose a new password by opening this link:\n\n" +
+			config.ResetPasswordURL + "?token=" + url.QueryEscape(token) + "\n\n" +
+			fmt.Sprintf("The link expires in %d minutes and works once. If you did not ask for it, you can ignore this email.\n", int(models.PasswordResetTTL.Minutes())),
+	}
+
+	// Send in the background, so that the response takes as long whether the
+	// address has an account or not
+	sendMailInBackground(ctx, msg, "password reset")
+	respond()
+}
+
+var forgotPasswordRoute = route{
+	method: http.MethodPost,
+	path:   "/forgot-password",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.PasswordResetRateLimit,
+		forgotPasswordHandler,
+	},
+}
+
+// resetPasswordHandler sets a new password with a token mailed by forgotPasswordHandler,
+// then logs the user out of all their sessions and revokes their access tokens.
+func resetPasswordHandler(ctx *socialnetwork.Context) {
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+	if len(request.Password) < 8 {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Password must be at least 8 characters long", nil)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while hashing the password.", nil)
+		return
+	}
+
+	userId, err := models.ResetPassword(ctx, ctx.Db.Conn, request.Token, string(hash))
+	if errors.Is(err, models.ErrInvalidToken) {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "This reset link is invalid, expired or was already used.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("resetting password failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+
+	// Whoever knew the old password must not stay logged in, nor keep a token
+	err = errors.Join(
+		config.Sess.Start(ctx).DeleteAll(userId),
+		models.DeleteAccessTokens(ctx, ctx.Db.Conn, userId),
+	)
+	if err != nil {
+		ctx.Logger().Error("revoking sessions after password reset failed", "user_id", userId, "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Your password was reset, but your sessions could not be closed.", nil)
+		return
+	}
+	// Having proven to own the address, the user may log in right away
+	user := models.User{}
+	if err := user.Get(ctx, ctx.Db.Conn, userId, true); err == nil {
+		if _, err := models.ClearLoginThrottle(ctx, ctx.Db.Conn, models.AccountLoginSubject(html.UnescapeString(user.Email))); err != nil {
+			ctx.Logger().Error("lifting login lockout failed", "user_id", userId, "error", err)
+		}
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Password reset. Please log in with your new password.",
+	})
+}
+
+var resetPasswordRoute = route{
+	method: http.MethodPost,
+	path:   "/reset-password",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.PasswordResetRateLimit,
+		resetPasswordHandler,
+	},
+}
+
+func init() {
+	AllHandler[forgotPasswordRoute.key()] = forgotPasswordRoute
+	AllHandler[resetPasswordRoute.key()] = resetPasswordRoute
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"Social_Network/pkg/models"
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestResetPasswordRevokesAccess(t *testing.T) {
+	user := createPasswordUser(t, "password-reset")
+	session := newTestSession(t, user.ID)
+	accessToken := newTestAccessToken(t, user.ID, "read:users")
+	if !accessTokenValid(t, accessToken) {
+		t.Fatal("the access token is not valid before the reset")
+	}
+	reset := models.PasswordReset{UserID: user.ID}
+	token, err := reset.Create(context.Background(), testDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]string{"token": token, "password": "an0ther password"}
+	if status := request(t, http.MethodPost, "/reset-password", "", body, nil); status != http.StatusOK {
+		t.Fatalf("resetting the password = %d", status)
+	}
+	if signedIn(t, session) {
+		t.Error("a session from before the reset is still valid")
+	}
+	if accessTokenValid(t, accessToken) {
+		t.Error("an access token from before the reset is still valid")
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
 
-	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/google/uuid" // UUID package for generating and handling unique IDs
@@ -14,14 +12,14 @@ import (
 
 // Handler for inserting a new post
 func insertPostHandler(ctx *socialnetwork.Context) {
-	newPost := models.Post{}
-	// Parse the incoming JSON body into the newPost struct
-	if err := ctx.BodyParser(&newPost); err != nil {
-		log.Println(err)
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"error": "Error while creating new post",
-		})
-		return
+	// The body was validated by middleware.IsPostValid
+	req := ctx.Values["request"].(*middleware.PostRequest)
+	newPost := models.Post{
+		Title:             req.Title,
+		Content:           req.Content,
+		ImageURL:          req.ImageURL,
+		Privacy:           models.PostPrivacy(req.Privacy),
+		SelectedFollowers: req.SelectedFollowers,
 	}
 
 	// Retrieve the user ID from the request context
@@ -29,18 +27,16 @@ func insertPostHandler(ctx *socialnetwork.Context) {
 	newPost.UserID = userPostOwnerId
 
 	// Attempt to save the post in the database
-	if err := newPost.Create(ctx.Db.Conn); err != nil {
-		log.Println(err)
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"error": "Error while creating new post",
-		})
+	if err := newPost.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("creating post failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while creating new post", nil)
 		return
 	}
 
 	// Send a successful response with the created post data
 	ctx.JSON(map[string]interface{}{
 		"status": http.StatusOK,
-		"data":   newPost.ExploitForRendering(ctx.Db.Conn), // Render the post for frontend use
+		"data":   newPost.ExploitForRendering(ctx, ctx.Db.Conn), // Render the post for frontend use
 	})
 }
 
@@ -49,10 +45,8 @@ func insertCommentHandler(ctx *socialnetwork.Context) {
 	newComment := models.Comment{}
 	// Parse the incoming JSON body into the newComment struct
 	if err := ctx.BodyParser(&newComment); err != nil {
-		log.Println(err)
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"error": "Error while creating new comment",
-		})
+		ctx.Logger().Error("parsing comment failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while creating new comment", nil)
 		return
 	}
 
@@ -61,22 +55,19 @@ func insertCommentHandler(ctx *socialnetwork.Context) {
 
 	// Fetch the post to which the comment is being added (for validation or context)
 	post := models.Post{}
-	post.Get(ctx.Db.Conn, newComment.PostID)
-	fmt.Println(post)
+	post.Get(ctx, ctx.Db.Conn, newComment.PostID)
 
 	// Attempt to save the comment in the database
-	if err := newComment.Create(ctx.Db.Conn); err != nil {
-		log.Println(err)
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"error": "Error while creating new comment",
-		})
+	if err := newComment.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("creating comment failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while creating new comment", nil)
 		return
 	}
 
 	// Send a successful response with the created comment data
 	ctx.JSON(map[string]interface{}{
 		"status": http.StatusOK,
-		"data":   newComment.PrepareForRendering(ctx.Db.Conn, string(post.Privacy), post.GroupID),
+		"data":   newComment.PrepareForRendering(ctx, ctx.Db.Conn, string(post.Privacy), post.GroupID),
 	})
 }
 
@@ -84,25 +75,19 @@ func insertCommentHandler(ctx *socialnetwork.Context) {
 func feedHandler(ctx *socialnetwork.Context) {
 	feedPosts := models.Posts{}
 
-	fmt.Println("func feedHandler(ctx *socialnetwork.Context) { *******************************************")
-	fmt.Println(ctx)
-	fmt.Printf("Type of userId: %T\n", ctx.Values["userId"])
-
 	user := ctx.Values["userId"].(uuid.UUID)
 
 	// Fetch posts accessible to the user based on their permissions
-	if err := feedPosts.GetAvailablePostForUser(ctx.Db.Conn, user); err != nil {
-		log.Println(err)
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"error": "Error while getting posts",
-		})
+	if err := feedPosts.GetAvailablePostForUser(ctx, ctx.Db.Conn, user); err != nil {
+		ctx.Logger().Error("fetching feed failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while getting posts", nil)
 		return
 	}
 
 	// Send a successful response with the fetched posts
 	ctx.JSON(map[string]interface{}{
 		"status": http.StatusOK,
-		"data":   feedPosts.ExploitForRendering(ctx.Db.Conn),
+		"data":   feedPosts.ExploitForRenderingSeenBy(ctx, ctx.Db.Conn, user),
 	})
 }
 
@@ -110,59 +95,66 @@ func feedHandler(ctx *socialnetwork.Context) {
 func handleGetGroupPost(ctx *socialnetwork.Context) {
 	// Extract group ID from query parameters
 	id := ctx.Request.URL.Query().Get("id")
+	userId := ctx.Values["userId"].(uuid.UUID)
 	post := models.Posts{}
 
 	// Fetch posts associated with the specified group ID
-	post.GetPostByGroupId(ctx.Db.Conn, id)
+	post.GetPostByGroupId(ctx, ctx.Db.Conn, id)
 
 	// Send a successful response with the group posts
 	ctx.JSON(map[string]interface{}{
 		"status": http.StatusOK,
-		"data":   post.ExploitForRendering(ctx.Db.Conn),
+		"data":   post.ExploitForRenderingSeenBy(ctx, ctx.Db.Conn, userId),
 	})
 }
 
 // Route definitions with paths, HTTP methods, and middleware configurations
 var getGroupsPostRoute = route{
 	path:   "/post/groups",
+	scope:  "read:posts",
 	method: http.MethodGet,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Ensure the user is authenticated
-		handleGetGroupPost,      // Final handler for the route
+		handleGetGroupPost, // Final handler for the route
 	},
 }
 
 var insertPostRoute = route{
 	path:   "/post/insert",
+	scope:  "write:posts",
 	method: http.MethodPost,
+	group:  verified,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Ensure the user is authenticated
-		insertPostHandler,       // Final handler for the route
+		middleware.PostRateLimit, // Throttle post creation per user
+		middleware.IsPostValid,   // Validate the new post
+		insertPostHandler,        // Final handler for the route
 	},
 }
 
 var getFeedPostsRoute = route{
 	path:   "/post/getFeed",
+	scope:  "read:posts",
 	method: http.MethodGet,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Ensure the user is authenticated
-		feedHandler,             // Final handler for the route
+		feedHandler, // Final handler for the route
 	},
 }
 
 var insertCommentRoot = route{
 	path:   "/post/insertComment",
+	scope:  "write:posts",
 	method: http.MethodPost,
+	group:  verified,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Ensure the user is authenticated
-		insertCommentHandler,    // Final handler for the route
+		insertCommentHandler, // Final handler for the route
 	},
 }
 
 // Initialization function to register all defined routes
 func init() {
-	AllHandler[insertCommentRoot.path] = insertCommentRoot
-	AllHandler[getFeedPostsRoute.path] = getFeedPostsRoute
-	AllHandler[insertPostRoute.path] = insertPostRoute
-	AllHandler[getGroupsPostRoute.path] = getGroupsPostRoute
+	AllHandler[insertCommentRoot.key()] = insertCommentRoot
+	AllHandler[getFeedPostsRoute.key()] = getFeedPostsRoute
+	AllHandler[insertPostRoute.key()] = insertPostRoute
+	AllHandler[getGroupsPostRoute.key()] = getGroupsPostRoute
 }
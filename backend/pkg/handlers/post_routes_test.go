@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestInsertPostValidation(t *testing.T) {
+	user := createTestUser(t, testEmail("post-author"), true)
+	token := newTestSession(t, user.ID)
+
+	for _, test := range []struct {
+		name   string
+		body   map[string]interface{}
+		status int
+	}{
+		{"no content", map[string]interface{}{"privacy": "public"}, http.StatusBadRequest},
+		{"unknown privacy", map[string]interface{}{"content": "hello", "privacy": "everyone"}, http.StatusBadRequest},
+		{"group privacy", map[string]interface{}{"content": "hello", "privacy": "group"}, http.StatusBadRequest},
+		{"title too long", map[string]interface{}{"title": strings.Repeat("a", 256), "content": "hello", "privacy": "public"}, http.StatusBadRequest},
+		{"valid", map[string]interface{}{"content": "hello", "privacy": "public"}, http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if status := request(t, http.MethodPost, "/post/insert", token, test.body, nil); status != test.status {
+				t.Errorf("status = %d, want %d", status, test.status)
+			}
+		})
+	}
+}
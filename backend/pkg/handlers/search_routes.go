@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// searchPageSize is how many users a search returns at once when the client does not say.
+	searchPageSize = 20
+
+	// searchMaxPageSize is how many users a search returns at once at most.
+	searchMaxPageSize = 50
+)
+
+// searchUsersHandler finds users by the words in q, matched as prefixes of
+// their nickname, names or about-me, or with typos by the trigrams of their
+// nickname and names. Pages follow one another through the
+// cursor returned with each, up to limit users at a time.
+func searchUsersHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	query := ctx.Request.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "q is required.", nil)
+		return
+	}
+	limit := searchPageSize
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > searchMaxPageSize {
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "limit must be between 1 and "+strconv.Itoa(searchMaxPageSize)+".", nil)
+			return
+		}
+	}
+	var after *models.SearchCursor
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := models.ParseSearchCursor(value)
+		if err != nil {
+			ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid cursor.", nil)
+			return
+		}
+		after = &cursor
+	}
+
+	users, next, err := models.SearchDirectory(ctx, ctx.Db.Conn, userId, q, after, limit)
+	if err != nil {
+		ctx.Logger().Error("searching users failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	nextCursor := ""
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"data":       users,
+		"nextCursor": nextCursor,
+	})
+}
+
+var searchUsersRoute = route{
+	method: http.MethodGet,
+	group:  authenticated,
+	path:   "/users/search",
+	scope:  "read:users",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.SearchRateLimit,
+		searchUsersHandler,
+	},
+}
+
+func init() {
+	AllHandler[searchUsersRoute.key()] = searchUsersRoute
+}
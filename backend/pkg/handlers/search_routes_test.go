@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// searchPage is a page of results of /users/search.
+type searchPage struct {
+	Data []struct {
+		ID        uuid.UUID `json:"id"`
+		Nickname  string    `json:"nickname"`
+		FirstName string    `json:"firstName"`
+	} `json:"data"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// requireFTS5 skips the test when SQLite was built without FTS5, which user search needs.
+func requireFTS5(t *testing.T) {
+	t.Helper()
+	var fts5 bool
+	if err := testDB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil || !fts5 {
+		t.Skip("SQLite was built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+}
+
+// searchWord returns a word no other user has in their profile.
+func searchWord() string {
+	return "w" + strings.ReplaceAll(uuid.NewString(), "-", "")[:11]
+}
+
+// createSearchUser registers a user with the given nickname and first name.
+func createSearchUser(t *testing.T, nickname, firstName string, public bool) uuid.UUID {
+	t.Helper()
+	user := createTestUser(t, testEmail("search"), true)
+	if _, err := testDB.Exec(`UPDATE users SET nickname=$1, first_name=$2, is_public=$3 WHERE id=$4`, nickname, firstName, public, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+// search returns a page of results of q for the user of token.
+func search(t *testing.T, token, q, cursor string, limit string) searchPage {
+	t.Helper()
+	query := url.Values{"q": {q}, "limit": {limit}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	var page searchPage
+	if status := request(t, http.MethodGet, "/users/search?"+query.Encode(), token, nil, &page); status != http.StatusOK {
+		t.Fatalf("searching %q = %d", q, status)
+	}
+	return page
+}
+
+func TestSearchForgivesTypos(t *testing.T) {
+	requireFTS5(t)
+	viewer := newTestSession(t, createTestUser(t, testEmail("searcher"), true).ID)
+	nickname, name := searchWord(), searchWord()
+	public := createSearchUser(t, nickname, "Ada", true)
+	private := createSearchUser(t, searchWord(), name, false)
+	typo := func(word string) string { return word[:5] + "z" + word[6:] }
+
+	for _, test := range []struct {
+		name string
+		q    string
+		want []uuid.UUID
+	}{
+		{"exact nickname", nickname, []uuid.UUID{public}},
+		{"nickname with a typo", typo(nickname), []uuid.UUID{public}},
+		{"nickname prefix with a typo", typo(nickname[:8]), []uuid.UUID{public}},
+		{"private name", name, nil},
+		{"private name with a typo", typo(name), nil},
+		{"unrelated", searchWord(), nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got []uuid.UUID
+			for _, user := range search(t, viewer, test.q, "", "50").Data {
+				if user.ID == public || user.ID == private {
+					got = append(got, user.ID)
+				}
+			}
+			if len(got) != len(test.want) || (len(got) > 0 && got[0] != test.want[0]) {
+				t.Errorf("found %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchPrivateNicknameWithTypo(t *testing.T) {
+	requireFTS5(t)
+	viewer := newTestSession(t, createTestUser(t, testEmail("searcher"), true).ID)
+	nickname := searchWord()
+	private := createSearchUser(t, nickname, "Ada", false)
+
+	page := search(t, viewer, nickname[:5]+"z"+nickname[6:], "", "50")
+	if len(page.Data) != 1 || page.Data[0].ID != private {
+		t.Fatalf("found %v, want the private user", page.Data)
+	}
+	if page.Data[0].FirstName != "" {
+		t.Errorf("first name %q shown to a user who does not follow them", page.Data[0].FirstName)
+	}
+}
+
+func TestSearchCursor(t *testing.T) {
+	requireFTS5(t)
+	viewer := newTestSession(t, createTestUser(t, testEmail("searcher"), true).ID)
+	name := searchWord()
+	// Equal matches, told apart by the cursor
+	want := map[uuid.UUID]bool{}
+	for i := 0; i < 5; i++ {
+		want[createSearchUser(t, searchWord(), name, true)] = true
+	}
+
+	seen := map[uuid.UUID]bool{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages == len(want) {
+			t.Fatal("the cursor does not move forward")
+		}
+		page := search(t, viewer, name, cursor, "2")
+		for _, user := range page.Data {
+			if seen[user.ID] {
+				t.Errorf("%v listed twice", user.ID)
+			}
+			seen[user.ID] = true
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if len(seen) != len(want) {
+		t.Errorf("%d users listed, want %d", len(seen), len(want))
+	}
+	for id := range want {
+		if !seen[id] {
+			t.Errorf("%v never listed", id)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	socialnetwork "Social_Network/app"
-	"Social_Network/pkg/middleware"
 	"net/http"
 )
 
@@ -26,12 +25,14 @@ func handleValidSession(ctx *socialnetwork.Context) {
 // Properties:
 // - path: The endpoint's URL path (e.g., "/checksession").
 // - method: The HTTP method to be used (GET in this case).
+// - scope: The personal access token scope required to call it.
 // - middlewareAndHandler: A chain of middleware and the handler function to process the request.
 var checkSessionRoute = route{
 	path:   "/checksession", // Define the URL path for the session check.
+	scope:  "read:users",    // Access token scope required
 	method: http.MethodGet,  // Use the GET method for this endpoint.
+	group:  authenticated,   // Group of routes requiring an authenticated user.
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Middleware to ensure the user is authenticated.
 		/*
 		   Additional middleware can be added here if required for further validations or processing.
 		   For example:
@@ -45,5 +46,5 @@ var checkSessionRoute = route{
 // init registers the checkSessionRoute with the global AllHandler map.
 // This ensures the route is available when the application initializes and starts handling requests.
 func init() {
-	AllHandler[checkSessionRoute.path] = checkSessionRoute // Add the route to the global route handler map.
+	AllHandler[checkSessionRoute.key()] = checkSessionRoute // Add the route to the global route handler map.
 }
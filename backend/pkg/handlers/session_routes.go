@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/app/middleware/csrf"
+	"Social_Network/app/session"
+	"Social_Network/pkg/config"
+	"Social_Network/pkg/middleware"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionView is a session as listed to its user. The session ID itself is a
+// credential, so sessions are told apart by their public ID.
+type sessionView struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Current   bool      `json:"current"` // Whether it is the session making the request
+}
+
+// listSessionsHandler lists the sessions of the current user, the most recently used first.
+func listSessionsHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	token, _ := ctx.Values["token"].(string)
+
+	list, err := config.Sess.Start(ctx).List(userId)
+	if err != nil {
+		ctx.Logger().Error("listing sessions failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+
+	sessions := make([]sessionView, 0, len(list))
+	for _, data := range list {
+		sessions = append(sessions, sessionView{
+			ID:        data.PublicID(),
+			UserAgent: data.UserAgent,
+			IP:        data.IP,
+			CreatedAt: data.CreatedAt,
+			LastSeen:  data.LastSeen,
+			ExpiresAt: data.ExpiresAt,
+			Current:   data.ID == token,
+		})
+	}
+	ctx.Status(http.StatusOK).JSON(sessions)
+}
+
+var listSessionsRoute = route{
+	method: http.MethodGet,
+	group:  authenticated,
+	path:   "/me/sessions",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		listSessionsHandler,
+	},
+}
+
+// revokeSessionHandler logs the current user out of one of their sessions,
+// given by its public ID. Revoking the current session logs out.
+func revokeSessionHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+
+	err := config.Sess.Start(ctx).Revoke(userId, ctx.Param("sessionID"))
+	if errors.Is(err, session.ErrNotFound) {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Session not found.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("revoking session failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Session revoked.",
+	})
+}
+
+var revokeSessionRoute = route{
+	method: http.MethodDelete,
+	group:  authenticated,
+	path:   "/me/sessions/{sessionID}",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		revokeSessionHandler,
+	},
+}
+
+// revokeOtherSessionsHandler logs the current user out everywhere but in the
+// session making the request.
+func revokeOtherSessionsHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	token, _ := ctx.Values["token"].(string)
+
+	removed, err := config.Sess.Start(ctx).DeleteOthers(userId, token)
+	if err != nil {
+		ctx.Logger().Error("revoking other sessions failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Logged out of the other sessions.",
+		"revoked": removed,
+	})
+}
+
+var revokeOtherSessionsRoute = route{
+	method: http.MethodDelete,
+	group:  authenticated,
+	path:   "/me/sessions",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		revokeOtherSessionsHandler,
+	},
+}
+
+// csrfTokenHandler returns the CSRF token to send in the X-CSRF-Token header of
+// POST, PUT and DELETE requests authenticated by the session cookie.
+func csrfTokenHandler(ctx *socialnetwork.Context) {
+	token, _ := ctx.Values["token"].(string)
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"csrfToken": csrf.Token(middleware.CSRFSecret, token),
+	})
+}
+
+var csrfTokenRoute = route{
+	method: http.MethodGet,
+	group:  authenticated,
+	path:   "/csrf-token",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		csrfTokenHandler,
+	},
+}
+
+func init() {
+	AllHandler[csrfTokenRoute.key()] = csrfTokenRoute
+	AllHandler[listSessionsRoute.key()] = listSessionsRoute
+	AllHandler[revokeSessionRoute.key()] = revokeSessionRoute
+	AllHandler[revokeOtherSessionsRoute.key()] = revokeOtherSessionsRoute
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/app/totp"
+	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
+	"database/sql"
+	"errors"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// totpIssuer names the application in authenticator apps.
+const totpIssuer = "Social Network"
+
+// twoFactorStatusHandler tells whether the current user uses two-factor
+// authentication, and how many recovery codes they have left.
+func twoFactorStatusHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+
+	twoFactor := models.TwoFactor{}
+	err := twoFactor.Get(ctx, ctx.Db.Conn, userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.Logger().Error("retrieving two-factor settings failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	left, err := models.CountRecoveryCodes(ctx, ctx.Db.Conn, userId)
+	if err != nil {
+		ctx.Logger().Error("counting recovery codes failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"enabled":           twoFactor.Enabled(),
+		"recoveryCodesLeft": left,
+	})
+}
+
+var twoFactorStatusRoute = route{
+	method: http.MethodGet,
+	group:  authenticated,
+	path:   "/me/2fa",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		twoFactorStatusHandler,
+	},
+}
+
+// enrollTwoFactorHandler generates a TOTP secret for the current user, to add to
+// their authenticator app. It is only used once confirmed with a code.
+func enrollTwoFactorHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+
+	existing := models.TwoFactor{}
+	err := existing.Get(ctx, ctx.Db.Conn, userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.Logger().Error("retrieving two-factor settings failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if existing.Enabled() {
+		ctx.Error(http.StatusConflict, socialnetwork.CodeConflict, "Two-factor authentication is already enabled.", nil)
+		return
+	}
+
+	user := models.User{}
+	if err := user.Get(ctx, ctx.Db.Conn, userId); err != nil {
+		ctx.Logger().Error("retrieving the current user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		ctx.Logger().Error("generating TOTP secret failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	twoFactor := models.TwoFactor{UserID: userId, Secret: secret}
+	if err := twoFactor.Create(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("saving TOTP secret failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"secret":  secret,
+		"uri":     totp.URI(totpIssuer, html.UnescapeString(user.Email), secret),
+		"message": "Add the secret to your authenticator app, then confirm with a code.",
+	})
+}
+
+var enrollTwoFactorRoute = route{
+	method: http.MethodPost,
+	group:  authenticated,
+	path:   "/me/2fa",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		enrollTwoFactorHandler,
+	},
+}
+
+// confirmTwoFactorHandler enables two-factor authentication once the current
+// user gives a code of the secret they enrolled, and returns their recovery codes.
+func confirmTwoFactorHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+
+	twoFactor := models.TwoFactor{}
+	err := twoFactor.Get(ctx, ctx.Db.Conn, userId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && twoFactor.Enabled()) {
+		ctx.Error(http.StatusConflict, socialnetwork.CodeConflict, "There is no two-factor authentication to confirm.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("retrieving two-factor settings failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	step, ok := totp.Validate(twoFactor.Secret, request.Code, time.Now())
+	if !ok {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid code.", nil)
+		return
+	}
+	codes, err := twoFactor.Confirm(ctx, ctx.Db.Conn, step)
+	if err != nil {
+		ctx.Logger().Error("confirming two-factor authentication failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"recoveryCodes": codes,
+		"message":       "Two-factor authentication enabled. Keep the recovery codes somewhere safe: each lets you log in once without your app.",
+	})
+}
+
+var confirmTwoFactorRoute = route{
+	method: http.MethodPost,
+	group:  authenticated,
+	path:   "/me/2fa/confirm",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.LoginRateLimit,
+		confirmTwoFactorHandler,
+	},
+}
+
+// passwordRequest is the body of the requests asking for the password again.
+type passwordRequest struct {
+	Password string `json:"password"`
+}
+
+// reauthenticate checks the password in the body of the request, and responds
+// with an error unless it is the one of the current user.
+func reauthenticate(ctx *socialnetwork.Context) bool {
+	var request passwordRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return false
+	}
+	return confirmPassword(ctx, request.Password)
+}
+
+// confirmPassword checks that password is the one of the current user, and
+// responds with an error otherwise. Wrong passwords count as failed logins of
+// the account and the client, so that a session cannot be used to guess it.
+func confirmPassword(ctx *socialnetwork.Context, password string) bool {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	user := models.User{}
+	if err := user.Get(ctx, ctx.Db.Conn, userId.String(), true); err != nil {
+		ctx.Logger().Error("retrieving the current user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return false
+	}
+	now := time.Now()
+	account, client, ok := loginThrottles(ctx, html.UnescapeString(user.Email), now)
+	if !ok {
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		loginFailed(ctx, user, &account, &client, now)
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "Invalid credentials. Please try again.", nil)
+		return false
+	}
+	return true
+}
+
+// disableTwoFactorHandler turns two-factor authentication off for the current
+// user, who must give their password again.
+func disableTwoFactorHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	if !reauthenticate(ctx) {
+		return
+	}
+	twoFactor := models.TwoFactor{UserID: userId}
+	if err := twoFactor.Delete(ctx, ctx.Db.Conn); err != nil {
+		ctx.Logger().Error("disabling two-factor authentication failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Two-factor authentication disabled.",
+	})
+}
+
+var disableTwoFactorRoute = route{
+	method: http.MethodDelete,
+	group:  authenticated,
+	path:   "/me/2fa",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.LoginRateLimit,
+		disableTwoFactorHandler,
+	},
+}
+
+// regenerateRecoveryCodesHandler replaces the recovery codes of the current user,
+// who must give their password again.
+func regenerateRecoveryCodesHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+	if !reauthenticate(ctx) {
+		return
+	}
+	twoFactor := models.TwoFactor{}
+	err := twoFactor.Get(ctx, ctx.Db.Conn, userId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !twoFactor.Enabled()) {
+		ctx.Error(http.StatusConflict, socialnetwork.CodeConflict, "Two-factor authentication is not enabled.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("retrieving two-factor settings failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	codes, err := models.RegenerateRecoveryCodes(ctx, ctx.Db.Conn, userId)
+	if err != nil {
+		ctx.Logger().Error("regenerating recovery codes failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"recoveryCodes": codes,
+	})
+}
+
+var regenerateRecoveryCodesRoute = route{
+	method: http.MethodPost,
+	group:  authenticated,
+	path:   "/me/2fa/recovery-codes",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.LoginRateLimit,
+		regenerateRecoveryCodesHandler,
+	},
+}
+
+// twoFactorLoginHandler completes a login started by loginHandler with the code
+// of the authenticator app of the user, or one of their recovery codes.
+func twoFactorLoginHandler(ctx *socialnetwork.Context) {
+	var request struct {
+		Challenge    string `json:"challenge"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := ctx.BodyParser(&request); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
+		return
+	}
+
+	challenge := models.LoginChallenge{}
+	err := challenge.Get(ctx, ctx.Db.Conn, request.Challenge)
+	if errors.Is(err, models.ErrInvalidToken) {
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "This login attempt expired. Please log in again.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("retrieving login challenge failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+
+	user := models.User{}
+	if err := user.Get(ctx, ctx.Db.Conn, challenge.UserID); err != nil {
+		ctx.Logger().Error("retrieving the user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+
+	// Wrong codes count as failed logins of the account, like wrong passwords
+	now := time.Now()
+	account, client, ok := loginThrottles(ctx, html.UnescapeString(user.Email), now)
+	if !ok {
+		return
+	}
+
+	twoFactor := models.TwoFactor{}
+	if err := twoFactor.Get(ctx, ctx.Db.Conn, challenge.UserID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ctx.Logger().Error("retrieving two-factor settings failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+
+	verified := false
+	switch {
+	case !twoFactor.Enabled():
+		// Disabled since the password was given; log in again
+	case request.Code != "":
+		if step, ok := totp.Validate(twoFactor.Secret, request.Code, now); ok {
+			verified = twoFactor.UseStep(ctx, ctx.Db.Conn, step) == nil
+		}
+	case request.RecoveryCode != "":
+		verified = models.UseRecoveryCode(ctx, ctx.Db.Conn, challenge.UserID, request.RecoveryCode) == nil
+	}
+	if !verified {
+		if err := challenge.Fail(ctx, ctx.Db.Conn); err != nil {
+			ctx.Logger().Error("counting failed login challenge failed", "error", err)
+		}
+		loginFailed(ctx, user, &account, &client, now)
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "Invalid code.", map[string]int{
+			"attemptsLeft": max(0, models.LoginChallengeAttempts-challenge.Attempts),
+		})
+		return
+	}
+	if err := challenge.Delete(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "This login attempt expired. Please log in again.", nil)
+		return
+	}
+
+	completeLogin(ctx, user)
+}
+
+var twoFactorLoginRoute = route{
+	method: http.MethodPost,
+	group:  guests,
+	path:   "/login/2fa",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.LoginRateLimit,
+		twoFactorLoginHandler,
+	},
+}
+
+func init() {
+	AllHandler[twoFactorStatusRoute.key()] = twoFactorStatusRoute
+	AllHandler[enrollTwoFactorRoute.key()] = enrollTwoFactorRoute
+	AllHandler[confirmTwoFactorRoute.key()] = confirmTwoFactorRoute
+	AllHandler[disableTwoFactorRoute.key()] = disableTwoFactorRoute
+	AllHandler[regenerateRecoveryCodesRoute.key()] = regenerateRecoveryCodesRoute
+	AllHandler[twoFactorLoginRoute.key()] = twoFactorLoginRoute
+}
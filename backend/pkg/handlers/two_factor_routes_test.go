@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"Social_Network/app/totp"
+	"Social_Network/pkg/models"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "Passw0rd!"
+
+// createPasswordUser registers a verified user with testPassword.
+func createPasswordUser(t *testing.T, name string) models.User {
+	t.Helper()
+	user := createTestUser(t, testEmail(name), true)
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.Exec(`UPDATE users SET password=$1 WHERE id=$2`, string(hash), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// createTwoFactorUser registers a verified user with testPassword and
+// two-factor authentication enabled, and returns them with their secret.
+func createTwoFactorUser(t *testing.T) (models.User, string) {
+	t.Helper()
+	ctx := context.Background()
+	user := createPasswordUser(t, "two-factor")
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	twoFactor := models.TwoFactor{UserID: user.ID, Secret: secret}
+	if err := twoFactor.Create(ctx, testDB); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := twoFactor.Confirm(ctx, testDB, totp.Step(time.Now())-1); err != nil {
+		t.Fatal(err)
+	}
+	return user, secret
+}
+
+// passwordLogin logs in with testPassword and returns the status and the
+// challenge to answer, if any.
+func passwordLogin(t *testing.T, user models.User) (int, string) {
+	t.Helper()
+	var response struct {
+		Challenge string `json:"challenge"`
+	}
+	status := request(t, http.MethodPost, "/login", "", map[string]string{"email": user.Email, "password": testPassword}, &response)
+	return status, response.Challenge
+}
+
+// accountFailures returns the failed logins recorded for the account of user.
+func accountFailures(t *testing.T, user models.User) int {
+	t.Helper()
+	throttle := models.LoginThrottle{}
+	if err := throttle.Get(context.Background(), testDB, models.AccountLoginSubject(user.Email)); err != nil {
+		t.Fatal(err)
+	}
+	return throttle.Failures
+}
+
+func TestTwoFactorWrongCodesThrottleAccount(t *testing.T) {
+	user, _ := createTwoFactorUser(t)
+	status, challenge := passwordLogin(t, user)
+	if status != http.StatusAccepted || challenge == "" {
+		t.Fatalf("login = %d, want a challenge", status)
+	}
+
+	body := map[string]string{"challenge": challenge, "code": "000000"}
+	for i := 0; i < models.AccountLoginLimit.FreeFailures; i++ {
+		if status := request(t, http.MethodPost, "/login/2fa", "", body, nil); status != http.StatusUnauthorized {
+			t.Fatalf("wrong code %d = %d, want %d", i+1, status, http.StatusUnauthorized)
+		}
+	}
+	if got := accountFailures(t, user); got != models.AccountLoginLimit.FreeFailures {
+		t.Errorf("%d failed logins recorded, want %d", got, models.AccountLoginLimit.FreeFailures)
+	}
+	if status := request(t, http.MethodPost, "/login/2fa", "", body, nil); status != http.StatusTooManyRequests {
+		t.Errorf("next code = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	// The password alone does not start over
+	if status, _ := passwordLogin(t, user); status != http.StatusTooManyRequests {
+		t.Errorf("login = %d, want %d", status, http.StatusTooManyRequests)
+	}
+}
+
+func TestTwoFactorLoginClearsFailures(t *testing.T) {
+	user, secret := createTwoFactorUser(t)
+	_, challenge := passwordLogin(t, user)
+	if status := request(t, http.MethodPost, "/login/2fa", "", map[string]string{"challenge": challenge, "code": "000000"}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("wrong code = %d", status)
+	}
+	// A correct password keeps the failure of the second factor
+	_, challenge = passwordLogin(t, user)
+	if got := accountFailures(t, user); got != 1 {
+		t.Fatalf("%d failed logins recorded after the password, want 1", got)
+	}
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := request(t, http.MethodPost, "/login/2fa", "", map[string]string{"challenge": challenge, "code": code}, nil); status != http.StatusOK {
+		t.Fatalf("right code = %d", status)
+	}
+	if got := accountFailures(t, user); got != 0 {
+		t.Errorf("%d failed logins recorded after logging in, want 0", got)
+	}
+}
@@ -19,9 +19,11 @@ func handleUpload(ctx *socialnetwork.Context) {
 // This route handles image uploads and ensures authentication before processing the request.
 var UploadRoute = route{
 	path:   "/upload",       // Endpoint path for uploading images.
+	scope:  "write:posts",   // Access token scope required
 	method: http.MethodPost, // HTTP method used for this endpoint (POST).
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,          // Middleware to enforce authentication.
+		middleware.UploadRateLimit,       // Middleware to throttle uploads per user.
 		middleware.ImageUploadMiddleware, // Middleware to handle file upload logic.
 		// Additional middleware can be added here if needed.
 		handleUpload, // Final handler to process the upload request.
@@ -31,5 +33,5 @@ var UploadRoute = route{
 // init initializes the upload route by registering it with the application's route map.
 // This makes the route available for the application to handle incoming requests.
 func init() {
-	AllHandler[UploadRoute.path] = UploadRoute // Register the route in the global handler map.
+	AllHandler[UploadRoute.key()] = UploadRoute // Register the route in the global handler map.
 }
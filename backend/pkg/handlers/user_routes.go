@@ -2,8 +2,10 @@ package handlers
 
 import (
 	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/config"
 	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
+	"errors"
 
 	"net/http"
 
@@ -12,7 +14,6 @@ import (
 )
 
 type updateValues struct {
-	Email       string `json:"email"`
 	Password    string `json:"password"`
 	NewPassword string `json:"newpassword"`
 }
@@ -26,41 +27,43 @@ func handleUpdateUser(ctx *socialnetwork.Context) {
 	userId := ctx.Values["userId"].(uuid.UUID)
 	user := new(models.User)
 	if err := ctx.BodyParser(user); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": "Error while parsing the form data.",
-			"status":  http.StatusBadRequest,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
 		return
 	}
 	user.ID = userId
-	if err := user.Validate(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": err.Error(),
-			"status":  http.StatusBadRequest,
-		})
+	previous := models.User{}
+	if err := previous.Get(ctx, ctx.Db.Conn, userId); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 		return
 	}
+	if err := user.Validate(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, err.Error(), nil)
+		return
+	}
+	changed := bcrypt.CompareHashAndPassword([]byte(previous.Password), []byte(user.Password)) != nil
 	newHash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while hashing the password.",
-			"status":  http.StatusInternalServerError,
-		})
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while hashing the password.", nil)
 		return
 	}
 	user.Password = string(newHash)
-	if err := user.Update(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"message": err.Error(),
-			"status":  http.StatusInternalServerError,
-		})
+	if err := user.Update(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 		return
 	}
-	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+	emailUpdated(ctx, previous, user)
+	response := map[string]interface{}{
 		"message": "User updated successfully",
 		"status":  http.StatusOK,
-	})
+	}
+	if changed {
+		idSession, ok := passwordChanged(ctx, userId)
+		if !ok {
+			return
+		}
+		response["session"] = idSession
+	}
+	ctx.Status(http.StatusOK).JSON(response)
 }
 
 func handleGetUser(ctx *socialnetwork.Context) {
@@ -72,29 +75,31 @@ func handleGetUser(ctx *socialnetwork.Context) {
 	}
 	req := new(request)
 	if err := ctx.BodyParser(req); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": "Error while parsing the form data.",
-			"status":  http.StatusBadRequest,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
 		return
 	}
 	switch req.Action {
 	case "get":
 		user := new(models.User)
 		if req.Nickname == "" {
-			if err := user.Get(ctx.Db.Conn, userId); err != nil {
-				ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-					"message": err.Error(),
-					"status":  http.StatusInternalServerError,
-				})
+			if err := user.Get(ctx, ctx.Db.Conn, userId); err != nil {
+				ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 				return
 			}
 		} else {
-			if err := user.Get(ctx.Db.Conn, req.Nickname); err != nil {
-				ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-					"message": err.Error(),
-					"status":  http.StatusInternalServerError,
-				})
+			if err := user.Get(ctx, ctx.Db.Conn, req.Nickname); err != nil {
+				ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
+				return
+			}
+			// Blocked users, either way, do not see each other's profile
+			blocked, err := models.IsBlocked(ctx, ctx.Db.Conn, userId, user.ID)
+			if err != nil {
+				ctx.Logger().Error("checking block failed", "error", err)
+				ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+				return
+			}
+			if blocked {
+				ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "User not found.", nil)
 				return
 			}
 		}
@@ -102,7 +107,7 @@ func handleGetUser(ctx *socialnetwork.Context) {
 		follower := new(models.Follower)
 		follower.FollowerID = userId
 		follower.FolloweeID = user.ID
-		follower.Get(ctx.Db.Conn)
+		follower.Get(ctx, ctx.Db.Conn)
 		if follower.Status == "" {
 			follower.Status = "none"
 		}
@@ -110,9 +115,9 @@ func handleGetUser(ctx *socialnetwork.Context) {
 			follower.Status = "self"
 		}
 
-		follow := new(models.Followers).CountAllByFollowerID(ctx.Db.Conn, userId)
-		following := new(models.Followers).CountAllByFolloweeID(ctx.Db.Conn, userId)
-		numpost, _ := models.CountPostsByUser(ctx.Db.Conn, user.ID)
+		follow := new(models.Followers).CountAllByFollowerID(ctx, ctx.Db.Conn, userId)
+		following := new(models.Followers).CountAllByFolloweeID(ctx, ctx.Db.Conn, userId)
+		numpost, _ := models.CountPostsByUser(ctx, ctx.Db.Conn, user.ID)
 		ctx.Status(http.StatusOK).JSON(map[string]interface{}{
 			"message": "User fetched successfully",
 			"status":  http.StatusOK,
@@ -136,11 +141,8 @@ func handleGetUser(ctx *socialnetwork.Context) {
 		})
 	case "posts":
 		posts := new(models.Posts)
-		if err := posts.GetUserPosts(ctx.Db.Conn, userId); err != nil {
-			ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-				"message": err.Error(),
-				"status":  http.StatusInternalServerError,
-			})
+		if err := posts.GetUserPosts(ctx, ctx.Db.Conn, userId); err != nil {
+			ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 			return
 		}
 		ctx.Status(http.StatusOK).JSON(map[string]interface{}{
@@ -149,10 +151,7 @@ func handleGetUser(ctx *socialnetwork.Context) {
 			"posts":   posts,
 		})
 	default:
-		ctx.JSON(map[string]interface{}{
-			"message": "Invalid action.",
-			"status":  http.StatusBadRequest,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid action.", nil)
 	}
 }
 
@@ -160,27 +159,24 @@ func handleUpdateUserInfos(ctx *socialnetwork.Context) {
 	userId := ctx.Values["userId"].(uuid.UUID)
 	user := new(models.User)
 	if err := ctx.BodyParser(user); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": "Error while parsing the form data.",
-			"status":  http.StatusBadRequest,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
 		return
 	}
 	user.ID = userId
-	if err := user.Validate(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": err.Error(),
-			"status":  http.StatusBadRequest,
-		})
+	previous := models.User{}
+	if err := previous.Get(ctx, ctx.Db.Conn, userId); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 		return
 	}
-	if err := user.Update(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"message": err.Error(),
-			"status":  http.StatusInternalServerError,
-		})
+	if err := user.Validate(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, err.Error(), nil)
+		return
+	}
+	if err := user.Update(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 		return
 	}
+	emailUpdated(ctx, previous, user)
 
 	data := map[string]interface{}{
 		"message": "User informations updated successfully.",
@@ -191,73 +187,70 @@ func handleUpdateUserInfos(ctx *socialnetwork.Context) {
 	ctx.JSON(data)
 }
 
+// passwordChanged logs the current user out of their other sessions and revokes
+// their access tokens once their password changed, and returns the new ID of the session making the request,
+// so that an ID leaked before is useless. It responds with an error, and ok is
+// false, if it failed.
+func passwordChanged(ctx *socialnetwork.Context, userId uuid.UUID) (idSession string, ok bool) {
+	token, _ := ctx.Values["token"].(string)
+	sessions := config.Sess.Start(ctx)
+	idSession, err := sessions.Rotate(token)
+	if err != nil {
+		ctx.Logger().Error("rotating session failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while renewing the session.", nil)
+		return "", false
+	}
+	_, err = sessions.DeleteOthers(userId, idSession)
+	if err = errors.Join(err, models.DeleteAccessTokens(ctx, ctx.Db.Conn, userId)); err != nil {
+		ctx.Logger().Error("revoking other sessions and access tokens failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return "", false
+	}
+	return idSession, true
+}
+
+// handleUpdateUserPassword changes the password of the current user, who must
+// give the current one. Wrong ones count as failed logins, see confirmPassword.
 func handleUpdateUserPassword(ctx *socialnetwork.Context) {
-	// Log the client's IP address that reached the login route.
+	userId := ctx.Values["userId"].(uuid.UUID)
 	var newCredentials = updateValues{}
 	// Try to deserialize the form data into the User instance.
 	if err := ctx.BodyParser(&newCredentials); err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while parsing the form data.",
-			"status":  http.StatusInternalServerError,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
 		return
 	}
-	var credentials = userCredentials{
-		Email:    newCredentials.Email,
-		Password: newCredentials.Password,
-	}
-	if err := credentials.Validate(); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"session": "",
-			"message": err.Error(),
-			"status":  http.StatusBadRequest,
-		})
-	}
-	newUser := models.User{
-		Email:    credentials.Email,
-		Password: credentials.Password,
+	if len(newCredentials.NewPassword) < 8 {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Password must be at least 8 characters long", nil)
+		return
 	}
-	err := newUser.Get(ctx.Db.Conn, credentials.Email, true)
-	if err != nil {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]interface{}{
-			"session": "",
-			"message": "invalid email.",
-			"status":  http.StatusUnauthorized,
-		})
+	if !confirmPassword(ctx, newCredentials.Password) {
 		return
 	}
-	// Check if the user's credentials are valid.
-	if err := bcrypt.CompareHashAndPassword([]byte(newUser.Password), []byte(credentials.Password)); err != nil {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Invalid credentials. Please try again.",
-			"status":  http.StatusUnauthorized,
-		})
+	newUser := models.User{}
+	if err := newUser.Get(ctx, ctx.Db.Conn, userId.String(), true); err != nil {
+		ctx.Logger().Error("retrieving the current user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
 		return
 	}
 	newPasswordHash, err := bcrypt.GenerateFromPassword([]byte(newCredentials.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while hashing the password.",
-			"status":  http.StatusInternalServerError,
-		})
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while hashing the password.", nil)
 		return
 	}
 	newUser.Password = string(newPasswordHash)
 	// Attempts to update the user in the database with the provided data.
-	if newUser.Update(ctx.Db.Conn) != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"session": "",
-			"message": "Error while creating the user.",
-			"status":  http.StatusInternalServerError,
-		})
+	if newUser.Update(ctx, ctx.Db.Conn) != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error while creating the user.", nil)
+		return
+	}
+
+	idSession, ok := passwordChanged(ctx, userId)
+	if !ok {
 		return
 	}
 
 	ctx.Status(http.StatusAccepted).JSON(map[string]interface{}{
-		"session": "", //idSession,
+		"session": idSession,
 		"data":    newUser,
 		"message": "User password successfully updated.",
 		"status":  "200",
@@ -268,31 +261,21 @@ func handleUpdateAvatar(ctx *socialnetwork.Context) {
 	userId := ctx.Values["userId"].(uuid.UUID)
 	user := new(avatarUpdate)
 	if err := ctx.BodyParser(user); err != nil {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]interface{}{
-			"message": "Error while parsing the form data.",
-			"status":  http.StatusBadRequest,
-		})
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error while parsing the form data.", nil)
 		return
 	}
 
 	currentUser := new(models.User)
 	currentUser.ID = userId
-	err := currentUser.Get(ctx.Db.Conn, user.Email, true)
+	err := currentUser.Get(ctx, ctx.Db.Conn, user.Email, true)
 	if err != nil {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]interface{}{
-			"session": "",
-			"message": "invalid email.",
-			"status":  http.StatusUnauthorized,
-		})
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "invalid email.", nil)
 		return
 	}
 
 	currentUser.AvatarImage = user.AvatarImage
-	if err := currentUser.Update(ctx.Db.Conn); err != nil {
-		ctx.Status(http.StatusInternalServerError).JSON(map[string]interface{}{
-			"message": err.Error(),
-			"status":  http.StatusInternalServerError,
-		})
+	if err := currentUser.Update(ctx, ctx.Db.Conn); err != nil {
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, err.Error(), nil)
 		return
 	}
 	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
@@ -307,52 +290,55 @@ func handleUpdateAvatar(ctx *socialnetwork.Context) {
 var updateUserRoute = route{
 	path:   "/updateuser",
 	method: http.MethodPut,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Middleware to check if the request is authenticated.
-		handleUpdateUser,        // Handler function to process the authentication request.
+		handleUpdateUser, // Handler function to process the authentication request.
 	},
 }
 
 var getUserRoute = route{
 	path:   "/getuser",
+	scope:  "read:users",
 	method: http.MethodPost,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Middleware to check if the request is authenticated.
-		handleGetUser,           // Handler function to process the authentication request.
+		handleGetUser, // Handler function to process the authentication request.
 	},
 }
 
 var updateUserInfosRoute = route{
 	path:   "/edituser",
 	method: http.MethodPut,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired, // Middleware to check if the request is authenticated.
-		handleUpdateUserInfos,   // Handler function to process the user informations update request.
+		handleUpdateUserInfos, // Handler function to process the user informations update request.
 	},
 }
 
 var updateUserPasswordRoute = route{
 	path:   "/updatepassword",
 	method: http.MethodPut,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,  // Middleware to check if the request is authenticated.
+		middleware.LoginRateLimit,
 		handleUpdateUserPassword, // Handler function to process the update the user password request.
 	},
 }
 
 var updateAvatarRoute = route{
 	path:   "/updateavatar",
+	scope:  "write:users",
 	method: http.MethodPut,
+	group:  authenticated,
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
-		middleware.AuthRequired,
 		handleUpdateAvatar,
 	},
 }
 
 func init() {
-	AllHandler[updateUserRoute.path] = updateUserRoute
-	AllHandler[getUserRoute.path] = getUserRoute
-	AllHandler[updateUserInfosRoute.path] = updateUserInfosRoute
-	AllHandler[updateUserPasswordRoute.path] = updateUserPasswordRoute
-	AllHandler[updateAvatarRoute.path] = updateAvatarRoute
+	AllHandler[updateUserRoute.key()] = updateUserRoute
+	AllHandler[getUserRoute.key()] = getUserRoute
+	AllHandler[updateUserInfosRoute.key()] = updateUserInfosRoute
+	AllHandler[updateUserPasswordRoute.key()] = updateUserPasswordRoute
+	AllHandler[updateAvatarRoute.key()] = updateAvatarRoute
 }
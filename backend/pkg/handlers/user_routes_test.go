@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"Social_Network/pkg/models"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestAccessToken creates a personal access token of a user with the given
+// scopes, and returns it.
+func newTestAccessToken(t *testing.T, userID uuid.UUID, scopes ...string) string {
+	t.Helper()
+	accessToken := models.AccessToken{UserID: userID, Name: "test", Scopes: scopes, ExpiresAt: time.Now().Add(time.Hour)}
+	token, err := accessToken.Create(context.Background(), testDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestCredentialRoutesRefuseAccessTokens(t *testing.T) {
+	user := createTestUser(t, testEmail("token-owner"), true)
+	token := newTestAccessToken(t, user.ID, models.AccessTokenScopes...)
+	body := map[string]interface{}{
+		"email":       testEmail("attacker"),
+		"password":    "a new password",
+		"firstName":   "Test",
+		"lastName":    "User",
+		"dateOfBirth": time.Now().AddDate(-20, 0, 0),
+	}
+
+	for _, path := range []string{"/updateuser", "/edituser"} {
+		if status := request(t, http.MethodPut, path, token, body, nil); status != http.StatusForbidden {
+			t.Errorf("PUT %s with an access token = %d, want %d", path, status, http.StatusForbidden)
+		}
+	}
+	after := models.User{}
+	if err := after.Get(context.Background(), testDB, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if after.Email != user.Email || after.Password != user.Password {
+		t.Error("the credentials changed")
+	}
+}
+
+// errorResponse is the body of the error responses.
+type errorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func TestUpdatePasswordChecksSessionUser(t *testing.T) {
+	user := createPasswordUser(t, "password-owner")
+	other := createPasswordUser(t, "password-other")
+	token := newTestSession(t, user.ID)
+
+	// The email in the body does not pick the account
+	var response errorResponse
+	body := map[string]string{"email": other.Email, "password": testPassword, "newpassword": "an0ther password"}
+	if status := request(t, http.MethodPut, "/updatepassword", newTestSession(t, createTestUser(t, testEmail("password-attacker"), true).ID), body, &response); status != http.StatusUnauthorized {
+		t.Fatalf("changing the password of another account = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := passwordLogin(t, other); status != http.StatusOK {
+		t.Errorf("login of the other account = %d, want %d", status, http.StatusOK)
+	}
+
+	// Wrong passwords get one answer and count toward the lockout
+	for i := 0; i < models.AccountLoginLimit.FreeFailures; i++ {
+		response = errorResponse{}
+		body := map[string]string{"password": "wrong password", "newpassword": "an0ther password"}
+		if status := request(t, http.MethodPut, "/updatepassword", token, body, &response); status != http.StatusUnauthorized {
+			t.Fatalf("wrong password %d = %d, want %d", i+1, status, http.StatusUnauthorized)
+		}
+		if response.Error.Message != "Invalid credentials. Please try again." {
+			t.Errorf("wrong password %d answered %q", i+1, response.Error.Message)
+		}
+	}
+	body = map[string]string{"password": testPassword, "newpassword": "an0ther password"}
+	if status := request(t, http.MethodPut, "/updatepassword", token, body, nil); status != http.StatusTooManyRequests {
+		t.Errorf("password after %d failures = %d, want %d", models.AccountLoginLimit.FreeFailures, status, http.StatusTooManyRequests)
+	}
+}
+
+func TestUpdatePassword(t *testing.T) {
+	user := createPasswordUser(t, "password-change")
+	token := newTestSession(t, user.ID)
+
+	body := map[string]string{"password": testPassword, "newpassword": "short"}
+	if status := request(t, http.MethodPut, "/updatepassword", token, body, nil); status != http.StatusBadRequest {
+		t.Errorf("short password = %d, want %d", status, http.StatusBadRequest)
+	}
+	body = map[string]string{"password": testPassword, "newpassword": "an0ther password"}
+	if status := request(t, http.MethodPut, "/updatepassword", token, body, nil); status != http.StatusAccepted {
+		t.Fatalf("changing the password = %d, want %d", status, http.StatusAccepted)
+	}
+	status := request(t, http.MethodPost, "/login", "", map[string]string{"email": user.Email, "password": "an0ther password"}, nil)
+	if status != http.StatusOK {
+		t.Errorf("login with the new password = %d, want %d", status, http.StatusOK)
+	}
+}
+
+// signedIn tells whether token still authenticates requests.
+func signedIn(t *testing.T, token string) bool {
+	t.Helper()
+	return request(t, http.MethodGet, "/me/sessions", token, nil, nil) == http.StatusOK
+}
+
+// accessTokenValid tells whether a personal access token with the read:users
+// scope still authenticates requests.
+func accessTokenValid(t *testing.T, token string) bool {
+	t.Helper()
+	return request(t, http.MethodPost, "/getuser", token, map[string]string{"action": "get"}, nil) == http.StatusOK
+}
+
+func TestPasswordChangeRenewsSessions(t *testing.T) {
+	for _, test := range []struct {
+		path   string
+		body   func(user models.User) map[string]interface{}
+		status int
+	}{
+		{"/updatepassword", func(models.User) map[string]interface{} {
+			return map[string]interface{}{"password": testPassword, "newpassword": "an0ther password"}
+		}, http.StatusAccepted},
+		{"/updateuser", func(user models.User) map[string]interface{} {
+			return map[string]interface{}{"email": user.Email, "password": "an0ther password", "firstName": "Test", "lastName": "User", "dateOfBirth": time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+		}, http.StatusOK},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			user := createPasswordUser(t, "password-sessions")
+			token, other := newTestSession(t, user.ID), newTestSession(t, user.ID)
+			accessToken := newTestAccessToken(t, user.ID, "read:users")
+
+			var response struct {
+				Session string `json:"session"`
+			}
+			if status := request(t, http.MethodPut, test.path, token, test.body(user), &response); status != test.status {
+				t.Fatalf("changing the password = %d, want %d", status, test.status)
+			}
+			if response.Session == "" || response.Session == token {
+				t.Errorf("session %q returned, want a new one", response.Session)
+			}
+			if signedIn(t, token) || signedIn(t, other) {
+				t.Error("a session from before the change is still valid")
+			}
+			if accessTokenValid(t, accessToken) {
+				t.Error("an access token from before the change is still valid")
+			}
+			if !signedIn(t, response.Session) {
+				t.Error("the new session is not valid")
+			}
+		})
+	}
+}
+
+func TestUpdateUserKeepsSessionsWithSamePassword(t *testing.T) {
+	user := createPasswordUser(t, "password-same")
+	token := newTestSession(t, user.ID)
+	body := map[string]interface{}{"email": user.Email, "password": testPassword, "firstName": "Test", "lastName": "User", "dateOfBirth": time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if status := request(t, http.MethodPut, "/updateuser", token, body, nil); status != http.StatusOK {
+		t.Fatalf("updating the user = %d", status)
+	}
+	if !signedIn(t, token) {
+		t.Error("the session ended although the password did not change")
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/app/mailer"
+	"Social_Network/app/middleware/ratelimit"
+	"Social_Network/pkg/config"
+	"Social_Network/pkg/middleware"
+	"Social_Network/pkg/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// verificationMailLimiter keeps an address from being flooded with verification
+// emails, such as by users changing their own address to it again and again.
+var verificationMailLimiter = ratelimit.NewLimiter(ratelimit.Config{Requests: 3, Per: time.Hour})
+
+// verificationEmail returns the email with a link confirming that user owns
+// their email address.
+func verificationEmail(user models.User) mailer.Message {
+	// Emails are stored escaped
+	email := html.UnescapeString(user.Email)
+	token := models.EmailVerification{
+		UserID:    user.ID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(models.EmailVerificationTTL),
+	}.Token(config.EmailVerificationSecret)
+	link := config.PublicURL + "/verify-email?token=" + url.QueryEscape(token)
+
+	return mailer.Message{
+		To:      []string{email},
+		Subject: "Confirm your email address",
+		Text: "Hello " + html.UnescapeString(user.FirstName) + ",\n\n" +
+			"Please confirm your email address by opening this link:\n\n" +
+			link + "\n\n" +
+			fmt.Sprintf("The link expires in %d hours. If you did not sign up, you can ignore this email.\n", int(models.EmailVerificationTTL.Hours())),
+	}
+}
+
+// allowVerificationEmail tells whether the address of user may get another
+// verification email, and if not, how long until it may.
+func allowVerificationEmail(ctx *socialnetwork.Context, user models.User) (bool, time.Duration) {
+	ok, wait := verificationMailLimiter.Allow(strings.ToLower(html.UnescapeString(user.Email)))
+	if !ok {
+		ctx.Logger().Warn("verification emails limited", "user_id", user.ID)
+	}
+	return ok, wait
+}
+
+// sendVerificationEmail mails user a link confirming they own their email
+// address, without holding up the response, unless the address got too many.
+func sendVerificationEmail(ctx *socialnetwork.Context, user models.User) {
+	if ok, _ := allowVerificationEmail(ctx, user); ok {
+		sendMailInBackground(ctx, verificationEmail(user), "verification")
+	}
+}
+
+// emailUpdated follows up on the update of user from previous: an email address
+// changed by the update is unverified until the user follows the link mailed to it.
+func emailUpdated(ctx *socialnetwork.Context, previous models.User, user *models.User) {
+	if previous.Email == html.EscapeString(user.Email) {
+		user.EmailVerified = previous.EmailVerified
+		return
+	}
+	user.EmailVerified = false
+	sendVerificationEmail(ctx, *user)
+}
+
+// verifyEmailHandler confirms the email address a verification link was sent to.
+// It does not require a session, as the link may be opened in another browser.
+func verifyEmailHandler(ctx *socialnetwork.Context) {
+	verification, err := models.ParseEmailVerification(config.EmailVerificationSecret, ctx.Request.URL.Query().Get("token"), time.Now())
+	if err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "This verification link is invalid or has expired.", nil)
+		return
+	}
+
+	user := models.User{ID: verification.UserID}
+	err = user.MarkEmailVerified(ctx, ctx.Db.Conn, verification.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The user changed their email address since, or is gone
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "This verification link is invalid or has expired.", nil)
+		return
+	}
+	if err != nil {
+		ctx.Logger().Error("verifying email failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Email address verified.",
+	})
+}
+
+var verifyEmailRoute = route{
+	method: http.MethodGet,
+	path:   "/verify-email",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		verifyEmailHandler,
+	},
+}
+
+// resendVerificationHandler mails the current user a new verification link.
+func resendVerificationHandler(ctx *socialnetwork.Context) {
+	userId, _ := ctx.Values["userId"].(uuid.UUID)
+
+	user := models.User{}
+	if err := user.Get(ctx, ctx.Db.Conn, userId); err != nil {
+		ctx.Logger().Error("retrieving the current user failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if user.EmailVerified {
+		ctx.Error(http.StatusConflict, socialnetwork.CodeConflict, "Your email address is already verified.", nil)
+		return
+	}
+	if ok, wait := allowVerificationEmail(ctx, user); !ok {
+		ctx.ResponseWriter.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(wait)))
+		ctx.Error(http.StatusTooManyRequests, socialnetwork.CodeTooManyRequests, "Too many verification emails were sent to this address, please retry later.", nil)
+		return
+	}
+	// Sent right away, so that the user learns if it failed
+	if err := config.Mailer.Send(ctx, verificationEmail(user)); err != nil {
+		ctx.Logger().Error("sending verification email failed", "error", err)
+		ctx.Error(http.StatusServiceUnavailable, socialnetwork.CodeUnavailable, "The verification email could not be sent, please try again later.", nil)
+		return
+	}
+	ctx.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "Verification email sent.",
+	})
+}
+
+var resendVerificationRoute = route{
+	method: http.MethodPost,
+	group:  authenticated,
+	path:   "/verify-email/resend",
+	middlewareAndHandler: []socialnetwork.HandlerFunc{
+		middleware.VerificationEmailRateLimit,
+		resendVerificationHandler,
+	},
+}
+
+func init() {
+	AllHandler[verifyEmailRoute.key()] = verifyEmailRoute
+	AllHandler[resendVerificationRoute.key()] = resendVerificationRoute
+}
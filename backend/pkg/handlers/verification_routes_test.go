@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"Social_Network/app/mailer"
+	"Social_Network/pkg/config"
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMailer keeps the emails it is asked to send. Until release is
+// closed, if not nil, sending waits for it.
+type recordingMailer struct {
+	release chan struct{}
+	mu      sync.Mutex
+	sent    []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	if m.release != nil {
+		select {
+		case <-m.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// to returns how many of the emails sent went to address.
+func (m *recordingMailer) to(address string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, msg := range m.sent {
+		if slices.Contains(msg.To, address) {
+			count++
+		}
+	}
+	return count
+}
+
+// useRecordingMailer sends the emails of the test to m.
+func useRecordingMailer(t *testing.T, m *recordingMailer) {
+	t.Helper()
+	previous := config.Mailer
+	config.Mailer = m
+	t.Cleanup(func() { config.Mailer = previous })
+}
+
+func TestRegistrationDoesNotWaitForEmail(t *testing.T) {
+	m := &recordingMailer{release: make(chan struct{})}
+	useRecordingMailer(t, m)
+	email := testEmail("registration")
+
+	done := make(chan int, 1)
+	go func() {
+		done <- request(t, http.MethodPost, "/registration", "", map[string]interface{}{
+			"email":       email,
+			"password":    testPassword,
+			"firstName":   "Test",
+			"lastName":    "User",
+			"dateOfBirth": time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, nil)
+	}()
+	select {
+	case status := <-done:
+		if status != http.StatusOK {
+			t.Errorf("registration = %d, want %d", status, http.StatusOK)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("registration waits for the verification email")
+	}
+
+	close(m.release)
+	for deadline := time.Now().Add(time.Second); m.to(email) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := m.to(email); got != 1 {
+		t.Errorf("%d verification emails sent, want 1", got)
+	}
+}
+
+func TestEmailChangeVerificationLimited(t *testing.T) {
+	m := &recordingMailer{}
+	useRecordingMailer(t, m)
+	user := createTestUser(t, testEmail("email-change"), true)
+	token := newTestSession(t, user.ID)
+	victim := testEmail("victim")
+
+	for i := 0; i < 5; i++ {
+		for _, email := range []string{victim, user.Email} {
+			body := map[string]interface{}{
+				"email":       email,
+				"password":    testPassword,
+				"firstName":   "Test",
+				"lastName":    "User",
+				"dateOfBirth": time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			if status := request(t, http.MethodPut, "/edituser", token, body, nil); status != http.StatusOK {
+				t.Fatalf("changing the email to %s = %d", email, status)
+			}
+		}
+	}
+	const limit = 3
+	for deadline := time.Now().Add(time.Second); m.to(victim) < limit && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := m.to(victim); got != limit {
+		t.Errorf("%d verification emails sent to the address, want %d", got, limit)
+	}
+}
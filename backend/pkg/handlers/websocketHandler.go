@@ -2,12 +2,19 @@ package handlers
 
 import (
 	socialnetwork "Social_Network/app"
+	"Social_Network/app/middleware/ratelimit"
+	"Social_Network/pkg/config"
 	"Social_Network/pkg/middleware"
 	"Social_Network/pkg/models"
 
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,9 +22,57 @@ import (
 )
 
 // ConnWrapper wraps a WebSocket connection, adding metadata such as its "Closed" state.
+// Writes go through its methods so that the broadcaster, the connection's own handler
+// and the shutdown hook never write to the connection concurrently.
 type ConnWrapper struct {
 	Conn   *websocket.Conn // The WebSocket connection object.
+	UserID uuid.UUID       // The user whose session opened the connection.
 	Closed bool            // Indicates whether the connection is closed.
+	mu     sync.Mutex      // Serializes writes and guards Closed.
+}
+
+// WriteJSON sends v as a JSON message unless the connection is already closed.
+func (w *ConnWrapper) WriteJSON(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Closed {
+		return websocket.ErrCloseSent
+	}
+	return w.Conn.WriteJSON(v)
+}
+
+// WriteMessage sends a message of the given type unless the connection is already closed.
+func (w *ConnWrapper) WriteMessage(messageType int, data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Closed {
+		return websocket.ErrCloseSent
+	}
+	return w.Conn.WriteMessage(messageType, data)
+}
+
+// CloseWithMessage sends a close frame with the given code and reason, then closes the connection.
+// Closing an already closed connection is a no-op.
+func (w *ConnWrapper) CloseWithMessage(code int, reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Closed {
+		return
+	}
+	w.Closed = true
+	deadline := time.Now().Add(time.Second)
+	_ = w.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	w.Conn.Close()
+}
+
+// Close closes the connection without sending a close frame.
+func (w *ConnWrapper) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.Closed {
+		w.Closed = true
+		w.Conn.Close()
+	}
 }
 
 // ConnMap is a thread-safe map for storing active WebSocket connections.
@@ -32,94 +87,206 @@ var (
 		WriteBufferSize: 1024,
 	}
 	conns = ConnMap{} // Global map to store all active WebSocket connections.
-	once  sync.Once   // Ensures initialization logic is executed only once.
+	once  sync.Once   // Ensures the broadcaster is started only once.
+
+	activeSockets sync.WaitGroup // Tracks running handleSocket calls so shutdown can wait for them.
+	shuttingDown  atomic.Bool    // Set once closeSockets starts; new connections are refused from then on.
+
+	broadcasterBeat atomic.Int64          // Unix time in nanoseconds of the broadcaster's last loop, 0 until it starts.
+	stopBroadcaster = make(chan struct{}) // Closed by closeSockets to end the broadcaster.
 )
 
+// broadcasterHeartbeat is how often an idle broadcaster reports it is alive, see broadcasterAlive.
+const broadcasterHeartbeat = 5 * time.Second
+
+// socketMessageTimeout is the time allowed to handle a single incoming message.
+const socketMessageTimeout = 10 * time.Second
+
+// socketMessageLimiter throttles private messages per sender. It is keyed by
+// user rather than by connection so that opening more sockets does not raise
+// the allowance.
+var socketMessageLimiter = ratelimit.NewLimiter(ratelimit.Config{Requests: 20, Per: 10 * time.Second, Burst: 10})
+
+// sendError sends an error message to the client, keeping the connection open.
+// Parameters:
+// - conn: The WebSocket connection to write to.
+// - status: HTTP status code the error code is derived from.
+// - message: Error message to send.
+// - id: The UUID of the connection, sent as the request ID.
+// - details: Optional details about the error, may be nil.
+func sendError(conn *ConnWrapper, status int, message string, id uuid.UUID, details any) {
+	err := conn.WriteJSON(socialnetwork.ErrorResponse{Error: socialnetwork.ErrorBody{
+		Code:      socialnetwork.CodeForStatus(status),
+		Message:   message,
+		Details:   details,
+		RequestID: id.String(),
+	}})
+	if err != nil && err != websocket.ErrCloseSent {
+		slog.Warn("writing to websocket failed", "conn_id", id, "error", err)
+	}
+}
+
 // sendErrorAndClose sends an error message to the client and closes the WebSocket connection.
 // Parameters:
 // - conn: The WebSocket connection to close.
-// - status: HTTP status code for the error.
+// - status: HTTP status code the error code is derived from.
 // - message: Error message to send.
 // - id: The UUID of the connection to remove from the global map.
-func sendErrorAndClose(conn *websocket.Conn, status int, message string, id uuid.UUID) {
-	err := conn.WriteJSON(map[string]interface{}{
-		"status":  status,
-		"message": message,
-	})
-	if err != nil {
-		log.Printf("Error writing to WebSocket: %v", err)
-	}
+func sendErrorAndClose(conn *ConnWrapper, status int, message string, id uuid.UUID) {
+	sendError(conn, status, message, id, nil)
 	conn.Close()
 	conns.Delete(id)
 }
 
+// startBroadcaster starts the goroutine forwarding models.Data to the open
+// WebSocket connections of the users concerned. It is registered as a start hook; later calls do nothing.
+func startBroadcaster() {
+	once.Do(func() {
+		broadcasterBeat.Store(time.Now().UnixNano())
+		go broadcast()
+	})
+}
+
+// broadcast sends every value received on models.Data to the active clients
+// of the users it is for, until stopBroadcaster is closed. It beats at least every broadcasterHeartbeat.
+func broadcast() {
+	slog.Info("starting websocket broadcaster")
+	heartbeat := time.NewTicker(broadcasterHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		broadcasterBeat.Store(time.Now().UnixNano())
+		var value map[string]interface{}
+		select {
+		case value = <-models.Data:
+		case <-heartbeat.C:
+			continue
+		case <-stopBroadcaster:
+			return
+		}
+
+		key, ok := value["key"].(string)
+		data, okData := value["data"].(map[string]interface{})
+		to, okTo := value["to"].([]uuid.UUID)
+		if !ok || !okData || !okTo {
+			continue
+		}
+
+		// Iterate over all active connections, skipping those of other users.
+		conns.Range(func(k, v interface{}) bool {
+			connID, validID := k.(uuid.UUID)
+			connWrapper, validWrapper := v.(*ConnWrapper)
+
+			if validID && validWrapper && slices.Contains(to, connWrapper.UserID) {
+				// Send a Ping message to keep the connection active.
+				if err := connWrapper.WriteMessage(websocket.PingMessage, nil); err != nil {
+					connWrapper.Close()
+					conns.Delete(connID)
+					return true
+				}
+
+				// Send the actual data to the client.
+				if err := connWrapper.WriteJSON(map[string]interface{}{
+					"data": data,
+					"type": key,
+				}); err != nil {
+					connWrapper.Close()
+					conns.Delete(connID)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// broadcasterAlive reports an error unless the broadcaster is running and has
+// looped recently, i.e. it is neither stopped nor stuck writing to a client.
+func broadcasterAlive() error {
+	beat := broadcasterBeat.Load()
+	if beat == 0 {
+		return errors.New("broadcaster not started")
+	}
+	if since := time.Since(time.Unix(0, beat)); since > 3*broadcasterHeartbeat {
+		return fmt.Errorf("broadcaster stalled for %s", since.Round(time.Second))
+	}
+	return nil
+}
+
+// closeSockets is registered as a shutdown hook. It refuses new WebSocket connections,
+// sends a "going away" close frame to every open one and waits for their handlers to return.
+func closeSockets(ctx context.Context) error {
+	if !shuttingDown.Swap(true) {
+		close(stopBroadcaster)
+	}
+	conns.Range(func(k, v interface{}) bool {
+		if connWrapper, ok := v.(*ConnWrapper); ok {
+			connWrapper.CloseWithMessage(websocket.CloseGoingAway, "server shutting down")
+		}
+		conns.Delete(k)
+		return true
+	})
+
+	done := make(chan struct{})
+	go func() {
+		activeSockets.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("closing websockets: %w", ctx.Err())
+	}
+}
+
 // handleSocket handles incoming WebSocket connections and manages real-time messaging.
 func handleSocket(ctx *socialnetwork.Context) {
+	// The handler is tracked before upgrading: the HTTP server waits for it until the
+	// connection is hijacked, and closeSockets waits for it from then on.
+	activeSockets.Add(1)
+	defer activeSockets.Done()
+	if shuttingDown.Load() {
+		ctx.Error(http.StatusServiceUnavailable, socialnetwork.CodeUnavailable, "Server is shutting down", nil)
+		return
+	}
+
 	// Upgrade the HTTP connection to a WebSocket connection.
-	conn, err := upgrader.Upgrade(ctx.ResponseWriter, ctx.Request, nil)
+	ws, err := upgrader.Upgrade(ctx.ResponseWriter, ctx.Request, nil)
 	if err != nil {
-		log.Printf("Error upgrading connection: %v", err)
+		ctx.Logger().Warn("upgrading to websocket failed", "error", err)
 		return
 	}
 
 	// Assign a unique ID to the connection and store it in the global map.
+	// The connection acts for the user of the session it was opened with.
 	id := uuid.New()
-	conns.Store(id, &ConnWrapper{Conn: conn, Closed: false})
+	conn := &ConnWrapper{Conn: ws, UserID: ctx.Values["userId"].(uuid.UUID), Closed: false}
+	token, _ := ctx.Values["token"].(string)
+	conns.Store(id, conn)
 
-	// Start a goroutine for broadcasting data to all active clients.
-	once.Do(func() {
-		go func() {
-			log.Println("Starting WebSocket broadcast goroutine")
-			for {
-				// Wait for new data to be available in the models.Data channel.
-				value := <-models.Data
-				key, ok := value["key"].(string)
-				data, okData := value["data"].(map[string]interface{})
-
-				if ok && okData {
-					// Iterate over all active connections.
-					conns.Range(func(k, v interface{}) bool {
-						connID, validID := k.(uuid.UUID)
-						connWrapper, validWrapper := v.(*ConnWrapper)
-
-						if validID && validWrapper && !connWrapper.Closed {
-							// Set a Pong handler to verify the connection is still alive.
-							connWrapper.Conn.SetPongHandler(func(string) error {
-								connWrapper.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-								return nil
-							})
-
-							// Send a Ping message to keep the connection active.
-							if err := connWrapper.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-								connWrapper.Closed = true
-								conns.Delete(connID)
-								return true
-							}
-
-							// Send the actual data to the client.
-							if err := connWrapper.Conn.WriteJSON(map[string]interface{}{
-								"data": data,
-								"type": key,
-							}); err != nil {
-								connWrapper.Closed = true
-								conns.Delete(connID)
-							}
-						}
-						return true
-					})
-				}
-			}
-		}()
+	// Set a Pong handler to verify the connection is still alive.
+	ws.SetPongHandler(func(string) error {
+		ws.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
 	})
 
 	// Handle incoming messages from the client.
 	for {
 		var incomingData map[string]interface{}
-		if err := conn.ReadJSON(&incomingData); err != nil {
+		if err := ws.ReadJSON(&incomingData); err != nil {
+			if shuttingDown.Load() {
+				// closeSockets already said goodbye to the client.
+				return
+			}
 			sendErrorAndClose(conn, http.StatusBadRequest, "Invalid message format", id)
 			return
 		}
 
+		// Stop acting for the user once they logged out or the session was revoked.
+		if !config.Sess.Start(ctx).Valid(token) {
+			sendErrorAndClose(conn, http.StatusUnauthorized, "You are not authenticated.", id)
+			return
+		}
+
 		// Handle specific message types, e.g., private or group messages.
 		switch incomingData["type"] {
 		case "private_message":
@@ -139,34 +306,66 @@ func handleSocket(ctx *socialnetwork.Context) {
 // - conn: The WebSocket connection sending the message.
 // - incomingData: The data payload received from the client.
 // - id: The unique identifier for the WebSocket connection.
-func handlePrivateMessage(ctx *socialnetwork.Context, conn *websocket.Conn, incomingData map[string]interface{}, id uuid.UUID) {
+func handlePrivateMessage(ctx *socialnetwork.Context, conn *ConnWrapper, incomingData map[string]interface{}, id uuid.UUID) {
 	msg, ok := incomingData["message"].(map[string]interface{})
 	if !ok {
 		sendErrorAndClose(conn, http.StatusBadRequest, "Invalid message format", id)
 		return
 	}
 
-	privateMessage := models.PrivateMessage{
-		Content:    msg["content"].(string),
-		SenderID:   uuid.MustParse(msg["sender_id"].(string)),
-		ReceiverID: uuid.MustParse(msg["receiver_id"].(string)),
-	}
+	content, _ := msg["content"].(string)
+	receiver, _ := msg["receiver_id"].(string)
+	receiverID, err := uuid.Parse(receiver)
 
 	// Validate the private message fields.
-	if privateMessage.Content == "" || privateMessage.SenderID == uuid.Nil || privateMessage.ReceiverID == uuid.Nil {
+	if content == "" || err != nil || receiverID == uuid.Nil {
 		sendErrorAndClose(conn, http.StatusBadRequest, "Incomplete message data", id)
 		return
 	}
+	// The sender is the user of the connection; any "sender_id" sent is ignored.
+	privateMessage := models.PrivateMessage{
+		Content:    content,
+		SenderID:   conn.UserID,
+		ReceiverID: receiverID,
+	}
+
+	// Drop the message, but keep the connection, when the sender floods.
+	if ok, retryAfter := socketMessageLimiter.Allow("user:" + privateMessage.SenderID.String()); !ok {
+		sendError(conn, http.StatusTooManyRequests, "Too many messages, please slow down.", id, map[string]int{
+			"retry_after": ratelimit.RetryAfterSeconds(retryAfter),
+		})
+		return
+	}
+
+	// The socket route has no timeout, so each message gets its own deadline.
+	msgCtx, cancel := context.WithTimeout(ctx, socketMessageTimeout)
+	defer cancel()
 
 	user := models.User{}
 	// Verify the existence of sender and receiver users.
-	if user.Get(ctx.Db.Conn, privateMessage.ReceiverID) != nil || user.Get(ctx.Db.Conn, privateMessage.SenderID) != nil {
+	if user.Get(msgCtx, ctx.Db.Conn, privateMessage.ReceiverID) != nil || user.Get(msgCtx, ctx.Db.Conn, privateMessage.SenderID) != nil {
 		sendErrorAndClose(conn, http.StatusNotFound, "Sender not found", id)
 		return
 	}
+	// Like the routes creating content, messaging waits for a verified address.
+	if !user.EmailVerified {
+		sendError(conn, http.StatusForbidden, "Please verify your email address first.", id, nil)
+		return
+	}
+
+	// Neither side of a block can reach the other.
+	blocked, err := models.IsBlocked(msgCtx, ctx.Db.Conn, privateMessage.SenderID, privateMessage.ReceiverID)
+	if err != nil {
+		sendErrorAndClose(conn, http.StatusInternalServerError, "Failed to check the receiver", id)
+		return
+	}
+	if blocked {
+		sendError(conn, http.StatusForbidden, "You cannot message this user.", id, nil)
+		return
+	}
 
 	// Save the private message to the database.
-	if err := privateMessage.Create(ctx.Db.Conn); err != nil {
+	if err := privateMessage.Create(msgCtx, ctx.Db.Conn); err != nil {
 		sendErrorAndClose(conn, http.StatusInternalServerError, "Failed to save message", id)
 		return
 	}
@@ -178,15 +377,17 @@ func handlePrivateMessage(ctx *socialnetwork.Context, conn *websocket.Conn, inco
 		Type:      models.TypeNewMessage,
 		Message:   privateMessage.Content,
 	}
-	if err := notification.Create(ctx.Db.Conn); err != nil {
+	if err := notification.Create(msgCtx, ctx.Db.Conn); err != nil {
 		sendErrorAndClose(conn, http.StatusInternalServerError, "Failed to create notification", id)
 	}
 }
 
 // handleSocketRoute defines the WebSocket endpoint for handling client connections.
 var handleSocketRoute = route{
-	path:   "/socket", // Endpoint URL path for WebSocket connections.
-	method: http.MethodGet,
+	path:    "/socket", // Endpoint URL path for WebSocket connections.
+	method:  http.MethodGet,
+	group:   authenticated, // Each connection acts for the user of a session.
+	timeout: noTimeout,     // The connection lives as long as the client stays; messages get their own deadline.
 	middlewareAndHandler: []socialnetwork.HandlerFunc{
 		middleware.AllowedServer, // Middleware to validate the request origin/server.
 		handleSocket,             // WebSocket handler for real-time communication.
@@ -195,5 +396,5 @@ var handleSocketRoute = route{
 
 // init registers the WebSocket route with the global AllHandler map.
 func init() {
-	AllHandler[handleSocketRoute.path] = handleSocketRoute
+	AllHandler[handleSocketRoute.key()] = handleSocketRoute
 }
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"Social_Network/pkg/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// dialSocket opens a WebSocket to server with the given session token.
+func dialSocket(t *testing.T, server *httptest.Server, token string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	ws, response, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/socket", header)
+	if err == nil {
+		t.Cleanup(func() { ws.Close() })
+	}
+	return ws, response, err
+}
+
+// readSocket returns the next JSON message of ws, or nil if none comes within timeout.
+func readSocket(t *testing.T, ws *websocket.Conn, timeout time.Duration) map[string]interface{} {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(timeout))
+	var message map[string]interface{}
+	if err := ws.ReadJSON(&message); err != nil {
+		return nil
+	}
+	return message
+}
+
+func TestSocketRequiresSession(t *testing.T) {
+	server := httptest.NewServer(testApp)
+	defer server.Close()
+
+	_, response, err := dialSocket(t, server, "")
+	if err == nil || response == nil || response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("dialing without a session: %v, want %d", err, http.StatusUnauthorized)
+	}
+}
+
+func TestSocketPrivateMessage(t *testing.T) {
+	startBroadcaster()
+	server := httptest.NewServer(testApp)
+	defer server.Close()
+	sender := createTestUser(t, testEmail("socket-sender"), true)
+	receiver := createTestUser(t, testEmail("socket-receiver"), true)
+	other := createTestUser(t, testEmail("socket-other"), true)
+
+	conns := map[uuid.UUID]*websocket.Conn{}
+	for _, user := range []uuid.UUID{sender.ID, receiver.ID, other.ID} {
+		ws, _, err := dialSocket(t, server, newTestSession(t, user))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[user] = ws
+	}
+
+	// The sender claimed in the payload is not trusted
+	err := conns[sender.ID].WriteJSON(map[string]interface{}{
+		"type": "private_message",
+		"message": map[string]interface{}{
+			"sender_id":   other.ID,
+			"receiver_id": receiver.ID,
+			"content":     "hello",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []uuid.UUID{sender.ID, receiver.ID} {
+		message := readSocket(t, conns[user], time.Second)
+		data, _ := message["data"].(map[string]interface{})
+		if message["type"] != "private_message" || data["SenderID"] != sender.ID.String() || data["ReceiverID"] != receiver.ID.String() {
+			t.Errorf("%v received %v, want the message from the user of the socket", user, message)
+		}
+	}
+	if message := readSocket(t, conns[other.ID], 100*time.Millisecond); message != nil {
+		t.Errorf("a user the message is not for received %v", message)
+	}
+}
+
+func TestSocketMalformedMessage(t *testing.T) {
+	server := httptest.NewServer(testApp)
+	defer server.Close()
+	user := createTestUser(t, testEmail("socket-malformed"), true)
+
+	for _, message := range []map[string]interface{}{
+		{"content": 42, "receiver_id": uuid.NewString()},
+		{"content": "hello", "receiver_id": "not a uuid"},
+		{"content": "hello"},
+	} {
+		ws, _, err := dialSocket(t, server, newTestSession(t, user.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.WriteJSON(map[string]interface{}{"type": "private_message", "message": message}); err != nil {
+			t.Fatal(err)
+		}
+		response := readSocket(t, ws, time.Second)
+		if body, _ := response["error"].(map[string]interface{}); body["code"] != "bad_request" {
+			t.Errorf("sending %v: received %v, want a bad request error", message, response)
+		}
+	}
+}
+
+func TestSocketRevokedSession(t *testing.T) {
+	server := httptest.NewServer(testApp)
+	defer server.Close()
+	user := createTestUser(t, testEmail("socket-revoked"), true)
+	token := newTestSession(t, user.ID)
+	ws, _, err := dialSocket(t, server, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := request(t, http.MethodDelete, "/logout", token, nil, nil); status != http.StatusOK {
+		t.Fatalf("logout = %d", status)
+	}
+	ws.WriteJSON(map[string]interface{}{"type": "private_message", "message": map[string]interface{}{"content": "hello", "receiver_id": user.ID}})
+	response := readSocket(t, ws, time.Second)
+	if body, _ := response["error"].(map[string]interface{}); body["code"] != "unauthorized" {
+		t.Errorf("received %v after logging out, want an unauthorized error", response)
+	}
+}
+
+func TestSocketHiddenNotifications(t *testing.T) {
+	startBroadcaster()
+	server := httptest.NewServer(testApp)
+	defer server.Close()
+	ctx := context.Background()
+	receiver := createTestUser(t, testEmail("notified"), true)
+	muted := createTestUser(t, testEmail("notifier-muted"), true)
+	blocked := createTestUser(t, testEmail("notifier-blocked"), true)
+	other := createTestUser(t, testEmail("notifier"), true)
+	if err := (&models.Mute{MuterID: receiver.ID, MutedID: muted.ID}).Create(ctx, testDB); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&models.Block{BlockerID: blocked.ID, BlockedID: receiver.ID}).Create(ctx, testDB); err != nil {
+		t.Fatal(err)
+	}
+	ws, _, err := dialSocket(t, server, newTestSession(t, receiver.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, author := range []uuid.UUID{muted.ID, blocked.ID, other.ID} {
+		notification := models.Notification{UserID: author, ConcernID: receiver.ID, Type: models.TypeFollowRequest, Message: "follow request"}
+		if err := notification.Create(ctx, testDB); err != nil {
+			t.Fatal(err)
+		}
+	}
+	message := readSocket(t, ws, time.Second)
+	user, _ := message["data"].(map[string]interface{})["user"].(map[string]interface{})
+	if message["type"] != "notification" || user["id"] != other.ID.String() {
+		t.Errorf("received %v, want the notification of the user not hidden", message)
+	}
+	if message := readSocket(t, ws, 100*time.Millisecond); message != nil {
+		t.Errorf("received %v from a hidden user", message)
+	}
+	var stored int
+	if err := testDB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE concern_id = $1`, receiver.ID).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != 1 {
+		t.Errorf("%d notifications stored, want 1", stored)
+	}
+}
@@ -3,10 +3,14 @@ package middleware
 import (
 	socialnetwork "Social_Network/app"
 	"Social_Network/pkg/config"
-	"fmt"
+	"Social_Network/pkg/models"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"os"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 // AuthRequired checks if the user is authenticated
@@ -17,32 +21,85 @@ func AuthRequired(ctx *socialnetwork.Context) {
 	if strings.HasPrefix(headerBearer, "Bearer ") {
 		token = strings.TrimPrefix(headerBearer, "Bearer ")
 	}
+	if strings.HasPrefix(token, models.AccessTokenPrefix) {
+		accessTokenAuth(ctx, token)
+		return
+	}
 
-	// Check if the session is valid for the provided token
-	if !config.Sess.Start(ctx).Valid(token) {
+	// Retrieve the session for the provided token
+	session, err := config.Sess.Start(ctx).Current(token)
+	if err != nil {
 		// Respond with an error if the user is not authenticated
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not authenticated.",
-		})
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are not authenticated.", nil)
 		return
 	}
+	userId := session.UserID
 
-	// Retrieve the user ID from the session
-	userId, err := config.Sess.Start(ctx).Get(token)
-	fmt.Println("// Retrieve the user ID from the session")
-	fmt.Println(userId)
+	// Store user ID and session ID in context for further use; the session ID
+	// is the token, whether it came as a bearer token or in the cookie
+	ctx.Values["userId"] = userId
+	ctx.Values["token"] = session.ID
+	// Tag everything logged for the rest of the request with the user
+	ctx.SetLogger(ctx.Logger().With("user_id", userId))
+	// Proceed to the next middleware
+	ctx.Next()
+}
 
+// accessTokenAuth authenticates the request with a personal access token. The
+// token is kept in the context for ScopeRequired, and no session ID is set, so
+// routes acting on the session stay out of reach.
+func accessTokenAuth(ctx *socialnetwork.Context, token string) {
+	accessToken := models.AccessToken{}
+	err := accessToken.Authenticate(ctx, ctx.Db.Conn, token)
+	if errors.Is(err, models.ErrInvalidToken) {
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are not authenticated.", nil)
+		return
+	}
 	if err != nil {
-		// Respond with an error if the user is not authenticated
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not authenticated.",
-		})
+		ctx.Logger().Error("checking access token failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
 		return
 	}
 
-	// Store user ID and token in context for further use
-	ctx.Values["userId"] = userId
-	ctx.Values["token"] = token
+	ctx.Values["userId"] = accessToken.UserID
+	ctx.Values["accessToken"] = accessToken
+	ctx.SetLogger(ctx.Logger().With("user_id", accessToken.UserID, "access_token_id", accessToken.ID))
+	ctx.Next()
+}
+
+// ScopeRequired returns a middleware letting requests authenticated by a
+// personal access token through only if the token grants scope. Without a
+// scope, the route is reserved to sessions. Other requests are let through.
+func ScopeRequired(scope string) socialnetwork.HandlerFunc {
+	return func(ctx *socialnetwork.Context) {
+		accessToken, ok := ctx.Values["accessToken"].(models.AccessToken)
+		if ok && (scope == "" || !accessToken.HasScope(scope)) {
+			var details interface{}
+			if scope != "" {
+				details = map[string]interface{}{"scope": scope}
+			}
+			ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "Your access token does not allow this request.", details)
+			return
+		}
+		// Proceed to the next middleware
+		ctx.Next()
+	}
+}
+
+// VerifiedRequired checks if the authenticated user confirmed their email address
+func VerifiedRequired(ctx *socialnetwork.Context) {
+	userId := ctx.Values["userId"].(uuid.UUID)
+	verified, err := models.IsEmailVerified(ctx, ctx.Db.Conn, userId)
+	if err != nil {
+		ctx.Logger().Error("checking email verification failed", "error", err)
+		ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+		return
+	}
+	if !verified {
+		// Respond with an error until the user follows the link they were mailed
+		ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "Please verify your email address first.", nil)
+		return
+	}
 	// Proceed to the next middleware
 	ctx.Next()
 }
@@ -59,9 +116,7 @@ func NoAuthRequired(ctx *socialnetwork.Context) {
 	// Check if the session is already valid for the provided token
 	if config.Sess.Start(ctx).Valid(token) {
 		// Respond with an error if the user is already authenticated
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are already authenticated.",
-		})
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are already authenticated.", nil)
 		return
 	}
 	// Proceed to the next middleware
@@ -74,9 +129,23 @@ func AllowedServer(ctx *socialnetwork.Context) {
 	// Check if the provided server key matches the expected one
 	if key != os.Getenv("SERVER_KEY") {
 		// Respond with an error if the server is not authorized
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not allowed to access this server.",
-		})
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are not allowed to access this server.", nil)
+		return
+	}
+	// Proceed to the next middleware
+	ctx.Next()
+}
+
+// MetricsAuth only lets through the requests bearing config.MetricsToken, and
+// hides the metrics altogether while it is not set.
+func MetricsAuth(ctx *socialnetwork.Context) {
+	if config.MetricsToken == "" {
+		ctx.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Not found", nil)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(ctx.GetBearerToken()), []byte(config.MetricsToken)) != 1 {
+		ctx.ResponseWriter.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are not allowed to read the metrics.", nil)
 		return
 	}
 	// Proceed to the next middleware
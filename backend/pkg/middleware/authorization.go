@@ -2,12 +2,9 @@ package middleware
 
 import (
 	socialnetwork "Social_Network/app"
-	"Social_Network/pkg/config"
 	"Social_Network/pkg/models"
-	"fmt"
 	"github.com/google/uuid"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path"
@@ -16,21 +13,31 @@ import (
 	"time"
 )
 
+// requestID returns an identifier from the named path parameter, falling back to
+// the legacy query string key so that both /groups/{groupID} and ?group_id= work.
+func requestID(c *socialnetwork.Context, param, query string) string {
+	if id := c.Param(param); id != "" {
+		return id
+	}
+	return c.Request.URL.Query().Get(query)
+}
+
 // HaveGroupAccess checks if the user is a member of the group and has access.
 func HaveGroupAccess(ctx *socialnetwork.Context) {
-	groupId := ctx.Request.URL.Query().Get("group_id")
-	if groupId == "" {
-		ctx.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Group ID is required.",
-		})
+	_groupId := requestID(ctx, "groupID", "group_id")
+	if _groupId == "" {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Group ID is required.", nil)
+		return
+	}
+	groupId, err := uuid.Parse(_groupId)
+	if err != nil {
+		ctx.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid group uuid", nil)
 		return
 	}
 	userUUID := ctx.Values["userId"].(uuid.UUID)
 	var mg = new(models.GroupMember)
-	if err := mg.GetMember(ctx.Db.Conn, userUUID, uuid.MustParse(groupId), false); err != nil {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not authorized.",
-		})
+	if err := mg.GetMember(ctx, ctx.Db.Conn, userUUID, groupId, false); err != nil {
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are not authorized.", nil)
 		return
 	}
 	ctx.Values["role"] = mg.Role
@@ -42,9 +49,7 @@ func HaveGroupAccess(ctx *socialnetwork.Context) {
 func IsGroupAdmin(ctx *socialnetwork.Context) {
 	role := ctx.Values["role"].(models.GroupMemberRole)
 	if role != models.MemberRoleAdmin {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not authorized.",
-		})
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are not authorized.", nil)
 		return
 	}
 	ctx.Next()
@@ -54,20 +59,40 @@ func IsGroupAdmin(ctx *socialnetwork.Context) {
 func CheckGroupRole(ctx *socialnetwork.Context, role models.GroupMemberRole) {
 	_role, ok := ctx.Values["role"].(models.GroupMemberRole)
 	if !ok {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not authorized.",
-		})
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are not authorized.", nil)
 		return
 	}
 	if _role != role {
-		ctx.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not authorized.",
-		})
+		ctx.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are not authorized.", nil)
 		return
 	}
 	ctx.Next()
 }
 
+// RequireSiteRole returns a middleware letting through the users with the given
+// site role or a more privileged one.
+func RequireSiteRole(role models.SiteRole) socialnetwork.HandlerFunc {
+	return func(ctx *socialnetwork.Context) {
+		// A parent group may have looked the role up already
+		userRole, ok := ctx.Values["siteRole"].(models.SiteRole)
+		if !ok {
+			var err error
+			userRole, err = models.GetSiteRole(ctx, ctx.Db.Conn, ctx.Values["userId"].(uuid.UUID))
+			if err != nil {
+				ctx.Logger().Error("retrieving site role failed", "error", err)
+				ctx.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Internal server error.", nil)
+				return
+			}
+			ctx.Values["siteRole"] = userRole
+		}
+		if !userRole.AtLeast(role) {
+			ctx.Error(http.StatusForbidden, socialnetwork.CodeForbidden, "You are not authorized.", nil)
+			return
+		}
+		ctx.Next()
+	}
+}
+
 const DirName = "uploads"
 
 // ImageUploadMiddleware checks if the file is an image and uploads it.
@@ -75,16 +100,12 @@ func ImageUploadMiddleware(c *socialnetwork.Context) {
 	// Parse the multipart form in the request
 	err := c.Request.ParseMultipartForm(10 << 20) // 10 MB
 	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Error parsing the form.",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error parsing the form.", nil)
 		return
 	}
 	file, handler, err := c.Request.FormFile("file")
 	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Error retrieving the file.",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Error retrieving the file.", nil)
 		return
 	}
 	defer file.Close()
@@ -92,9 +113,7 @@ func ImageUploadMiddleware(c *socialnetwork.Context) {
 	// Check if the file is an image
 	ext := []string{".jpeg", ".jpg", ".png", ".svg+xml", ".gif"}
 	if !contains(ext, strings.ToLower(filepath.Ext(handler.Filename))) {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "File type not allowed.",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "File type not allowed.", nil)
 		return
 	}
 
@@ -104,17 +123,13 @@ func ImageUploadMiddleware(c *socialnetwork.Context) {
 	// Create the file using the id as the name and the extension from the original file
 	dst, err := os.Create(pathImg)
 	if err != nil {
-		c.Status(http.StatusInternalServerError).JSON(map[string]string{
-			"error": "Error creating the file.",
-		})
+		c.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error creating the file.", nil)
 		return
 	}
 
 	// Write the file
 	if _, err := io.Copy(dst, file); err != nil {
-		c.Status(http.StatusInternalServerError).JSON(map[string]string{
-			"error": "Error writing the file.",
-		})
+		c.Error(http.StatusInternalServerError, socialnetwork.CodeInternal, "Error writing the file.", nil)
 		return
 	}
 	c.Values["file"] = pathImg
@@ -131,155 +146,18 @@ func contains(s []string, e string) bool {
 	return false
 }
 
-// IsPostValid checks if the post data is valid.
-func IsPostValid(c *socialnetwork.Context) {
-	var data = map[string]interface{}{}
-	if err := c.BodyParser(&data); err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Error parsing request body.",
-		})
-		return
-	}
-
-	privacy := data["privacy"].(string)
-	_, err := models.PostPrivacyFromString(privacy)
-	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid privacy value.",
-		})
-		return
-	}
-
-	if data["content"] == nil || strings.TrimSpace(data["content"].(string)) == "" ||
-		data["title"] == nil || strings.TrimSpace(data["title"].(string)) == "" ||
-		(data["image_url"] == nil && strings.TrimSpace(data["image_url"].(string)) == "") {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid data.",
-		})
-		return
-	}
-	c.Next()
-}
-
-// CreateGroupMiddleware checks if the group creation data is valid.
-func CreateGroupMiddleware(c *socialnetwork.Context) {
-	var token string
-	headerBearer := c.Request.Header.Get("Authorization")
-	if strings.HasPrefix(headerBearer, "Bearer ") {
-		token = strings.TrimPrefix(headerBearer, "Bearer ")
-	}
-	var data = map[string]interface{}{}
-	if err := c.BodyParser(&data); err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Error parsing request body.",
-		})
-		return
-	}
-	_, err := config.Sess.Start(c).Get(token)
-	if err != nil {
-		c.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not logged in.",
-		})
-		return
-	}
-
-	if data["title"] == nil || strings.TrimSpace(data["title"].(string)) == "" ||
-		data["description"] == nil || strings.TrimSpace(data["description"].(string)) == "" ||
-		(data["banner_url"] == nil && strings.TrimSpace(data["banner_url"].(string)) == "") {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid data.",
-		})
-		return
-	}
-	c.Next()
-}
-
-// CreateEventMiddleware checks if the event creation data is valid.
-func CreateEventMiddleware(c *socialnetwork.Context) {
-	var token string
-	headerBearer := c.Request.Header.Get("Authorization")
-	if strings.HasPrefix(headerBearer, "Bearer ") {
-		token = strings.TrimPrefix(headerBearer, "Bearer ")
-	}
-	var data = map[string]interface{}{}
-	if err := c.BodyParser(&data); err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Error parsing request body.",
-		})
-		return
-	}
-	_, err := config.Sess.Start(c).Get(token)
-	if err != nil {
-		c.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not logged in.",
-		})
-		return
-	}
-
-	if data["group_id"] == nil || strings.TrimSpace(data["group_id"].(string)) == "" ||
-		data["title"] == nil || strings.TrimSpace(data["title"].(string)) == "" ||
-		data["description"] == nil || strings.TrimSpace(data["description"].(string)) == "" ||
-		data["date_time"] == nil || strings.TrimSpace(data["date_time"].(string)) == "" {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid data.",
-		})
-		return
-	}
-	c.Next()
-}
-
-func IsGroupPostValid(c *socialnetwork.Context) {
-	var data = map[string]interface{}{}
-	if err := c.BodyParser(&data); err != nil {
-		log.Println(err.Error())
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Error parsing request body",
-		})
-		return
-	}
-	if data["title"] == nil || strings.TrimSpace(data["title"].(string)) == "" ||
-		data["content"] == nil || strings.TrimSpace(data["content"].(string)) == "" || data["privacy"] == nil || strings.TrimSpace(data["privacy"].(string)) == "" || (data["privacy"] != "public" && data["privacy"] != "private" && data["privacy"] != "group" && data["privacy"] != "almost private") {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid data",
-		})
-		return
-	}
-	c.Next()
-}
-func IsGroupValid(c *socialnetwork.Context) {
-	var data = map[string]interface{}{}
-	if err := c.BodyParser(&data); err != nil {
-		log.Println(err.Error())
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Error parsing request body",
-		})
-		return
-	}
-	if data["title"] == nil || strings.TrimSpace(data["title"].(string)) == "" ||
-		data["description"] == nil || strings.TrimSpace(data["description"].(string)) == "" {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid data",
-		})
-		return
-	}
-	c.Next()
-}
 func IsGroupExist(c *socialnetwork.Context) {
-	_groupId := c.Request.URL.Query().Get("group_id")
+	_groupId := requestID(c, "groupID", "group_id")
 	group := new(models.Group)
 	// Check if the group is uuid
 	groupId, err := uuid.Parse(_groupId)
 
 	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid group uuid",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid group uuid", nil)
 		return
 	}
-	if err := group.Get(c.Db.Conn, groupId, false, false); err != nil {
-		c.Status(http.StatusNotFound).JSON(map[string]string{
-			"error": "Group not found",
-		})
+	if err := group.Get(c, c.Db.Conn, groupId, false, false); err != nil {
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Group not found", nil)
 		return
 	}
 	c.Values["group_id"] = groupId
@@ -287,32 +165,24 @@ func IsGroupExist(c *socialnetwork.Context) {
 	c.Next()
 }
 func IsInvitedUserExist(c *socialnetwork.Context) {
-	_userId := c.Request.URL.Query().Get("user_id")
-	fmt.Println("IsInvitedUserExist(c *socialnetwork.Context) {")
-	fmt.Println("_userId")
+	_userId := requestID(c, "userID", "user_id")
 	user := new(models.User)
 	userId, err := uuid.Parse(_userId)
 	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid user uuid",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid user uuid", nil)
 		return
 	}
-	if err := user.Get(c.Db.Conn, userId); err != nil {
-		c.Status(http.StatusNotFound).JSON(map[string]string{
-			"error": "User not found",
-		})
+	if err := user.Get(c, c.Db.Conn, userId); err != nil {
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "User not found", nil)
 		return
 	}
 	// Check if the user is already in the group
 	groupId := c.Values["group_id"].(uuid.UUID)
 	member := new(models.GroupMember)
-	if err := member.GetMember(c.Db.Conn, userId, groupId, false); err == nil &&
+	if err := member.GetMember(c, c.Db.Conn, userId, groupId, false); err == nil &&
 		(member.Status == models.MemberStatusInvited ||
 			member.Status == models.MemberStatusAccepted) {
-		c.Status(http.StatusConflict).JSON(map[string]string{
-			"error": "User already in the group",
-		})
+		c.Error(http.StatusConflict, socialnetwork.CodeConflict, "User already in the group", nil)
 		return
 	}
 	c.Values["invited_user_id"] = userId
@@ -322,35 +192,27 @@ func NoGroupAccess(c *socialnetwork.Context) {
 	groupId := c.Values["group_id"].(uuid.UUID)
 	userUUID := c.Values["userId"].(uuid.UUID)
 	var mg = new(models.GroupMember)
-	if err := mg.GetMember(c.Db.Conn, userUUID, groupId, false); err == nil && mg.Status != models.MemberStatusDeclined {
-		c.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are already a member of this group.",
-		})
+	if err := mg.GetMember(c, c.Db.Conn, userUUID, groupId, false); err == nil && mg.Status != models.MemberStatusDeclined {
+		c.Error(http.StatusUnauthorized, socialnetwork.CodeUnauthorized, "You are already a member of this group.", nil)
 		return
 	}
 	c.Next()
 }
 func IsGroupPostExist(c *socialnetwork.Context) {
 	groupId := c.Values["group_id"].(uuid.UUID)
-	_postId := c.Request.URL.Query().Get("post_id")
+	_postId := requestID(c, "postID", "post_id")
 	post := new(models.Post)
 	postId, err := uuid.Parse(_postId)
 	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid post uuid",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid post uuid", nil)
 		return
 	}
-	if err := post.Get(c.Db.Conn, postId); err != nil {
-		c.Status(http.StatusNotFound).JSON(map[string]string{
-			"error": "Post not found",
-		})
+	if err := post.Get(c, c.Db.Conn, postId); err != nil {
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Post not found", nil)
 		return
 	}
 	if post.GroupID != groupId {
-		c.Status(http.StatusNotFound).JSON(map[string]string{
-			"error": "Post not found",
-		})
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Post not found", nil)
 		return
 	}
 	c.Values["group_id"] = groupId
@@ -358,20 +220,16 @@ func IsGroupPostExist(c *socialnetwork.Context) {
 	c.Next()
 }
 func IsInvitationExist(c *socialnetwork.Context) {
-	_invitationId := c.Request.URL.Query().Get("invitation_id")
+	_invitationId := requestID(c, "invitationID", "invitation_id")
 	member := new(models.GroupMember)
 	invitationId, err := uuid.Parse(_invitationId)
 	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid invitation uuid",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid invitation uuid", nil)
 		return
 	}
 
-	if err := member.GetMemberById(c.Db.Conn, invitationId, false); err != nil && member.Status != models.MemberStatusInvited {
-		c.Status(http.StatusNotFound).JSON(map[string]string{
-			"error": "Invitation not found",
-		})
+	if err := member.GetMemberById(c, c.Db.Conn, invitationId, false); err != nil && member.Status != models.MemberStatusInvited {
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Invitation not found", nil)
 		return
 	}
 	c.Values["invitation_id"] = invitationId
@@ -379,19 +237,15 @@ func IsInvitationExist(c *socialnetwork.Context) {
 	c.Next()
 }
 func IsAccessDemandExist(c *socialnetwork.Context) {
-	_requestingId := c.Request.URL.Query().Get("requesting_id")
+	_requestingId := requestID(c, "requestingID", "requesting_id")
 	member := new(models.GroupMember)
 	requestingId, err := uuid.Parse(_requestingId)
 	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid requesting uuid",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid requesting uuid", nil)
 		return
 	}
-	if err := member.GetMemberById(c.Db.Conn, requestingId, false); err != nil && member.Status != models.MemberStatusRequesting {
-		c.Status(http.StatusNotFound).JSON(map[string]string{
-			"error": "Requesting not found",
-		})
+	if err := member.GetMemberById(c, c.Db.Conn, requestingId, false); err != nil && member.Status != models.MemberStatusRequesting {
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Requesting not found", nil)
 		return
 	}
 
@@ -400,59 +254,26 @@ func IsAccessDemandExist(c *socialnetwork.Context) {
 	c.Next()
 }
 func IsEventExist(c *socialnetwork.Context) {
-	_eventId := c.Request.URL.Query().Get("event_id")
+	_eventId := requestID(c, "eventID", "event_id")
 	event := new(models.Event)
 	eventId, err := uuid.Parse(_eventId)
 	if err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid event uuid",
-		})
+		c.Error(http.StatusBadRequest, socialnetwork.CodeBadRequest, "Invalid event uuid", nil)
 		return
 	}
-	if err := event.Get(c.Db.Conn, eventId, false, false); err != nil {
-		c.Status(http.StatusNotFound).JSON(map[string]string{
-			"error": "Event not found",
-		})
+	if err := event.Get(c, c.Db.Conn, eventId, false, false); err != nil {
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Event not found", nil)
+		return
+	}
+	if groupId, _ := c.Values["group_id"].(uuid.UUID); event.GroupID != groupId {
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Event not found", nil)
 		return
 	}
 	if event.DateTime.Before(time.Now()) {
-		c.Status(http.StatusNotFound).JSON(map[string]string{
-			"error": "Event is passed",
-		})
+		c.Error(http.StatusNotFound, socialnetwork.CodeNotFound, "Event is passed", nil)
 		return
 	}
 	c.Values["event_id"] = eventId
 	c.Values["event"] = event
 	c.Next()
 }
-
-// CreateGroupMessageMiddleware is a middleware that checks if the data is valid
-func CreateGroupMessageMiddleware(c *socialnetwork.Context) {
-	var token string
-	headerBearer := c.Request.Header.Get("Authorization")
-	if strings.HasPrefix(headerBearer, "Bearer ") {
-		token = strings.TrimPrefix(headerBearer, "Bearer ")
-	}
-	var data = map[string]interface{}{}
-	if err := c.BodyParser(&data); err != nil {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Error parsing request body",
-		})
-		return
-	}
-	_, err := config.Sess.Start(c).Get(token)
-	if err != nil {
-		c.Status(http.StatusUnauthorized).JSON(map[string]string{
-			"error": "You are not logged in.",
-		})
-		return
-	}
-	if data["group_id"] == nil || strings.TrimSpace(data["group_id"].(string)) == "" ||
-		data["content"] == nil || strings.TrimSpace(data["content"].(string)) == "" {
-		c.Status(http.StatusBadRequest).JSON(map[string]string{
-			"error": "Invalid data",
-		})
-		return
-	}
-	c.Next()
-}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/pkg/config"
+)
+
+// CSRFSecret is the key CSRF tokens are derived with. It is set from the
+// CSRF_SECRET environment variable, or randomly at startup.
+var CSRFSecret []byte
+
+// CookieSession returns the session ID of requests authenticated by the session
+// cookie, so that they are checked for CSRF. Requests sending a bearer token are
+// authenticated by it instead and are exempt.
+func CookieSession(ctx *socialnetwork.Context) string {
+	if ctx.GetBearerToken() != "" {
+		return ""
+	}
+	cookie, err := ctx.Request.Cookie(config.Sess.Config.CookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
@@ -1 +1,52 @@
-package middleware	
\ No newline at end of file
+package middleware
+
+import (
+	socialnetwork "Social_Network/app"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Logging logs one line per request once the rest of the chain has returned:
+// method, path, route, status, latency and, for authenticated requests, the user ID.
+// The request ID is carried by the request's logger. Server errors are logged at
+// error level, client errors at warn level and everything else at info level.
+// It should be the first global middleware so it sees the response of every other one.
+func Logging(ctx *socialnetwork.Context) {
+	start := time.Now()
+	log := ctx.Logger()
+
+	ctx.Next()
+
+	status := ctx.StatusCode()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) && !ctx.Written():
+		// The app answers timed out requests once the chain has returned.
+		status = http.StatusServiceUnavailable
+	case status == 0:
+		status = http.StatusOK
+	}
+
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	} else if status >= http.StatusBadRequest {
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", ctx.Request.Method),
+		slog.String("path", ctx.Request.URL.Path),
+		slog.String("route", ctx.Route().Pattern()),
+		slog.Int("status", status),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if userId, ok := ctx.Values["userId"].(uuid.UUID); ok {
+		attrs = append(attrs, slog.String("user_id", userId.String()))
+	}
+	log.LogAttrs(context.Background(), level, "request", attrs...)
+}
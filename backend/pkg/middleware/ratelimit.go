@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	socialnetwork "Social_Network/app"
+	"Social_Network/app/middleware/ratelimit"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Proxies makes guests be rate limited by the client IP forwarded by the
+// reverse proxy in X-Forwarded-For rather than by the IP of the connection, if
+// trusted. It is set from the TRUST_PROXY and TRUSTED_PROXIES environment variables.
+var Proxies ratelimit.Proxies
+
+// ClientKey keys rate limits by the authenticated user, or by client IP for guests.
+func ClientKey(ctx *socialnetwork.Context) string {
+	if userId, ok := ctx.Values["userId"].(uuid.UUID); ok {
+		return "user:" + userId.String()
+	}
+	return "ip:" + ratelimit.ClientIP(ctx.Request, Proxies)
+}
+
+var (
+	// LoginRateLimit slows down password guessing.
+	LoginRateLimit = ratelimit.New(ratelimit.Config{Requests: 5, Per: time.Minute, Key: ClientKey})
+
+	// RegistrationRateLimit limits account creation from a single client.
+	RegistrationRateLimit = ratelimit.New(ratelimit.Config{Requests: 5, Per: 10 * time.Minute, Key: ClientKey})
+
+	// VerificationEmailRateLimit limits how often a user can have the verification email sent again.
+	VerificationEmailRateLimit = ratelimit.New(ratelimit.Config{Requests: 3, Per: 10 * time.Minute, Key: ClientKey})
+
+	// PasswordResetRateLimit limits password reset requests and attempts from a single client.
+	PasswordResetRateLimit = ratelimit.New(ratelimit.Config{Requests: 5, Per: 15 * time.Minute, Key: ClientKey})
+
+	// UploadRateLimit limits image uploads per user.
+	UploadRateLimit = ratelimit.New(ratelimit.Config{Requests: 20, Per: time.Minute, Key: ClientKey})
+
+	// ExportRateLimit limits how often a user can download their data, which is costly to gather.
+	ExportRateLimit = ratelimit.New(ratelimit.Config{Requests: 3, Per: time.Hour, Key: ClientKey})
+
+	// SearchRateLimit limits directory searches, which clients may send as the user types.
+	SearchRateLimit = ratelimit.New(ratelimit.Config{Requests: 60, Per: time.Minute, Burst: 20, Key: ClientKey})
+
+	// PostRateLimit limits post creation per user.
+	PostRateLimit = ratelimit.New(ratelimit.Config{Requests: 10, Per: time.Minute, Burst: 5, Key: ClientKey})
+)
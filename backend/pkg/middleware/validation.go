@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	socialnetwork "Social_Network/app"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PostRequest is the body expected when creating a post outside of a group,
+// see GroupPostRequest for the others.
+type PostRequest struct {
+	Title             string      `json:"title" validate:"max=255"`
+	Content           string      `json:"content" validate:"required"`
+	ImageURL          string      `json:"image_url" validate:"max=255"`
+	Privacy           string      `json:"privacy" validate:"required,oneof=public|private|almost private|unlisted"`
+	SelectedFollowers []uuid.UUID `json:"followersSelectedID"`
+}
+
+// GroupRequest is the body expected when creating a group.
+type GroupRequest struct {
+	Title       string `json:"title" validate:"required,max=255"`
+	Description string `json:"description" validate:"required"`
+	BannerURL   string `json:"banner_url" validate:"max=255"`
+}
+
+// GroupPostRequest is the body expected when posting in a group.
+type GroupPostRequest struct {
+	Title    string `json:"title" validate:"max=255"`
+	Content  string `json:"content" validate:"required"`
+	ImageURL string `json:"image_url" validate:"max=255"`
+	Privacy  string `json:"privacy" validate:"required,oneof=public|private|group|almost private"`
+}
+
+// EventRequest is the body expected when creating a group event.
+type EventRequest struct {
+	Title       string    `json:"title" validate:"required,max=255"`
+	Description string    `json:"description" validate:"required"`
+	DateTime    time.Time `json:"date_time" validate:"required,future"`
+}
+
+// GroupMessageRequest is the body expected when sending a message to a group.
+type GroupMessageRequest struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+// bindRequest binds the request body into a new T and stores it in c.Values["request"]
+// for the handler, or responds with the invalid fields.
+func bindRequest[T any](c *socialnetwork.Context) {
+	req := new(T)
+	if err := c.Bind(req); err != nil {
+		c.BindError(err)
+		return
+	}
+	c.Values["request"] = req
+	c.Next()
+}
+
+// IsPostValid checks the body of a new post, see PostRequest.
+func IsPostValid(c *socialnetwork.Context) {
+	bindRequest[PostRequest](c)
+}
+
+// IsGroupValid checks the body of a new group, see GroupRequest.
+func IsGroupValid(c *socialnetwork.Context) {
+	bindRequest[GroupRequest](c)
+}
+
+// IsGroupPostValid checks the body of a new group post, see GroupPostRequest.
+func IsGroupPostValid(c *socialnetwork.Context) {
+	bindRequest[GroupPostRequest](c)
+}
+
+// CreateEventMiddleware checks the body of a new event, see EventRequest.
+func CreateEventMiddleware(c *socialnetwork.Context) {
+	bindRequest[EventRequest](c)
+}
+
+// CreateGroupMessageMiddleware checks the body of a new group message, see GroupMessageRequest.
+func CreateGroupMessageMiddleware(c *socialnetwork.Context) {
+	bindRequest[GroupMessageRequest](c)
+}
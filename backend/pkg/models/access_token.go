@@ -0,0 +1,155 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// AccessTokenPrefix starts every personal access token, which tells them
+	// apart from session IDs and makes them easy to spot when leaked.
+	AccessTokenPrefix = "snpat_"
+
+	// AccessTokenMaxTTL is the longest a personal access token can be valid for.
+	AccessTokenMaxTTL = 365 * 24 * time.Hour
+
+	// accessTokenUseInterval is how often the last use of a token is recorded at
+	// most, so that clients making many requests do not write on each of them.
+	accessTokenUseInterval = time.Minute
+)
+
+// AccessTokenScopes are the scopes a personal access token can be given, each
+// granting the routes that declare it.
+var AccessTokenScopes = []string{
+	"read:users", "write:users",
+	"read:posts", "write:posts",
+	"read:messages", "write:messages",
+	"read:groups", "write:groups",
+	"read:notifications", "write:notifications",
+}
+
+// AccessToken is a personal access token, with which a user's scripts call the
+// API in their name, limited to its scopes. Only a hash of the token is stored.
+type AccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// HasScope tells whether the token grants scope.
+func (a *AccessToken) HasScope(scope string) bool {
+	return slices.Contains(a.Scopes, scope)
+}
+
+// Create records a new token for a.UserID with its name, scopes and expiry, and
+// returns the token, which cannot be retrieved afterwards.
+func (a *AccessToken) Create(ctx context.Context, db *sql.DB) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	token = AccessTokenPrefix + token
+	a.ID = uuid.New()
+	a.CreatedAt = time.Now()
+	a.LastUsedAt = nil
+	query := `INSERT INTO access_tokens (id, user_id, name, token_hash, scopes, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = db.ExecContext(ctx, query, a.ID, a.UserID, a.Name, hashToken(token), strings.Join(a.Scopes, " "), a.ExpiresAt, a.CreatedAt)
+	if err != nil {
+		return "", fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return token, nil
+}
+
+// Authenticate gets the token matching the one given by a client and records
+// that it was used. It returns ErrInvalidToken for unknown and expired tokens,
+// and for those of deleted or suspended users.
+func (a *AccessToken) Authenticate(ctx context.Context, db *sql.DB, token string) error {
+	now := time.Now()
+	query := `SELECT t.id, t.user_id, t.name, t.scopes, t.expires_at, t.last_used_at, t.created_at FROM access_tokens t
+	JOIN users u ON u.id = t.user_id WHERE t.token_hash=$1 AND u.suspended_at IS NULL AND u.deleted_at IS NULL`
+
+	var scopes string
+	err := db.QueryRowContext(ctx, query, hashToken(token)).Scan(&a.ID, &a.UserID, &a.Name, &scopes, &a.ExpiresAt, &a.LastUsedAt, &a.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if !now.Before(a.ExpiresAt) {
+		return ErrInvalidToken
+	}
+	a.Scopes = strings.Fields(scopes)
+
+	if a.LastUsedAt == nil || now.Sub(*a.LastUsedAt) >= accessTokenUseInterval {
+		if _, err := db.ExecContext(ctx, `UPDATE access_tokens SET last_used_at=$1 WHERE id=$2`, now, a.ID); err != nil {
+			return fmt.Errorf("unable to execute the query. %v", err)
+		}
+		a.LastUsedAt = &now
+	}
+	return nil
+}
+
+// Delete revokes the token with ID a.ID, if it belongs to a.UserID. It returns
+// sql.ErrNoRows otherwise.
+func (a *AccessToken) Delete(ctx context.Context, db *sql.DB) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM access_tokens WHERE id=$1 AND user_id=$2`, a.ID, a.UserID)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListAccessTokens returns the personal access tokens of a user, expired ones
+// included, the most recent first.
+func ListAccessTokens(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]AccessToken, error) {
+	query := `SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at FROM access_tokens WHERE user_id=$1 ORDER BY created_at DESC`
+
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []AccessToken{}
+	for rows.Next() {
+		var a AccessToken
+		var scopes string
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Name, &scopes, &a.ExpiresAt, &a.LastUsedAt, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("unable to scan the row. %v", err)
+		}
+		a.Scopes = strings.Fields(scopes)
+		tokens = append(tokens, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+	return tokens, nil
+}
+
+// DeleteAccessTokens revokes every personal access token of a user.
+func DeleteAccessTokens(ctx context.Context, db *sql.DB, userID uuid.UUID) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM access_tokens WHERE user_id=$1`, userID); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountDeletion is the request of a user to delete their account, which is
+// carried out once the grace period ends unless they cancel it.
+type AccountDeletion struct {
+	UserID      uuid.UUID `json:"-"`
+	RequestedAt time.Time `json:"requestedAt"`
+	PurgeAt     time.Time `json:"purgeAt"`
+}
+
+// Schedule records that d.UserID wants their account deleted at d.PurgeAt. If
+// they already asked, the earlier request is kept and d is set to it.
+func (d *AccountDeletion) Schedule(ctx context.Context, db *sql.DB) error {
+	query := `INSERT INTO account_deletions (user_id, requested_at, purge_at) VALUES ($1, $2, $3) ON CONFLICT (user_id) DO NOTHING`
+
+	if _, err := db.ExecContext(ctx, query, d.UserID, d.RequestedAt, d.PurgeAt); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return d.Get(ctx, db, d.UserID)
+}
+
+// Get the pending deletion of a user's account. It returns sql.ErrNoRows if
+// there is none.
+func (d *AccountDeletion) Get(ctx context.Context, db *sql.DB, userID uuid.UUID) error {
+	query := `SELECT user_id, requested_at, purge_at FROM account_deletions WHERE user_id=$1`
+
+	err := db.QueryRowContext(ctx, query, userID).Scan(&d.UserID, &d.RequestedAt, &d.PurgeAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return nil
+}
+
+// CancelAccountDeletion forgets the pending deletion of a user's account. It
+// tells whether there was one.
+func CancelAccountDeletion(ctx context.Context, db *sql.DB, userID uuid.UUID) (bool, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM account_deletions WHERE user_id=$1`, userID)
+	if err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return n > 0, nil
+}
+
+// DueAccountDeletions returns the users whose account is to be purged by now.
+func DueAccountDeletions(ctx context.Context, db *sql.DB, now time.Time) ([]uuid.UUID, error) {
+	rows, err := db.QueryContext(ctx, `SELECT user_id FROM account_deletions WHERE purge_at <= $1`, now)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer rows.Close()
+
+	users := []uuid.UUID{}
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("unable to scan the row. %v", err)
+		}
+		users = append(users, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+	return users, nil
+}
+
+// purgeStatements delete everything a user made or that is only about them,
+// given their ID as $1. Groups they created were dealt with before. Messages
+// other users sent them are kept, as they belong to their senders.
+var purgeStatements = []string{
+	// Their posts, with the comments of others on them
+	`DELETE FROM comments WHERE user_id=$1 OR post_id IN (SELECT id FROM posts WHERE user_id=$1)`,
+	`DELETE FROM selected_users WHERE user_id=$1 OR post_id IN (SELECT id FROM posts WHERE user_id=$1)`,
+	`DELETE FROM groupPosts WHERE post_id IN (SELECT id FROM posts WHERE user_id=$1)`,
+	`DELETE FROM posts WHERE user_id=$1`,
+	// Their events, and their answers to those of others
+	`DELETE FROM events_participants WHERE member_id=$1 OR event_id IN (SELECT id FROM events WHERE creator_id=$1)`,
+	`DELETE FROM events WHERE creator_id=$1`,
+	// Their messages and relationships
+	`DELETE FROM private_messages WHERE sender_id=$1`,
+	`DELETE FROM group_messages WHERE sender_id=$1`,
+	`DELETE FROM invitations WHERE inviting_user_id=$1 OR invited_user_id=$1`,
+	`DELETE FROM group_members WHERE member_id=$1`,
+	`DELETE FROM followers WHERE follower_id=$1 OR followee_id=$1`,
+	`DELETE FROM notifications WHERE user_id=$1 OR concern_id=$1 OR member_id=$1`,
+	`DELETE FROM blocks WHERE blocker_id=$1 OR blocked_id=$1`,
+	`DELETE FROM mutes WHERE muter_id=$1 OR muted_id=$1`,
+	// Their credentials; sessions are revoked by the caller, from whichever store holds them
+	`DELETE FROM password_resets WHERE user_id=$1`,
+	`DELETE FROM two_factor WHERE user_id=$1`,
+	`DELETE FROM recovery_codes WHERE user_id=$1`,
+	`DELETE FROM login_challenges WHERE user_id=$1`,
+	`DELETE FROM user_identities WHERE user_id=$1`,
+	`DELETE FROM access_tokens WHERE user_id=$1`,
+	`DELETE FROM account_deletions WHERE user_id=$1`,
+}
+
+// groupPurgeStatements delete a group and all it holds, given its ID as $1.
+var groupPurgeStatements = []string{
+	`DELETE FROM comments WHERE post_id IN (SELECT id FROM posts WHERE group_id=$1)`,
+	`DELETE FROM selected_users WHERE post_id IN (SELECT id FROM posts WHERE group_id=$1)`,
+	`DELETE FROM groupPosts WHERE group_id=$1`,
+	`DELETE FROM posts WHERE group_id=$1`,
+	`DELETE FROM events_participants WHERE event_id IN (SELECT id FROM events WHERE group_id=$1)`,
+	`DELETE FROM events WHERE group_id=$1`,
+	`DELETE FROM group_messages WHERE group_id=$1`,
+	`DELETE FROM invitations WHERE group_member_id IN (SELECT id FROM group_members WHERE group_id=$1)`,
+	`DELETE FROM group_members WHERE group_id=$1`,
+	`DELETE FROM notifications WHERE group_id=$1`,
+	`DELETE FROM groups WHERE id=$1`,
+}
+
+// PurgeAccount deletes the data of a user for good and anonymizes their
+// account. Groups they created go to their longest accepted member, admins
+// first, or are deleted if they have none. It returns the uploaded files no
+// longer used by anyone, for the caller to remove.
+func PurgeAccount(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin the transaction. %v", err)
+	}
+	defer tx.Rollback()
+
+	// Files referenced by what is about to be deleted, which may be unused afterwards
+	files, err := queryStrings(ctx, tx, `SELECT avatar_image FROM users WHERE id=$1
+	UNION SELECT image_url FROM posts WHERE user_id=$1 OR group_id IN (SELECT id FROM groups WHERE creator_id=$1)
+	UNION SELECT image_url FROM comments WHERE user_id=$1 OR post_id IN (SELECT id FROM posts WHERE user_id=$1 OR group_id IN (SELECT id FROM groups WHERE creator_id=$1))
+	UNION SELECT banner_url FROM groups WHERE creator_id=$1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var email string
+	if err := tx.QueryRowContext(ctx, `SELECT email FROM users WHERE id=$1`, userID).Scan(&email); err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+
+	groups, err := queryStrings(ctx, tx, `SELECT id FROM groups WHERE creator_id=$1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, groupID := range groups {
+		var heir uuid.UUID
+		err := tx.QueryRowContext(ctx, `SELECT member_id FROM group_members WHERE group_id=$1 AND member_id!=$2 AND status='accepted' AND deleted_at IS NULL
+		ORDER BY role='admin' DESC, created_at LIMIT 1`, groupID, userID).Scan(&heir)
+		switch {
+		case err == nil:
+			if _, err := tx.ExecContext(ctx, `UPDATE groups SET creator_id=$1 WHERE id=$2`, heir, groupID); err != nil {
+				return nil, fmt.Errorf("unable to execute the query. %v", err)
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE group_members SET role='admin' WHERE group_id=$1 AND member_id=$2`, groupID, heir); err != nil {
+				return nil, fmt.Errorf("unable to execute the query. %v", err)
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			for _, statement := range groupPurgeStatements {
+				if _, err := tx.ExecContext(ctx, statement, groupID); err != nil {
+					return nil, fmt.Errorf("unable to execute the query. %v", err)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unable to execute the query. %v", err)
+		}
+	}
+
+	for _, statement := range purgeStatements {
+		if _, err := tx.ExecContext(ctx, statement, userID); err != nil {
+			return nil, fmt.Errorf("unable to execute the query. %v", err)
+		}
+	}
+	// Only an anonymous row is left, which the messages kept still point to
+	_, err = tx.ExecContext(ctx, `UPDATE users SET email='deleted-' || id || '@invalid', password='', first_name='Deleted', last_name='user', date_of_birth=$1,
+	avatar_image='uploads/default-avatar.png', nickname='deleted-' || id, about_me='', is_public=FALSE, email_verified_at=NULL,
+	role='user', suspended_at=NULL, suspension_reason=NULL, updated_at=$2, deleted_at=COALESCE(deleted_at, $2) WHERE id=$3`, time.Time{}, time.Now(), userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM login_throttles WHERE subject=$1`, AccountLoginSubject(html.UnescapeString(email))); err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+
+	unused := []string{}
+	for _, file := range files {
+		if !IsUpload(file) {
+			continue
+		}
+		var used bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE avatar_image=$1) OR EXISTS (SELECT 1 FROM posts WHERE image_url=$1)
+		OR EXISTS (SELECT 1 FROM comments WHERE image_url=$1) OR EXISTS (SELECT 1 FROM groups WHERE banner_url=$1)`, file).Scan(&used)
+		if err != nil {
+			return nil, fmt.Errorf("unable to execute the query. %v", err)
+		}
+		if !used {
+			unused = append(unused, file)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit the transaction. %v", err)
+	}
+	return unused, nil
+}
+
+// IsUpload tells whether p is the path of a file uploaded by a user, like
+// "uploads/<uuid>.png", rather than a bundled image or any other path.
+func IsUpload(p string) bool {
+	dir, name := path.Split(p)
+	if dir != "uploads/" || path.Clean(p) != p {
+		return false
+	}
+	_, err := uuid.Parse(strings.TrimSuffix(name, path.Ext(name)))
+	return err == nil
+}
+
+// queryStrings returns the first column of the rows of a query, skipping NULLs.
+func queryStrings(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value sql.NullString
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("unable to scan the row. %v", err)
+		}
+		if value.Valid {
+			values = append(values, value.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+	return values, nil
+}
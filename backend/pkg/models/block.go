@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Relation is a user another one blocked or muted, as listed to the latter.
+type Relation struct {
+	UserID      uuid.UUID `json:"userId"`
+	Nickname    string    `json:"nickname"`
+	FirstName   string    `json:"firstName"`
+	LastName    string    `json:"lastName"`
+	AvatarImage string    `json:"avatarImage"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Block keeps two users apart: neither sees the other's posts, comments and
+// profile, and they can neither follow nor message each other.
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+// Create records the block and ends the follow relationships between both users.
+// Blocking a user twice is not an error.
+func (b *Block) Create(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin the transaction. %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `INSERT INTO blocks (blocker_id, blocked_id, created_at) VALUES ($1, $2, $3) ON CONFLICT (blocker_id, blocked_id) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, b.BlockerID, b.BlockedID, now); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	query = `UPDATE followers SET deleted_at = $1 WHERE deleted_at IS NULL AND ((follower_id = $2 AND followee_id = $3) OR (follower_id = $3 AND followee_id = $2))`
+	if _, err := tx.ExecContext(ctx, query, now, b.BlockerID, b.BlockedID); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	// Pending follow requests between them go away with the relationships
+	query = `UPDATE notifications SET deleted_at = $1 WHERE deleted_at IS NULL AND type LIKE 'follow%' AND ((user_id = $2 AND concern_id = $3) OR (user_id = $3 AND concern_id = $2))`
+	if _, err := tx.ExecContext(ctx, query, now, b.BlockerID, b.BlockedID); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit the transaction. %v", err)
+	}
+	return nil
+}
+
+// Delete lifts the block. It returns sql.ErrNoRows if there was none.
+func (b *Block) Delete(ctx context.Context, db *sql.DB) error {
+	return execOnRelation(ctx, db, `DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2`, b.BlockerID, b.BlockedID)
+}
+
+// IsBlocked tells whether either user blocked the other.
+func IsBlocked(ctx context.Context, db *sql.DB, userID, otherID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM blocks WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1))`
+
+	var blocked bool
+	if err := db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return blocked, nil
+}
+
+// ListBlocks returns the users a user blocked, the most recent first.
+func ListBlocks(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]Relation, error) {
+	return listRelations(ctx, db, `SELECT u.id, u.nickname, u.first_name, u.last_name, u.avatar_image, b.created_at FROM blocks b
+	JOIN users u ON u.id = b.blocked_id WHERE b.blocker_id = $1 ORDER BY b.created_at DESC`, userID)
+}
+
+// Mute hides the posts, comments and notifications of a user from another one,
+// who can still follow and message them.
+type Mute struct {
+	MuterID uuid.UUID
+	MutedID uuid.UUID
+}
+
+// Create records the mute. Muting a user twice is not an error.
+func (m *Mute) Create(ctx context.Context, db *sql.DB) error {
+	query := `INSERT INTO mutes (muter_id, muted_id, created_at) VALUES ($1, $2, $3) ON CONFLICT (muter_id, muted_id) DO NOTHING`
+
+	if _, err := db.ExecContext(ctx, query, m.MuterID, m.MutedID, time.Now()); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return nil
+}
+
+// Delete lifts the mute. It returns sql.ErrNoRows if there was none.
+func (m *Mute) Delete(ctx context.Context, db *sql.DB) error {
+	return execOnRelation(ctx, db, `DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2`, m.MuterID, m.MutedID)
+}
+
+// ListMutes returns the users a user muted, the most recent first.
+func ListMutes(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]Relation, error) {
+	return listRelations(ctx, db, `SELECT u.id, u.nickname, u.first_name, u.last_name, u.avatar_image, m.created_at FROM mutes m
+	JOIN users u ON u.id = m.muted_id WHERE m.muter_id = $1 ORDER BY m.created_at DESC`, userID)
+}
+
+// authorNotHidden is an SQL condition keeping the rows whose author, in column,
+// the viewer neither blocked, muted nor was blocked by. It takes the viewer's
+// ID as its three arguments.
+func authorNotHidden(column string) string {
+	return `NOT EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id = ? AND b.blocked_id = ` + column + `) OR (b.blocker_id = ` + column + ` AND b.blocked_id = ?))
+	AND NOT EXISTS (SELECT 1 FROM mutes m WHERE m.muter_id = ? AND m.muted_id = ` + column + `)`
+}
+
+// IsHidden tells whether the viewer blocked, muted or was blocked by the author,
+// whose content is then left out for them, see authorNotHidden.
+func IsHidden(ctx context.Context, db *sql.DB, viewerID, authorID uuid.UUID) (bool, error) {
+	query := `SELECT NOT (` + authorNotHidden("a.author") + `) FROM (SELECT ? AS author) a`
+
+	var hidden bool
+	if err := db.QueryRowContext(ctx, query, viewerID, viewerID, viewerID, authorID).Scan(&hidden); err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return hidden, nil
+}
+
+// execOnRelation runs a query removing a block or mute, and returns
+// sql.ErrNoRows if it matched no row.
+func execOnRelation(ctx context.Context, db *sql.DB, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// listRelations returns the users selected by query.
+func listRelations(ctx context.Context, db *sql.DB, query string, userID uuid.UUID) ([]Relation, error) {
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer rows.Close()
+
+	relations := []Relation{}
+	for rows.Next() {
+		var r Relation
+		if err := rows.Scan(&r.UserID, &r.Nickname, &r.FirstName, &r.LastName, &r.AvatarImage, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("unable to scan the row. %v", err)
+		}
+		relations = append(relations, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+	return relations, nil
+}
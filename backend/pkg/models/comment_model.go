@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -43,7 +44,7 @@ func (c *Comment) IsValid() bool {
 }
 
 // Create inserts a new comment into the database.
-func (c *Comment) Create(db *sql.DB) error {
+func (c *Comment) Create(ctx context.Context, db *sql.DB) error {
 	// Validate the comment before proceeding.
 	if !c.IsValid() {
 		return errors.New("comment is not valid")
@@ -56,14 +57,13 @@ func (c *Comment) Create(db *sql.DB) error {
 	query := `INSERT INTO comments (id, user_id, post_id, content, image_url, created_at, updated_at) 
 			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query: %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
-		c.ID,
+	_, err = stmt.ExecContext(ctx, c.ID,
 		c.UserID,
 		c.PostID,
 		html.EscapeString(c.Content),
@@ -80,11 +80,11 @@ func (c *Comment) Create(db *sql.DB) error {
 }
 
 // Get retrieves a comment from the database.
-func (c *Comment) Get(db *sql.DB, id uuid.UUID) error {
+func (c *Comment) Get(ctx context.Context, db *sql.DB, id uuid.UUID) error {
 	query := `SELECT id, user_id, post_id, content, image_url, created_at, updated_at, deleted_at 
 			  FROM comments WHERE id = $1 AND deleted_at IS NULL`
 
-	err := db.QueryRow(query, id).Scan(
+	err := db.QueryRowContext(ctx, query, id).Scan(
 		&c.ID,
 		&c.UserID,
 		&c.PostID,
@@ -106,17 +106,16 @@ func (c *Comment) Get(db *sql.DB, id uuid.UUID) error {
 }
 
 // Update modifies a comment in the database.
-func (c *Comment) Update(db *sql.DB) error {
+func (c *Comment) Update(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE comments SET content = $1, image_url = $2, updated_at = $3 WHERE id = $4`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query: %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
-		html.EscapeString(c.Content),
+	_, err = stmt.ExecContext(ctx, html.EscapeString(c.Content),
 		html.EscapeString(c.ImageURL),
 		time.Now(),
 		c.ID,
@@ -130,17 +129,16 @@ func (c *Comment) Update(db *sql.DB) error {
 }
 
 // Delete removes a comment from the database.
-func (c *Comment) Delete(db *sql.DB) error {
+func (c *Comment) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE comments SET deleted_at = $1 WHERE id = $2`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query: %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
-		time.Now(),
+	_, err = stmt.ExecContext(ctx, time.Now(),
 		c.ID,
 	)
 
@@ -151,17 +149,17 @@ func (c *Comment) Delete(db *sql.DB) error {
 }
 
 // GetCommentsForPost retrieves all comments for a specific post.
-func (c *Comments) GetCommentsForPost(db *sql.DB, postID uuid.UUID) error {
+func (c *Comments) GetCommentsForPost(ctx context.Context, db *sql.DB, postID uuid.UUID) error {
 	query := `SELECT id, user_id, post_id, content, image_url, created_at, updated_at, deleted_at 
 			  FROM comments WHERE post_id = $1 AND deleted_at IS NULL`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query: %v", err)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(postID)
+	rows, err := stmt.QueryContext(ctx, postID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query: %v", err)
 	}
@@ -188,10 +186,43 @@ func (c *Comments) GetCommentsForPost(db *sql.DB, postID uuid.UUID) error {
 	return nil
 }
 
+// GetCommentsSeenBy retrieves the comments of a post that viewerID may see,
+// leaving out those of users they blocked, muted or were blocked by.
+func (c *Comments) GetCommentsSeenBy(ctx context.Context, db *sql.DB, postID, viewerID uuid.UUID) error {
+	query := `SELECT id, user_id, post_id, content, image_url, created_at, updated_at, deleted_at 
+			  FROM comments WHERE post_id = ? AND deleted_at IS NULL AND ` + authorNotHidden("comments.user_id")
+
+	rows, err := db.QueryContext(ctx, query, postID, viewerID, viewerID, viewerID)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment Comment
+		err := rows.Scan(
+			&comment.ID,
+			&comment.UserID,
+			&comment.PostID,
+			&comment.Content,
+			&comment.ImageURL,
+			&comment.CreatedAt,
+			&comment.UpdatedAt,
+			&comment.DeletedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("unable to scan the row: %v", err)
+		}
+		*c = append(*c, comment)
+	}
+
+	return rows.Err()
+}
+
 // PrepareForRendering prepares the comment data for rendering in the frontend.
-func (c *Comment) PrepareForRendering(db *sql.DB, postPrivacy string, postGroupId uuid.UUID) map[string]interface{} {
+func (c *Comment) PrepareForRendering(ctx context.Context, db *sql.DB, postPrivacy string, postGroupId uuid.UUID) map[string]interface{} {
 	user := User{}
-	user.Get(db, c.UserID)
+	user.Get(ctx, db, c.UserID)
 	return map[string]interface{}{
 		"group_id":           postGroupId,
 		"postPrivacy":        postPrivacy,
@@ -207,12 +238,12 @@ func (c *Comment) PrepareForRendering(db *sql.DB, postPrivacy string, postGroupI
 }
 
 // PrepareCommentsForRendering prepares all comments for rendering in the frontend.
-func (comments *Comments) PrepareCommentsForRendering(db *sql.DB, postPrivacy string, postGroupId uuid.UUID) []map[string]interface{} {
+func (comments *Comments) PrepareCommentsForRendering(ctx context.Context, db *sql.DB, postPrivacy string, postGroupId uuid.UUID) []map[string]interface{} {
 	var result []map[string]interface{}
 	for _, comment := range *comments {
 		user := User{}
-		user.Get(db, comment.UserID)
-		result = append(result, comment.PrepareForRendering(db, postPrivacy, postGroupId))
+		user.Get(ctx, db, comment.UserID)
+		result = append(result, comment.PrepareForRendering(ctx, db, postPrivacy, postGroupId))
 	}
 	return result
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"html"
@@ -43,7 +44,7 @@ type EventParticipant struct {
 	User      User          `json:"user"`
 }
 
-func (e *Event) Create(db *sql.DB) error {
+func (e *Event) Create(ctx context.Context, db *sql.DB) error {
 	e.ID = uuid.New()
 	e.CreatedAt = time.Now()
 	e.UpdatedAt = time.Now()
@@ -51,12 +52,12 @@ func (e *Event) Create(db *sql.DB) error {
 	query := `INSERT INTO events (id, group_id, creator_id, title, description, date_time, created_at, updated_at) 
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
-	_, err = stm.Exec(e.ID, e.GroupID, e.CreatorID, html.EscapeString(e.Title), html.EscapeString(e.Description), e.DateTime, e.CreatedAt, e.UpdatedAt)
+	_, err = stm.ExecContext(ctx, e.ID, e.GroupID, e.CreatorID, html.EscapeString(e.Title), html.EscapeString(e.Description), e.DateTime, e.CreatedAt, e.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("error executing insert: %v", err)
 	}
@@ -64,23 +65,23 @@ func (e *Event) Create(db *sql.DB) error {
 	return nil
 }
 
-func (e *Event) Get(db *sql.DB, id uuid.UUID, getParticipants, getUser bool) error {
+func (e *Event) Get(ctx context.Context, db *sql.DB, id uuid.UUID, getParticipants, getUser bool) error {
 	query := `SELECT id, group_id, creator_id, title, description, date_time, created_at, updated_at FROM events WHERE id = $1`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
 
-	err = stm.QueryRow(id).Scan(&e.ID, &e.GroupID, &e.CreatorID, &e.Title, &e.Description, &e.DateTime, &e.CreatedAt, &e.UpdatedAt)
+	err = stm.QueryRowContext(ctx, id).Scan(&e.ID, &e.GroupID, &e.CreatorID, &e.Title, &e.Description, &e.DateTime, &e.CreatedAt, &e.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("error getting event: %v", err)
 	}
 
 	if getParticipants {
 		p := EventParticipants{}
-		if err := p.GetEventParticipants(db, e.ID, getUser); err != nil {
+		if err := p.GetEventParticipants(ctx, db, e.ID, getUser); err != nil {
 			return err
 		}
 	}
@@ -88,17 +89,17 @@ func (e *Event) Get(db *sql.DB, id uuid.UUID, getParticipants, getUser bool) err
 	return nil
 }
 
-func (e *Event) Update(db *sql.DB) error {
+func (e *Event) Update(ctx context.Context, db *sql.DB) error {
 	e.UpdatedAt = time.Now()
 
 	query := `UPDATE events SET title = $1, description = $2, date_time = $3, updated_at = $4 WHERE id = $5`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
-	_, err = stm.Exec(html.EscapeString(e.Title), html.EscapeString(e.Description), e.DateTime, e.UpdatedAt, e.ID)
+	_, err = stm.ExecContext(ctx, html.EscapeString(e.Title), html.EscapeString(e.Description), e.DateTime, e.UpdatedAt, e.ID)
 	if err != nil {
 		return fmt.Errorf("error executing update: %v", err)
 	}
@@ -106,20 +107,20 @@ func (e *Event) Update(db *sql.DB) error {
 	return nil
 }
 
-func (e *Event) Delete(db *sql.DB) error {
+func (e *Event) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE events SET deleted_at = $1 WHERE id = $2`
 
-	if err := e.Participants.DeleteEventParticipants(db, e.ID); err != nil {
+	if err := e.Participants.DeleteEventParticipants(ctx, db, e.ID); err != nil {
 		return err
 	}
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
 
-	_, err = stm.Exec(time.Now(), e.ID)
+	_, err = stm.ExecContext(ctx, time.Now(), e.ID)
 	if err != nil {
 		return fmt.Errorf("error executing delete: %v", err)
 	}
@@ -127,7 +128,7 @@ func (e *Event) Delete(db *sql.DB) error {
 	return nil
 }
 
-func (p *EventParticipant) CreateParticipant(db *sql.DB, eventID, memberID uuid.UUID) error {
+func (p *EventParticipant) CreateParticipant(ctx context.Context, db *sql.DB, eventID, memberID uuid.UUID) error {
 	p.ID = uuid.New()
 	p.EventID = eventID
 	p.MemberID = memberID
@@ -137,13 +138,13 @@ func (p *EventParticipant) CreateParticipant(db *sql.DB, eventID, memberID uuid.
 	query := `INSERT INTO events_participants (id, event_id, member_id, response, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6)`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
 
-	_, err = stm.Exec(p.ID, p.EventID, p.MemberID, p.Response, p.CreatedAt, p.UpdatedAt)
+	_, err = stm.ExecContext(ctx, p.ID, p.EventID, p.MemberID, p.Response, p.CreatedAt, p.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("error executing insert: %v", err)
 	}
@@ -151,23 +152,23 @@ func (p *EventParticipant) CreateParticipant(db *sql.DB, eventID, memberID uuid.
 	return nil
 }
 
-func (p *EventParticipant) GetParticipant(db *sql.DB, eventID, memberID, userID uuid.UUID, getUser bool) error {
+func (p *EventParticipant) GetParticipant(ctx context.Context, db *sql.DB, eventID, memberID, userID uuid.UUID, getUser bool) error {
 	query := `SELECT id, event_id, member_id, response, created_at, updated_at FROM events_participants WHERE event_id = $1 AND member_id = $2`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
 
-	err = stm.QueryRow(eventID, memberID).Scan(&p.ID, &p.EventID, &p.MemberID, &p.Response, &p.CreatedAt, &p.UpdatedAt)
+	err = stm.QueryRowContext(ctx, eventID, memberID).Scan(&p.ID, &p.EventID, &p.MemberID, &p.Response, &p.CreatedAt, &p.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("error getting event participant: %v", err)
 	}
 
 	if getUser {
 		u := User{}
-		if err := u.Get(db, userID); err != nil {
+		if err := u.Get(ctx, db, userID); err != nil {
 			return err
 		}
 		p.User = u
@@ -176,17 +177,17 @@ func (p *EventParticipant) GetParticipant(db *sql.DB, eventID, memberID, userID
 	return nil
 }
 
-func (p *EventParticipant) UpdateParticipant(db *sql.DB) error {
+func (p *EventParticipant) UpdateParticipant(ctx context.Context, db *sql.DB) error {
 	p.UpdatedAt = time.Now()
 
 	query := `UPDATE events_participants SET response = $1, updated_at = $2 WHERE id = $3`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
-	_, err = stm.Exec(p.Response, p.UpdatedAt, p.ID)
+	_, err = stm.ExecContext(ctx, p.Response, p.UpdatedAt, p.ID)
 	if err != nil {
 		return fmt.Errorf("error executing update: %v", err)
 	}
@@ -194,16 +195,16 @@ func (p *EventParticipant) UpdateParticipant(db *sql.DB) error {
 	return nil
 }
 
-func (p *EventParticipant) Delete(db *sql.DB) error {
+func (p *EventParticipant) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE events_participants SET deleted_at = $1 WHERE id = $2`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
 
-	_, err = stm.Exec(time.Now(), p.ID)
+	_, err = stm.ExecContext(ctx, time.Now(), p.ID)
 	if err != nil {
 		return fmt.Errorf("error executing delete: %v", err)
 	}
@@ -211,16 +212,16 @@ func (p *EventParticipant) Delete(db *sql.DB) error {
 	return nil
 }
 
-func (p *EventParticipants) GetEventParticipants(db *sql.DB, eventID uuid.UUID, getUser bool) error {
+func (p *EventParticipants) GetEventParticipants(ctx context.Context, db *sql.DB, eventID uuid.UUID, getUser bool) error {
 	query := `SELECT id, event_id, member_id, response, created_at, updated_at FROM events_participants WHERE event_id = $1`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
 
-	rows, err := stm.Query(eventID)
+	rows, err := stm.QueryContext(ctx, eventID)
 	if err != nil {
 		return fmt.Errorf("error getting event participants: %v", err)
 	}
@@ -235,7 +236,7 @@ func (p *EventParticipants) GetEventParticipants(db *sql.DB, eventID uuid.UUID,
 
 		if getUser {
 			m := GroupMember{}
-			if err := m.GetMemberById(db, participant.MemberID, true); err != nil {
+			if err := m.GetMemberById(ctx, db, participant.MemberID, true); err != nil {
 				return err
 			}
 
@@ -248,16 +249,16 @@ func (p *EventParticipants) GetEventParticipants(db *sql.DB, eventID uuid.UUID,
 	return nil
 }
 
-func (e *Events) GetGroupEvents(db *sql.DB, groupID uuid.UUID, getParticipants, getUser bool) error {
+func (e *Events) GetGroupEvents(ctx context.Context, db *sql.DB, groupID uuid.UUID, getParticipants, getUser bool) error {
 	query := `SELECT id, group_id, creator_id, title, description, date_time, created_at, updated_at FROM events WHERE group_id = $1`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
 
-	rows, err := stm.Query(groupID)
+	rows, err := stm.QueryContext(ctx, groupID)
 	if err != nil {
 		return fmt.Errorf("error getting group events: %v", err)
 	}
@@ -272,7 +273,7 @@ func (e *Events) GetGroupEvents(db *sql.DB, groupID uuid.UUID, getParticipants,
 
 		if getParticipants {
 			p := EventParticipants{}
-			if err := p.GetEventParticipants(db, event.ID, getUser); err != nil {
+			if err := p.GetEventParticipants(ctx, db, event.ID, getUser); err != nil {
 				return err
 			}
 			event.Participants = p
@@ -284,16 +285,16 @@ func (e *Events) GetGroupEvents(db *sql.DB, groupID uuid.UUID, getParticipants,
 	return nil
 }
 
-func (p *EventParticipants) DeleteEventParticipants(db *sql.DB, eventID uuid.UUID) error {
+func (p *EventParticipants) DeleteEventParticipants(ctx context.Context, db *sql.DB, eventID uuid.UUID) error {
 	query := `UPDATE events_participants SET deleted_at = $1 WHERE event_id = $2`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
 	}
 	defer stm.Close()
 
-	_, err = stm.Exec(time.Now(), eventID)
+	_, err = stm.ExecContext(ctx, time.Now(), eventID)
 	if err != nil {
 		return fmt.Errorf("error executing delete: %v", err)
 	}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+
+	"github.com/google/uuid"
+)
+
+// ExportSection is one kind of data of a user, as rows of column names to values.
+type ExportSection []map[string]interface{}
+
+// UserExport is everything a user can download about themselves.
+type UserExport struct {
+	Sections map[string]ExportSection // By name, such as "posts"
+	Files    []string                 // Uploaded files they use
+}
+
+// exportQueries select the sections of a data export, given the user ID as $1.
+var exportQueries = map[string]string{
+	"profile": `SELECT id, email, first_name, last_name, date_of_birth, avatar_image, nickname, about_me, is_public, email_verified_at, role, created_at, updated_at
+	FROM users WHERE id=$1`,
+	"posts":    `SELECT id, group_id, title, content, image_url, privacy, created_at, updated_at, deleted_at FROM posts WHERE user_id=$1 ORDER BY created_at`,
+	"comments": `SELECT id, post_id, content, image_url, created_at, updated_at, deleted_at FROM comments WHERE user_id=$1 ORDER BY created_at`,
+	"private_messages": `SELECT id, sender_id, receiver_id, content, created_at, deleted_at FROM private_messages
+	WHERE sender_id=$1 OR receiver_id=$1 ORDER BY created_at`,
+	"group_messages": `SELECT id, group_id, content, created_at, deleted_at FROM group_messages WHERE sender_id=$1 ORDER BY created_at`,
+	"followers": `SELECT f.follower_id AS user_id, u.nickname, f.status, f.created_at FROM followers f LEFT JOIN users u ON u.id = f.follower_id
+	WHERE f.followee_id=$1 AND f.deleted_at IS NULL ORDER BY f.created_at`,
+	"following": `SELECT f.followee_id AS user_id, u.nickname, f.status, f.created_at FROM followers f LEFT JOIN users u ON u.id = f.followee_id
+	WHERE f.follower_id=$1 AND f.deleted_at IS NULL ORDER BY f.created_at`,
+	"group_memberships": `SELECT m.group_id, g.title, g.creator_id = m.member_id AS creator, m.status, m.role, m.created_at FROM group_members m
+	LEFT JOIN groups g ON g.id = m.group_id WHERE m.member_id=$1 AND m.deleted_at IS NULL ORDER BY m.created_at`,
+	"event_responses": `SELECT p.event_id, e.group_id, e.title, e.date_time, p.response, p.created_at FROM events_participants p
+	LEFT JOIN events e ON e.id = p.event_id WHERE p.member_id=$1 AND p.deleted_at IS NULL ORDER BY p.created_at`,
+	"events": `SELECT id, group_id, title, description, date_time, created_at, updated_at FROM events WHERE creator_id=$1 ORDER BY created_at`,
+	"blocks": `SELECT b.blocked_id AS user_id, u.nickname, b.created_at FROM blocks b LEFT JOIN users u ON u.id = b.blocked_id
+	WHERE b.blocker_id=$1 ORDER BY b.created_at`,
+	"mutes": `SELECT m.muted_id AS user_id, u.nickname, m.created_at FROM mutes m LEFT JOIN users u ON u.id = m.muted_id
+	WHERE m.muter_id=$1 ORDER BY m.created_at`,
+}
+
+// ExportUserData gathers the data of a user for them to download.
+func ExportUserData(ctx context.Context, db *sql.DB, userID uuid.UUID) (UserExport, error) {
+	export := UserExport{Sections: map[string]ExportSection{}}
+	for name, query := range exportQueries {
+		section, err := exportSection(ctx, db, query, userID)
+		if err != nil {
+			return export, err
+		}
+		export.Sections[name] = section
+	}
+
+	files, err := db.QueryContext(ctx, `SELECT avatar_image FROM users WHERE id=$1 UNION SELECT image_url FROM posts WHERE user_id=$1
+	UNION SELECT image_url FROM comments WHERE user_id=$1 UNION SELECT banner_url FROM groups WHERE creator_id=$1`, userID)
+	if err != nil {
+		return export, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer files.Close()
+	for files.Next() {
+		var file sql.NullString
+		if err := files.Scan(&file); err != nil {
+			return export, fmt.Errorf("unable to scan the row. %v", err)
+		}
+		// Only files they uploaded, never paths they typed in
+		if file.Valid && IsUpload(file.String) {
+			export.Files = append(export.Files, file.String)
+		}
+	}
+	if err := files.Err(); err != nil {
+		return export, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+	return export, nil
+}
+
+// exportSection runs query and returns its rows, with the text stored escaped
+// turned back into what the user wrote.
+func exportSection(ctx context.Context, db *sql.DB, query string, args ...interface{}) (ExportSection, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the columns. %v", err)
+	}
+	section := ExportSection{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("unable to scan the row. %v", err)
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			switch value := values[i].(type) {
+			case []byte:
+				row[column] = html.UnescapeString(string(value))
+			case string:
+				row[column] = html.UnescapeString(value)
+			default:
+				row[column] = value
+			}
+		}
+		section = append(section, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+	return section, nil
+}
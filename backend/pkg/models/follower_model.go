@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -32,7 +33,7 @@ type Follower struct {
 }
 
 // Create a new follower in the database.
-func (follower *Follower) Create(db *sql.DB) error {
+func (follower *Follower) Create(ctx context.Context, db *sql.DB) error {
 	// Set default values for the follower entry.
 	follower.ID = uuid.New()        // Generate a new UUID for the follower.
 	follower.CreatedAt = time.Now() // Set the creation time.
@@ -42,15 +43,14 @@ func (follower *Follower) Create(db *sql.DB) error {
 	query := `INSERT INTO followers (id, follower_id, followee_id, status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
 
 	// Prepare the SQL statement.
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close() // Ensure the statement is closed after execution.
 
 	// Execute the SQL statement to insert the new follower.
-	_, err = stmt.Exec(
-		follower.ID,
+	_, err = stmt.ExecContext(ctx, follower.ID,
 		follower.FollowerID,
 		follower.FolloweeID,
 		follower.Status,
@@ -66,7 +66,7 @@ func (follower *Follower) Create(db *sql.DB) error {
 }
 
 // Get a follower by follower_id and followee_id.
-func (follower *Follower) Get(db *sql.DB, reverse ...bool) error {
+func (follower *Follower) Get(ctx context.Context, db *sql.DB, reverse ...bool) error {
 	// Optionally reverse the follower and followee IDs.
 	if len(reverse) > 0 && reverse[0] {
 		follower.FollowerID, follower.FolloweeID = follower.FolloweeID, follower.FollowerID
@@ -76,7 +76,7 @@ func (follower *Follower) Get(db *sql.DB, reverse ...bool) error {
 	query := `SELECT id, follower_id, followee_id, status, created_at, updated_at FROM followers WHERE follower_id = $1 AND followee_id = $2 AND deleted_at IS NULL`
 
 	// Execute the query and map the result to the follower struct.
-	err := db.QueryRow(query, follower.FollowerID, follower.FolloweeID).Scan(
+	err := db.QueryRowContext(ctx, query, follower.FollowerID, follower.FolloweeID).Scan(
 		&follower.ID,
 		&follower.FollowerID,
 		&follower.FolloweeID,
@@ -93,19 +93,19 @@ func (follower *Follower) Get(db *sql.DB, reverse ...bool) error {
 }
 
 // Update the status of an existing follower.
-func (follower *Follower) Update(db *sql.DB) error {
+func (follower *Follower) Update(ctx context.Context, db *sql.DB) error {
 	// SQL query to update the status of a follower.
 	query := `UPDATE followers SET status = $1, updated_at = $2 WHERE id = $3`
 
 	// Prepare the SQL statement.
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close() // Ensure the statement is closed after execution.
 
 	// Execute the SQL statement to update the follower's status.
-	_, err = stmt.Exec(follower.Status, time.Now(), follower.ID)
+	_, err = stmt.ExecContext(ctx, follower.Status, time.Now(), follower.ID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -114,19 +114,19 @@ func (follower *Follower) Update(db *sql.DB) error {
 }
 
 // Delete (soft-delete) a follower by setting deleted_at to the current time.
-func (follower *Follower) Delete(db *sql.DB) error {
+func (follower *Follower) Delete(ctx context.Context, db *sql.DB) error {
 	// SQL query to soft-delete the follower by updating deleted_at.
 	query := `UPDATE followers SET deleted_at = $1 WHERE id = $2`
 
 	// Prepare the SQL statement.
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close() // Ensure the statement is closed after execution.
 
 	// Execute the SQL statement to mark the follower as deleted.
-	_, err = stmt.Exec(time.Now(), follower.ID)
+	_, err = stmt.ExecContext(ctx, time.Now(), follower.ID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -135,12 +135,12 @@ func (follower *Follower) Delete(db *sql.DB) error {
 }
 
 // GetAllByFolloweeID Get all accepted followers for a given followee ID.
-func (followers *Followers) GetAllByFolloweeID(db *sql.DB, followeeID uuid.UUID) error {
+func (followers *Followers) GetAllByFolloweeID(ctx context.Context, db *sql.DB, followeeID uuid.UUID) error {
 	// SQL query to fetch all accepted followers for the given followee.
 	query := `SELECT id, follower_id, followee_id, status, created_at, updated_at FROM followers WHERE followee_id = $1 AND status= "accepted" AND  deleted_at IS NULL`
 
 	// Execute the query.
-	rows, err := db.Query(query, followeeID)
+	rows, err := db.QueryContext(ctx, query, followeeID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -167,13 +167,13 @@ func (followers *Followers) GetAllByFolloweeID(db *sql.DB, followeeID uuid.UUID)
 }
 
 // CountAllByFolloweeID Count the total number of accepted followers for a given followee ID.
-func (followers *Followers) CountAllByFolloweeID(db *sql.DB, followeeID uuid.UUID) int {
+func (followers *Followers) CountAllByFolloweeID(ctx context.Context, db *sql.DB, followeeID uuid.UUID) int {
 	// SQL query to count the accepted followers for the given followee.
 	query := `SELECT COUNT(id) FROM followers WHERE followee_id = $1 AND status = $2 AND deleted_at IS NULL`
 	var count int
 
 	// Execute the query and get the count.
-	err := db.QueryRow(query, followeeID, StatusAccepted).Scan(&count)
+	err := db.QueryRowContext(ctx, query, followeeID, StatusAccepted).Scan(&count)
 	if err != nil {
 		return 0
 	}
@@ -181,12 +181,12 @@ func (followers *Followers) CountAllByFolloweeID(db *sql.DB, followeeID uuid.UUI
 }
 
 // GetAllByFollowerID Get all accepted followers for a given follower ID.
-func (followers *Followers) GetAllByFollowerID(db *sql.DB, followerID uuid.UUID) error {
+func (followers *Followers) GetAllByFollowerID(ctx context.Context, db *sql.DB, followerID uuid.UUID) error {
 	// SQL query to fetch all accepted followers for the given follower.
 	query := `SELECT id, follower_id, followee_id, status, created_at, updated_at FROM followers WHERE follower_id = $1 AND status= "accepted" AND deleted_at IS NULL`
 
 	// Execute the query.
-	rows, err := db.Query(query, followerID)
+	rows, err := db.QueryContext(ctx, query, followerID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -213,13 +213,13 @@ func (followers *Followers) GetAllByFollowerID(db *sql.DB, followerID uuid.UUID)
 }
 
 // CountAllByFollowerID Count the total number of accepted followers for a given follower ID.
-func (followers *Followers) CountAllByFollowerID(db *sql.DB, followerID uuid.UUID) int {
+func (followers *Followers) CountAllByFollowerID(ctx context.Context, db *sql.DB, followerID uuid.UUID) int {
 	// SQL query to count the accepted followers for the given follower.
 	query := `SELECT COUNT(id) FROM followers WHERE follower_id = $1 AND status = $2 AND deleted_at IS NULL`
 	var count int
 
 	// Execute the query and get the count.
-	err := db.QueryRow(query, followerID, StatusAccepted).Scan(&count)
+	err := db.QueryRowContext(ctx, query, followerID, StatusAccepted).Scan(&count)
 	if err != nil {
 		return 0
 	}
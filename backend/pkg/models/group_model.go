@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"html"
@@ -63,7 +64,7 @@ type GroupMember struct {
 }
 
 // Create inserts a new group into the database
-func (g *Group) Create(db *sql.DB) error {
+func (g *Group) Create(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
 	// Define the group default properties
@@ -72,12 +73,12 @@ func (g *Group) Create(db *sql.DB) error {
 	g.UpdatedAt = time.Now()
 	query := `INSERT INTO groups (id, title, description, banner_url, creator_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 
-	_, err = stmt.Exec(g.ID, html.EscapeString(g.Title), html.EscapeString(g.Description), html.EscapeString(g.BannerURL), g.CreatorID, g.CreatedAt, g.UpdatedAt)
+	_, err = stmt.ExecContext(ctx, g.ID, html.EscapeString(g.Title), html.EscapeString(g.Description), html.EscapeString(g.BannerURL), g.CreatorID, g.CreatedAt, g.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -87,7 +88,7 @@ func (g *Group) Create(db *sql.DB) error {
 		Status: MemberStatusAccepted,
 		Role:   MemberRoleAdmin,
 	}
-	err = gm.CreateMember(db, g.CreatorID, g.ID)
+	err = gm.CreateMember(ctx, db, g.CreatorID, g.ID)
 	if err != nil {
 		return fmt.Errorf("unable to create group member. %v", err)
 	}
@@ -98,18 +99,18 @@ func (g *Group) Create(db *sql.DB) error {
 }
 
 // Get retrieves a group from the database
-func (g *Group) Get(db *sql.DB, id uuid.UUID, getmembers, getuser bool) error {
+func (g *Group) Get(ctx context.Context, db *sql.DB, id uuid.UUID, getmembers, getuser bool) error {
 	// Mux.RLock()
 	// defer Mux.RUnlock()
 	query := `SELECT id, title, description, banner_url, creator_id, created_at, updated_at, deleted_at FROM groups WHERE id=$1 AND deleted_at IS NULL`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stm.Close()
 
-	row := stm.QueryRow(id)
+	row := stm.QueryRowContext(ctx, id)
 	err = row.Scan(
 		&g.ID,
 		&g.Title,
@@ -125,7 +126,7 @@ func (g *Group) Get(db *sql.DB, id uuid.UUID, getmembers, getuser bool) error {
 	}
 
 	if getmembers {
-		err = g.GetMembers(db, GroupMemberStatus(StatusAccepted), getuser)
+		err = g.GetMembers(ctx, db, GroupMemberStatus(StatusAccepted), getuser)
 		if err != nil {
 			return fmt.Errorf("unable to get group members. %v", err)
 		}
@@ -135,18 +136,18 @@ func (g *Group) Get(db *sql.DB, id uuid.UUID, getmembers, getuser bool) error {
 }
 
 // Update updates the group in the database
-func (g *Group) Update(db *sql.DB) error {
+func (g *Group) Update(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
 	g.UpdatedAt = time.Now()
 	query := `UPDATE groups SET title=$1, description=$2, banner_url=$3, updated_at=$4 WHERE id=$5`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 
-	_, err = stmt.Exec(html.EscapeString(g.Title), html.EscapeString(g.Description), html.EscapeString(g.BannerURL), g.UpdatedAt, g.ID)
+	_, err = stmt.ExecContext(ctx, html.EscapeString(g.Title), html.EscapeString(g.Description), html.EscapeString(g.BannerURL), g.UpdatedAt, g.ID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -154,17 +155,34 @@ func (g *Group) Update(db *sql.DB) error {
 	return nil
 }
 
+// Restore brings back a deleted group. It returns sql.ErrNoRows if no group
+// with this ID is deleted.
+func (g *Group) Restore(ctx context.Context, db *sql.DB) error {
+	result, err := db.ExecContext(ctx, `UPDATE groups SET deleted_at=NULL WHERE id=$1 AND deleted_at IS NOT NULL`, g.ID)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // Delete removes the group from the database
-func (g *Group) Delete(db *sql.DB) error {
+func (g *Group) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE groups SET deleted_at=$1 WHERE id=$2`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(time.Now(), g.ID)
+	_, err = stmt.ExecContext(ctx, time.Now(), g.ID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -173,16 +191,16 @@ func (g *Group) Delete(db *sql.DB) error {
 }
 
 // GetAllGroups retrieves all groups from the database
-func (gs *Groups) GetAllGroups(db *sql.DB, getmembers, getuser bool) error {
+func (gs *Groups) GetAllGroups(ctx context.Context, db *sql.DB, getmembers, getuser bool) error {
 	query := `SELECT id, title, description, banner_url, creator_id, created_at, updated_at, deleted_at FROM groups WHERE deleted_at IS NULL`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stm.Close()
 
-	rows, err := stm.Query()
+	rows, err := stm.QueryContext(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -205,7 +223,7 @@ func (gs *Groups) GetAllGroups(db *sql.DB, getmembers, getuser bool) error {
 		}
 
 		if getmembers {
-			err = g.GetMembers(db, GroupMemberStatus(StatusAccepted), getuser)
+			err = g.GetMembers(ctx, db, GroupMemberStatus(StatusAccepted), getuser)
 			if err != nil {
 				return fmt.Errorf("unable to get group members. %v", err)
 			}
@@ -218,7 +236,7 @@ func (gs *Groups) GetAllGroups(db *sql.DB, getmembers, getuser bool) error {
 }
 
 // CreateMember inserts a new member into the group in the database
-func (gm *GroupMember) CreateMember(db *sql.DB, memberID, groupID uuid.UUID) error {
+func (gm *GroupMember) CreateMember(ctx context.Context, db *sql.DB, memberID, groupID uuid.UUID) error {
 	gm.ID = uuid.New()
 	gm.GroupID = groupID
 	gm.MemberID = memberID
@@ -226,12 +244,12 @@ func (gm *GroupMember) CreateMember(db *sql.DB, memberID, groupID uuid.UUID) err
 	gm.UpdatedAt = time.Now()
 	query := `INSERT INTO group_members (id, group_id, member_id, status, role, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 
-	_, err = stmt.Exec(gm.ID, gm.GroupID, gm.MemberID, gm.Status, gm.Role, gm.CreatedAt, gm.UpdatedAt)
+	_, err = stmt.ExecContext(ctx, gm.ID, gm.GroupID, gm.MemberID, gm.Status, gm.Role, gm.CreatedAt, gm.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -240,16 +258,16 @@ func (gm *GroupMember) CreateMember(db *sql.DB, memberID, groupID uuid.UUID) err
 }
 
 // GetMember retrieves a member from the group in the database
-func (gm *GroupMember) GetMember(db *sql.DB, memberID, groupID uuid.UUID, getuser bool) error {
+func (gm *GroupMember) GetMember(ctx context.Context, db *sql.DB, memberID, groupID uuid.UUID, getuser bool) error {
 	query := `SELECT id, group_id, member_id, status, role, created_at, updated_at, deleted_at FROM group_members WHERE group_id=$1 AND member_id=$2 AND deleted_at IS NULL`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stm.Close()
 
-	row := stm.QueryRow(groupID, memberID)
+	row := stm.QueryRowContext(ctx, groupID, memberID)
 	err = row.Scan(
 		&gm.ID,
 		&gm.GroupID,
@@ -266,7 +284,7 @@ func (gm *GroupMember) GetMember(db *sql.DB, memberID, groupID uuid.UUID, getuse
 
 	if getuser {
 		var user = new(User)
-		err = user.Get(db, memberID)
+		err = user.Get(ctx, db, memberID)
 		if err != nil {
 			return fmt.Errorf("unable to get user. %v", err)
 		}
@@ -276,16 +294,16 @@ func (gm *GroupMember) GetMember(db *sql.DB, memberID, groupID uuid.UUID, getuse
 	return nil
 }
 
-func (gm *GroupMember) GetMemberById(db *sql.DB, id uuid.UUID, getuser bool) error {
+func (gm *GroupMember) GetMemberById(ctx context.Context, db *sql.DB, id uuid.UUID, getuser bool) error {
 	query := `SELECT id, group_id, member_id, status, role, created_at, updated_at, deleted_at FROM group_members WHERE id=$1 AND deleted_at IS NULL`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stm.Close()
 
-	row := stm.QueryRow(id)
+	row := stm.QueryRowContext(ctx, id)
 	err = row.Scan(
 		&gm.ID,
 		&gm.GroupID,
@@ -302,7 +320,7 @@ func (gm *GroupMember) GetMemberById(db *sql.DB, id uuid.UUID, getuser bool) err
 
 	if getuser {
 		var user = new(User)
-		err = user.Get(db, gm.MemberID)
+		err = user.Get(ctx, db, gm.MemberID)
 		if err != nil {
 			return fmt.Errorf("unable to get user. %v", err)
 		}
@@ -313,17 +331,17 @@ func (gm *GroupMember) GetMemberById(db *sql.DB, id uuid.UUID, getuser bool) err
 }
 
 // UpdateMember updates the member in the group in the database
-func (gm *GroupMember) UpdateMember(db *sql.DB) error {
+func (gm *GroupMember) UpdateMember(ctx context.Context, db *sql.DB) error {
 	gm.UpdatedAt = time.Now()
 	query := `UPDATE group_members SET status=$1, updated_at=$2 WHERE id=$3`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(gm.Status, gm.UpdatedAt, gm.ID)
+	_, err = stmt.ExecContext(ctx, gm.Status, gm.UpdatedAt, gm.ID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -332,16 +350,16 @@ func (gm *GroupMember) UpdateMember(db *sql.DB) error {
 }
 
 // DeleteMember removes the member from the group in the database
-func (gm *GroupMember) DeleteMember(db *sql.DB) error {
+func (gm *GroupMember) DeleteMember(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE group_members SET deleted_at=$1 WHERE id=$2`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(time.Now(), gm.ID)
+	_, err = stmt.ExecContext(ctx, time.Now(), gm.ID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -350,16 +368,16 @@ func (gm *GroupMember) DeleteMember(db *sql.DB) error {
 }
 
 // GetMembers retrieves all members of the group from the database
-func (g *Group) GetMembers(db *sql.DB, status GroupMemberStatus, getusers bool) error {
+func (g *Group) GetMembers(ctx context.Context, db *sql.DB, status GroupMemberStatus, getusers bool) error {
 	query := `SELECT id, group_id, member_id, status, role, created_at, updated_at, deleted_at FROM group_members WHERE group_id=$1 AND status=$2 AND deleted_at IS NULL`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stm.Close()
 
-	rows, err := stm.Query(g.ID, status)
+	rows, err := stm.QueryContext(ctx, g.ID, status)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -384,7 +402,7 @@ func (g *Group) GetMembers(db *sql.DB, status GroupMemberStatus, getusers bool)
 		if getusers {
 			var user = new(User)
 
-			err = user.Get(db, gm.MemberID)
+			err = user.Get(ctx, db, gm.MemberID)
 			if err != nil {
 				return fmt.Errorf("unable to get user. %v", err)
 			}
@@ -397,16 +415,16 @@ func (g *Group) GetMembers(db *sql.DB, status GroupMemberStatus, getusers bool)
 	return nil
 }
 
-func (mg *GroupMembers) Get(db *sql.DB, id uuid.UUID, status GroupMemberStatus) error {
+func (mg *GroupMembers) Get(ctx context.Context, db *sql.DB, id uuid.UUID, status GroupMemberStatus) error {
 	query := `SELECT id, group_id, member_id, status, role, created_at, updated_at, deleted_at FROM group_members WHERE group_id=$1 AND status=$2 AND deleted_at IS NULL`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stm.Close()
 
-	rows, err := stm.Query(id, status)
+	rows, err := stm.QueryContext(ctx, id, status)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -435,16 +453,16 @@ func (mg *GroupMembers) Get(db *sql.DB, id uuid.UUID, status GroupMemberStatus)
 }
 
 // GetPosts retrieves all posts of the group from the database
-func (Posts *Posts) GetGroupPosts(db *sql.DB, groupID uuid.UUID) error {
+func (Posts *Posts) GetGroupPosts(ctx context.Context, db *sql.DB, groupID uuid.UUID) error {
 	query := `SELECT id, group_id, title, content, image_url, privacy, created_at, updated_at, deleted_at FROM posts WHERE group_id=$1 AND deleted_at IS NULL`
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stm.Close()
 
-	rows, err := stm.Query(groupID)
+	rows, err := stm.QueryContext(ctx, groupID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -474,7 +492,7 @@ func (Posts *Posts) GetGroupPosts(db *sql.DB, groupID uuid.UUID) error {
 	return nil
 }
 
-func (inv *GroupInvitation) SaveInvitation(db *sql.DB, gm GroupMember, invitingUserId uuid.UUID, invitedUserId uuid.UUID) error {
+func (inv *GroupInvitation) SaveInvitation(ctx context.Context, db *sql.DB, gm GroupMember, invitingUserId uuid.UUID, invitedUserId uuid.UUID) error {
 	inv.ID = uuid.New()
 	inv.InvitingUserId = invitingUserId
 	inv.InvitedUserId = invitedUserId
@@ -483,12 +501,12 @@ func (inv *GroupInvitation) SaveInvitation(db *sql.DB, gm GroupMember, invitingU
 	inv.UpdatedAt = time.Now()
 	query := `INSERT INTO invitations (id, inviting_user_id, invited_user_id, group_member_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 
-	_, err = stmt.Exec(inv.ID, inv.InvitingUserId, inv.InvitedUserId, inv.GroupMemberId, inv.CreatedAt, inv.UpdatedAt)
+	_, err = stmt.ExecContext(ctx, inv.ID, inv.InvitingUserId, inv.InvitedUserId, inv.GroupMemberId, inv.CreatedAt, inv.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -501,16 +519,16 @@ type Invitations []struct {
 	MemberId uuid.UUID
 }
 
-func (inv *Invitations) GetInvitations(db *sql.DB, userId uuid.UUID) error {
+func (inv *Invitations) GetInvitations(ctx context.Context, db *sql.DB, userId uuid.UUID) error {
 	query := `SELECT groups.*, group_members.id FROM groups JOIN group_members ON groups.id=group_members.group_id JOIN invitations ON group_members.id = invitations.group_member_id where group_members.status="invited" AND invitations.invited_user_id=$1;`
 	var memberId uuid.UUID
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stm.Close()
 
-	rows, err := stm.Query(userId)
+	rows, err := stm.QueryContext(ctx, userId)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
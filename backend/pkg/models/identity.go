@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OIDCStateTTL is how long a user has to sign in with the provider.
+const OIDCStateTTL = 10 * time.Minute
+
+// UserIdentity links a user to their account with an external OpenID Connect
+// provider, identified by the issuer of the provider and the subject it gives.
+type UserIdentity struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Issuer    string
+	Subject   string
+	Email     string // Email address given by the provider when linked
+	CreatedAt time.Time
+}
+
+// Get the identity with the given issuer and subject. It returns sql.ErrNoRows
+// if no user is linked to it.
+func (i *UserIdentity) Get(ctx context.Context, db *sql.DB, issuer, subject string) error {
+	query := `SELECT id, user_id, issuer, subject, email, created_at FROM user_identities WHERE issuer=$1 AND subject=$2`
+
+	err := db.QueryRowContext(ctx, query, issuer, subject).Scan(&i.ID, &i.UserID, &i.Issuer, &i.Subject, &i.Email, &i.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return nil
+}
+
+// Create links the identity to i.UserID.
+func (i *UserIdentity) Create(ctx context.Context, db *sql.DB) error {
+	i.ID = uuid.New()
+	i.CreatedAt = time.Now()
+	query := `INSERT INTO user_identities (id, user_id, issuer, subject, email, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	if _, err := db.ExecContext(ctx, query, i.ID, i.UserID, i.Issuer, i.Subject, i.Email, i.CreatedAt); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return nil
+}
+
+// OIDCState is a sign-in started with a provider, waiting for the user to come
+// back with a code. It holds what the code is checked and exchanged with.
+type OIDCState struct {
+	ID           uuid.UUID
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+// Create records the sign-in under state, of which only a hash is stored.
+func (o *OIDCState) Create(ctx context.Context, db *sql.DB, state string) error {
+	o.ID = uuid.New()
+	o.CreatedAt = time.Now()
+	o.ExpiresAt = o.CreatedAt.Add(OIDCStateTTL)
+
+	// Sign-ins never completed have no use once expired, drop them along the way
+	if _, err := db.ExecContext(ctx, `DELETE FROM oidc_states WHERE expires_at < $1`, o.CreatedAt); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	query := `INSERT INTO oidc_states (id, state_hash, nonce, code_verifier, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := db.ExecContext(ctx, query, o.ID, hashToken(state), o.Nonce, o.CodeVerifier, o.ExpiresAt, o.CreatedAt); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return nil
+}
+
+// Consume gets and removes the sign-in recorded under state, so that it completes
+// once. It returns ErrInvalidToken for unknown and expired states.
+func (o *OIDCState) Consume(ctx context.Context, db *sql.DB, state string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin the transaction. %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, nonce, code_verifier, expires_at, created_at FROM oidc_states WHERE state_hash=$1`
+	err = tx.QueryRowContext(ctx, query, hashToken(state)).Scan(&o.ID, &o.Nonce, &o.CodeVerifier, &o.ExpiresAt, &o.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM oidc_states WHERE id=$1`, o.ID); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit the transaction. %v", err)
+	}
+	if !time.Now().Before(o.ExpiresAt) {
+		return ErrInvalidToken
+	}
+	return nil
+}
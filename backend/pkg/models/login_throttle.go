@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoginLimit is how failed logins of a subject, an account or a client IP, are
+// throttled: past a few failures each attempt has to wait twice as long as the
+// previous one, and too many failures lock the subject out for a while.
+type LoginLimit struct {
+	FreeFailures int           // Failures allowed before attempts are delayed
+	MaxFailures  int           // Failures locking the subject out
+	Lockout      time.Duration // How long a lockout lasts
+	Window       time.Duration // How long failures are remembered after the last one
+}
+
+var (
+	// AccountLoginLimit throttles password guessing on a single account.
+	AccountLoginLimit = LoginLimit{FreeFailures: 3, MaxFailures: 10, Lockout: 15 * time.Minute, Window: time.Hour}
+
+	// IPLoginLimit throttles a client guessing passwords across many accounts.
+	IPLoginLimit = LoginLimit{FreeFailures: 20, MaxFailures: 100, Lockout: 15 * time.Minute, Window: time.Hour}
+)
+
+// LoginThrottle holds the recent failed logins of a subject, such as
+// "email:user@example.com" or "ip:203.0.113.7".
+type LoginThrottle struct {
+	Subject      string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  sql.NullTime
+}
+
+// Get the failed logins of subject. A subject without any is left at zero.
+func (t *LoginThrottle) Get(ctx context.Context, db *sql.DB, subject string) error {
+	*t = LoginThrottle{Subject: subject}
+	query := `SELECT failures, last_failed_at, locked_until FROM login_throttles WHERE subject=$1`
+
+	err := db.QueryRowContext(ctx, query, subject).Scan(&t.Failures, &t.LastFailedAt, &t.LockedUntil)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return nil
+}
+
+// RetryAfter returns how long the subject has to wait under limit before its
+// next login attempt, zero if it may try now.
+func (t *LoginThrottle) RetryAfter(limit LoginLimit, now time.Time) time.Duration {
+	if t.LockedUntil.Valid && now.Before(t.LockedUntil.Time) {
+		return t.LockedUntil.Time.Sub(now)
+	}
+	if t.Failures < limit.FreeFailures || now.Sub(t.LastFailedAt) >= limit.Window {
+		return 0
+	}
+	delay := min(time.Second<<min(t.Failures-limit.FreeFailures, 16), limit.Lockout)
+	return max(t.LastFailedAt.Add(delay).Sub(now), 0)
+}
+
+// Fail records a failed login of t.Subject at now, and locks the subject out
+// once it reaches limit.MaxFailures. It tells whether this failure locked it out.
+func (t *LoginThrottle) Fail(ctx context.Context, db *sql.DB, limit LoginLimit, now time.Time) (bool, error) {
+	query := `INSERT INTO login_throttles (subject, failures, last_failed_at) VALUES ($1, 1, $2)
+	ON CONFLICT (subject) DO UPDATE SET failures=CASE WHEN last_failed_at < $3 THEN 1 ELSE failures+1 END, last_failed_at=excluded.last_failed_at
+	RETURNING failures, locked_until`
+
+	err := db.QueryRowContext(ctx, query, t.Subject, now, now.Add(-limit.Window)).Scan(&t.Failures, &t.LockedUntil)
+	if err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	t.LastFailedAt = now
+	if t.Failures < limit.MaxFailures {
+		return false, nil
+	}
+
+	// Failures start over with the lockout, so that concurrent failures lock
+	// the subject out, and report it, once
+	lockedUntil := now.Add(limit.Lockout)
+	result, err := db.ExecContext(ctx, `UPDATE login_throttles SET failures=0, locked_until=$1 WHERE subject=$2 AND failures >= $3`, lockedUntil, t.Subject, limit.MaxFailures)
+	if err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	t.Failures = 0
+	t.LockedUntil = sql.NullTime{Time: lockedUntil, Valid: true}
+	return n > 0, nil
+}
+
+// ClearLoginThrottle forgets the failed logins of subject, lifting its lockout.
+// It tells whether there were any.
+func ClearLoginThrottle(ctx context.Context, db *sql.DB, subject string) (bool, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM login_throttles WHERE subject=$1`, subject)
+	if err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return n > 0, nil
+}
+
+// AccountLoginSubject returns the subject the failed logins with an email
+// address are recorded under, whether an account uses it or not.
+func AccountLoginSubject(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+// IPLoginSubject returns the subject the failed logins from a client IP are recorded under.
+func IPLoginSubject(ip string) string {
+	return "ip:" + ip
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"Social_Network/app/logger"
+	"context"
 	"database/sql"
 	"html"
 	"time"
@@ -30,7 +32,7 @@ type GroupMessage struct {
 	DeletedAt sql.NullTime
 }
 
-func (m *PrivateMessage) Create(db *sql.DB) error {
+func (m *PrivateMessage) Create(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
 	m.ID = uuid.New()
@@ -38,12 +40,12 @@ func (m *PrivateMessage) Create(db *sql.DB) error {
 	m.UpdatedAt = time.Now()
 	query := `INSERT INTO private_messages (id, sender_id, receiver_id, content, created_at) 
 		VALUES ($1, $2, $3, $4, $5)`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	_, err = stm.Exec(m.ID, m.SenderID, m.ReceiverID, html.EscapeString(m.Content), m.CreatedAt)
+	_, err = stm.ExecContext(ctx, m.ID, m.SenderID, m.ReceiverID, html.EscapeString(m.Content), m.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -56,14 +58,21 @@ func (m *PrivateMessage) Create(db *sql.DB) error {
 		"CreatedAt":  m.CreatedAt,
 	}
 
-	Data <- map[string]interface{}{
+	// The message is saved either way; only the live push is given up if the
+	// broadcaster does not take it before the request ends.
+	select {
+	case Data <- map[string]interface{}{
 		"key":  "private_message",
 		"data": data,
+		"to":   []uuid.UUID{m.SenderID, m.ReceiverID},
+	}:
+	case <-ctx.Done():
+		logger.FromContext(ctx).Warn("private message not pushed to websocket clients", "message_id", m.ID, "error", ctx.Err())
 	}
 
 	return nil
 }
-func (m *GroupMessage) Create(db *sql.DB) error {
+func (m *GroupMessage) Create(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
 	m.ID = uuid.New()
@@ -71,12 +80,12 @@ func (m *GroupMessage) Create(db *sql.DB) error {
 	m.UpdatedAt = time.Now()
 	query := `INSERT INTO group_messages (id, group_id, sender_id, content, created_at) 
 		VALUES ($1, $2, $3, $4, $5)`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	_, err = stm.Exec(m.ID, m.GroupID, m.SenderID, html.EscapeString(m.Content), m.CreatedAt)
+	_, err = stm.ExecContext(ctx, m.ID, m.GroupID, m.SenderID, html.EscapeString(m.Content), m.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -90,112 +99,112 @@ func (m *GroupMessage) Create(db *sql.DB) error {
 	// })
 	return nil
 }
-func (m *PrivateMessage) Get(db *sql.DB, id uuid.UUID) error {
+func (m *PrivateMessage) Get(ctx context.Context, db *sql.DB, id uuid.UUID) error {
 	// Mux.RLock()
 	// defer Mux.RUnlock()
 	query := `SELECT id, sender_id, receiver_id, content, created_at, updated_at, deleted_at FROM private_messages WHERE id = $1 AND deleted_at IS NULL`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	err = stm.QueryRow(id).Scan(&m.ID, &m.SenderID, &m.ReceiverID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt)
+	err = stm.QueryRowContext(ctx, id).Scan(&m.ID, &m.SenderID, &m.ReceiverID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (m *PrivateMessage) GetLastMessage(db *sql.DB, senderID, receiverID uuid.UUID) error {
+func (m *PrivateMessage) GetLastMessage(ctx context.Context, db *sql.DB, senderID, receiverID uuid.UUID) error {
 	// Mux.RLock()
 	// defer Mux.RUnlock()
 	query := `SELECT id, sender_id, receiver_id, content, created_at, updated_at, deleted_at FROM private_messages WHERE (sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1) AND deleted_at IS NULL ORDER BY created_at DESC LIMIT 1`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	err = stm.QueryRow(senderID, receiverID).Scan(&m.ID, &m.SenderID, &m.ReceiverID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt)
+	err = stm.QueryRowContext(ctx, senderID, receiverID).Scan(&m.ID, &m.SenderID, &m.ReceiverID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (m *GroupMessage) Get(db *sql.DB, id uuid.UUID) error {
+func (m *GroupMessage) Get(ctx context.Context, db *sql.DB, id uuid.UUID) error {
 	// Mux.RLock()
 	// defer Mux.RUnlock()
 	query := `SELECT id, group_id, sender_id, content, created_at, updated_at, deleted_at FROM group_messages WHERE id = $1 AND deleted_at IS NULL`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	err = stm.QueryRow(id).Scan(&m.ID, &m.GroupID, &m.SenderID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt)
+	err = stm.QueryRowContext(ctx, id).Scan(&m.ID, &m.GroupID, &m.SenderID, &m.Content, &m.CreatedAt, &m.UpdatedAt, &m.DeletedAt)
 	if err != nil {
 		return err
 	}
 	return nil
 }
-func (m *PrivateMessage) Update(db *sql.DB) error {
+func (m *PrivateMessage) Update(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
 	m.UpdatedAt = time.Now()
 	query := `UPDATE private_messages SET content = $1, updated_at = $2 WHERE id = $3`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	_, err = stm.Exec(html.EscapeString(m.Content), m.UpdatedAt, m.ID)
+	_, err = stm.ExecContext(ctx, html.EscapeString(m.Content), m.UpdatedAt, m.ID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
-func (m *GroupMessage) Update(db *sql.DB) error {
+func (m *GroupMessage) Update(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
 	m.UpdatedAt = time.Now()
 	query := `UPDATE group_messages SET content = $1, updated_at = $2 WHERE id = $3`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	_, err = stm.Exec(html.EscapeString(m.Content), m.UpdatedAt, m.ID)
+	_, err = stm.ExecContext(ctx, html.EscapeString(m.Content), m.UpdatedAt, m.ID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
-func (m *PrivateMessage) Delete(db *sql.DB) error {
+func (m *PrivateMessage) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE private_messages SET deleted_at = $1 WHERE id = $2`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	_, err = stm.Exec(time.Now(), m.ID)
+	_, err = stm.ExecContext(ctx, time.Now(), m.ID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
-func (m *GroupMessage) Delete(db *sql.DB) error {
+func (m *GroupMessage) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE group_messages SET deleted_at = $1 WHERE id = $2`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	_, err = stm.Exec(time.Now(), m.ID)
+	_, err = stm.ExecContext(ctx, time.Now(), m.ID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
-func (ms *PrivateMessages) GetPrivateMessages(db *sql.DB, receiverID, senderID uuid.UUID) error {
+func (ms *PrivateMessages) GetPrivateMessages(ctx context.Context, db *sql.DB, receiverID, senderID uuid.UUID) error {
 	query := `
         SELECT id, sender_id, receiver_id, content, created_at, updated_at, deleted_at 
         FROM private_messages 
@@ -204,7 +213,7 @@ func (ms *PrivateMessages) GetPrivateMessages(db *sql.DB, receiverID, senderID u
             (receiver_id = $2 AND sender_id = $1) AND 
             deleted_at IS NULL
     `
-	rows, err := db.Query(query, receiverID, senderID)
+	rows, err := db.QueryContext(ctx, query, receiverID, senderID)
 	if err != nil {
 		return err
 	}
@@ -218,9 +227,9 @@ func (ms *PrivateMessages) GetPrivateMessages(db *sql.DB, receiverID, senderID u
 	}
 	return nil
 }
-func (ms *GroupMessages) GetGroupMessages(db *sql.DB, groupID uuid.UUID) error {
+func (ms *GroupMessages) GetGroupMessages(ctx context.Context, db *sql.DB, groupID uuid.UUID) error {
 	query := `SELECT id, group_id, sender_id, content, created_at, updated_at, deleted_at FROM group_messages WHERE group_id = $1 AND deleted_at IS NULL`
-	rows, err := db.Query(query, groupID)
+	rows, err := db.QueryContext(ctx, query, groupID)
 	if err != nil {
 		return err
 	}
@@ -235,7 +244,7 @@ func (ms *GroupMessages) GetGroupMessages(db *sql.DB, groupID uuid.UUID) error {
 	// get user of each message
 	for i, m := range *ms {
 		user := User{}
-		err := user.Get(db, m.SenderID)
+		err := user.Get(ctx, db, m.SenderID)
 		if err != nil {
 			return err
 		}
@@ -243,9 +252,9 @@ func (ms *GroupMessages) GetGroupMessages(db *sql.DB, groupID uuid.UUID) error {
 	}
 	return nil
 }
-func (ms *PrivateMessages) GetPrivateMessagesBetween(db *sql.DB, senderID, receiverID uuid.UUID) error {
+func (ms *PrivateMessages) GetPrivateMessagesBetween(ctx context.Context, db *sql.DB, senderID, receiverID uuid.UUID) error {
 	query := `SELECT id, sender_id, receiver_id, content, created_at, updated_at, deleted_at FROM private_messages WHERE (sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1) AND deleted_at IS NULL`
-	rows, err := db.Query(query, senderID, receiverID)
+	rows, err := db.QueryContext(ctx, query, senderID, receiverID)
 	if err != nil {
 		return err
 	}
@@ -259,14 +268,14 @@ func (ms *PrivateMessages) GetPrivateMessagesBetween(db *sql.DB, senderID, recei
 	}
 	return nil
 }
-func (ms *GroupMessages) ClearGroupMessages(db *sql.DB, groupID uuid.UUID) error {
+func (ms *GroupMessages) ClearGroupMessages(ctx context.Context, db *sql.DB, groupID uuid.UUID) error {
 	query := `UPDATE group_messages SET deleted_at = $1 WHERE group_id = $2`
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stm.Close()
-	_, err = stm.Exec(time.Now(), groupID)
+	_, err = stm.ExecContext(ctx, time.Now(), groupID)
 	if err != nil {
 		return err
 	}
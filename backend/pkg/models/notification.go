@@ -1,6 +1,8 @@
 package models
 
 import (
+	"Social_Network/app/logger"
+	"context"
 	"database/sql"
 	"html"
 	"time"
@@ -19,6 +21,7 @@ const (
 	TypeGroupInvitation NotificationType = "group_invitation"
 	TypeNewMessage      NotificationType = "new_message"
 	TypeNewEvent        NotificationType = "new_event"
+	TypeAccountLocked   NotificationType = "account_locked"
 	// Add more types as needed
 )
 
@@ -35,23 +38,34 @@ type Notification struct {
 	DeletedAt sql.NullTime
 }
 
-// Create a new notification
-func (n *Notification) Create(db *sql.DB) error {
+// Create a new notification, and push it to the websocket clients of the user
+// it concerns. Nothing is created if that user blocked, muted or was blocked by
+// its author, as listing notifications would leave it out anyway.
+func (n *Notification) Create(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
+	hidden, err := IsHidden(ctx, db, n.ConcernID, n.UserID)
+	if err != nil {
+		return err
+	}
+	if hidden {
+		return nil
+	}
 	n.ID = uuid.New()
 	n.CreatedAt = time.Now()
 
 	query := `INSERT INTO notifications (id, user_id, concern_id, group_id, member_id,is_invite,type, message, created_at) 
 		VALUES ($1, $2, $3, $4, $5, $6,$7,$8, $9)`
 
-	_, err := db.Exec(query, n.ID, n.UserID, n.ConcernID, n.GroupId, n.MemberId, n.Is_invite, n.Type, html.EscapeString(n.Message), n.CreatedAt)
+	_, err = db.ExecContext(ctx, query, n.ID, n.UserID, n.ConcernID, n.GroupId, n.MemberId, n.Is_invite, n.Type, html.EscapeString(n.Message), n.CreatedAt)
 	if err != nil {
 		return err
 	}
 
 	user := new(User)
-	user.Get(db, n.UserID)
+	if err := user.Get(ctx, db, n.UserID); err != nil {
+		logger.FromContext(ctx).Warn("loading notification author failed", "notification_id", n.ID, "error", err)
+	}
 	user.Password = ""
 	data := map[string]interface{}{
 		"id":         n.ID,
@@ -65,22 +79,29 @@ func (n *Notification) Create(db *sql.DB) error {
 		"is_invite":  n.Is_invite,
 	}
 
-	Data <- map[string]interface{}{
+	// The notification is saved either way; only the live push is given up
+	// if the broadcaster does not take it before the request ends.
+	select {
+	case Data <- map[string]interface{}{
 		"key":  "notification",
 		"data": data,
+		"to":   []uuid.UUID{n.ConcernID},
+	}:
+	case <-ctx.Done():
+		logger.FromContext(ctx).Warn("notification not pushed to websocket clients", "notification_id", n.ID, "error", ctx.Err())
 	}
 
 	return nil
 }
 
 // Get a notification by its ID
-func (n *Notification) Get(db *sql.DB, id ...uuid.UUID) error {
+func (n *Notification) Get(ctx context.Context, db *sql.DB, id ...uuid.UUID) error {
 	// Mux.RLock()
 	// defer Mux.RUnlock()
 	if len(id) > 0 {
 		query := `SELECT id, user_id, concern_id, group_id, member_id, is_invite,type, message, created_at, deleted_at FROM notifications WHERE id = $1 AND deleted_at IS NULL`
 
-		stm, err := db.Prepare(query)
+		stm, err := db.PrepareContext(ctx, query)
 
 		if err != nil {
 			return err
@@ -88,14 +109,14 @@ func (n *Notification) Get(db *sql.DB, id ...uuid.UUID) error {
 
 		defer stm.Close()
 
-		err = stm.QueryRow(id[0]).Scan(&n.ID, &n.UserID, &n.ConcernID, &n.GroupId, &n.MemberId, &n.Is_invite, &n.Type, &n.Message, &n.CreatedAt, &n.DeletedAt)
+		err = stm.QueryRowContext(ctx, id[0]).Scan(&n.ID, &n.UserID, &n.ConcernID, &n.GroupId, &n.MemberId, &n.Is_invite, &n.Type, &n.Message, &n.CreatedAt, &n.DeletedAt)
 		if err != nil {
 			return err
 		}
 	} else {
 		query := `SELECT id, group_id, member_id,is_invite,message, created_at, deleted_at FROM notifications WHERE user_id = $1 AND concern_id = $2  AND type = $3 AND deleted_at IS NULL`
 
-		stm, err := db.Prepare(query)
+		stm, err := db.PrepareContext(ctx, query)
 
 		if err != nil {
 			return err
@@ -103,7 +124,7 @@ func (n *Notification) Get(db *sql.DB, id ...uuid.UUID) error {
 
 		defer stm.Close()
 
-		err = stm.QueryRow(n.UserID, n.ConcernID, n.Type).Scan(&n.ID, &n.GroupId, &n.MemberId, &n.Is_invite, &n.Type, &n.Message, &n.CreatedAt, &n.DeletedAt)
+		err = stm.QueryRowContext(ctx, n.UserID, n.ConcernID, n.Type).Scan(&n.ID, &n.GroupId, &n.MemberId, &n.Is_invite, &n.Type, &n.Message, &n.CreatedAt, &n.DeletedAt)
 		if err != nil {
 			return err
 		}
@@ -113,10 +134,10 @@ func (n *Notification) Get(db *sql.DB, id ...uuid.UUID) error {
 }
 
 // Delete a notification
-func (n *Notification) Delete(db *sql.DB) error {
+func (n *Notification) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE notifications SET deleted_at = $1 WHERE id = $2`
 
-	_, err := db.Exec(query, time.Now(), n.ID)
+	_, err := db.ExecContext(ctx, query, time.Now(), n.ID)
 	if err != nil {
 		return err
 	}
@@ -124,17 +145,18 @@ func (n *Notification) Delete(db *sql.DB) error {
 }
 
 // GetByUser Get all notifications for a user
-func (n *Notifications) GetByUser(db *sql.DB, userID uuid.UUID) error {
-	query := `SELECT id, user_id, group_id, member_id,is_invite,type, message, created_at, deleted_at FROM notifications WHERE concern_id = $1 AND deleted_at IS NULL`
+func (n *Notifications) GetByUser(ctx context.Context, db *sql.DB, userID uuid.UUID) error {
+	query := `SELECT id, user_id, group_id, member_id,is_invite,type, message, created_at, deleted_at FROM notifications WHERE concern_id = ? AND deleted_at IS NULL AND ` + authorNotHidden("notifications.user_id")
 
-	stm, err := db.Prepare(query)
+	stm, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 
 	defer stm.Close()
 
-	rows, err := stm.Query(userID)
+	// Notifications from users the user blocked or muted are left out
+	rows, err := stm.QueryContext(ctx, userID, userID, userID, userID)
 	if err != nil {
 		return err
 	}
@@ -153,10 +175,10 @@ func (n *Notifications) GetByUser(db *sql.DB, userID uuid.UUID) error {
 }
 
 // ClearByUser deletes all notifications for a user
-func (n *Notifications) ClearByUser(db *sql.DB, userID uuid.UUID) error {
+func (n *Notifications) ClearByUser(ctx context.Context, db *sql.DB, userID uuid.UUID) error {
 	query := `UPDATE notifications SET deleted_at = $1 WHERE user_id = $2`
 
-	_, err := db.Exec(query, time.Now(), userID)
+	_, err := db.ExecContext(ctx, query, time.Now(), userID)
 	if err != nil {
 		return err
 	}
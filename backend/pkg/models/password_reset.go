@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordResetTTL is how long a password reset token stays valid.
+const PasswordResetTTL = time.Hour
+
+// PasswordReset is a request to reset the password of a user. Only a hash of its
+// token is stored, so the tokens cannot be read back from the database.
+type PasswordReset struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+// Create records a password reset for p.UserID and returns its token, the only
+// copy of which is to be sent to the user. Tokens issued before for the user
+// stop working.
+func (p *PasswordReset) Create(ctx context.Context, db *sql.DB) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	p.ID = uuid.New()
+	p.CreatedAt = time.Now()
+	p.ExpiresAt = p.CreatedAt.Add(PasswordResetTTL)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to begin the transaction. %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE password_resets SET used_at=$1 WHERE user_id=$2 AND used_at IS NULL`, p.CreatedAt, p.UserID); err != nil {
+		return "", fmt.Errorf("unable to execute the query. %v", err)
+	}
+	query := `INSERT INTO password_resets (id, user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, query, p.ID, p.UserID, hashToken(token), p.ExpiresAt, p.CreatedAt); err != nil {
+		return "", fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("unable to commit the transaction. %v", err)
+	}
+	return token, nil
+}
+
+// ResetPassword sets the password hash of the user a reset token was issued to,
+// and uses the token up. It returns ErrInvalidToken for unknown, used and
+// expired tokens.
+func ResetPassword(ctx context.Context, db *sql.DB, token, passwordHash string) (uuid.UUID, error) {
+	var p PasswordReset
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("unable to begin the transaction. %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, user_id, expires_at, used_at, created_at FROM password_resets WHERE token_hash=$1`
+	err = tx.QueryRowContext(ctx, query, hashToken(token)).Scan(&p.ID, &p.UserID, &p.ExpiresAt, &p.UsedAt, &p.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	now := time.Now()
+	if p.UsedAt.Valid || !now.Before(p.ExpiresAt) {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	// Guard against the token being used concurrently
+	result, err := tx.ExecContext(ctx, `UPDATE password_resets SET used_at=$1 WHERE id=$2 AND used_at IS NULL`, now, p.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 1 {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	result, err = tx.ExecContext(ctx, `UPDATE users SET password=$1, updated_at=$2 WHERE id=$3 AND deleted_at IS NULL`, passwordHash, now, p.UserID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 1 {
+		return uuid.Nil, ErrInvalidToken
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("unable to commit the transaction. %v", err)
+	}
+	return p.UserID, nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -78,7 +79,7 @@ func PostPrivacyFromString(s string) (PostPrivacy, error) {
 }
 
 // Create inserts a new post into the database
-func (Posts *Post) Create(db *sql.DB) error {
+func (Posts *Post) Create(ctx context.Context, db *sql.DB) error {
 	if !Posts.IsValid() {
 		return errors.New("something wrong with the comment")
 	}
@@ -87,14 +88,13 @@ func (Posts *Post) Create(db *sql.DB) error {
 	Posts.UpdatedAt = time.Now()
 	query := `INSERT INTO posts (id, user_id, group_id, title, content, image_url, privacy, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
-		Posts.ID,
+	_, err = stmt.ExecContext(ctx, Posts.ID,
 		Posts.UserID.String(),
 		Posts.GroupID.String(),
 		html.EscapeString(Posts.Title),
@@ -112,7 +112,7 @@ func (Posts *Post) Create(db *sql.DB) error {
 		return nil
 	}
 
-	return Posts.saveFolowersSelection(db)
+	return Posts.saveFolowersSelection(ctx, db)
 }
 
 func (Posts *Post) IsValid() bool {
@@ -127,12 +127,12 @@ func (Posts *Post) IsValid() bool {
 }
 
 // Get retrieves a post from the database
-func (Posts *Post) Get(db *sql.DB, id uuid.UUID) error {
+func (Posts *Post) Get(ctx context.Context, db *sql.DB, id uuid.UUID) error {
 	// Mux.RLock()
 	// defer Mux.RUnlock()
 	query := `SELECT id, user_id, title, content, image_url, privacy, created_at, updated_at, deleted_at, group_id  FROM posts WHERE id = $1 AND deleted_at IS NULL`
 
-	err := db.QueryRow(query, id).Scan(
+	err := db.QueryRowContext(ctx, query, id).Scan(
 		&Posts.ID,
 		&Posts.UserID,
 		&Posts.Title,
@@ -144,7 +144,6 @@ func (Posts *Post) Get(db *sql.DB, id uuid.UUID) error {
 		&Posts.DeletedAt,
 		&Posts.GroupID,
 	)
-	fmt.Println(Posts.Content, Posts.GroupID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("no post found with id %v", id)
@@ -156,19 +155,18 @@ func (Posts *Post) Get(db *sql.DB, id uuid.UUID) error {
 }
 
 // Update modifies a post in the database
-func (Posts *Post) Update(db *sql.DB) error {
+func (Posts *Post) Update(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
 	query := `UPDATE posts SET title = $1, content = $2, image_url = $3, privacy = $4, updated_at = $5 WHERE id = $6`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
-		html.EscapeString(Posts.Title),
+	_, err = stmt.ExecContext(ctx, html.EscapeString(Posts.Title),
 		html.EscapeString(Posts.Content),
 		html.EscapeString(Posts.ImageURL),
 		Posts.Privacy,
@@ -183,18 +181,34 @@ func (Posts *Post) Update(db *sql.DB) error {
 	return nil
 }
 
+// Restore brings back a deleted post. It returns sql.ErrNoRows if no post with
+// this ID is deleted.
+func (Posts *Post) Restore(ctx context.Context, db *sql.DB) error {
+	result, err := db.ExecContext(ctx, `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`, Posts.ID)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // Delete removes a post from the database
-func (Posts *Post) Delete(db *sql.DB) error {
+func (Posts *Post) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE posts SET deleted_at = $1 WHERE id = $2`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
-		time.Now(),
+	_, err = stmt.ExecContext(ctx, time.Now(),
 		Posts.ID,
 	)
 
@@ -206,16 +220,16 @@ func (Posts *Post) Delete(db *sql.DB) error {
 }
 
 // GetUserPosts retrieves all the posts from a user
-func (Posts *Posts) GetUserPosts(db *sql.DB, userID uuid.UUID) error {
+func (Posts *Posts) GetUserPosts(ctx context.Context, db *sql.DB, userID uuid.UUID) error {
 	query := `SELECT id, user_id, title, content, image_url, privacy, created_at, updated_at, deleted_at FROM posts WHERE user_id = $1 AND deleted_at IS NULL`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(userID)
+	rows, err := stmt.QueryContext(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -244,16 +258,16 @@ func (Posts *Posts) GetUserPosts(db *sql.DB, userID uuid.UUID) error {
 }
 
 // GetAll retrieves all the posts from the database
-func (Posts *Posts) GetAll(db *sql.DB) error {
+func (Posts *Posts) GetAll(ctx context.Context, db *sql.DB) error {
 	query := `SELECT id, user_id, title, content, image_url, privacy, created_at, updated_at, deleted_at FROM posts WHERE deleted_at IS NULL`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query()
+	rows, err := stmt.QueryContext(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -280,17 +294,17 @@ func (Posts *Posts) GetAll(db *sql.DB) error {
 	return nil
 }
 
-func (Posts *Post) saveFolowersSelection(db *sql.DB) error {
+func (Posts *Post) saveFolowersSelection(ctx context.Context, db *sql.DB) error {
 	query := `INSERT INTO selected_users (id, post_id, user_id) VALUES (? ,?, ?)`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
 	for _, userID := range Posts.SelectedFollowers {
-		_, err = stmt.Exec(uuid.New(), Posts.ID, userID)
+		_, err = stmt.ExecContext(ctx, uuid.New(), Posts.ID, userID)
 		if err != nil {
 			return fmt.Errorf("unable to execute the query. %v", err)
 		}
@@ -298,28 +312,29 @@ func (Posts *Post) saveFolowersSelection(db *sql.DB) error {
 	return nil
 }
 
-func (Posts *Posts) GetAvailablePostForUser(db *sql.DB, userID uuid.UUID) error {
+func (Posts *Posts) GetAvailablePostForUser(ctx context.Context, db *sql.DB, userID uuid.UUID) error {
 	query := `SELECT * FROM posts WHERE 
     (privacy = 'public' OR 
     (privacy = 'private' AND deleted_at IS NULL AND EXISTS (SELECT 1 FROM followers f WHERE posts.user_id = f.followee_id AND f.follower_id = ? AND f.status = 'accepted')) OR 
     (privacy = 'almost private' AND deleted_at IS NULL AND EXISTS (SELECT 1 FROM selected_users us WHERE posts.id = us.post_id AND us.user_id = ?)) OR 
     user_id = ?) AND 
-    deleted_at IS NULL AND (group_id  IS NULL OR group_id = "00000000-0000-0000-0000-000000000000") AND privacy != 'group' 
+    deleted_at IS NULL AND (group_id  IS NULL OR group_id = "00000000-0000-0000-0000-000000000000") AND privacy != 'group' AND
+    ` + authorNotHidden("posts.user_id") + `
     ORDER BY created_at DESC`
-	if err := Posts.getPostsFromQuery(db, query, userID, userID, userID); err != nil {
+	if err := Posts.getPostsFromQuery(ctx, db, query, userID, userID, userID, userID, userID, userID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (Posts *Posts) getPostsFromQuery(db *sql.DB, query string, args ...interface{}) error {
-	stmt, err := db.Prepare(query)
+func (Posts *Posts) getPostsFromQuery(ctx context.Context, db *sql.DB, query string, args ...interface{}) error {
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -346,20 +361,38 @@ func (Posts *Posts) getPostsFromQuery(db *sql.DB, query string, args ...interfac
 	}
 	return nil
 }
-func (Posts *Posts) ExploitForRendering(db *sql.DB) []map[string]interface{} {
+func (Posts *Posts) ExploitForRendering(ctx context.Context, db *sql.DB) []map[string]interface{} {
 	valueToReturn := []map[string]interface{}{}
 	for _, v := range *Posts {
 		user := User{}
-		user.Get(db, v.UserID)
-		valueToReturn = append(valueToReturn, v.ExploitForRendering(db))
+		user.Get(ctx, db, v.UserID)
+		valueToReturn = append(valueToReturn, v.ExploitForRendering(ctx, db))
 	}
 	return valueToReturn
 }
-func (Posts *Post) ExploitForRendering(db *sql.DB) map[string]interface{} {
-	user := User{}
-	user.Get(db, Posts.UserID)
+
+// ExploitForRenderingSeenBy renders the posts like ExploitForRendering, without
+// the comments viewerID should not see.
+func (Posts *Posts) ExploitForRenderingSeenBy(ctx context.Context, db *sql.DB, viewerID uuid.UUID) []map[string]interface{} {
+	valueToReturn := []map[string]interface{}{}
+	for _, v := range *Posts {
+		postComments := Comments{}
+		postComments.GetCommentsSeenBy(ctx, db, v.ID, viewerID)
+		valueToReturn = append(valueToReturn, v.render(ctx, db, postComments))
+	}
+	return valueToReturn
+}
+
+func (Posts *Post) ExploitForRendering(ctx context.Context, db *sql.DB) map[string]interface{} {
 	postComments := Comments{}
-	postComments.GetCommentsForPost(db, Posts.ID)
+	postComments.GetCommentsForPost(ctx, db, Posts.ID)
+	return Posts.render(ctx, db, postComments)
+}
+
+// render prepares the post and the given comments on it for the frontend.
+func (Posts *Post) render(ctx context.Context, db *sql.DB, postComments Comments) map[string]interface{} {
+	user := User{}
+	user.Get(ctx, db, Posts.UserID)
 
 	return map[string]interface{}{
 		"group_id":           Posts.GroupID,
@@ -369,21 +402,21 @@ func (Posts *Post) ExploitForRendering(db *sql.DB) map[string]interface{} {
 		"content":            Posts.Content,
 		"userAvatarImageUrl": user.AvatarImage,
 		"createdAt":          timeAgo(Posts.CreatedAt),
-		"comments":           postComments.PrepareCommentsForRendering(db, string(Posts.Privacy), Posts.GroupID),
+		"comments":           postComments.PrepareCommentsForRendering(ctx, db, string(Posts.Privacy), Posts.GroupID),
 		"userOwnerNickname":  user.Nickname,
 	}
 }
 
-func (Posts *Posts) GetPostByGroupId(db *sql.DB, groupID string) error {
+func (Posts *Posts) GetPostByGroupId(ctx context.Context, db *sql.DB, groupID string) error {
 	query := `SELECT * FROM posts WHERE group_id = $1 AND deleted_at IS NULL ORDER BY created_at DESC`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(groupID)
+	rows, err := stmt.QueryContext(ctx, groupID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -404,7 +437,7 @@ func (Posts *Posts) GetPostByGroupId(db *sql.DB, groupID string) error {
 			&post.DeletedAt,
 		)
 		if err != nil {
-			fmt.Errorf("unable to scan the row. %v", err)
+			return fmt.Errorf("unable to scan the row. %v", err)
 		}
 		*Posts = append(*Posts, post)
 	}
@@ -431,11 +464,11 @@ func timeAgo(t time.Time) string {
 		return fmt.Sprintf("%d seconds ago", seconds)
 	}
 }
-func CountPostsByUser(db *sql.DB, userID uuid.UUID) (int, error) {
+func CountPostsByUser(ctx context.Context, db *sql.DB, userID uuid.UUID) (int, error) {
 	query := `SELECT COUNT(*) FROM posts WHERE user_id = $1 AND deleted_at IS NULL`
 
 	var count int
-	err := db.QueryRow(query, userID).Scan(&count)
+	err := db.QueryRowContext(ctx, query, userID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("unable to execute the query. %v", err)
 	}
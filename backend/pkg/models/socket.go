@@ -1,6 +1,12 @@
 package models
 
-var Data = make(chan map[string]interface{})
+// DataBuffer is how many broadcasts may queue up while the WebSocket broadcaster is busy.
+const DataBuffer = 256
+
+// Data carries notifications and messages to the WebSocket broadcaster. Each
+// value holds the "key" and "data" sent, and "to", the []uuid.UUID of the users
+// whose sockets receive it. Its length is the broadcast backlog exported at /metrics.
+var Data = make(chan map[string]interface{}, DataBuffer)
 
 // var Mux = sync.RWMutex{}
 
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SiteStats are the figures of the whole site shown to admins.
+type SiteStats struct {
+	Users struct {
+		Total     int              `json:"total"`
+		Verified  int              `json:"verified"`
+		Suspended int              `json:"suspended"`
+		NewLast7d int              `json:"newLast7Days"`
+		ByRole    map[SiteRole]int `json:"byRole"`
+	} `json:"users"`
+	Posts           ContentStats `json:"posts"`
+	Comments        ContentStats `json:"comments"`
+	Groups          ContentStats `json:"groups"`
+	PrivateMessages int          `json:"privateMessages"`
+	GroupMessages   int          `json:"groupMessages"`
+	Events          int          `json:"events"`
+}
+
+// ContentStats count soft-deletable content.
+type ContentStats struct {
+	Active  int `json:"active"`
+	Deleted int `json:"deleted"`
+}
+
+// GetSiteStats counts the users and content of the site.
+func GetSiteStats(ctx context.Context, db *sql.DB) (SiteStats, error) {
+	var stats SiteStats
+	stats.Users.ByRole = map[SiteRole]int{}
+
+	query := `SELECT COUNT(*), COUNT(email_verified_at), COUNT(suspended_at), COALESCE(SUM(created_at >= $1), 0) FROM users WHERE deleted_at IS NULL`
+	err := db.QueryRowContext(ctx, query, time.Now().AddDate(0, 0, -7)).Scan(&stats.Users.Total, &stats.Users.Verified, &stats.Users.Suspended, &stats.Users.NewLast7d)
+	if err != nil {
+		return stats, fmt.Errorf("unable to execute the query. %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT role, COUNT(*) FROM users WHERE deleted_at IS NULL GROUP BY role`)
+	if err != nil {
+		return stats, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var role SiteRole
+		var count int
+		if err := rows.Scan(&role, &count); err != nil {
+			return stats, fmt.Errorf("unable to scan the row. %v", err)
+		}
+		stats.Users.ByRole[role] = count
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+
+	// Table names come from this list, never from the request
+	for table, content := range map[string]*ContentStats{"posts": &stats.Posts, "comments": &stats.Comments, "groups": &stats.Groups} {
+		query := `SELECT COUNT(*) - COUNT(deleted_at), COUNT(deleted_at) FROM ` + table
+		if err := db.QueryRowContext(ctx, query).Scan(&content.Active, &content.Deleted); err != nil {
+			return stats, fmt.Errorf("unable to execute the query. %v", err)
+		}
+	}
+	for table, count := range map[string]*int{"private_messages": &stats.PrivateMessages, "group_messages": &stats.GroupMessages, "events": &stats.Events} {
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE deleted_at IS NULL`).Scan(count); err != nil {
+			return stats, fmt.Errorf("unable to execute the query. %v", err)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// newToken returns a random URL-safe token of 256 bits.
+func newToken() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("unable to generate the token. %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// hashToken returns the hash a token is stored as, so that the database does not
+// hold anything that can be used as is. Tokens are random enough for a fast hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
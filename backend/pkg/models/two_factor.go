@@ -0,0 +1,283 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// RecoveryCodeCount is how many recovery codes a user gets when enabling
+	// two-factor authentication.
+	RecoveryCodeCount = 10
+
+	// LoginChallengeTTL is how long a user has to give their second factor after their password.
+	LoginChallengeTTL = 5 * time.Minute
+
+	// LoginChallengeAttempts is how many wrong codes a login challenge takes.
+	LoginChallengeAttempts = 5
+)
+
+// TwoFactor is the TOTP secret of a user. Two-factor authentication is only
+// enabled once the user confirmed it with a code from their app.
+type TwoFactor struct {
+	UserID      uuid.UUID
+	Secret      string
+	ConfirmedAt sql.NullTime
+	LastStep    int64 // Last time step a code was accepted for, so that codes work once
+	CreatedAt   time.Time
+}
+
+// Enabled tells whether two-factor authentication is confirmed and in use.
+func (t *TwoFactor) Enabled() bool {
+	return t.ConfirmedAt.Valid
+}
+
+// Get the two-factor settings of a user. It returns sql.ErrNoRows if they never set it up.
+func (t *TwoFactor) Get(ctx context.Context, db *sql.DB, userID uuid.UUID) error {
+	query := `SELECT user_id, secret, confirmed_at, last_step, created_at FROM two_factor WHERE user_id=$1`
+
+	err := db.QueryRowContext(ctx, query, userID).Scan(&t.UserID, &t.Secret, &t.ConfirmedAt, &t.LastStep, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return nil
+}
+
+// Create records a new secret for t.UserID, waiting for confirmation. It replaces
+// a secret that was not confirmed, but never an enabled one.
+func (t *TwoFactor) Create(ctx context.Context, db *sql.DB) error {
+	t.CreatedAt = time.Now()
+	t.ConfirmedAt = sql.NullTime{}
+	t.LastStep = 0
+	query := `INSERT INTO two_factor (user_id, secret, created_at) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE SET secret=excluded.secret, last_step=0, created_at=excluded.created_at WHERE confirmed_at IS NULL`
+
+	result, err := db.ExecContext(ctx, query, t.UserID, t.Secret, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return errors.New("two-factor authentication is already enabled")
+	}
+	return nil
+}
+
+// Confirm enables two-factor authentication, the user having given a code for
+// step, and returns a new set of recovery codes for them to keep.
+func (t *TwoFactor) Confirm(ctx context.Context, db *sql.DB, step int64) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin the transaction. %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `UPDATE two_factor SET confirmed_at=$1, last_step=$2 WHERE user_id=$3 AND confirmed_at IS NULL AND secret=$4`, now, step, t.UserID, t.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	codes, err := replaceRecoveryCodes(ctx, tx, t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit the transaction. %v", err)
+	}
+	t.ConfirmedAt = sql.NullTime{Time: now, Valid: true}
+	t.LastStep = step
+	return codes, nil
+}
+
+// UseStep records that a code was accepted for step. It returns ErrInvalidToken
+// if a code of that step or a later one was already accepted.
+func (t *TwoFactor) UseStep(ctx context.Context, db *sql.DB, step int64) error {
+	result, err := db.ExecContext(ctx, `UPDATE two_factor SET last_step=$1 WHERE user_id=$2 AND last_step < $1`, step, t.UserID)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidToken
+	}
+	t.LastStep = step
+	return nil
+}
+
+// Delete disables two-factor authentication for t.UserID, with their recovery codes.
+func (t *TwoFactor) Delete(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin the transaction. %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id=$1`, t.UserID); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM two_factor WHERE user_id=$1`, t.UserID); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit the transaction. %v", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user, used or not.
+func RegenerateRecoveryCodes(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin the transaction. %v", err)
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit the transaction. %v", err)
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes stores new recovery codes for a user in place of their
+// previous ones, and returns them. Only their hashes are stored.
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID uuid.UUID) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id=$1`, userID); err != nil {
+		return nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	codes := make([]string, RecoveryCodeCount)
+	now := time.Now()
+	for i := range codes {
+		// 10 base32 characters, 50 bits, split in two to be copied easily
+		random := make([]byte, 10)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("unable to generate the recovery codes. %v", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(random))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+
+		query := `INSERT INTO recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, $4)`
+		if _, err := tx.ExecContext(ctx, query, uuid.New(), userID, hashToken(normalizeRecoveryCode(codes[i])), now); err != nil {
+			return nil, fmt.Errorf("unable to execute the query. %v", err)
+		}
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode makes a recovery code as typed by the user comparable
+// to the one given, whatever the case and separators.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// UseRecoveryCode uses up a recovery code of a user. It returns ErrInvalidToken
+// if the user has no such unused code.
+func UseRecoveryCode(ctx context.Context, db *sql.DB, userID uuid.UUID, code string) error {
+	query := `UPDATE recovery_codes SET used_at=$1 WHERE user_id=$2 AND code_hash=$3 AND used_at IS NULL`
+
+	result, err := db.ExecContext(ctx, query, time.Now(), userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left.
+func CountRecoveryCodes(ctx context.Context, db *sql.DB, userID uuid.UUID) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM recovery_codes WHERE user_id=$1 AND used_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return count, nil
+}
+
+// LoginChallenge is a login halfway through: the user gave the right password
+// and has yet to give their second factor. It does not authenticate anything.
+type LoginChallenge struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// Create records a login challenge for c.UserID and returns its token, which
+// the client sends back with the second factor.
+func (c *LoginChallenge) Create(ctx context.Context, db *sql.DB) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	c.ID = uuid.New()
+	c.CreatedAt = time.Now()
+	c.ExpiresAt = c.CreatedAt.Add(LoginChallengeTTL)
+
+	// Expired challenges have no use, drop them along the way
+	if _, err := db.ExecContext(ctx, `DELETE FROM login_challenges WHERE user_id=$1`, c.UserID); err != nil {
+		return "", fmt.Errorf("unable to execute the query. %v", err)
+	}
+	query := `INSERT INTO login_challenges (id, user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := db.ExecContext(ctx, query, c.ID, c.UserID, hashToken(token), c.ExpiresAt, c.CreatedAt); err != nil {
+		return "", fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return token, nil
+}
+
+// Get the login challenge with the given token. It returns ErrInvalidToken for
+// unknown and expired challenges, and those out of attempts.
+func (c *LoginChallenge) Get(ctx context.Context, db *sql.DB, token string) error {
+	query := `SELECT id, user_id, attempts, expires_at, created_at FROM login_challenges WHERE token_hash=$1`
+
+	err := db.QueryRowContext(ctx, query, hashToken(token)).Scan(&c.ID, &c.UserID, &c.Attempts, &c.ExpiresAt, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if c.Attempts >= LoginChallengeAttempts || !time.Now().Before(c.ExpiresAt) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Fail counts a wrong second factor against the challenge.
+func (c *LoginChallenge) Fail(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `UPDATE login_challenges SET attempts=attempts+1 WHERE id=$1`, c.ID); err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	c.Attempts++
+	return nil
+}
+
+// Delete removes the challenge once the login completed. It returns
+// ErrInvalidToken if it was already removed, by a concurrent login.
+func (c *LoginChallenge) Delete(ctx context.Context, db *sql.DB) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM login_challenges WHERE id=$1`, c.ID)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -16,24 +17,51 @@ import (
 
 type Users []User
 
+// SiteRole is the role of a user across the whole site, unlike the role of a
+// member in a group. Each role can do what the ones before it can.
+type SiteRole string
+
+const (
+	SiteRoleUser      SiteRole = "user"
+	SiteRoleModerator SiteRole = "moderator"
+	SiteRoleAdmin     SiteRole = "admin"
+)
+
+// siteRoleRanks orders the site roles from the least to the most privileged.
+var siteRoleRanks = map[SiteRole]int{SiteRoleUser: 0, SiteRoleModerator: 1, SiteRoleAdmin: 2}
+
+// Valid tells whether r is a known role.
+func (r SiteRole) Valid() bool {
+	_, ok := siteRoleRanks[r]
+	return ok
+}
+
+// AtLeast tells whether r is role or a more privileged one.
+func (r SiteRole) AtLeast(role SiteRole) bool {
+	return r.Valid() && siteRoleRanks[r] >= siteRoleRanks[role]
+}
+
 type User struct {
 	ID    uuid.UUID `sql:"type:uuid;primary key" json:"id"`
 	Email string    `sql:"type:varchar(100);unique" json:"email"`
 	// Pseudo      string    `sql:"type:uuid;unique" json:"pseudo"`
-	Password    string       `sql:"type:varchar(100)" json:"password"`
-	FirstName   string       `sql:"type:varchar(100)" json:"firstName"`
-	LastName    string       `sql:"type:varchar(100)" json:"lastName"`
-	DateOfBirth time.Time    `json:"dateOfBirth"`
-	AvatarImage string       `sql:"type:varchar(255)" json:"avatarImage"`
-	Nickname    string       `sql:"type:varchar(100);unique" json:"nickname"`
-	AboutMe     string       `sql:"type:text" json:"aboutMe"`
-	IsPublic    bool         `json:"isPublic"`
-	CreatedAt   time.Time    `json:"createdAt"`
-	UpdatedAt   time.Time    `json:"updatedAt"`
-	DeletedAt   sql.NullTime `json:"deletedAt"`
+	Password      string       `sql:"type:varchar(100)" json:"password"`
+	FirstName     string       `sql:"type:varchar(100)" json:"firstName"`
+	LastName      string       `sql:"type:varchar(100)" json:"lastName"`
+	DateOfBirth   time.Time    `json:"dateOfBirth"`
+	AvatarImage   string       `sql:"type:varchar(255)" json:"avatarImage"`
+	Nickname      string       `sql:"type:varchar(100);unique" json:"nickname"`
+	AboutMe       string       `sql:"type:text" json:"aboutMe"`
+	IsPublic      bool         `json:"isPublic"`
+	EmailVerified bool         `json:"emailVerified"`
+	Role          SiteRole     `json:"role"`
+	SuspendedAt   sql.NullTime `json:"-"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	UpdatedAt     time.Time    `json:"updatedAt"`
+	DeletedAt     sql.NullTime `json:"deletedAt"`
 }
 
-func (u *User) Validate(db *sql.DB) error {
+func (u *User) Validate(ctx context.Context, db *sql.DB) error {
 	requiredFields := []string{"Email", "Password", "FirstName", "LastName", "DateOfBirth"}
 
 	v := reflect.ValueOf(u).Elem()
@@ -62,10 +90,10 @@ func (u *User) Validate(db *sql.DB) error {
 	} else {
 		query := `SELECT COUNT(*) FROM users WHERE nickname = $1`
 		var count int
-		row := db.QueryRow(query, u.Nickname)
+		row := db.QueryRowContext(ctx, query, u.Nickname)
 		err := row.Scan(&count)
 		if err != nil {
-			return fmt.Errorf("unable to query from database: ", err)
+			return fmt.Errorf("unable to query from database: %v", err)
 		}
 
 		if count > 0 {
@@ -77,7 +105,7 @@ func (u *User) Validate(db *sql.DB) error {
 }
 
 // Create a new user
-func (u *User) Create(db *sql.DB) error {
+func (u *User) Create(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
 	// Define the user default properties
@@ -90,15 +118,14 @@ func (u *User) Create(db *sql.DB) error {
 	// }
 	query := `INSERT INTO users (id, email, password, first_name, last_name, date_of_birth, avatar_image, nickname, about_me, is_public, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
-		u.ID.String(),
+	_, err = stmt.ExecContext(ctx, u.ID.String(),
 		html.EscapeString(u.Email),
 		// user.Pseudo,
 		u.Password,
@@ -120,15 +147,15 @@ func (u *User) Create(db *sql.DB) error {
 }
 
 // Get a user by its ID
-func (u *User) Get(db *sql.DB, identifier interface{}, password ...bool) error {
+func (u *User) Get(ctx context.Context, db *sql.DB, identifier interface{}, password ...bool) error {
 	if identifier == "" {
-		fmt.Println("identifier cannot be an empty string")
 		return errors.New("identifier cannot be an empty string")
 	}
 	// Mux.RLock()
 	// defer Mux.RUnlock()
-	query := `SELECT id, email, password, first_name, last_name, date_of_birth, avatar_image, nickname, about_me, is_public, created_at, updated_at FROM users WHERE id=$1 OR email=$1 OR nickname=$1`
-	stmt, err := db.Prepare(query)
+	query := `SELECT id, email, password, first_name, last_name, date_of_birth, avatar_image, nickname, about_me, is_public, email_verified_at IS NOT NULL, role, suspended_at, created_at, updated_at FROM users WHERE (id=$1 OR email=$1 OR nickname=$1)`
+	// Deleted users are only found by ID, as what others kept still points to them
+	stmt, err := db.PrepareContext(ctx, query+" AND deleted_at IS NULL")
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -139,7 +166,7 @@ func (u *User) Get(db *sql.DB, identifier interface{}, password ...bool) error {
 			return errors.New("identifier cannot be an empty string")
 		}
 
-		err := stmt.QueryRow(identifier).Scan(
+		err := stmt.QueryRowContext(ctx, identifier).Scan(
 			&u.ID,
 			&u.Email,
 			// &user.Pseudo,
@@ -151,11 +178,13 @@ func (u *User) Get(db *sql.DB, identifier interface{}, password ...bool) error {
 			&u.Nickname,
 			&u.AboutMe,
 			&u.IsPublic,
+			&u.EmailVerified,
+			&u.Role,
+			&u.SuspendedAt,
 			&u.CreatedAt,
 			&u.UpdatedAt,
 		)
 		if err != nil {
-			fmt.Println(err)
 			return fmt.Errorf("unable to execute the query. %v", err)
 		}
 		if (len(password) > 0 && password[0] == false) || len(password) == 0 {
@@ -163,11 +192,10 @@ func (u *User) Get(db *sql.DB, identifier interface{}, password ...bool) error {
 		}
 	case uuid.UUID:
 		if id == uuid.Nil {
-			fmt.Println("identifier cannot be nil UUID")
 			return errors.New("identifier cannot be nil UUID")
 		}
 
-		err := db.QueryRow(query, identifier).Scan(
+		err := db.QueryRowContext(ctx, query, identifier).Scan(
 			&u.ID,
 			&u.Email,
 			// &user.Pseudo,
@@ -179,6 +207,9 @@ func (u *User) Get(db *sql.DB, identifier interface{}, password ...bool) error {
 			&u.Nickname,
 			&u.AboutMe,
 			&u.IsPublic,
+			&u.EmailVerified,
+			&u.Role,
+			&u.SuspendedAt,
 			&u.CreatedAt,
 			&u.UpdatedAt,
 		)
@@ -189,31 +220,25 @@ func (u *User) Get(db *sql.DB, identifier interface{}, password ...bool) error {
 			u.Password = ""
 		}
 	default:
-		fmt.Println("identifier type not supported")
 		return errors.New("identifier type not supported")
 	}
-
-	fmt.Println("identifier ********************")
-	fmt.Println(identifier)
-	fmt.Println("USER ********************")
-	fmt.Println(u)
 	return nil
 }
 
 // Update a user
-func (u *User) Update(db *sql.DB) error {
+func (u *User) Update(ctx context.Context, db *sql.DB) error {
 	// Mux.Lock()
 	// defer Mux.Unlock()
-	query := `UPDATE users SET email=$1, password=$2, first_name=$3, last_name=$4, date_of_birth=$5, avatar_image=$6, nickname=$7, about_me=$8, is_public=$9, updated_at=$10 WHERE id=$11`
+	// Changing the email address takes verifying the new one
+	query := `UPDATE users SET email_verified_at=CASE WHEN email=$1 THEN email_verified_at END, email=$1, password=$2, first_name=$3, last_name=$4, date_of_birth=$5, avatar_image=$6, nickname=$7, about_me=$8, is_public=$9, updated_at=$10 WHERE id=$11`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(
-		html.EscapeString(u.Email),
+	_, err = stmt.ExecContext(ctx, html.EscapeString(u.Email),
 		html.EscapeString(u.Password),
 		html.EscapeString(u.FirstName),
 		html.EscapeString(u.LastName),
@@ -235,27 +260,69 @@ func (u *User) Update(db *sql.DB) error {
 }
 
 // Delete a user
-func (u *User) Delete(db *sql.DB) error {
+func (u *User) Delete(ctx context.Context, db *sql.DB) error {
 	query := `UPDATE users SET deleted_at=$1 WHERE id=$2`
 
-	stmt, err := db.Prepare(query)
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to prepare the query. %v", err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(time.Now(), u.ID)
+	_, err = stmt.ExecContext(ctx, time.Now(), u.ID)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
 	return nil
 }
 
+// MarkEmailVerified records that the user confirmed owning email. It fails
+// with sql.ErrNoRows if email is no longer the address of the user.
+func (u *User) MarkEmailVerified(ctx context.Context, db *sql.DB, email string) error {
+	query := `UPDATE users SET email_verified_at=COALESCE(email_verified_at, $1) WHERE id=$2 AND email=$3 AND deleted_at IS NULL`
+
+	result, err := db.ExecContext(ctx, query, time.Now(), u.ID, html.EscapeString(email))
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	u.EmailVerified = true
+	return nil
+}
+
+// IsEmailVerified tells whether the user with the given ID confirmed their email address.
+func IsEmailVerified(ctx context.Context, db *sql.DB, userID uuid.UUID) (bool, error) {
+	query := `SELECT email_verified_at IS NOT NULL FROM users WHERE id=$1 AND deleted_at IS NULL`
+
+	var verified bool
+	if err := db.QueryRowContext(ctx, query, userID).Scan(&verified); err != nil {
+		return false, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return verified, nil
+}
+
+// GetSiteRole returns the site role of the user with the given ID.
+func GetSiteRole(ctx context.Context, db *sql.DB, userID uuid.UUID) (SiteRole, error) {
+	query := `SELECT role FROM users WHERE id=$1 AND deleted_at IS NULL`
+
+	var role SiteRole
+	if err := db.QueryRowContext(ctx, query, userID).Scan(&role); err != nil {
+		return "", fmt.Errorf("unable to execute the query. %v", err)
+	}
+	return role, nil
+}
+
 // GetAll users
-func (users *Users) GetAll(db *sql.DB) error {
-	query := `SELECT id, email, password, first_name, last_name, date_of_birth, avatar_image, nickname, about_me, is_public, created_at, updated_at FROM users WHERE deleted_at IS NULL`
+func (users *Users) GetAll(ctx context.Context, db *sql.DB) error {
+	query := `SELECT id, email, password, first_name, last_name, date_of_birth, avatar_image, nickname, about_me, is_public, email_verified_at IS NOT NULL, role, suspended_at, created_at, updated_at FROM users WHERE deleted_at IS NULL`
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -275,6 +342,9 @@ func (users *Users) GetAll(db *sql.DB) error {
 			&user.Nickname,
 			&user.AboutMe,
 			&user.IsPublic,
+			&user.EmailVerified,
+			&user.Role,
+			&user.SuspendedAt,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -286,15 +356,15 @@ func (users *Users) GetAll(db *sql.DB) error {
 
 	return nil
 }
-func (users *Users) GetFlow(db *sql.DB, userid uuid.UUID) error {
+func (users *Users) GetFlow(ctx context.Context, db *sql.DB, userid uuid.UUID) error {
 	query := `
-	SELECT DISTINCT u.*
+	SELECT DISTINCT u.id, u.email, u.password, u.first_name, u.last_name, u.date_of_birth, u.avatar_image, u.nickname, u.about_me, u.is_public, u.email_verified_at IS NOT NULL, u.role, u.suspended_at, u.created_at, u.updated_at, u.deleted_at
 	FROM users u
 	JOIN followers f ON (u.id = f.follower_id OR u.id = f.followee_id)
 	WHERE f.status = 'accepted' -- Vous pouvez ajouter des conditions supplémentaires ici si nécessaire
 	AND (f.follower_id = $1 OR f.followee_id = $1);`
 
-	rows, err := db.Query(query, userid)
+	rows, err := db.QueryContext(ctx, query, userid)
 	if err != nil {
 		return fmt.Errorf("unable to execute the query. %v", err)
 	}
@@ -313,6 +383,9 @@ func (users *Users) GetFlow(db *sql.DB, userid uuid.UUID) error {
 			&user.Nickname,
 			&user.AboutMe,
 			&user.IsPublic,
+			&user.EmailVerified,
+			&user.Role,
+			&user.SuspendedAt,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
@@ -325,3 +398,152 @@ func (users *Users) GetFlow(db *sql.DB, userid uuid.UUID) error {
 
 	return nil
 }
+
+// Suspended tells whether the user was suspended by a moderator, which keeps
+// them from logging in.
+func (u *User) Suspended() bool {
+	return u.SuspendedAt.Valid
+}
+
+// SetRole gives the user the site role role.
+func (u *User) SetRole(ctx context.Context, db *sql.DB, role SiteRole) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	if err := u.execOnUser(ctx, db, `UPDATE users SET role=$1, updated_at=$2 WHERE id=$3 AND deleted_at IS NULL`, role, time.Now(), u.ID); err != nil {
+		return err
+	}
+	u.Role = role
+	return nil
+}
+
+// Suspend keeps the user from logging in until unsuspended, for the given reason.
+func (u *User) Suspend(ctx context.Context, db *sql.DB, reason string) error {
+	now := time.Now()
+	if err := u.execOnUser(ctx, db, `UPDATE users SET suspended_at=COALESCE(suspended_at, $1), suspension_reason=$2 WHERE id=$3 AND deleted_at IS NULL`, now, reason, u.ID); err != nil {
+		return err
+	}
+	if !u.SuspendedAt.Valid {
+		u.SuspendedAt = sql.NullTime{Time: now, Valid: true}
+	}
+	return nil
+}
+
+// Unsuspend lets a suspended user log in again.
+func (u *User) Unsuspend(ctx context.Context, db *sql.DB) error {
+	if err := u.execOnUser(ctx, db, `UPDATE users SET suspended_at=NULL, suspension_reason=NULL WHERE id=$1 AND deleted_at IS NULL`, u.ID); err != nil {
+		return err
+	}
+	u.SuspendedAt = sql.NullTime{}
+	return nil
+}
+
+// execOnUser runs a query updating the user, and returns sql.ErrNoRows if it
+// matched no row.
+func (u *User) execOnUser(ctx context.Context, db *sql.DB, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to execute the query. %v", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UserFilter selects the users listed by SearchUsers. Zero fields select everyone.
+type UserFilter struct {
+	ID        uuid.UUID // Only the user with this ID
+	Query     string    // Part of the email, nickname, first or last name
+	Role      SiteRole  // Only users with this role
+	Suspended *bool     // Only users suspended, or not
+	Limit     int
+	Offset    int
+}
+
+// UserSummary is a user as listed to moderators.
+type UserSummary struct {
+	User
+	SuspendedAt      *time.Time `json:"suspendedAt"`
+	SuspensionReason string     `json:"suspensionReason"`
+}
+
+// SearchUsers returns the users selected by filter, the most recent first, and
+// how many there are in all.
+func SearchUsers(ctx context.Context, db *sql.DB, filter UserFilter) ([]UserSummary, int, error) {
+	where := []string{"deleted_at IS NULL"}
+	args := []interface{}{}
+	if filter.ID != uuid.Nil {
+		args = append(args, filter.ID)
+		where = append(where, fmt.Sprintf("id=$%d", len(args)))
+	}
+	if filter.Query != "" {
+		// Fields are stored escaped; LIKE wildcards in the query are matched as is
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(html.EscapeString(filter.Query)) + "%"
+		args = append(args, pattern)
+		n := len(args)
+		where = append(where, fmt.Sprintf(`(email LIKE $%[1]d ESCAPE '\' OR nickname LIKE $%[1]d ESCAPE '\' OR first_name LIKE $%[1]d ESCAPE '\' OR last_name LIKE $%[1]d ESCAPE '\')`, n))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		where = append(where, fmt.Sprintf("role=$%d", len(args)))
+	}
+	if filter.Suspended != nil {
+		if *filter.Suspended {
+			where = append(where, "suspended_at IS NOT NULL")
+		} else {
+			where = append(where, "suspended_at IS NULL")
+		}
+	}
+	conditions := strings.Join(where, " AND ")
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE `+conditions, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("unable to execute the query. %v", err)
+	}
+
+	query := `SELECT id, email, first_name, last_name, date_of_birth, avatar_image, nickname, about_me, is_public, email_verified_at IS NOT NULL, role, suspended_at, COALESCE(suspension_reason, ''), created_at, updated_at FROM users WHERE ` +
+		conditions + fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	rows, err := db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer rows.Close()
+
+	users := []UserSummary{}
+	for rows.Next() {
+		var user UserSummary
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.FirstName,
+			&user.LastName,
+			&user.DateOfBirth,
+			&user.AvatarImage,
+			&user.Nickname,
+			&user.AboutMe,
+			&user.IsPublic,
+			&user.EmailVerified,
+			&user.Role,
+			&user.User.SuspendedAt,
+			&user.SuspensionReason,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to scan the row. %v", err)
+		}
+		if user.User.SuspendedAt.Valid {
+			user.SuspendedAt = &user.User.SuspendedAt.Time
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+	return users, total, nil
+}
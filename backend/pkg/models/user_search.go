@@ -0,0 +1,219 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// maxSearchTerms bounds the words of a directory search, to keep FTS5 queries small.
+const maxSearchTerms = 8
+
+// maxSearchTrigrams bounds the trigrams a directory search looks up to forgive typos.
+const maxSearchTrigrams = 24
+
+// Ranks of directory results, best first: how much of the profile it took to
+// match every word of the search.
+const (
+	rankNickname = iota // The nickname alone
+	rankNames           // The nickname and names
+	rankAboutMe         // The about-me as well
+	rankSimilar         // None, but the nickname and names share enough trigrams with the search
+)
+
+// ErrInvalidCursor is returned for a search cursor that was not made by SearchCursor.Encode.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// UserSearchResult is a user as listed in the directory.
+type UserSearchResult struct {
+	ID            uuid.UUID `json:"id"`
+	Nickname      string    `json:"nickname"`
+	FirstName     string    `json:"firstName"`
+	LastName      string    `json:"lastName"`
+	AvatarImage   string    `json:"avatarImage"`
+	IsPublic      bool      `json:"isPublic"`
+	MutualFollows int       `json:"mutualFollows"` // Users the searcher follows who follow them
+	rank          int
+}
+
+// SearchCursor is where a page of directory results ends: the position of its
+// last result in the ranking, which no other result shares.
+type SearchCursor struct {
+	MutualFollows int
+	Rank          int
+	ID            uuid.UUID
+}
+
+// Encode makes the cursor into an opaque string for clients to send back.
+func (c SearchCursor) Encode() string {
+	raw := strconv.Itoa(c.MutualFollows) + ":" + strconv.Itoa(c.Rank) + ":" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseSearchCursor reads a cursor made by Encode. It returns ErrInvalidCursor
+// if s is not one.
+func ParseSearchCursor(s string) (SearchCursor, error) {
+	var c SearchCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return c, ErrInvalidCursor
+	}
+	if c.MutualFollows, err = strconv.Atoi(parts[0]); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.Rank, err = strconv.Atoi(parts[1]); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.ID, err = uuid.Parse(parts[2]); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// searchWords splits a directory search into words. Anything but letters and
+// digits separates words, so no FTS5 syntax gets through.
+func searchWords(q string) []string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	return words
+}
+
+// searchTerms makes each word a term matched as a prefix by FTS5.
+func searchTerms(words []string) []string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"*`
+	}
+	return terms
+}
+
+// searchTrigrams returns the distinct trigrams of the words, in lower case,
+// up to maxSearchTrigrams. Words shorter than three letters have none.
+func searchTrigrams(words []string) []string {
+	seen := map[string]bool{}
+	trigrams := []string{}
+	for _, word := range words {
+		runes := []rune(strings.ToLower(word))
+		for i := 0; i+3 <= len(runes) && len(trigrams) < maxSearchTrigrams; i++ {
+			trigram := string(runes[i : i+3])
+			if !seen[trigram] {
+				seen[trigram] = true
+				trigrams = append(trigrams, trigram)
+			}
+		}
+	}
+	return trigrams
+}
+
+// SearchDirectory finds the users whose nickname, names or about-me start with
+// every word of q, for viewerID to browse. Users whose nickname and names share
+// at least half of the trigrams of q are found as well, so that typos are
+// forgiven. Users who follow many of the people the viewer follows come first,
+// then the best matches, see rankNickname. Private profiles the viewer does not
+// follow are only found by their nickname and listed without their names.
+// Blocked, suspended and deleted users are left out. It returns up to limit
+// users after the cursor, if any, and the cursor of the next page, or nil on
+// the last one.
+func SearchDirectory(ctx context.Context, db *sql.DB, viewerID uuid.UUID, q string, after *SearchCursor, limit int) ([]UserSearchResult, *SearchCursor, error) {
+	words := searchWords(q)
+	if len(words) == 0 {
+		return []UserSearchResult{}, nil, nil
+	}
+	match := strings.Join(searchTerms(words), " ")
+	args := []interface{}{match, "{nickname first_name last_name} : (" + match + ")", "nickname : (" + match + ")"}
+
+	// Each trigram found counts once per user, in any of the columns and in the nickname
+	similar := `SELECT NULL AS user_id, 0 AS shared, 0 AS nickname_shared WHERE 0`
+	trigrams := searchTrigrams(words)
+	threshold := (len(trigrams) + 1) / 2
+	if len(trigrams) > 0 {
+		lookups := make([]string, len(trigrams))
+		for i, trigram := range trigrams {
+			lookups[i] = `SELECT user_id, 0 AS nickname FROM users_trigram WHERE users_trigram MATCH ?
+			UNION ALL SELECT user_id, 1 FROM users_trigram WHERE users_trigram MATCH ?`
+			args = append(args, `"`+trigram+`"`, `nickname : "`+trigram+`"`)
+		}
+		similar = `SELECT user_id, SUM(1 - nickname) AS shared, SUM(nickname) AS nickname_shared FROM (` +
+			strings.Join(lookups, " UNION ALL ") + `) GROUP BY user_id HAVING shared >= ?`
+		args = append(args, threshold)
+	}
+
+	query := `WITH words AS (
+		SELECT user_id FROM users_fts WHERE users_fts MATCH ?
+	), names AS (
+		SELECT user_id FROM users_fts WHERE users_fts MATCH ?
+	), nicknames AS (
+		SELECT user_id FROM users_fts WHERE users_fts MATCH ?
+	), similar AS (` + similar + `
+	), matches AS (
+		SELECT user_id FROM words UNION SELECT user_id FROM similar
+	), candidates AS (
+		SELECT u.id, u.nickname, u.first_name, u.last_name, u.avatar_image, u.is_public,
+		CASE WHEN u.id IN (SELECT user_id FROM nicknames) THEN ? WHEN u.id IN (SELECT user_id FROM names) THEN ?
+			WHEN u.id IN (SELECT user_id FROM words) THEN ? ELSE ? END AS match_rank,
+		u.id IN (SELECT user_id FROM similar WHERE nickname_shared >= ?) AS similar_nickname,
+		EXISTS (SELECT 1 FROM followers f WHERE f.follower_id = ? AND f.followee_id = u.id AND f.status = 'accepted' AND f.deleted_at IS NULL) AS following,
+		(SELECT COUNT(DISTINCT a.followee_id) FROM followers a JOIN followers b ON b.follower_id = a.followee_id
+			WHERE a.follower_id = ? AND a.status = 'accepted' AND a.deleted_at IS NULL
+			AND b.followee_id = u.id AND b.status = 'accepted' AND b.deleted_at IS NULL) AS mutual
+		FROM matches m JOIN users u ON u.id = m.user_id
+		WHERE u.deleted_at IS NULL AND u.suspended_at IS NULL AND u.id != ?
+		AND NOT EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id = ? AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = ?))
+	)
+	SELECT id, nickname, first_name, last_name, avatar_image, is_public, following, mutual, match_rank FROM candidates
+	WHERE (is_public OR following OR match_rank = ? OR similar_nickname)`
+	args = append(args, rankNickname, rankNames, rankAboutMe, rankSimilar, threshold,
+		viewerID, viewerID, viewerID, viewerID, viewerID, rankNickname)
+	if after != nil {
+		query += ` AND (mutual < ? OR (mutual = ? AND (match_rank > ? OR (match_rank = ? AND id > ?))))`
+		args = append(args, after.MutualFollows, after.MutualFollows, after.Rank, after.Rank, after.ID)
+	}
+	// One more than asked tells whether there is a next page
+	query += ` ORDER BY mutual DESC, match_rank, id LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to execute the query. %v", err)
+	}
+	defer rows.Close()
+
+	results := []UserSearchResult{}
+	for rows.Next() {
+		var r UserSearchResult
+		var following bool
+		err := rows.Scan(&r.ID, &r.Nickname, &r.FirstName, &r.LastName, &r.AvatarImage, &r.IsPublic, &following, &r.MutualFollows, &r.rank)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to scan the row. %v", err)
+		}
+		if !r.IsPublic && !following {
+			r.FirstName, r.LastName = "", ""
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("unable to iterate over the rows. %v", err)
+	}
+
+	if len(results) <= limit {
+		return results, nil, nil
+	}
+	results = results[:limit]
+	last := results[limit-1]
+	return results, &SearchCursor{MutualFollows: last.MutualFollows, Rank: last.rank, ID: last.ID}, nil
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerificationTTL is how long an email verification link stays valid.
+const EmailVerificationTTL = 24 * time.Hour
+
+// ErrInvalidToken is returned for tokens that are malformed, forged or expired.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// EmailVerification is what an email verification token vouches for: that
+// whoever holds it received mail at Email, sent to the user UserID.
+type EmailVerification struct {
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+}
+
+// Token signs the verification with secret. The token is URL-safe and carries
+// the verification itself, so nothing needs to be stored to check it.
+func (v EmailVerification) Token(secret []byte) string {
+	payload := strings.Join([]string{v.UserID.String(), strconv.FormatInt(v.ExpiresAt.Unix(), 10), v.Email}, "|")
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + signEmailVerification(secret, encoded)
+}
+
+// ParseEmailVerification checks the signature of token and that it has not
+// expired at now, and returns the verification it carries.
+func ParseEmailVerification(secret []byte, token string, now time.Time) (EmailVerification, error) {
+	var v EmailVerification
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signEmailVerification(secret, encoded))) {
+		return v, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return v, ErrInvalidToken
+	}
+	fields := strings.SplitN(string(payload), "|", 3)
+	if len(fields) != 3 {
+		return v, ErrInvalidToken
+	}
+	if v.UserID, err = uuid.Parse(fields[0]); err != nil {
+		return v, ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return v, ErrInvalidToken
+	}
+	v.ExpiresAt, v.Email = time.Unix(expires, 0), fields[2]
+	if !now.Before(v.ExpiresAt) {
+		return v, ErrInvalidToken
+	}
+	return v, nil
+}
+
+// signEmailVerification returns the signature of an encoded verification. The
+// purpose is part of the signed data so the secret can be shared with other tokens.
+func signEmailVerification(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("email-verification:" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -58,10 +58,6 @@ export default defineNuxtConfig({
     ],
   },
 
-  image: {
-    domains: ['localhost:8081'],
-  },
-
   pinia: {
     storesDirs: ['~/stores/**', '#/stores/**', '@/stores/**'],
   },
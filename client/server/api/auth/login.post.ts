@@ -12,6 +12,7 @@ export default defineEventHandler(async (event) => {
     headers: {
       Accept: "application/json",
       "Content-Type": "application/json",
+      "X-Forwarded-For": forwardedFor(event),
     },
     body: {
       email: username,
@@ -24,7 +25,7 @@ export default defineEventHandler(async (event) => {
   if (response.status !== "200" || response.data === undefined) {
     return sendError(event, createError({
       statusCode: 400 as number,
-      statusMessage: response.message
+      statusMessage: response.error?.message ?? response.message
     }))
   }
 
@@ -23,6 +23,7 @@ export default defineEventHandler(async (event) => {
     headers: {
       Accept: "application/json",
       "Content-Type": "application/json",
+      "X-Forwarded-For": forwardedFor(event),
     },
     body: JSON.stringify(register),
   });
@@ -32,7 +33,7 @@ export default defineEventHandler(async (event) => {
   if (response.status !== "200") {
     return sendError(event, createError({
       statusCode: 400,
-      statusMessage: response.message
+      statusMessage: response.error?.message ?? response.message
     }))
   }
 
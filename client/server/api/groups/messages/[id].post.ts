@@ -10,6 +10,7 @@ export default defineEventHandler(async (event) => {
 
   const token = event.context.token;
   const groupId = getRouterParam(event, "id");
+  const body = await readBody(event);
 
   const response = await $fetch<ServerResponse<GroupMessage>>(`${process.env.BACKEND_URL}/group/messages/new?group_id=${groupId}`, {
     method: "POST",
@@ -18,6 +19,7 @@ export default defineEventHandler(async (event) => {
       "Content-Type": "application/json",
       Authorization: `Bearer ${token}`,
     },
+    body,
   });
 
   return response;
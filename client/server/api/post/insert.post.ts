@@ -11,23 +11,29 @@ export default defineEventHandler(async (event) => {
     let jsonBody = JSON.stringify(body);
     // console.log("jsonBody", jsonBody)
 
-    const response = await fetch(`${process.env.BACKEND_URL}` + '/post/insert', {
+    // Group posts go through the group route, which checks the membership
+    const path = body.group_id ? `/create-post-group?group_id=${body.group_id}` : '/post/insert';
+    const res = await fetch(`${process.env.BACKEND_URL}` + path, {
         method: 'POST',
         headers: {
             Authorization: `Bearer ${token}`,
         },
         body: jsonBody,
-    }).then(async (res) => await res.json()).catch((err) => {
+    }).catch((err) => {
         console.log(err);
+        return null;
+    });
+    if (!res) {
         return {
             status: 500,
             body: 'Internal server error',
         };
-    });
-    if (response.status !== 200) {
+    }
+    const response = await res.json();
+    if (!res.ok) {
         return {
-            status: response.status,
-            body: response.message,
+            status: res.status,
+            body: response.error?.message,
         };
     }
 
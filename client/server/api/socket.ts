@@ -1,9 +1,14 @@
 import type { Peer } from "crossws";
 import { getQuery } from "ufo";
-import { server_socket } from "~/stores/socketCon"
+import { WebSocketClient } from "~/server/utils/server_socket";
 
 const notifconns = new Map<string, { conn: Peer, onLine: boolean }>();
 const messageconns = new Map<string, { conn: Peer, otherId: string }>();
+// The backend socket of each connected user, opened with their session so that
+// the backend knows who sends, and shared by their peers.
+const backendconns = new Map<string, { socket: WebSocketClient, peers: Set<string> }>();
+// The user each peer was authenticated as when it opened.
+const peerUsers = new Map<string, string>();
 
 export const notifUser = (data: any) => {
   const user = notifconns.get(data.concernID)
@@ -11,7 +16,7 @@ export const notifUser = (data: any) => {
 
   if (user) {
     console.log(user.onLine, "notifUser");
-    
+
     if (user?.onLine) {
       if (data.type === 'new_message') {
         const otherId = messageconns.get(data.concernID)?.otherId
@@ -26,26 +31,62 @@ export const notifUser = (data: any) => {
   }
 }
 
-export const messageUser = (data: any) => {
-
-  const user = messageconns.get(data.ReceiverID)
+// messageUser forwards a message the backend sent to userId, its sender or receiver.
+export const messageUser = (userId: string, data: any) => {
+  const user = messageconns.get(userId)
   if (user) {
     user.conn.send({ type: 'new_message', data })
   }
-  const sender = messageconns.get(data.SenderID)
-  if (sender) {
-    sender.conn.send({ type: 'new_message', data })
+}
+
+// connectBackend opens the backend socket of a user, unless a peer of theirs already did.
+const connectBackend = (userId: string, token: string, peer: Peer) => {
+  let backend = backendconns.get(userId)
+  if (!backend) {
+    const url = process.env.BACKEND_URL?.split('http')[1]
+    const socket = new WebSocketClient(`ws${url}/socket?key=socket`, 5000, { Authorization: `Bearer ${token}` })
+    socket.onmessage("notification", notifUser)
+    socket.onmessage("private_message", (data) => messageUser(userId, data))
+    backend = { socket, peers: new Set() }
+    backendconns.set(userId, backend)
+  }
+  backend.peers.add(peer.id)
+}
+
+// disconnectBackend closes the backend socket of a user once none of their peers is left.
+const disconnectBackend = (userId: string, peer: Peer) => {
+  const backend = backendconns.get(userId)
+  if (!backend) {
+    return
+  }
+  backend.peers.delete(peer.id)
+  if (backend.peers.size === 0) {
+    backend.socket.close()
+    backendconns.delete(userId)
   }
 }
 
 export default defineWebSocketHandler({
   async open(peer: Peer) {
+    const config = useRuntimeConfig();
+    // @ts-ignore
+    const _cookie = decodeURIComponent((peer.headers.cookie ?? '').split(config.cookieName + '=').pop().split(';')[0]);
+    if (!_cookie) {
+      return;
+    }
+    const { user, token } = await getUserFromToken(_cookie);
+    if (!user || !token) {
+      return;
+    }
+    const userId = user.id;
+    peerUsers.set(peer.id, userId);
+    connectBackend(userId, token, peer);
+
     const query = get(peer)
     const channel = query.channel as string
-    const userId = query.userId as string;
     if (channel === "notif") {
       console.log(userId, "notif");
-      
+
       notifconns.set(userId, { conn: peer, onLine: true });
     }
     if (channel === "message") {
@@ -57,7 +98,10 @@ export default defineWebSocketHandler({
     const data = JSON.parse(message.toString())
     const query = get(peer)
     const channel = query.channel as string
-    const userId = query.userId as string;
+    const userId = peerUsers.get(peer.id);
+    if (!userId) {
+      return;
+    }
     // if (channel === "notif") {
     //   notifUser(data)
     // }
@@ -72,7 +116,7 @@ export default defineWebSocketHandler({
         })
         peer.send(JSON.stringify({ type: 'online', users }))
       } else if (data.type === 'private_message') {
-        server_socket!.send(data)
+        backendconns.get(userId)?.socket.send(data)
       }
     }
   },
@@ -81,7 +125,12 @@ export default defineWebSocketHandler({
     console.log(`[ws] close ${peer}`);
     const query = get(peer)
     const channel = query.channel as string
-    const userId = query.userId as string;
+    const userId = peerUsers.get(peer.id);
+    if (!userId) {
+      return;
+    }
+    peerUsers.delete(peer.id);
+    disconnectBackend(userId, peer);
     if (channel === "notif") {
       notifconns.set(userId, { conn: peer, onLine: false });
     } else if (channel === "message") {
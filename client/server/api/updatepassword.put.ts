@@ -1,5 +1,7 @@
 import { sendError } from 'h3'
 import { fetcher } from '../utils/fetcher'
+import { serialize, sign } from '../utils/cookie'
+import { sessionUpdater } from '../utils/sessionHandler'
 
 export default defineEventHandler(async (event) => {
     const body = await readBody(event)
@@ -12,10 +14,23 @@ export default defineEventHandler(async (event) => {
         }))
     } else {
         try {
-            body['email'] = event.context.user.email
             const result = await fetcher(`${process.env.BACKEND_URL}`+"/updatepassword", "PUT", JSON.stringify(body), token)
             
             const { password: _password, ...userWithoutPassword } = result.data;
+
+            // The backend issues a new session ID when the password changes
+            if (result.session) {
+                const config = useRuntimeConfig()
+                const signedSession = sign(serialize({ session: result.session }), config.cookieSecret)
+                setCookie(event, config.cookieName, signedSession, {
+                    httpOnly: true,
+                    path: "/",
+                    sameSite: "strict",
+                    secure: false,
+                    expires: new Date(Date.now() + config.cookieExpires),
+                })
+                await sessionUpdater(result.session, result.data, event)
+            }
             const cleanInfos = {
                 message: result.message,
                 user: userWithoutPassword,
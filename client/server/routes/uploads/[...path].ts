@@ -0,0 +1,6 @@
+// Uploaded files are served by the backend, which is not reachable from the
+// browser, so they are fetched through here.
+export default defineEventHandler((event) => {
+  const path = getRouterParam(event, "path") ?? "";
+  return proxyRequest(event, `${process.env.BACKEND_URL}/uploads/${path}`);
+});
@@ -1,10 +0,0 @@
-import {server_socket} from "~/stores/socketCon"
-
-import { messageUser } from "../../api/socket";
-
-export default defineTask({
-    async run() {
-        server_socket?.onmessage("private_message", messageUser);
-        return { result: 'ok' }
-    }
-})
\ No newline at end of file
@@ -0,0 +1,10 @@
+import type { H3Event } from "h3";
+
+// forwardedFor returns the X-Forwarded-For to send the backend: the one the
+// request came with, if any, followed by the address it came from. The backend
+// only trusts the hops its trusted proxies added, from the right.
+export function forwardedFor(event: H3Event) {
+  const forwarded = getRequestHeader(event, "x-forwarded-for");
+  const ip = getRequestIP(event);
+  return [forwarded, ip].filter(Boolean).join(", ");
+}
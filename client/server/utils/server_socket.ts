@@ -3,16 +3,20 @@ export class WebSocketClient {
     private socket: WebSocket | null = null;
     private readonly url: string;
     private readonly reconnectInterval: number;
+    private readonly headers: { [key: string]: string };
+    private closed = false;
     private seters: { [key: string]: (data: any) => void } = {}
 
-    constructor(url: string, reconnectInterval = 5000) {
+    // headers are sent when connecting, e.g. the session of the user the socket acts for.
+    constructor(url: string, reconnectInterval = 5000, headers: { [key: string]: string } = {}) {
         this.url = url;
         this.reconnectInterval = reconnectInterval;
+        this.headers = headers;
         this.connect();
     }
 
     private connect() {
-        this.socket = new WebSocket(this.url);
+        this.socket = new WebSocket(this.url, { headers: this.headers });
 
         this.socket.onopen = (event: WebSocket.Event) => {
             console.log('WebSocket connection established');
@@ -24,6 +28,9 @@ export class WebSocketClient {
         };
 
         this.socket.onclose = (event: WebSocket.CloseEvent) => {
+            if (this.closed) {
+                return;
+            }
             console.log('WebSocket connection closed. Reconnecting...');
             setTimeout(() => this.connect(), this.reconnectInterval);
         };
@@ -53,6 +60,11 @@ export class WebSocketClient {
     public onmessage(type: string, seter: (data: any) => void) {
         this.seters[type] = seter
     }
+    // close closes the connection for good.
+    public close() {
+        this.closed = true;
+        this.socket?.close();
+    }
     public send(data: any) {
         // console.log(data);
         
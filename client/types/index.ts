@@ -13,11 +13,19 @@ export interface User {
   // pseudo: string;
 }
 
+export interface ServerError {
+  code: string;
+  message: string;
+  details?: unknown;
+  request_id: string;
+}
+
 export interface ServerResponse<T> {
   status: string;
   session?: string;
   message: string;
   data?: T;
+  error?: ServerError;
 }
 
 export interface Group {
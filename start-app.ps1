@@ -5,7 +5,7 @@
 # Function to run the backend in a new terminal window
 function Start-Backend {
     Write-Host "Starting Backend..." -ForegroundColor Cyan
-    Start-Process "powershell.exe" -ArgumentList "-NoExit", "-Command", "cd backend; go run ."
+    Start-Process "powershell.exe" -ArgumentList "-NoExit", "-Command", "cd backend; go run -tags sqlite_fts5 ."
 }
 
 # Function to run the frontend in a new terminal window
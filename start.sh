@@ -5,7 +5,7 @@
 # Function to run the backend in a new terminal window
 start_backend() {
     echo "Starting Backend..."
-    gnome-terminal -- bash -c "cd backend; go run .; exec bash"
+    gnome-terminal -- bash -c "cd backend; go run -tags sqlite_fts5 .; exec bash"
 }
 
 # Function to run the frontend in a new terminal window